@@ -0,0 +1,360 @@
+// Package client is a Go client for the device API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"homework/internal/model"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	watchTimeoutSeconds = 30
+	watchRetryInterval  = time.Second
+)
+
+type (
+	Device     = model.Device
+	State      = model.State
+	Change     = model.Change
+	ChangeType = model.ChangeType
+	Report     = model.Report
+	Issue      = model.Issue
+
+	Snapshot      = model.Snapshot
+	ChangeSet     = model.ChangeSet
+	Restore       = model.Restore
+	RestoreResult = model.RestoreResult
+)
+
+const (
+	StateOrdered        = model.StateOrdered
+	StateReceived       = model.StateReceived
+	StateProvisioned    = model.StateProvisioned
+	StateActive         = model.StateActive
+	StateMaintenance    = model.StateMaintenance
+	StateDecommissioned = model.StateDecommissioned
+
+	ChangeAdded    = model.ChangeAdded
+	ChangeModified = model.ChangeModified
+	ChangeDeleted  = model.ChangeDeleted
+)
+
+const resourceVersionHeader = "X-Resource-Version"
+
+// Filter narrows ListDevices and Watch results. Zero fields match any device.
+type Filter struct {
+	State  State
+	Model  string
+	Labels map[string]string
+	// Attributes maps dot-separated attribute paths to expected values, e.g. "panel.size": "55".
+	Attributes map[string]string
+}
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+}
+
+type Option func(*Client)
+
+// New creates a client of the device API at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: defaultTimeout},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithHTTPClient sends requests with a copy of hc, which later options don't change.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		copied := *hc
+		c.http = &copied
+	}
+}
+
+// WithTransport sets the round tripper used for requests, e.g. to add retries,
+// authentication or logging around http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.http.Transport = rt
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.http.Timeout = timeout
+	}
+}
+
+// WithToken authenticates requests with a bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// DefaultURL is the base URL of a server on the host and port of HTTP_HOST and HTTP_PORT,
+// localhost:8080 by default, as the server itself reads them.
+func DefaultURL() string {
+	host, port := os.Getenv("HTTP_HOST"), os.Getenv("HTTP_PORT")
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "8080"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func (c *Client) GetDevice(ctx context.Context, num string) (Device, error) {
+	var d Device
+	err := c.do(ctx, http.MethodGet, devicePath(num), nil, nil, &d)
+	return d, err
+}
+
+func (c *Client) CreateDevice(ctx context.Context, d Device) error {
+	return c.do(ctx, http.MethodPost, "/v1/devices", nil, d, nil)
+}
+
+func (c *Client) UpdateDevice(ctx context.Context, d Device) error {
+	return c.do(ctx, http.MethodPut, devicePath(d.SerialNum), nil, d, nil)
+}
+
+func (c *Client) DeleteDevice(ctx context.Context, num string) error {
+	return c.do(ctx, http.MethodDelete, devicePath(num), nil, nil, nil)
+}
+
+func (c *Client) TransitionDevice(ctx context.Context, num string, to State, reason string) error {
+	body := map[string]any{"state": to, "reason": reason}
+	return c.do(ctx, http.MethodPost, devicePath(num)+"/transition", nil, body, nil)
+}
+
+func (c *Client) ListDevices(ctx context.Context, f Filter) ([]Device, error) {
+	var devices []Device
+	err := c.do(ctx, http.MethodGet, "/v1/devices", query(f), nil, &devices)
+	return devices, err
+}
+
+// Report checks the registry for duplicate IPs, IPs outside subnets, malformed
+// serial numbers, silent devices and models missing from the catalog.
+func (c *Client) Report(ctx context.Context) (Report, error) {
+	var r Report
+	err := c.do(ctx, http.MethodGet, "/v1/report", nil, nil, &r)
+	return r, err
+}
+
+// Snapshot returns every device as of a single resource version.
+func (c *Client) Snapshot(ctx context.Context) (Snapshot, error) {
+	var s Snapshot
+	err := c.do(ctx, http.MethodGet, "/v1/admin/snapshot", nil, nil, &s)
+	return s, err
+}
+
+// ChangesSince returns the mutations after rv. It fails with ErrResourceVersionGone if the server
+// no longer retains them.
+func (c *Client) ChangesSince(ctx context.Context, rv uint64) (ChangeSet, error) {
+	var set ChangeSet
+	q := url.Values{"since": {strconv.FormatUint(rv, 10)}}
+	err := c.do(ctx, http.MethodGet, "/v1/admin/changes", q, nil, &set)
+	return set, err
+}
+
+// RestoreDevices writes devices as they are and deletes others, bypassing validation.
+func (c *Client) RestoreDevices(ctx context.Context, r Restore) (RestoreResult, error) {
+	var result RestoreResult
+	err := c.do(ctx, http.MethodPost, "/v1/admin/restore", nil, r, &result)
+	return result, err
+}
+
+// ResourceVersion returns the version of the latest registry mutation.
+func (c *Client) ResourceVersion(ctx context.Context) (uint64, error) {
+	resp, err := c.send(ctx, http.MethodHead, "/v1/devices", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return strconv.ParseUint(resp.Header.Get(resourceVersionHeader), 10, 64)
+}
+
+// Watch calls fn for every change after rv that matches f, until ctx is canceled
+// or fn returns an error. It resumes after the last change seen when the server
+// ends the stream, waiting a while first if the stream had no changes. If rv is older
+// than the retained history, it fails with ErrResourceVersionGone.
+func (c *Client) Watch(ctx context.Context, rv uint64, f Filter, fn func(Change) error) error {
+	for {
+		q := query(f)
+		q.Set("watch", "true")
+		q.Set("resourceVersion", strconv.FormatUint(rv, 10))
+		q.Set("timeoutSeconds", strconv.Itoa(watchTimeoutSeconds))
+
+		resp, err := c.stream(ctx, q)
+		if err != nil {
+			return err
+		}
+		next, err := watchStream(resp, rv, fn)
+		_ = resp.Body.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if next == rv {
+			// don't reconnect in a loop to a server ending streams right away
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(watchRetryInterval):
+			}
+		}
+		rv = next
+	}
+}
+
+// stream starts a watch request; it bypasses the client timeout, which would cut the stream.
+func (c *Client) stream(ctx context.Context, q url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/devices?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	c.authorize(req)
+
+	hc := *c.http
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// watchStream reads watch events until the stream ends and returns the last version seen.
+func watchStream(resp *http.Response, rv uint64, fn func(Change) error) (uint64, error) {
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Change
+			Code    int          `json:"code"`
+			Reason  model.Reason `json:"reason"`
+			Message string       `json:"message"`
+		}
+		err := dec.Decode(&event)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// the stream ends on server timeout, possibly in the middle of an event
+			return rv, nil
+		}
+		if err != nil {
+			return rv, err
+		}
+		if event.Type == "ERROR" {
+			apiErr := &APIError{StatusCode: event.Code, Message: event.Message, Reason: event.Reason}
+			apiErr.err = reasons[event.Reason]
+			return rv, apiErr
+		}
+
+		rv = event.ResourceVersion
+		if err := fn(event.Change); err != nil {
+			return rv, err
+		}
+	}
+}
+
+func query(f Filter) url.Values {
+	q := url.Values{}
+	if f.State != "" {
+		q.Set("state", string(f.State))
+	}
+	if f.Model != "" {
+		q.Set("model", f.Model)
+	}
+	for k, v := range f.Labels {
+		q.Add("label", k+"="+v)
+	}
+	for k, v := range f.Attributes {
+		q.Add("attr", k+"="+v)
+	}
+	return q
+}
+
+// do sends a request with in encoded as JSON and decodes the response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, in, out any) error {
+	resp, err := c.send(ctx, method, path, q, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send returns a successful response; the caller closes its body.
+func (c *Client) send(ctx context.Context, method, path string, q url.Values, in any) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	c.authorize(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+func devicePath(num string) string {
+	return "/v1/devices/" + url.PathEscape(num)
+}
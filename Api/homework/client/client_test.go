@@ -0,0 +1,209 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"homework/client"
+	"homework/internal/catalog"
+	"homework/internal/handler"
+	"homework/internal/router"
+	"homework/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T, options ...client.Option) (*client.Client, service.Service) {
+	cat := catalog.NewService()
+	svc := service.NewService(service.NewStorage(), service.WithAttributeValidator(cat))
+	cat.SetDevices(svc)
+	assert.Nil(t, cat.RegisterModel(catalog.Model{Name: "panel"}))
+	_, err := cat.RegisterSchema("panel", []byte(`{"type":"object","properties":{"size":{"type":"integer"}},"required":["size"]}`))
+	assert.Nil(t, err)
+
+	srv := httptest.NewServer(router.NewRouter(handler.NewHandler(svc)))
+	t.Cleanup(srv.Close)
+	return client.New(srv.URL, options...), svc
+}
+
+func TestDeviceLifecycle(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+
+	d := client.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1", Labels: map[string]string{"rack": "a"}}
+	assert.Nil(t, c.CreateDevice(ctx, d))
+
+	got, err := c.GetDevice(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, client.StateOrdered, got.State)
+	assert.Equal(t, "a", got.Labels["rack"])
+
+	d.IP = "2.2.2.2"
+	assert.Nil(t, c.UpdateDevice(ctx, d))
+	assert.Nil(t, c.TransitionDevice(ctx, "1", client.StateReceived, "arrived"))
+
+	got, err = c.GetDevice(ctx, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "2.2.2.2", got.IP)
+	assert.Equal(t, client.StateReceived, got.State)
+	assert.Equal(t, "arrived", got.LastTransition.Reason)
+
+	devices, err := c.ListDevices(ctx, client.Filter{State: client.StateReceived, Labels: map[string]string{"rack": "a"}})
+	assert.Nil(t, err)
+	assert.Len(t, devices, 1)
+
+	rv, err := c.ResourceVersion(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), rv)
+
+	assert.Nil(t, c.DeleteDevice(ctx, "1"))
+	_, err = c.GetDevice(ctx, "1")
+	assert.ErrorIs(t, err, client.ErrDeviceDoesNotExist)
+}
+
+func TestErrors(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+	assert.Nil(t, c.CreateDevice(ctx, client.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}))
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"exists", func() error {
+			return c.CreateDevice(ctx, client.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"})
+		}, client.ErrDeviceAlreadyExists},
+		{"not found", func() error { return c.DeleteDevice(ctx, "2") }, client.ErrDeviceDoesNotExist},
+		{"model", func() error {
+			return c.CreateDevice(ctx, client.Device{SerialNum: "2", IP: "1.1.1.1"})
+		}, client.ErrInvalidModel},
+		{"serial", func() error {
+			return c.CreateDevice(ctx, client.Device{Model: "switch", IP: "1.1.1.1"})
+		}, client.ErrInvalidSerialNumber},
+		{"ip", func() error {
+			return c.CreateDevice(ctx, client.Device{SerialNum: "2", Model: "switch", IP: "x"})
+		}, client.ErrInvalidIPAddress},
+		{"state", func() error { return c.TransitionDevice(ctx, "1", "lost", "") }, client.ErrInvalidState},
+		{"transition", func() error {
+			return c.TransitionDevice(ctx, "1", client.StateActive, "")
+		}, client.ErrIllegalTransition},
+		{"attributes", func() error {
+			return c.CreateDevice(ctx, client.Device{SerialNum: "2", Model: "panel", IP: "1.1.1.1"})
+		}, client.ErrInvalidAttributes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			assert.ErrorIs(t, err, tt.want)
+
+			var apiErr *client.APIError
+			assert.True(t, errors.As(err, &apiErr))
+		})
+	}
+
+	assert.Nil(t, c.TransitionDevice(ctx, "1", client.StateDecommissioned, "scrapped"))
+	err := c.UpdateDevice(ctx, client.Device{SerialNum: "1", Model: "switch", IP: "2.2.2.2"})
+	assert.ErrorIs(t, err, client.ErrDeviceDecommissioned)
+
+	err = c.CreateDevice(ctx, client.Device{SerialNum: "3", Model: "panel", IP: "1.1.1.1"})
+	var apiErr *client.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.NotEmpty(t, apiErr.Fields)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransport(t *testing.T) {
+	var calls atomic.Int32
+	c, _ := newClient(t, client.WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		r.Header.Set("Authorization", "Bearer token")
+		return http.DefaultTransport.RoundTrip(r)
+	})))
+
+	_, err := c.ListDevices(context.Background(), client.Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestToken(t *testing.T) {
+	var auth atomic.Value
+	hc := &http.Client{}
+	c, _ := newClient(t, client.WithHTTPClient(hc), client.WithTimeout(time.Second), client.WithToken("secret"),
+		client.WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			auth.Store(r.Header.Get("Authorization"))
+			return http.DefaultTransport.RoundTrip(r)
+		})))
+
+	_, err := c.ListDevices(context.Background(), client.Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer secret", auth.Load())
+	// the options changed a copy of the caller's client
+	assert.Zero(t, hc.Timeout)
+	assert.Nil(t, hc.Transport)
+}
+
+func TestWatch(t *testing.T) {
+	c, svc := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, svc.CreateDevice(client.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}))
+	rv := svc.ResourceVersion()
+
+	var got []client.Change
+	done := make(chan error)
+	go func() {
+		done <- c.Watch(ctx, rv, client.Filter{Model: "switch"}, func(ch client.Change) error {
+			got = append(got, ch)
+			if len(got) == 2 {
+				return errStop
+			}
+			return nil
+		})
+	}()
+
+	assert.Nil(t, svc.CreateDevice(client.Device{SerialNum: "2", Model: "router", IP: "1.1.1.1"}))
+	assert.Nil(t, svc.CreateDevice(client.Device{SerialNum: "3", Model: "switch", IP: "1.1.1.1"}))
+	assert.Nil(t, svc.DeleteDevice("1"))
+
+	assert.ErrorIs(t, <-done, errStop)
+	assert.Equal(t, client.ChangeAdded, got[0].Type)
+	assert.Equal(t, "3", got[0].Device.SerialNum)
+	assert.Equal(t, client.ChangeDeleted, got[1].Type)
+}
+
+func TestWatchStreamEnds(t *testing.T) {
+	var watches atomic.Int32
+	streaming := func(body string) *client.Client {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			watches.Add(1)
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+		return client.New(srv.URL)
+	}
+	ignore := func(client.Change) error { return nil }
+
+	// a truncated stream ends like a server timeout, and the watch resumes after a while
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err := streaming(`{"type":`).Watch(ctx, 0, client.Filter{}, ignore)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), watches.Load())
+
+	var syntaxErr *json.SyntaxError
+	err = streaming("not json\n").Watch(context.Background(), 0, client.Filter{}, ignore)
+	assert.ErrorAs(t, err, &syntaxErr)
+}
+
+var errStop = errors.New("stop")
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the device API, told apart by the reason of the response.
+var (
+	ErrDeviceAlreadyExists  = errors.New("device already exists")
+	ErrDeviceDoesNotExist   = errors.New("device doesn't exist")
+	ErrInvalidModel         = errors.New("invalid model")
+	ErrInvalidSerialNumber  = errors.New("invalid serial number")
+	ErrInvalidIPAddress     = errors.New("invalid IP address")
+	ErrInvalidState         = errors.New("invalid device state")
+	ErrIllegalTransition    = errors.New("illegal state transition")
+	ErrDeviceDecommissioned = errors.New("device is decommissioned")
+	ErrInvalidAttributes    = errors.New("invalid attributes")
+	ErrResourceVersionGone  = errors.New("resource version is too old")
+	ErrFrozen               = errors.New("changes are frozen")
+	ErrDeviceInUse          = errors.New("device has links or contains devices")
+)
+
+var reasons = map[model.Reason]error{
+	model.ReasonDeviceAlreadyExists:  ErrDeviceAlreadyExists,
+	model.ReasonDeviceDoesNotExist:   ErrDeviceDoesNotExist,
+	model.ReasonInvalidModel:         ErrInvalidModel,
+	model.ReasonInvalidSerialNumber:  ErrInvalidSerialNumber,
+	model.ReasonInvalidIPAddress:     ErrInvalidIPAddress,
+	model.ReasonInvalidState:         ErrInvalidState,
+	model.ReasonIllegalTransition:    ErrIllegalTransition,
+	model.ReasonDeviceDecommissioned: ErrDeviceDecommissioned,
+	model.ReasonInvalidAttributes:    ErrInvalidAttributes,
+	model.ReasonResourceVersionGone:  ErrResourceVersionGone,
+	model.ReasonFrozen:               ErrFrozen,
+	model.ReasonDeviceInUse:          ErrDeviceInUse,
+}
+
+// FieldError is a single attribute validation failure.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// APIError is a non-successful response of the device API.
+// It unwraps to the error matching its reason, if there is one.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Reason     model.Reason
+	// Fields lists attribute validation failures of ErrInvalidAttributes.
+	Fields []FieldError
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("device API: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+func decodeError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+
+	var body struct {
+		Message string       `json:"message"`
+		Reason  model.Reason `json:"reason"`
+		Errors  []FieldError `json:"errors"`
+	}
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	if json.Unmarshal(raw, &body) == nil && body.Message != "" {
+		apiErr.Message = body.Message
+		apiErr.Reason = body.Reason
+		apiErr.Fields = body.Errors
+	}
+	apiErr.err = reasons[apiErr.Reason]
+	return apiErr
+}
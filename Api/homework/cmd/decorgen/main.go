@@ -0,0 +1,626 @@
+// Decorgen generates logging, metrics, tracing and timeout decorators for an interface.
+// It is meant to run from go:generate, e.g.
+//
+//	//go:generate go run homework/cmd/decorgen -i homework/internal/service.Service -o service_gen.go
+//
+// The decorators call helpers of the package they are generated into, see internal/decorator,
+// and the tracing decorator calls package homework/internal/tracing. The methods don't take
+// a context, so a timed out call can't be cancelled: the timeout decorator only limits the
+// read-only methods listed with -r.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Decorators
+const (
+	Logging = "logging"
+	Metrics = "metrics"
+	Tracing = "tracing"
+	Timeout = "timeout"
+)
+
+var decorators = []string{Logging, Metrics, Tracing, Timeout}
+
+func main() {
+	if err := run(os.Args[1:], os.Getenv, locate); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "decorgen:", err)
+		os.Exit(1)
+	}
+}
+
+// locate returns the directory and name of the package with the import path.
+func locate(importPath string) (string, string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}\n{{.Name}}", importPath).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", "", fmt.Errorf("go list %s: %s", importPath, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", "", err
+	}
+	dir, name, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return dir, name, nil
+}
+
+func run(args []string, getenv func(string) string, locate func(string) (string, string, error)) error {
+	fs := flag.NewFlagSet("decorgen", flag.ContinueOnError)
+	iface := fs.String("i", "", "interface to decorate, as import/path.Name")
+	out := fs.String("o", "", "output file, stdout if empty")
+	pkg := fs.String("p", getenv("GOPACKAGE"), "output package, GOPACKAGE if empty")
+	only := fs.String("d", strings.Join(decorators, ","), "comma-separated decorators to generate")
+	reads := fs.String("r", "", "comma-separated read-only methods, the only ones the timeout decorator limits")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	importPath, name, ok := cutLast(*iface, ".")
+	if !ok || importPath == "" || name == "" {
+		return fmt.Errorf("-i: want import/path.Name, got %q", *iface)
+	}
+	if *pkg == "" {
+		return errors.New("-p: required outside of go generate")
+	}
+	selected, err := parseDecorators(*only)
+	if err != nil {
+		return err
+	}
+
+	dir, pkgName, err := locate(importPath)
+	if err != nil {
+		return err
+	}
+	data, err := load(dir, pkgName, importPath, name)
+	if err != nil {
+		return err
+	}
+	data.Package = *pkg
+	data.Decorators = selected
+	if err := markReads(data.Methods, *reads); err != nil {
+		return err
+	}
+	if selected[Timeout] && !slices.ContainsFunc(data.Methods, func(m Method) bool { return m.Read }) {
+		return errors.New("-r: the timeout decorator needs the read-only methods to limit")
+	}
+
+	src, err := generate(data)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+func parseDecorators(s string) (map[string]bool, error) {
+	selected := make(map[string]bool)
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		known := false
+		for _, k := range decorators {
+			known = known || d == k
+		}
+		if !known {
+			return nil, fmt.Errorf("-d: unknown decorator %q, want %s", d, strings.Join(decorators, ", "))
+		}
+		selected[d] = true
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("-d: no decorators")
+	}
+	return selected, nil
+}
+
+// markReads marks the methods named in the comma-separated list s as read-only.
+func markReads(methods []Method, s string) error {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		i := slices.IndexFunc(methods, func(m Method) bool { return m.Name == name })
+		if i < 0 {
+			return fmt.Errorf("-r: unknown method %q", name)
+		}
+		methods[i].Read = true
+	}
+	return nil
+}
+
+type Param struct {
+	Name     string
+	Type     string
+	Variadic bool
+}
+
+type Method struct {
+	Name    string
+	Params  []Param
+	Results []string
+	// Read is set for read-only methods, safe to abandon on timeout.
+	Read bool
+}
+
+// Args returns the arguments passing the parameters on.
+func (m Method) Args() string {
+	args := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		args[i] = p.Name
+		if p.Variadic {
+			args[i] += "..."
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// LogArgs returns the arguments worth logging, leaving out functions and channels.
+func (m Method) LogArgs() string {
+	var args []string
+	for _, p := range m.Params {
+		if !strings.HasPrefix(p.Type, "func") && !strings.Contains(p.Type, "chan") {
+			args = append(args, p.Name)
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// Signature returns the parameters and results of the method.
+func (m Method) Signature() string {
+	params := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		params[i] = p.Name + " " + p.Type
+	}
+	sig := "(" + strings.Join(params, ", ") + ")"
+	switch len(m.Results) {
+	case 0:
+	case 1:
+		sig += " " + m.Results[0]
+	default:
+		sig += " (" + strings.Join(m.Results, ", ") + ")"
+	}
+	return sig
+}
+
+// Vars returns the names of the result variables, e.g. "r0, r1".
+func (m Method) Vars() string {
+	vars := make([]string, len(m.Results))
+	for i := range m.Results {
+		vars[i] = "r" + strconv.Itoa(i)
+	}
+	return strings.Join(vars, ", ")
+}
+
+// Err returns the result variable holding the error, or "nil" if the method doesn't return one.
+func (m Method) Err() string {
+	if n := len(m.Results); n > 0 && m.Results[n-1] == "error" {
+		return "r" + strconv.Itoa(n-1)
+	}
+	return "nil"
+}
+
+type Data struct {
+	Package string
+	// Interface is the qualified interface type, e.g. service.Service.
+	Interface string
+	// Name is the interface name, e.g. Service, and Op prefixes method names in logs, metrics and spans.
+	Name       string
+	Op         string
+	Methods    []Method
+	Imports    []Import
+	Decorators map[string]bool
+}
+
+// Import is an import path with the name it's imported as, empty if the name is the last path element.
+type Import struct {
+	Name string
+	Path string
+}
+
+func newImport(name, p string) Import {
+	if name == path.Base(p) {
+		name = ""
+	}
+	return Import{Name: name, Path: p}
+}
+
+// load parses the package in dir and returns the methods of the named interface,
+// with their types qualified for use outside the package.
+func load(dir, pkgName, importPath, name string) (Data, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return Data{}, err
+	}
+	p, ok := pkgs[pkgName]
+	if !ok {
+		return Data{}, fmt.Errorf("package %s not found in %s", pkgName, dir)
+	}
+
+	files := make([]string, 0, len(p.Files))
+	for f := range p.Files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, fname := range files {
+		f := p.Files[fname]
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != name {
+					continue
+				}
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					return Data{}, fmt.Errorf("%s.%s is not an interface", importPath, name)
+				}
+				if ts.TypeParams != nil {
+					return Data{}, fmt.Errorf("%s.%s: generic interfaces are not supported", importPath, name)
+				}
+				return methods(fset, f, it, pkgName, importPath, name)
+			}
+		}
+	}
+	return Data{}, fmt.Errorf("interface %s not found in %s", name, importPath)
+}
+
+func methods(fset *token.FileSet, f *ast.File, it *ast.InterfaceType, pkgName, importPath, name string) (Data, error) {
+	imports := make(map[string]string)
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		alias := path.Base(p)
+		if imp.Name != nil {
+			alias = imp.Name.Name
+		}
+		imports[alias] = p
+	}
+
+	q := qualifier{fset: fset, pkg: pkgName, imports: imports, used: map[string]bool{pkgName: true}}
+	imports[pkgName] = importPath
+	data := Data{
+		Interface: pkgName + "." + name,
+		Name:      name,
+		Op:        strings.ToLower(name),
+	}
+	for _, field := range it.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return Data{}, fmt.Errorf("%s.%s: embedded interfaces are not supported", importPath, name)
+		}
+		m := Method{Name: field.Names[0].Name}
+		for _, param := range fieldList(ft.Params) {
+			typ := param.Type
+			variadic := false
+			if ell, ok := typ.(*ast.Ellipsis); ok {
+				typ, variadic = ell.Elt, true
+			}
+			t, err := q.format(typ)
+			if err != nil {
+				return Data{}, err
+			}
+			if variadic {
+				t = "..." + t
+			}
+			for range max(len(param.Names), 1) {
+				m.Params = append(m.Params, Param{Name: "a" + strconv.Itoa(len(m.Params)), Type: t, Variadic: variadic})
+			}
+		}
+		for _, result := range fieldList(ft.Results) {
+			t, err := q.format(result.Type)
+			if err != nil {
+				return Data{}, err
+			}
+			for range max(len(result.Names), 1) {
+				m.Results = append(m.Results, t)
+			}
+		}
+		data.Methods = append(data.Methods, m)
+	}
+
+	for name := range q.used {
+		data.Imports = append(data.Imports, newImport(name, imports[name]))
+	}
+	return data, nil
+}
+
+func fieldList(l *ast.FieldList) []*ast.Field {
+	if l == nil {
+		return nil
+	}
+	return l.List
+}
+
+// qualifier prints types of the interface package as seen from another package.
+// imports maps the names of the imported packages to their paths, used collects the names in use.
+type qualifier struct {
+	fset    *token.FileSet
+	pkg     string
+	imports map[string]string
+	used    map[string]bool
+}
+
+func (q qualifier) format(expr ast.Expr) (string, error) {
+	var err error
+	expr = q.qualify(expr, &err)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, q.fset, expr); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// qualify returns a copy of the type expr with the exported names of the package
+// prefixed by its name, and records the imports it uses.
+func (q qualifier) qualify(expr ast.Expr, err *error) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if unicode.IsUpper([]rune(e.Name)[0]) {
+			return &ast.SelectorExpr{X: ast.NewIdent(q.pkg), Sel: ast.NewIdent(e.Name)}
+		}
+		return e
+	case *ast.SelectorExpr:
+		x, _ := e.X.(*ast.Ident)
+		if x == nil || q.imports[x.Name] == "" {
+			*err = fmt.Errorf("unknown package in %s", q.pos(e))
+			return e
+		}
+		q.used[x.Name] = true
+		return e
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: q.qualify(e.X, err)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: e.Len, Elt: q.qualify(e.Elt, err)}
+	case *ast.MapType:
+		return &ast.MapType{Key: q.qualify(e.Key, err), Value: q.qualify(e.Value, err)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: e.Dir, Value: q.qualify(e.Value, err)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: q.qualify(e.Elt, err)}
+	case *ast.FuncType:
+		return &ast.FuncType{Params: q.qualifyFields(e.Params, err), Results: q.qualifyFields(e.Results, err)}
+	case *ast.InterfaceType:
+		if len(e.Methods.List) > 0 {
+			*err = fmt.Errorf("interface literals are not supported: %s", q.pos(e))
+		}
+		return e
+	case *ast.StructType:
+		if len(e.Fields.List) > 0 {
+			*err = fmt.Errorf("struct literals are not supported: %s", q.pos(e))
+		}
+		return e
+	default:
+		*err = fmt.Errorf("unsupported type at %s", q.pos(e))
+		return e
+	}
+}
+
+func (q qualifier) qualifyFields(l *ast.FieldList, err *error) *ast.FieldList {
+	if l == nil {
+		return nil
+	}
+	out := &ast.FieldList{}
+	for _, f := range l.List {
+		out.List = append(out.List, &ast.Field{Names: f.Names, Type: q.qualify(f.Type, err)})
+	}
+	return out
+}
+
+func (q qualifier) pos(n ast.Node) string {
+	return q.fset.Position(n.Pos()).String()
+}
+
+func generate(data Data) ([]byte, error) {
+	imports := map[Import]bool{{Path: "context"}: true}
+	for _, imp := range data.Imports {
+		imports[imp] = true
+	}
+	if data.Decorators[Logging] {
+		imports[Import{Path: "log/slog"}] = true
+	}
+	if data.Decorators[Logging] || data.Decorators[Metrics] || data.Decorators[Timeout] {
+		imports[Import{Path: "time"}] = true
+	}
+	if data.Decorators[Tracing] {
+		imports[Import{Path: "homework/internal/tracing"}] = true
+	}
+	data.Imports = data.Imports[:0]
+	for imp := range imports {
+		data.Imports = append(data.Imports, imp)
+	}
+	sort.Slice(data.Imports, func(i, j int) bool {
+		a, b := data.Imports[i], data.Imports[j]
+		return a.Path < b.Path || a.Path == b.Path && a.Name < b.Name
+	})
+
+	var buf bytes.Buffer
+	if err := generated.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+var generated = template.Must(template.New("").Parse(`// Code generated by decorgen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{if .Name}}{{.Name}} {{end}}"{{.Path}}"
+{{- end}}
+)
+{{$d := .}}
+{{- if .Decorators.logging}}
+// Logged{{.Name}} logs the calls of next with their arguments and duration,
+// at debug level or warn level if they fail.
+func Logged{{.Name}}(next {{.Interface}}, logger *slog.Logger) {{.Interface}} {
+	return &logged{{.Name}}{next: next, ctx: context.Background(), logger: logger}
+}
+
+type logged{{.Name}} struct {
+	next   {{.Interface}}
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+func (s *logged{{.Name}}) WithContext(ctx context.Context) {{.Interface}} {
+	return &logged{{.Name}}{next: bind(ctx, s.next), ctx: ctx, logger: s.logger}
+}
+{{range .Methods}}
+func (s *logged{{$d.Name}}) {{.Name}}{{.Signature}} {
+	start := time.Now()
+	{{if .Results}}{{.Vars}} := {{end}}s.next.{{.Name}}({{.Args}})
+	logCall(s.ctx, s.logger, "{{$d.Op}}.{{.Name}}", []any{ {{- .LogArgs -}} }, start, {{.Err}})
+	{{- if .Results}}
+	return {{.Vars}}
+	{{- end}}
+}
+{{end}}
+{{- end}}
+
+{{- if .Decorators.metrics}}
+// Metrics{{.Name}} observes the duration and outcome of the calls of next.
+func Metrics{{.Name}}(next {{.Interface}}, o Observer) {{.Interface}} {
+	return &metrics{{.Name}}{next: next, o: o}
+}
+
+type metrics{{.Name}} struct {
+	next {{.Interface}}
+	o    Observer
+}
+
+func (s *metrics{{.Name}}) WithContext(ctx context.Context) {{.Interface}} {
+	return &metrics{{.Name}}{next: bind(ctx, s.next), o: s.o}
+}
+{{range .Methods}}
+func (s *metrics{{$d.Name}}) {{.Name}}{{.Signature}} {
+	start := time.Now()
+	{{if .Results}}{{.Vars}} := {{end}}s.next.{{.Name}}({{.Args}})
+	s.o.ObserveCall("{{$d.Op}}.{{.Name}}", time.Since(start), {{.Err}})
+	{{- if .Results}}
+	return {{.Vars}}
+	{{- end}}
+}
+{{end}}
+{{- end}}
+
+{{- if .Decorators.tracing}}
+// Traced{{.Name}} starts a span per call of next. Bind it to a request context
+// to make its spans children of the request span.
+func Traced{{.Name}}(next {{.Interface}}) {{.Interface}} {
+	return &traced{{.Name}}{next: next, ctx: context.Background()}
+}
+
+type traced{{.Name}} struct {
+	next {{.Interface}}
+	ctx  context.Context
+}
+
+func (s *traced{{.Name}}) WithContext(ctx context.Context) {{.Interface}} {
+	return &traced{{.Name}}{next: s.next, ctx: ctx}
+}
+{{range .Methods}}
+func (s *traced{{$d.Name}}) {{.Name}}{{.Signature}} {
+	ctx, span := tracing.Start(s.ctx, "{{$d.Op}}.{{.Name}}")
+	defer span.End()
+	{{if .Results}}{{.Vars}} := {{end}}bind(ctx, s.next).{{.Name}}({{.Args}})
+	{{- if ne .Err "nil"}}
+	tracing.Record(span, {{.Err}})
+	{{- end}}
+	{{- if .Results}}
+	return {{.Vars}}
+	{{- end}}
+}
+{{end}}
+{{- end}}
+
+{{- if .Decorators.timeout}}
+// Timeout{{.Name}} fails the read-only calls of next once timeout passes, or once
+// the bound context is done. Timed out calls keep running in the background, so
+// writes, which would still be applied, and calls not returning an error aren't limited.
+func Timeout{{.Name}}(next {{.Interface}}, timeout time.Duration) {{.Interface}} {
+	return &timeout{{.Name}}{next: next, ctx: context.Background(), timeout: timeout}
+}
+
+type timeout{{.Name}} struct {
+	next    {{.Interface}}
+	ctx     context.Context
+	timeout time.Duration
+}
+
+func (s *timeout{{.Name}}) WithContext(ctx context.Context) {{.Interface}} {
+	return &timeout{{.Name}}{next: s.next, ctx: ctx, timeout: s.timeout}
+}
+{{range .Methods}}
+func (s *timeout{{$d.Name}}) {{.Name}}{{.Signature}} {
+	{{- if or (eq .Err "nil") (not .Read)}}
+	{{if .Results}}return {{end}}bind(s.ctx, s.next).{{.Name}}({{.Args}})
+	{{- else}}
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	type result struct {
+		{{- range $i, $r := .Results}}
+		r{{$i}} {{$r}}
+		{{- end}}
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		{{with .}}{{range $i, $r := .Results}}{{if $i}}, {{end}}res.r{{$i}}{{end}}{{end}} = bind(ctx, s.next).{{.Name}}({{.Args}})
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		return {{range $i, $r := .Results}}{{if $i}}, {{end}}res.r{{$i}}{{end}}
+	case <-ctx.Done():
+		var res result
+		res.{{.Err}} = timedOut(ctx, "{{$d.Op}}.{{.Name}}")
+		return {{range $i, $r := .Results}}{{if $i}}, {{end}}res.r{{$i}}{{end}}
+	}
+	{{- end}}
+}
+{{end}}
+{{- end}}
+`))
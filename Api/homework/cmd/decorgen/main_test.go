@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const greeterPath = "homework/cmd/decorgen/testdata/greeter"
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func locateIn(dir, name string) func(string) (string, string, error) {
+	return func(string) (string, string, error) { return dir, name, nil }
+}
+
+func TestGenerate(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "greeter_gen.go")
+	err := run([]string{"-i", greeterPath + ".Greeter", "-o", out, "-r", "Greet"}, env(map[string]string{"GOPACKAGE": "decorated"}), locateIn("testdata/greeter", "greeter"))
+	assert.Nil(t, err)
+
+	raw, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	src := string(raw)
+	assert.Contains(t, src, "func (s *tracedGreeter) Greet(a0 context.Context, a1 ...string) (*greeter.Greeting, error) {")
+	assert.Contains(t, src, "tracing.Record(span, r1)")
+	assert.Contains(t, src, `logCall(s.ctx, s.logger, "greeter.Forget", []any{a0}, start, nil)`)
+	assert.Contains(t, src, `res.r1 = timedOut(ctx, "greeter.Greet")`)
+	assert.Contains(t, src, "func (s *timeoutGreeter) Save(a0 *greeter.Greeting) error {\n\treturn bind(s.ctx, s.next).Save(a0)")
+
+	build(t, out)
+}
+
+// build compiles the generated file src with the helpers of testdata/decorated.
+func build(t *testing.T, src string) {
+	if testing.Short() {
+		t.Skip("runs go build")
+	}
+	dir, err := filepath.Abs("testdata/decorated")
+	assert.Nil(t, err)
+	overlay, err := json.Marshal(map[string]any{"Replace": map[string]string{filepath.Join(dir, "greeter_gen.go"): src}})
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "overlay.json")
+	assert.Nil(t, os.WriteFile(path, overlay, 0o644))
+
+	out, err := exec.Command("go", "build", "-overlay", path, "./testdata/decorated").CombinedOutput()
+	assert.Nil(t, err, string(out))
+}
+
+func TestInvalid(t *testing.T) {
+	locate := locateIn("testdata/greeter", "greeter")
+	assert.ErrorContains(t, run([]string{"-i", "Greeter", "-p", "x"}, env(nil), locate), "-i")
+	assert.ErrorContains(t, run([]string{"-i", greeterPath + ".Greeter"}, env(nil), locate), "-p")
+	assert.ErrorContains(t, run([]string{"-i", greeterPath + ".Greeter", "-p", "x", "-d", "caching"}, env(nil), locate), "unknown decorator")
+	assert.ErrorContains(t, run([]string{"-i", greeterPath + ".Greeting", "-p", "x"}, env(nil), locate), "not an interface")
+	assert.ErrorContains(t, run([]string{"-i", greeterPath + ".Missing", "-p", "x"}, env(nil), locate), "not found")
+	assert.ErrorContains(t, run([]string{"-i", greeterPath + ".Greeter", "-p", "x", "-d", "timeout"}, env(nil), locate), "-r")
+	assert.ErrorContains(t, run([]string{"-i", greeterPath + ".Greeter", "-p", "x", "-r", "Greet,Missing"}, env(nil), locate), "unknown method")
+}
+
+// TestUpToDate fails if the decorators of internal/decorator weren't regenerated after an interface change.
+func TestUpToDate(t *testing.T) {
+	for file, args := range map[string][]string{
+		"service_gen.go": {"-i", "homework/internal/service.Service", "-d", "logging,metrics,timeout", "-r", "GetDevice,ListDevices,Changes"},
+		"storage_gen.go": {"-i", "homework/internal/service.Storage", "-d", "logging,metrics"},
+	} {
+		out := filepath.Join(t.TempDir(), file)
+		err := run(append(args, "-o", out), env(map[string]string{"GOPACKAGE": "decorator"}), locateIn("../../internal/service", "service"))
+		assert.Nil(t, err)
+
+		want, _ := os.ReadFile(filepath.Join("../../internal/decorator", file))
+		got, _ := os.ReadFile(out)
+		assert.Equal(t, string(want), string(got), "run go generate ./internal/decorator")
+	}
+}
@@ -0,0 +1,26 @@
+// Package decorated provides the helpers decorgen output calls, for compiling it in tests.
+package decorated
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Observer interface {
+	ObserveCall(op string, d time.Duration, err error)
+}
+
+func bind[T any](ctx context.Context, v T) T {
+	if b, ok := any(v).(interface{ WithContext(context.Context) T }); ok {
+		return b.WithContext(ctx)
+	}
+	return v
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, op string, args []any, start time.Time, err error) {
+}
+
+func timedOut(ctx context.Context, op string) error {
+	return ctx.Err()
+}
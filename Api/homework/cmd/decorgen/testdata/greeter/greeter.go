@@ -0,0 +1,17 @@
+package greeter
+
+import (
+	"context"
+	tm "time"
+)
+
+type Greeting struct {
+	Text string
+}
+
+type Greeter interface {
+	Greet(ctx context.Context, names ...string) (*Greeting, error)
+	Since(tm.Time) tm.Duration
+	Forget(map[string][]Greeting, func(Greeting) bool)
+	Save(*Greeting) error
+}
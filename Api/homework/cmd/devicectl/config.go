@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configFileName = ".devicectl.yaml"
+
+// config is layered: defaults, then the config file, then the environment, then flags.
+type config struct {
+	Host    string        `yaml:"host"`
+	Port    string        `yaml:"port"`
+	Output  string        `yaml:"output"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Token is the bearer token sent when the server requires authentication.
+	Token string `yaml:"token"`
+}
+
+func defaultConfig() config {
+	return config{Host: "localhost", Port: "8080", Output: "table", Timeout: 10 * time.Second}
+}
+
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, configFileName)
+}
+
+// loadFile merges the config file at path into cfg. A missing default file is not an error.
+func (cfg *config) loadFile(path string, explicit bool) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	file := config{}
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return err
+	}
+	cfg.merge(file)
+	return nil
+}
+
+// loadEnv reads HTTP_HOST and HTTP_PORT like the server does, DEVICECTL_OUTPUT,
+// and REGISTRY_TOKEN like the other commands.
+func (cfg *config) loadEnv() {
+	cfg.merge(config{
+		Host:   os.Getenv("HTTP_HOST"),
+		Port:   os.Getenv("HTTP_PORT"),
+		Output: os.Getenv("DEVICECTL_OUTPUT"),
+		Token:  os.Getenv("REGISTRY_TOKEN"),
+	})
+}
+
+func (cfg *config) merge(other config) {
+	if other.Host != "" {
+		cfg.Host = other.Host
+	}
+	if other.Port != "" {
+		cfg.Port = other.Port
+	}
+	if other.Output != "" {
+		cfg.Output = other.Output
+	}
+	if other.Timeout != 0 {
+		cfg.Timeout = other.Timeout
+	}
+	if other.Token != "" {
+		cfg.Token = other.Token
+	}
+}
+
+func (cfg config) baseURL() string {
+	return "http://" + net.JoinHostPort(cfg.Host, cfg.Port)
+}
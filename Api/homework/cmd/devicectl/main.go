@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/client"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// exit codes
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+	exitConflict
+	exitInvalid
+	exitUnavailable
+)
+
+var errUsage = errors.New("usage")
+
+const usage = `usage: devicectl [flags] <command> [command flags]
+
+commands:
+  get <serial>                       show a device
+  create -serial S -model M -ip IP   create a device (or -f file)
+  update -serial S -model M -ip IP   update a device (or -f file)
+  delete <serial>                    delete a device
+  list [-state S] [-model M] [-label k=v]... [-attr path=v]...
+  import -f file [-upsert]           create devices from a JSON or YAML list
+  export [-f file]                   write every device as JSON or YAML
+  report [-strict]                   check the registry, -strict fails on issues
+
+flags:
+`
+
+// stringsFlag collects a repeated string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+type app struct {
+	client *client.Client
+	output string
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("devicectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "config file, default ~/"+configFileName)
+	addr := fs.String("addr", "", "device API base URL, overrides host and port")
+	output := fs.String("o", "", "output format: table, json or yaml")
+	timeout := fs.Duration("timeout", 0, "request timeout")
+	token := fs.String("token", "", "bearer token, also REGISTRY_TOKEN")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg := defaultConfig()
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if err := cfg.loadFile(path, explicit); err != nil {
+		_, _ = fmt.Fprintln(stderr, "devicectl: config:", err)
+		return exitUsage
+	}
+	cfg.loadEnv()
+	cfg.merge(config{Output: *output, Timeout: *timeout, Token: *token})
+
+	baseURL := cfg.baseURL()
+	if *addr != "" {
+		baseURL = *addr
+	}
+
+	a := &app{
+		client: client.New(baseURL, client.WithTimeout(cfg.Timeout), client.WithToken(cfg.Token)),
+		output: cfg.Output,
+		stdin:  stdin,
+		stdout: stdout,
+	}
+
+	err := a.dispatch(context.Background(), fs.Arg(0), fs.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+		return exitUsage
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "devicectl:", err)
+	}
+	return exitCode(err)
+}
+
+func (a *app) dispatch(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "get":
+		return a.get(ctx, args)
+	case "create":
+		return a.write(ctx, args, a.client.CreateDevice)
+	case "update":
+		return a.write(ctx, args, a.client.UpdateDevice)
+	case "delete":
+		return a.delete(ctx, args)
+	case "list":
+		return a.list(ctx, args)
+	case "import":
+		return a.importDevices(ctx, args)
+	case "export":
+		return a.export(ctx, args)
+	case "report":
+		return a.report(ctx, args)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+}
+
+func (a *app) get(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	d, err := a.client.GetDevice(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return write(a.stdout, a.output, d)
+}
+
+func (a *app) write(ctx context.Context, args []string, send func(context.Context, client.Device) error) error {
+	fs := flag.NewFlagSet("devicectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		d      client.Device
+		labels stringsFlag
+	)
+	file := fs.String("f", "", "read the device from a JSON or YAML file, - for stdin")
+	fs.StringVar(&d.SerialNum, "serial", "", "serial number")
+	fs.StringVar(&d.Model, "model", "", "model")
+	fs.StringVar(&d.IP, "ip", "", "IP address")
+	fs.StringVar(&d.Firmware, "firmware", "", "firmware version")
+	fs.Var(&labels, "label", "key=value label, repeatable")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if *file != "" {
+		devices, err := a.readFile(*file)
+		if err != nil {
+			return err
+		}
+		if len(devices) != 1 {
+			return fmt.Errorf("%s: expected a single device, got %d", *file, len(devices))
+		}
+		d = devices[0]
+	}
+	for _, l := range labels {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok {
+			return fmt.Errorf("%w: invalid label %q", errUsage, l)
+		}
+		if d.Labels == nil {
+			d.Labels = make(map[string]string)
+		}
+		d.Labels[k] = v
+	}
+
+	return send(ctx, d)
+}
+
+func (a *app) delete(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return a.client.DeleteDevice(ctx, args[0])
+}
+
+func (a *app) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("devicectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var (
+		f            client.Filter
+		labels, attr stringsFlag
+		state        string
+	)
+	fs.StringVar(&state, "state", "", "lifecycle state")
+	fs.StringVar(&f.Model, "model", "", "model")
+	fs.Var(&labels, "label", "key=value label, repeatable")
+	fs.Var(&attr, "attr", "path=value attribute, repeatable")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	f.State = client.State(state)
+
+	var err error
+	if f.Labels, err = selector(labels); err != nil {
+		return err
+	}
+	if f.Attributes, err = selector(attr); err != nil {
+		return err
+	}
+
+	devices, err := a.client.ListDevices(ctx, f)
+	if err != nil {
+		return err
+	}
+	return writeList(a.stdout, a.output, devices)
+}
+
+func (a *app) importDevices(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("devicectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "JSON or YAML file with devices, - for stdin")
+	upsert := fs.Bool("upsert", false, "update devices that already exist")
+	if err := fs.Parse(args); err != nil || *file == "" {
+		return errUsage
+	}
+
+	devices, err := a.readFile(*file)
+	if err != nil {
+		return err
+	}
+
+	created, updated := 0, 0
+	for _, d := range devices {
+		err := a.client.CreateDevice(ctx, d)
+		if *upsert && statusOf(err) == http.StatusConflict {
+			err = a.client.UpdateDevice(ctx, d)
+			if err == nil {
+				updated++
+				continue
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("device %q: %w (imported %d)", d.SerialNum, err, created+updated)
+		}
+		created++
+	}
+
+	_, _ = fmt.Fprintf(a.stdout, "created %d, updated %d\n", created, updated)
+	return nil
+}
+
+func (a *app) export(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("devicectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "output file, default stdout")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	devices, err := a.client.ListDevices(ctx, client.Filter{})
+	if err != nil {
+		return err
+	}
+
+	format := a.output
+	if format == "table" {
+		format = "json"
+	}
+
+	out := a.stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	return writeList(out, format, devices)
+}
+
+func (a *app) report(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("devicectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	strict := fs.Bool("strict", false, "exit with an error if there are issues")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	r, err := a.client.Report(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(a.stdout, a.output, r); err != nil {
+		return err
+	}
+	if *strict && len(r.Issues) > 0 {
+		return fmt.Errorf("%d registry issues", len(r.Issues))
+	}
+	return nil
+}
+
+func (a *app) readFile(name string) ([]client.Device, error) {
+	if name == "-" {
+		return readDevices(a.stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readDevices(f)
+}
+
+// writeList writes devices as a list even if there is only one.
+func writeList(w io.Writer, format string, devices []client.Device) error {
+	if devices == nil {
+		devices = []client.Device{}
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(devices)
+	case "yaml":
+		return writeYAML(w, devices)
+	default:
+		return write(w, format, devices...)
+	}
+}
+
+func selector(pairs []string) (map[string]string, error) {
+	var m map[string]string
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid selector %q", errUsage, p)
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func statusOf(err error) int {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, errUsage) {
+		return exitUsage
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return exitUnavailable
+	}
+
+	switch status := statusOf(err); {
+	case status == http.StatusNotFound:
+		return exitNotFound
+	case status == http.StatusConflict:
+		return exitConflict
+	case status == http.StatusBadRequest:
+		return exitInvalid
+	case status >= http.StatusInternalServerError:
+		return exitUnavailable
+	default:
+		return exitError
+	}
+}
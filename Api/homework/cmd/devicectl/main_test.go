@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework/client"
+	"homework/internal/handler"
+	"homework/internal/middleware"
+	"homework/internal/report"
+	"homework/internal/router"
+	"homework/internal/service"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	h := handler.NewHandler(service.NewService(service.NewStorage()))
+	srv := httptest.NewServer(router.NewRouter(h))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func devicectl(t *testing.T, srv *httptest.Server, stdin string, args ...string) (int, string) {
+	t.Setenv("HOME", t.TempDir())
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-addr", srv.URL}, args...)
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	srv := newServer(t)
+
+	code, _ := devicectl(t, srv, "", "create", "-serial", "1", "-model", "switch", "-ip", "1.1.1.1", "-label", "rack=a")
+	assert.Equal(t, exitOK, code)
+
+	code, _ = devicectl(t, srv, "", "create", "-serial", "2", "-model", "switch", "-ip", "bad")
+	assert.Equal(t, exitInvalid, code)
+
+	code, out := devicectl(t, srv, "", "-o", "json", "get", "1")
+	assert.Equal(t, exitOK, code)
+	var d client.Device
+	assert.Nil(t, json.Unmarshal([]byte(out), &d))
+	assert.Equal(t, "a", d.Labels["rack"])
+
+	code, _ = devicectl(t, srv, "", "create", "-serial", "1", "-model", "switch", "-ip", "1.1.1.1")
+	assert.Equal(t, exitConflict, code)
+
+	code, out = devicectl(t, srv, "", "list", "-model", "switch")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "SERIAL")
+	assert.Contains(t, out, "1.1.1.1")
+
+	code, _ = devicectl(t, srv, "", "delete", "1")
+	assert.Equal(t, exitOK, code)
+
+	code, _ = devicectl(t, srv, "", "get", "1")
+	assert.Equal(t, exitNotFound, code)
+
+	code, _ = devicectl(t, srv, "", "frobnicate")
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestImportExport(t *testing.T) {
+	srv := newServer(t)
+	input := `
+- serial_number: "1"
+  model: switch
+  ip: 1.1.1.1
+- serial_number: "2"
+  model: router
+  ip: 2.2.2.2
+`
+	code, out := devicectl(t, srv, input, "import", "-f", "-")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "created 2, updated 0\n", out)
+
+	code, _ = devicectl(t, srv, input, "import", "-f", "-")
+	assert.Equal(t, exitConflict, code)
+
+	code, out = devicectl(t, srv, input, "import", "-f", "-", "-upsert")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "created 0, updated 2\n", out)
+
+	file := filepath.Join(t.TempDir(), "devices.yaml")
+	code, _ = devicectl(t, srv, "", "-o", "yaml", "export", "-f", file)
+	assert.Equal(t, exitOK, code)
+
+	f, err := os.Open(file)
+	assert.Nil(t, err)
+	defer f.Close()
+	devices, err := readDevices(f)
+	assert.Nil(t, err)
+	assert.Len(t, devices, 2)
+	assert.Equal(t, "router", devices[1].Model)
+}
+
+func TestUnavailable(t *testing.T) {
+	srv := newServer(t)
+	srv.Close()
+
+	code, _ := devicectl(t, srv, "", "get", "1")
+	assert.Equal(t, exitUnavailable, code)
+}
+
+func TestToken(t *testing.T) {
+	h := handler.NewHandler(service.NewService(service.NewStorage()))
+	srv := httptest.NewServer(middleware.Auth([]string{"secret"})(router.NewRouter(h)))
+	t.Cleanup(srv.Close)
+
+	code, _ := devicectl(t, srv, "", "list")
+	assert.Equal(t, exitError, code)
+	code, _ = devicectl(t, srv, "", "-token", "secret", "list")
+	assert.Equal(t, exitOK, code)
+
+	t.Setenv("REGISTRY_TOKEN", "secret")
+	code, _ = devicectl(t, srv, "", "list")
+	assert.Equal(t, exitOK, code)
+}
+
+func TestReport(t *testing.T) {
+	storage := service.NewStorage()
+	h := handler.NewHandler(service.NewService(storage))
+	rules, err := report.ParseRules([]string{"10.0.0.0/8"}, nil, 0)
+	assert.Nil(t, err)
+	reports := report.NewService(storage, nil, nil, rules)
+	srv := httptest.NewServer(router.NewRouter(h, router.WithReport(handler.NewReportHandler(h, reports))))
+	t.Cleanup(srv.Close)
+
+	code, out := devicectl(t, srv, "", "report", "-strict")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "0 devices, 0 issues")
+
+	code, _ = devicectl(t, srv, "", "create", "-serial", "1", "-model", "switch", "-ip", "1.1.1.1")
+	assert.Equal(t, exitOK, code)
+
+	code, out = devicectl(t, srv, "", "report")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "ip_outside_subnets  1")
+
+	code, out = devicectl(t, srv, "", "-o", "json", "report", "-strict")
+	assert.Equal(t, exitError, code)
+	var r client.Report
+	assert.Nil(t, json.Unmarshal([]byte(out), &r))
+	assert.Equal(t, 1, r.Devices)
+	assert.Len(t, r.Issues, 1)
+}
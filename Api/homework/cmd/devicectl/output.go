@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"homework/client"
+	"homework/internal/report"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// write prints v as a table of devices, JSON or YAML.
+func write(w io.Writer, format string, devices ...client.Device) error {
+	var v any = devices
+	if len(devices) == 1 {
+		v = devices[0]
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		return writeYAML(w, v)
+	case "table":
+		return writeTable(w, devices)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// writeReport prints r as JSON, YAML or, for table, human-readable text.
+func writeReport(w io.Writer, format string, r client.Report) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "yaml":
+		return writeYAML(w, r)
+	case "table":
+		return report.WriteText(w, r)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// writeYAML converts v through JSON first, so YAML keys match the API field names.
+func writeYAML(w io.Writer, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func writeTable(w io.Writer, devices []client.Device) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SERIAL\tMODEL\tIP\tSTATE\tFIRMWARE\tLABELS")
+	for _, d := range devices {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.SerialNum, d.Model, d.IP, dash(string(d.State)), dash(d.Firmware), dash(labels(d.Labels)))
+	}
+	return tw.Flush()
+}
+
+func labels(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// readDevices decodes a JSON or YAML list of devices, or a single device.
+func readDevices(r io.Reader) ([]client.Device, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	// YAML is a superset of JSON, re-encode to JSON to reuse the json tags of Device
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := doc.([]any); !ok {
+		var d client.Device
+		if err := json.Unmarshal(normalized, &d); err != nil {
+			return nil, err
+		}
+		return []client.Device{d}, nil
+	}
+
+	var devices []client.Device
+	if err := json.Unmarshal(normalized, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
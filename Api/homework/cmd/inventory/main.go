@@ -0,0 +1,125 @@
+// Command inventory renders the device registry as an Ansible dynamic inventory or an ssh_config fragment.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"homework/client"
+	"homework/internal/export"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+)
+
+// stringsFlag collects a repeated string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var (
+		groupTemplates stringsFlag
+		labels         stringsFlag
+		cfg            export.InventoryConfig
+	)
+
+	fs := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", client.DefaultURL(), "device API base URL, also HTTP_HOST and HTTP_PORT")
+	token := fs.String("token", os.Getenv("REGISTRY_TOKEN"), "bearer token, also REGISTRY_TOKEN")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	format := fs.String("format", "ansible", "output format: ansible or ssh")
+	fs.Bool("list", false, "print the whole inventory, the default (Ansible inventory script protocol)")
+	host := fs.String("host", "", "print variables of a single host (Ansible inventory script protocol)")
+	modelName := fs.String("model", "", "only devices of this model")
+	state := fs.String("state", "", "only devices in this lifecycle state")
+	fs.Var(&labels, "label", "only devices with this key=value label, repeatable")
+	fs.StringVar(&cfg.HostTemplate, "host-template", "", "host name template, default {{.SerialNum}}")
+	fs.Var(&groupTemplates, "group-template", "group name template, repeatable, default {{.Model}}")
+	fs.BoolVar(&cfg.GroupByLabels, "group-by-labels", false, "add a <key>_<value> group per device label")
+	fs.StringVar(&cfg.SSHUser, "user", "", "ssh User")
+	fs.IntVar(&cfg.SSHPort, "port", 0, "ssh Port")
+	fs.StringVar(&cfg.IdentityFile, "identity-file", "", "ssh IdentityFile")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "ansible" && *format != "ssh" {
+		_, _ = fmt.Fprintf(stderr, "inventory: unknown format %q\n", *format)
+		return exitUsage
+	}
+	f := client.Filter{State: client.State(*state), Model: *modelName}
+	for _, l := range labels {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok {
+			_, _ = fmt.Fprintf(stderr, "inventory: label %q: want key=value\n", l)
+			return exitUsage
+		}
+		if f.Labels == nil {
+			f.Labels = make(map[string]string)
+		}
+		f.Labels[k] = v
+	}
+	if len(groupTemplates) > 0 {
+		cfg.GroupTemplates = groupTemplates
+	}
+
+	// hostvars are included in --list output, so --host has nothing to add
+	if *host != "" {
+		_, _ = fmt.Fprintln(stdout, "{}")
+		return exitOK
+	}
+
+	c := client.New(*addr, client.WithTimeout(*timeout), client.WithToken(*token))
+	if err := render(context.Background(), c, f, cfg, *format, stdout); err != nil {
+		_, _ = fmt.Fprintln(stderr, "inventory:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func render(ctx context.Context, c *client.Client, f client.Filter, cfg export.InventoryConfig, format string, w io.Writer) error {
+	inv, err := export.NewInventory(cfg)
+	if err != nil {
+		return err
+	}
+	devices, err := c.ListDevices(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if format == "ssh" {
+		res, err := inv.SSHConfig(devices)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, res)
+		return err
+	}
+	res, err := inv.Ansible(devices)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
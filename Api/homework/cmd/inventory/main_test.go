@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/handler"
+	"homework/internal/model"
+	"homework/internal/router"
+	"homework/internal/service"
+	"net/http/httptest"
+	"testing"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	svc := service.NewService(service.NewStorage())
+	for _, d := range []model.Device{
+		{SerialNum: "1", Model: "switch", IP: "10.0.0.1", Labels: map[string]string{"site": "lab"}},
+		{SerialNum: "2", Model: "display", IP: "10.0.0.2", Labels: map[string]string{"site": "hq"}},
+	} {
+		assert.Nil(t, svc.CreateDevice(d))
+	}
+	srv := httptest.NewServer(router.NewRouter(handler.NewHandler(svc)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func inventory(t *testing.T, srv *httptest.Server, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-addr", srv.URL}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAnsible(t *testing.T) {
+	srv := newServer(t)
+
+	code, out, _ := inventory(t, srv, "--list", "-label", "site=lab")
+	assert.Equal(t, exitOK, code)
+	var res map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(out), &res))
+	assert.Equal(t, map[string]any{"hosts": []any{"1"}}, res["switch"])
+	assert.NotContains(t, res, "display")
+
+	code, out, _ = inventory(t, srv, "--host", "1")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "{}\n", out)
+}
+
+func TestSSHConfig(t *testing.T) {
+	srv := newServer(t)
+
+	code, out, _ := inventory(t, srv, "-format", "ssh", "-model", "display", "-user", "admin")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "Host 2\n    HostName 10.0.0.2\n    User admin\n\n", out)
+
+	code, _, errOut := inventory(t, srv, "-format", "ssh", "-user", "admin\nProxyCommand x")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, errOut, "must not contain whitespace or control characters")
+}
+
+func TestInvalid(t *testing.T) {
+	srv := newServer(t)
+
+	code, _, _ := inventory(t, srv, "-format", "yaml")
+	assert.Equal(t, exitUsage, code)
+	code, _, _ = inventory(t, srv, "-label", "site")
+	assert.Equal(t, exitUsage, code)
+
+	code, _, errOut := inventory(t, srv, "-group-template", "all")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, errOut, "reserved")
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/client"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operations against the device API.
+const (
+	opCreate = "create"
+	opGet    = "get"
+	opUpdate = "update"
+	opDelete = "delete"
+	opList   = "list"
+)
+
+var operations = []string{opCreate, opGet, opUpdate, opDelete, opList}
+
+// labelKey labels the devices of a run, so list only sees them and cleanup finds them.
+const labelKey = "loadgen"
+
+const deviceModel = "loadgen"
+
+// mix is the relative weight of each operation.
+type mix map[string]int
+
+// parseMix parses weights such as "create=20,get=50,update=20,delete=5,list=5".
+func parseMix(s string) (mix, error) {
+	m := make(mix)
+	total := 0
+	for _, pair := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix %q: want op=weight", pair)
+		}
+		if !isOperation(op) {
+			return nil, fmt.Errorf("mix: unknown operation %q, want one of %s", op, strings.Join(operations, ", "))
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("mix %q: weight must be a non-negative integer", pair)
+		}
+		m[op] += w
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.New("mix: weights must not all be zero")
+	}
+	return m, nil
+}
+
+func isOperation(op string) bool {
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns an operation with probability proportional to its weight.
+func (m mix) pick() string {
+	total := 0
+	for _, op := range operations {
+		total += m[op]
+	}
+	n := rand.IntN(total)
+	for _, op := range operations {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	panic("unreachable")
+}
+
+// keyspace holds the serial numbers of the devices that exist. It is safe for concurrent use.
+type keyspace struct {
+	mu      sync.Mutex
+	serials []string
+	index   map[string]int
+}
+
+func newKeyspace() *keyspace {
+	return &keyspace{index: make(map[string]int)}
+}
+
+func (k *keyspace) add(serial string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.index[serial]; ok {
+		return
+	}
+	k.index[serial] = len(k.serials)
+	k.serials = append(k.serials, serial)
+}
+
+// random returns a random serial, or false if there are none.
+func (k *keyspace) random() (string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.serials) == 0 {
+		return "", false
+	}
+	return k.serials[rand.IntN(len(k.serials))], true
+}
+
+// take removes and returns a random serial, so no other request deletes it too.
+func (k *keyspace) take() (string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.serials) == 0 {
+		return "", false
+	}
+	i := rand.IntN(len(k.serials))
+	serial := k.serials[i]
+	last := len(k.serials) - 1
+	k.serials[i] = k.serials[last]
+	k.index[k.serials[i]] = i
+	k.serials = k.serials[:last]
+	delete(k.index, serial)
+	return serial, true
+}
+
+func (k *keyspace) all() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]string(nil), k.serials...)
+}
+
+// load drives operations against the device API and records their results.
+type load struct {
+	client   *client.Client
+	runID    string
+	mix      mix
+	keys     *keyspace
+	recorder *recorder
+	seq      atomic.Uint64
+}
+
+// do runs an operation. Operations that need an existing device create one if there are none.
+func (l *load) do(ctx context.Context, op string) (string, error) {
+	switch op {
+	case opGet:
+		if serial, ok := l.keys.random(); ok {
+			_, err := l.client.GetDevice(ctx, serial)
+			return op, err
+		}
+	case opUpdate:
+		if serial, ok := l.keys.random(); ok {
+			return op, l.client.UpdateDevice(ctx, l.device(serial))
+		}
+	case opDelete:
+		if serial, ok := l.keys.take(); ok {
+			err := l.client.DeleteDevice(ctx, serial)
+			if err != nil && !errors.Is(err, client.ErrDeviceDoesNotExist) {
+				// it may still exist, keep it for cleanup
+				l.keys.add(serial)
+			}
+			return op, err
+		}
+	case opList:
+		_, err := l.client.ListDevices(ctx, client.Filter{Labels: map[string]string{labelKey: l.runID}})
+		return op, err
+	}
+	return opCreate, l.create(ctx)
+}
+
+func (l *load) create(ctx context.Context) error {
+	d := l.device(fmt.Sprintf("lg-%s-%d", l.runID, l.seq.Add(1)))
+	if err := l.client.CreateDevice(ctx, d); err != nil {
+		return err
+	}
+	l.keys.add(d.SerialNum)
+	return nil
+}
+
+// device returns a device of the run with a random IP, so updates change something.
+func (l *load) device(serial string) client.Device {
+	return client.Device{
+		SerialNum: serial,
+		Model:     deviceModel,
+		IP:        fmt.Sprintf("10.%d.%d.%d", rand.IntN(256), rand.IntN(256), 1+rand.IntN(254)),
+		Labels:    map[string]string{labelKey: l.runID},
+	}
+}
+
+// closed runs concurrency workers, each sending its next request when the last one
+// completes, until the run ends. Latency is the service time of each request.
+func (l *load) closed(ctx context.Context, concurrency int) {
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				start := time.Now()
+				// in-flight requests finish after the run ends rather than fail
+				op, err := l.do(context.WithoutCancel(ctx), l.mix.pick())
+				l.recorder.record(op, time.Since(start), err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// open sends requests at a fixed rate until the run ends, whether or not earlier ones
+// completed, with at most concurrency in flight. Latency counts from when a request
+// was due, so a server falling behind the schedule shows up as queueing time.
+func (l *load) open(ctx context.Context, rate float64, concurrency int) {
+	interval := time.Duration(float64(time.Second) / rate)
+	sem := make(chan struct{}, concurrency)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	start := time.Now()
+	for i := 0; ; i++ {
+		due := start.Add(time.Duration(i) * interval)
+		timer.Reset(time.Until(due))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			op, err := l.do(context.WithoutCancel(ctx), l.mix.pick())
+			l.recorder.record(op, time.Since(due), err)
+		}()
+	}
+}
+
+// preload creates n devices with concurrency workers before the run, without recording them.
+func (l *load) preload(ctx context.Context, n, concurrency int) error {
+	return l.parallel(ctx, n, concurrency, func(ctx context.Context, _ int) error {
+		return l.create(ctx)
+	})
+}
+
+// cleanup deletes the devices left by the run.
+func (l *load) cleanup(ctx context.Context, concurrency int) error {
+	serials := l.keys.all()
+	return l.parallel(ctx, len(serials), concurrency, func(ctx context.Context, i int) error {
+		err := l.client.DeleteDevice(ctx, serials[i])
+		if errors.Is(err, client.ErrDeviceDoesNotExist) {
+			return nil
+		}
+		return err
+	})
+}
+
+// parallel calls f for 0..n-1 with concurrency workers and returns the first error.
+func (l *load) parallel(ctx context.Context, n, concurrency int, f func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for range min(concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < n && ctx.Err() == nil; i = int(next.Add(1) - 1) {
+				if err := f(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,191 @@
+// Command loadgen drives a mix of device API operations against a server and reports
+// latency percentiles, errors by status and throughput over time as JSON.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/client"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+)
+
+// Load modes.
+const (
+	modeClosed = "closed"
+	modeOpen   = "open"
+)
+
+const defaultMix = "create=20,get=50,update=20,delete=5,list=5"
+
+const usage = `usage: loadgen [flags]
+
+Closed-loop mode runs -concurrency workers sending requests back to back. Open-loop mode
+sends -rate requests per second with at most -concurrency in flight, and counts latency
+from when each request was due.
+
+flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", client.DefaultURL(), "device API base URL, also HTTP_HOST and HTTP_PORT")
+	token := fs.String("token", os.Getenv("REGISTRY_TOKEN"), "bearer token, also REGISTRY_TOKEN")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	mode := fs.String("mode", modeClosed, "load mode: closed or open")
+	concurrency := fs.Int("concurrency", 16, "workers in closed mode, the in-flight limit in open mode")
+	rate := fs.Float64("rate", 100, "requests per second in open mode")
+	duration := fs.Duration("duration", 30*time.Second, "length of the run")
+	mixFlag := fs.String("mix", defaultMix, "relative weights of create, get, update, delete and list")
+	devices := fs.Int("devices", 1000, "devices to create before the run")
+	interval := fs.Duration("interval", time.Second, "interval of the throughput timeline")
+	out := fs.String("out", "", "write the report to this file instead of stdout")
+	cleanup := fs.Bool("cleanup", true, "delete the devices of the run when it ends")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	m, err := parseMix(*mixFlag)
+	if err == nil {
+		err = validate(*mode, *concurrency, *rate, *duration, *devices, *interval)
+	}
+	if err == nil && fs.NArg() > 0 {
+		err = fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "loadgen:", err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	// keep a connection per worker instead of churning through ports
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = *concurrency
+	l := &load{
+		client: client.New(*addr, client.WithTimeout(*timeout), client.WithTransport(transport), client.WithToken(*token)),
+		runID:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		mix:    m,
+		keys:   newKeyspace(),
+	}
+
+	clean := func() int {
+		if !*cleanup {
+			return exitOK
+		}
+		// the run may have been interrupted, cleaning up should not be
+		if err := l.cleanup(context.WithoutCancel(ctx), *concurrency); err != nil {
+			_, _ = fmt.Fprintln(stderr, "loadgen: cleanup:", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	if err := l.preload(ctx, *devices, *concurrency); err != nil {
+		_, _ = fmt.Fprintln(stderr, "loadgen: preload:", err)
+		// delete the devices created before the failure
+		clean()
+		return exitError
+	}
+
+	rep := l.run(ctx, *mode, *rate, *concurrency, *duration, *interval)
+	rep.Target = *addr
+	rep.Mode = *mode
+	rep.Concurrency = *concurrency
+	rep.Mix = m
+	if *mode == modeOpen {
+		rep.Rate = *rate
+	}
+
+	code := clean()
+	if err := writeReport(rep, *out, stdout); err != nil {
+		_, _ = fmt.Fprintln(stderr, "loadgen:", err)
+		return exitError
+	}
+	return code
+}
+
+func validate(mode string, concurrency int, rate float64, duration time.Duration, devices int, interval time.Duration) error {
+	switch {
+	case mode != modeClosed && mode != modeOpen:
+		return fmt.Errorf("mode must be %s or %s", modeClosed, modeOpen)
+	case concurrency <= 0:
+		return errors.New("concurrency must be positive")
+	case mode == modeOpen && rate <= 0:
+		return errors.New("rate must be positive")
+	case duration <= 0:
+		return errors.New("duration must be positive")
+	case devices < 0:
+		return errors.New("devices must not be negative")
+	case interval <= 0:
+		return errors.New("interval must be positive")
+	}
+	return nil
+}
+
+// run sends load for duration, or until ctx is cancelled, and reports the results.
+func (l *load) run(ctx context.Context, mode string, rate float64, concurrency int, duration, interval time.Duration) Report {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	l.recorder = newRecorder(time.Now(), interval)
+	tickCtx, stopTicks := context.WithCancel(context.Background())
+	ticks := make(chan struct{})
+	go func() {
+		defer close(ticks)
+		l.recorder.run(tickCtx)
+	}()
+
+	if mode == modeOpen {
+		l.open(ctx, rate, concurrency)
+	} else {
+		l.closed(ctx, concurrency)
+	}
+	stopTicks()
+	<-ticks
+	return l.recorder.report(time.Now())
+}
+
+func writeReport(rep Report, path string, stdout io.Writer) error {
+	if path == "" {
+		return encode(stdout, rep)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f, rep); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func encode(w io.Writer, rep Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
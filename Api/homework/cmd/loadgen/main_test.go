@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/client"
+	"homework/internal/handler"
+	"homework/internal/router"
+	"homework/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T) (*httptest.Server, service.Storage) {
+	storage := service.NewStorage()
+	srv := httptest.NewServer(router.NewRouter(handler.NewHandler(service.NewService(storage))))
+	t.Cleanup(srv.Close)
+	return srv, storage
+}
+
+func loadgen(t *testing.T, srv *httptest.Server, args ...string) (int, Report, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"-addr", srv.URL}, args...), &stdout, &stderr)
+	var rep Report
+	if code == exitOK {
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &rep))
+	}
+	return code, rep, stderr.String()
+}
+
+func count(storage service.Storage) int {
+	n := 0
+	storage.Range(func(client.Device) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestClosedLoop(t *testing.T) {
+	srv, storage := newServer(t)
+
+	code, rep, stderr := loadgen(t, srv, "-mode", "closed", "-concurrency", "4", "-duration", "300ms",
+		"-interval", "100ms", "-devices", "20")
+	require.Equal(t, exitOK, code, stderr)
+
+	assert.Equal(t, srv.URL, rep.Target)
+	assert.Equal(t, modeClosed, rep.Mode)
+	assert.Equal(t, 4, rep.Concurrency)
+	assert.Equal(t, 50, rep.Mix[opGet])
+	assert.Positive(t, rep.Requests)
+	assert.Positive(t, rep.Throughput)
+	assert.Positive(t, rep.Latency.P99)
+	assert.GreaterOrEqual(t, rep.Latency.Max, rep.Latency.P50)
+	assert.NotEmpty(t, rep.Timeline)
+
+	var sum int64
+	for _, op := range rep.Operations {
+		sum += op.Requests
+	}
+	assert.Equal(t, rep.Requests, sum)
+	sum = 0
+	for _, i := range rep.Timeline {
+		sum += i.Requests
+	}
+	assert.Equal(t, rep.Requests, sum)
+	assert.Zero(t, count(storage), "cleanup deletes the devices of the run")
+}
+
+func TestOpenLoop(t *testing.T) {
+	srv, storage := newServer(t)
+
+	code, rep, stderr := loadgen(t, srv, "-mode", "open", "-rate", "200", "-duration", "250ms",
+		"-mix", "create=1,get=1", "-devices", "0", "-cleanup=false")
+	require.Equal(t, exitOK, code, stderr)
+
+	assert.Equal(t, modeOpen, rep.Mode)
+	assert.Equal(t, 200.0, rep.Rate)
+	// 50 requests are due in 250ms
+	assert.InDelta(t, 50, rep.Requests, 5)
+	assert.Zero(t, rep.Errors)
+	assert.ElementsMatch(t, []string{opCreate, opGet}, keys(rep.Operations))
+	assert.Equal(t, int(rep.Operations[opCreate].Requests), count(storage))
+}
+
+func TestErrorsByStatus(t *testing.T) {
+	srv, _ := newServer(t)
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-addr", srv.URL, "-token", "secret", "-duration", "100ms",
+		"-devices", "0", "-mix", "list=1", "-concurrency", "1", "-out", path}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+	assert.Empty(t, stdout.String())
+
+	// lists don't fail, so force failures with a server that is gone
+	srv.Close()
+	code, rep, _ := loadgen(t, srv, "-duration", "100ms", "-devices", "0", "-mix", "get=1",
+		"-concurrency", "1", "-cleanup=false")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, rep.Requests, rep.Errors)
+	assert.Equal(t, rep.Errors, rep.Statuses["transport"])
+}
+
+func TestPreloadFailureCleansUp(t *testing.T) {
+	storage := service.NewStorage()
+	api := router.NewRouter(handler.NewHandler(service.NewService(storage)))
+	var creates atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && creates.Add(1) > 5 {
+			http.Error(w, "full", http.StatusInsufficientStorage)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	code, _, stderr := loadgen(t, srv, "-devices", "20", "-concurrency", "1", "-duration", "100ms")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "preload")
+	assert.Zero(t, count(storage), "cleanup deletes the devices created before the failure")
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, "409", status(&client.APIError{StatusCode: 409}))
+	assert.Equal(t, "timeout", status(context.DeadlineExceeded))
+	assert.Equal(t, "transport", status(assert.AnError))
+}
+
+func TestRecorder(t *testing.T) {
+	start := time.Now()
+	r := newRecorder(start, time.Second)
+	r.record(opGet, time.Millisecond, nil)
+	r.record(opGet, 3*time.Millisecond, &client.APIError{StatusCode: 404})
+	r.tick(start.Add(time.Second))
+	r.record(opCreate, 2*time.Millisecond, nil)
+
+	rep := r.report(start.Add(2 * time.Second))
+	assert.Equal(t, int64(3), rep.Requests)
+	assert.Equal(t, int64(1), rep.Errors)
+	assert.Equal(t, 1.5, rep.Throughput)
+	assert.Equal(t, map[string]int64{"404": 1}, rep.Statuses)
+	assert.Equal(t, map[string]int64{"404": 1}, rep.Operations[opGet].Statuses)
+	assert.InDelta(t, 3, rep.Latency.Max, 0.01)
+	assert.Equal(t, []Interval{
+		{Second: 1, Requests: 2, Errors: 1, P99: rep.Operations[opGet].Latency.P99},
+		{Second: 2, Requests: 1, P99: rep.Operations[opCreate].Latency.P99},
+	}, rep.Timeline)
+}
+
+func TestInvalid(t *testing.T) {
+	srv, _ := newServer(t)
+	for _, args := range [][]string{
+		{"-mix", "create=1,scan=1"},
+		{"-mix", "get=0"},
+		{"-mix", "get"},
+		{"-mode", "burst"},
+		{"-mode", "open", "-rate", "0"},
+		{"-concurrency", "0"},
+		{"extra"},
+	} {
+		code, _, _ := loadgen(t, srv, args...)
+		assert.Equal(t, exitUsage, code, args)
+	}
+}
+
+func TestMix(t *testing.T) {
+	m, err := parseMix("get=3, list=1,get=1")
+	require.NoError(t, err)
+	assert.Equal(t, mix{opGet: 4, opList: 1}, m)
+
+	seen := make(map[string]int)
+	for range 1000 {
+		seen[m.pick()]++
+	}
+	assert.ElementsMatch(t, []string{opGet, opList}, keys(seen))
+	assert.Greater(t, seen[opGet], seen[opList])
+}
+
+func TestKeyspace(t *testing.T) {
+	k := newKeyspace()
+	_, ok := k.take()
+	assert.False(t, ok)
+
+	k.add("a")
+	k.add("b")
+	k.add("a")
+	assert.ElementsMatch(t, []string{"a", "b"}, k.all())
+
+	first, _ := k.take()
+	second, _ := k.take()
+	assert.ElementsMatch(t, []string{"a", "b"}, []string{first, second})
+	_, ok = k.random()
+	assert.False(t, ok)
+}
+
+func keys[V any](m map[string]V) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"homework/client"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Latencies are recorded in microseconds up to a minute with 3 significant digits.
+const (
+	minLatency = 1
+	maxLatency = int64(time.Minute / time.Microsecond)
+	sigFigs    = 3
+)
+
+// Latency summarizes a histogram in milliseconds.
+type Latency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	P999 float64 `json:"p999_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// OpReport is the result of one kind of operation.
+type OpReport struct {
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	Throughput float64 `json:"throughput"`
+	Latency    Latency `json:"latency"`
+	// Statuses counts failures by HTTP status, or by timeout and transport for requests without a response.
+	Statuses map[string]int64 `json:"statuses,omitempty"`
+}
+
+// Interval is the throughput of one interval of the run.
+type Interval struct {
+	// Second is the offset of the interval end from the start of the run.
+	Second   float64 `json:"second"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	P99      float64 `json:"p99_ms"`
+}
+
+// Report is the JSON result of a run, meant to be compared across runs.
+type Report struct {
+	Target      string         `json:"target"`
+	Mode        string         `json:"mode"`
+	Rate        float64        `json:"rate,omitempty"`
+	Concurrency int            `json:"concurrency"`
+	Mix         map[string]int `json:"mix"`
+	StartedAt   time.Time      `json:"started_at"`
+	Elapsed     float64        `json:"elapsed_seconds"`
+
+	Requests   int64               `json:"requests"`
+	Errors     int64               `json:"errors"`
+	Throughput float64             `json:"throughput"`
+	Latency    Latency             `json:"latency"`
+	Statuses   map[string]int64    `json:"statuses,omitempty"`
+	Operations map[string]OpReport `json:"operations"`
+	Timeline   []Interval          `json:"timeline"`
+}
+
+type opStats struct {
+	latency  *hdrhistogram.Histogram
+	errors   int64
+	statuses map[string]int64
+}
+
+// recorder collects the results of a run. It is safe for concurrent use.
+type recorder struct {
+	mu    sync.Mutex
+	start time.Time
+	ops   map[string]*opStats
+
+	interval time.Duration
+	current  *hdrhistogram.Histogram
+	errors   int64
+	timeline []Interval
+}
+
+func newRecorder(start time.Time, interval time.Duration) *recorder {
+	return &recorder{
+		start:    start,
+		ops:      make(map[string]*opStats),
+		interval: interval,
+		current:  newHistogram(),
+	}
+}
+
+func newHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(minLatency, maxLatency, sigFigs)
+}
+
+// record adds the latency and outcome of an op. In open-loop mode the latency counts from
+// when the request was scheduled, so queueing behind a slow server shows up.
+func (r *recorder) record(op string, latency time.Duration, err error) {
+	us := min(max(latency.Microseconds(), minLatency), maxLatency)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.ops[op]
+	if !ok {
+		s = &opStats{latency: newHistogram(), statuses: make(map[string]int64)}
+		r.ops[op] = s
+	}
+	_ = s.latency.RecordValue(us)
+	_ = r.current.RecordValue(us)
+	if err != nil {
+		s.errors++
+		s.statuses[status(err)]++
+		r.errors++
+	}
+}
+
+// tick closes the current interval.
+func (r *recorder) tick(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.timeline = append(r.timeline, Interval{
+		Second:   now.Sub(r.start).Round(time.Millisecond).Seconds(),
+		Requests: r.current.TotalCount(),
+		Errors:   r.errors,
+		P99:      ms(r.current.ValueAtQuantile(99)),
+	})
+	r.current.Reset()
+	r.errors = 0
+}
+
+// run ticks every interval until ctx is done.
+func (r *recorder) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.tick(now)
+		}
+	}
+}
+
+// report summarizes the run, closing the last partial interval.
+func (r *recorder) report(end time.Time) Report {
+	if r.current.TotalCount() > 0 {
+		r.tick(end)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := end.Sub(r.start).Seconds()
+	total := newHistogram()
+	rep := Report{
+		StartedAt:  r.start.UTC(),
+		Elapsed:    elapsed,
+		Statuses:   make(map[string]int64),
+		Operations: make(map[string]OpReport, len(r.ops)),
+		Timeline:   r.timeline,
+	}
+	for op, s := range r.ops {
+		total.Merge(s.latency)
+		requests := s.latency.TotalCount()
+		rep.Operations[op] = OpReport{
+			Requests:   requests,
+			Errors:     s.errors,
+			Throughput: float64(requests) / elapsed,
+			Latency:    summarize(s.latency),
+			Statuses:   s.statuses,
+		}
+		rep.Errors += s.errors
+		for k, v := range s.statuses {
+			rep.Statuses[k] += v
+		}
+	}
+	rep.Requests = total.TotalCount()
+	rep.Throughput = float64(rep.Requests) / elapsed
+	rep.Latency = summarize(total)
+	if rep.Timeline == nil {
+		rep.Timeline = []Interval{}
+	}
+	return rep
+}
+
+func summarize(h *hdrhistogram.Histogram) Latency {
+	return Latency{
+		Mean: h.Mean() / 1000,
+		P50:  ms(h.ValueAtQuantile(50)),
+		P90:  ms(h.ValueAtQuantile(90)),
+		P99:  ms(h.ValueAtQuantile(99)),
+		P999: ms(h.ValueAtQuantile(99.9)),
+		Max:  ms(h.Max()),
+	}
+}
+
+func ms(us int64) float64 {
+	return float64(us) / 1000
+}
+
+// status classifies a failed request by HTTP status, or as a timeout or transport error.
+func status(err error) string {
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return strconv.Itoa(apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), isTimeout(err):
+		return "timeout"
+	default:
+		return "transport"
+	}
+}
+
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
@@ -1,32 +1,74 @@
 package main
 
 import (
-	"homework/internal/handler"
-	"homework/internal/router"
-	"homework/internal/service"
-	"log"
-	"net"
-	"net/http"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/internal/app"
+	"homework/internal/config"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
-func Address() string {
-	host := os.Getenv("HTTP_HOST")
-	port := os.Getenv("HTTP_PORT")
-	if host == "" {
-		host = "localhost"
-	}
-	if port == "" {
-		port = "8080"
+func Logger(cfg config.Log) *slog.Logger {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level))
+	opts := &slog.HandlerOptions{Level: level}
+
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(os.Stderr, opts))
 	}
-	return net.JoinHostPort(host, port)
+	return slog.New(slog.NewJSONHandler(os.Stderr, opts))
 }
 
 func main() {
-	h := handler.NewHandler(service.NewService(service.NewStorage()))
-	mux := router.NewRouter(h)
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(2)
+	}
+
+	logger := Logger(cfg.Log)
+	slog.SetDefault(logger)
+
+	if err := run(cfg, logger); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(cfg config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := app.New(cfg, logger)
+	if err := a.Start(ctx); err != nil {
+		return err
+	}
 
-	if err := http.ListenAndServe(Address(), mux); err != nil {
-		log.Fatal(err)
+	code := 0
+	select {
+	case <-ctx.Done():
+	case sig := <-a.Wait():
+		code = sig.ExitCode
+	}
+	stop()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), a.StopTimeout())
+	defer cancel()
+	if err := a.Stop(stopCtx); err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("exit code %d", code)
 	}
+	logger.Info("stopped")
+	return nil
 }
@@ -0,0 +1,418 @@
+// Command registry-backup takes offline backups of the device registry and restores them.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/client"
+	"homework/internal/backup"
+	"homework/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+)
+
+// restoreBatch keeps restore requests under the server body limit.
+const restoreBatch = 500
+
+var errUsage = errors.New("usage")
+
+const usage = `usage: registry-backup [flags] <command> [command flags]
+
+commands:
+  keygen -key file                          create an AES-256 key file
+  create -dir D [-key file] [-incremental]  back up the registry, or the changes since the newest backup
+  list -dir D                               list the backups in D
+  verify [-key file] file...                verify backup checksums, and contents with the key
+  restore -dir D [-key file] [-at rv | -snapshot file] [-serial S]... [-dry-run]
+                                            restore the registry, or some devices, as of a log position;
+                                            large restores are sent in batches, rerun an interrupted one
+
+flags:
+`
+
+// stringsFlag collects a repeated string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+type app struct {
+	client *client.Client
+	stdout io.Writer
+	now    func() time.Time
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("registry-backup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", client.DefaultURL(), "device API base URL, also HTTP_HOST and HTTP_PORT")
+	token := fs.String("token", os.Getenv("REGISTRY_TOKEN"), "bearer token, also REGISTRY_TOKEN")
+	timeout := fs.Duration("timeout", time.Minute, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	a := &app{client: client.New(*addr, client.WithTimeout(*timeout), client.WithToken(*token)), stdout: stdout, now: time.Now}
+
+	err := a.dispatch(context.Background(), fs.Arg(0), fs.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+		return exitUsage
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "registry-backup:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func (a *app) dispatch(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "keygen":
+		return a.keygen(args)
+	case "create":
+		return a.create(ctx, args)
+	case "list":
+		return a.list(args)
+	case "verify":
+		return a.verify(args)
+	case "restore":
+		return a.restore(ctx, args)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+}
+
+func (a *app) keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	path := fs.String("key", "", "key file to create")
+	if err := fs.Parse(args); err != nil || *path == "" {
+		return errUsage
+	}
+	return backup.GenerateKey(*path)
+}
+
+func (a *app) create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dir := fs.String("dir", "", "backup directory")
+	keyPath := fs.String("key", "", "encrypt with the key in this file")
+	incremental := fs.Bool("incremental", false, "back up the changes since the newest backup")
+	if err := fs.Parse(args); err != nil || *dir == "" {
+		return errUsage
+	}
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		return err
+	}
+
+	var archive backup.Archive
+	var name string
+	if *incremental {
+		headers, err := readHeaders(*dir)
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return fmt.Errorf("no backups in %s, create a full one first", *dir)
+		}
+		since := headers[len(headers)-1].ResourceVersion
+		// a server that lost its log on restart counts versions from 0 again, and would
+		// report no changes since until it passes the backups
+		rv, err := a.client.ResourceVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if rv < since {
+			return fmt.Errorf("server is at resource version %d, behind the newest backup at %d: the registry was reset, create a full backup", rv, since)
+		}
+		changes, err := a.client.ChangesSince(ctx, since)
+		if errors.Is(err, client.ErrResourceVersionGone) {
+			return fmt.Errorf("changes since %d are no longer retained, create a full backup: %w", since, err)
+		}
+		if err != nil {
+			return err
+		}
+		if len(changes.Changes) == 0 {
+			_, _ = fmt.Fprintf(a.stdout, "no changes since %d\n", since)
+			return nil
+		}
+		archive = backup.NewIncremental(changes, a.now())
+		name = fmt.Sprintf("incr-%020d-%020d.rbk", changes.From, changes.ResourceVersion)
+	} else {
+		snapshot, err := a.client.Snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		archive = backup.NewFull(snapshot, a.now())
+		name = fmt.Sprintf("full-%020d.rbk", snapshot.ResourceVersion)
+	}
+
+	path := filepath.Join(*dir, name)
+	if err := writeArchive(path, archive, key); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(a.stdout, "wrote %s\n", path)
+	return nil
+}
+
+func (a *app) list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dir := fs.String("dir", "", "backup directory")
+	if err := fs.Parse(args); err != nil || *dir == "" {
+		return errUsage
+	}
+	headers, err := readHeaders(*dir)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FILE\tKIND\tFROM\tRESOURCE VERSION\tITEMS\tENCRYPTED\tCREATED")
+	for _, h := range headers {
+		items := h.Devices
+		if h.Kind == backup.KindIncremental {
+			items = h.Changes
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%t\t%s\n",
+			h.file, h.Kind, h.From, h.ResourceVersion, items, h.Encryption != "", h.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func (a *app) verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	keyPath := fs.String("key", "", "decrypt with the key in this file")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		_, err := readArchive(path, key)
+		switch {
+		case errors.Is(err, backup.ErrKeyRequired):
+			_, _ = fmt.Fprintf(a.stdout, "%s: checksum ok, contents not verified without the key\n", path)
+		case err != nil:
+			failed++
+			_, _ = fmt.Fprintf(a.stdout, "%s: FAILED: %v\n", path, err)
+		default:
+			_, _ = fmt.Fprintf(a.stdout, "%s: ok\n", path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d backups failed verification", failed, fs.NArg())
+	}
+	return nil
+}
+
+func (a *app) restore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var serials stringsFlag
+	dir := fs.String("dir", "", "backup directory")
+	keyPath := fs.String("key", "", "decrypt with the key in this file")
+	at := fs.Uint64("at", 0, "resource version to restore, default the newest backed up")
+	snapshot := fs.String("snapshot", "", "restore this full backup instead")
+	fs.Var(&serials, "serial", "restore only this device, repeatable")
+	dryRun := fs.Bool("dry-run", false, "show what would change")
+	if err := fs.Parse(args); err != nil || (*dir == "") == (*snapshot == "") || *snapshot != "" && *at != 0 {
+		return errUsage
+	}
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	var target model.Snapshot
+	if *snapshot != "" {
+		archive, err := readArchive(*snapshot, key)
+		if err != nil {
+			return err
+		}
+		if archive.Header.Kind != backup.KindFull {
+			return fmt.Errorf("%s is an incremental backup, restore by -dir and -at", *snapshot)
+		}
+		target = archive.Snapshot
+	} else {
+		archives, err := readArchives(*dir, key)
+		if err != nil {
+			return err
+		}
+		if target, err = backup.StateAt(archives, *at); err != nil {
+			return err
+		}
+	}
+
+	current, err := a.client.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	plan := backup.Plan(current, target, serials)
+
+	if *dryRun {
+		_, _ = fmt.Fprintf(a.stdout, "would restore %d and delete %d devices as of resource version %d\n",
+			len(plan.Devices), len(plan.Delete), target.ResourceVersion)
+		for _, d := range plan.Devices {
+			_, _ = fmt.Fprintf(a.stdout, "restore %s\n", d.SerialNum)
+		}
+		for _, num := range plan.Delete {
+			_, _ = fmt.Fprintf(a.stdout, "delete %s\n", num)
+		}
+		return nil
+	}
+
+	// Devices are restored before any is deleted, so a failure part way leaves extra devices
+	// rather than a partly emptied registry. The plan is made from the current registry, so
+	// running the same restore again finishes it.
+	var total model.RestoreResult
+	for len(plan.Delete) > 0 || len(plan.Devices) > 0 {
+		var batch model.Restore
+		n := min(len(plan.Devices), restoreBatch)
+		batch.Devices, plan.Devices = plan.Devices[:n], plan.Devices[n:]
+		n = min(len(plan.Delete), restoreBatch-len(batch.Devices))
+		batch.Delete, plan.Delete = plan.Delete[:n], plan.Delete[n:]
+
+		result, err := a.client.RestoreDevices(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("restored %d and deleted %d devices before: %w; run the same restore again to finish it",
+				total.Restored, total.Deleted, err)
+		}
+		total.Restored += result.Restored
+		total.Deleted += result.Deleted
+		total.ResourceVersion = result.ResourceVersion
+	}
+	_, _ = fmt.Fprintf(a.stdout, "restored %d and deleted %d devices as of resource version %d\n",
+		total.Restored, total.Deleted, target.ResourceVersion)
+	return nil
+}
+
+func loadKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return backup.LoadKey(path)
+}
+
+// writeArchive writes to a temporary file first, so that no partial backup is left behind.
+func writeArchive(path string, archive backup.Archive, key []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	err = backup.Write(f, archive, key)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func readArchive(path string, key []byte) (backup.Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return backup.Archive{}, err
+	}
+	defer f.Close()
+	return backup.Read(f, key)
+}
+
+func readArchives(dir string, key []byte) ([]backup.Archive, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.rbk"))
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]backup.Archive, 0, len(paths))
+	for _, path := range paths {
+		a, err := readArchive(path, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		archives = append(archives, a)
+	}
+	return archives, nil
+}
+
+type header struct {
+	backup.Header
+	file string
+}
+
+// readHeaders returns the headers of the backups in dir by resource version.
+func readHeaders(dir string) ([]header, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.rbk"))
+	if err != nil {
+		return nil, err
+	}
+	headers := make([]header, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		h, err := backup.ReadHeader(bufio.NewReader(f))
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		headers = append(headers, header{Header: h, file: filepath.Base(path)})
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		if headers[i].ResourceVersion != headers[j].ResourceVersion {
+			return headers[i].ResourceVersion < headers[j].ResourceVersion
+		}
+		return headers[i].Kind < headers[j].Kind
+	})
+	return headers, nil
+}
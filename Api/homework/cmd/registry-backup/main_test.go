@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/backup"
+	"homework/internal/handler"
+	"homework/internal/model"
+	"homework/internal/router"
+	"homework/internal/service"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newServer(t *testing.T) (*httptest.Server, service.Storage) {
+	storage := service.NewStorage()
+	h := handler.NewHandler(service.NewService(storage))
+	srv := httptest.NewServer(router.NewRouter(h, router.WithBackup(handler.NewBackupHandler(h, backup.NewService(storage)))))
+	t.Cleanup(srv.Close)
+	return srv, storage
+}
+
+func registryBackup(t *testing.T, srv *httptest.Server, args ...string) (int, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-addr", srv.URL}, args...), &stdout, &stderr)
+	return code, stdout.String() + stderr.String()
+}
+
+func serials(storage service.Storage) []string {
+	var s []string
+	storage.Range(func(d model.Device) bool {
+		s = append(s, d.SerialNum)
+		return true
+	})
+	return s
+}
+
+func TestBackupRestore(t *testing.T) {
+	srv, storage := newServer(t)
+	dir := t.TempDir()
+	key := filepath.Join(dir, "backup.key")
+
+	code, _ := registryBackup(t, srv, "keygen", "-key", key)
+	assert.Equal(t, exitOK, code)
+
+	code, _ = registryBackup(t, srv, "create", "-dir", dir, "-incremental")
+	assert.Equal(t, exitError, code)
+
+	storage.Add(model.Device{SerialNum: "a", Model: "switch", IP: "1.1.1.1"})
+	code, _ = registryBackup(t, srv, "create", "-dir", dir, "-key", key)
+	assert.Equal(t, exitOK, code)
+
+	storage.Add(model.Device{SerialNum: "b", Model: "switch", IP: "1.1.1.2"})
+	storage.Add(model.Device{SerialNum: "a", Model: "switch", IP: "2.2.2.2"})
+	code, out := registryBackup(t, srv, "create", "-dir", dir, "-key", key, "-incremental")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "incr-00000000000000000001-00000000000000000003.rbk")
+
+	code, out = registryBackup(t, srv, "create", "-dir", dir, "-key", key, "-incremental")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "no changes since 3\n", out)
+
+	code, out = registryBackup(t, srv, "list", "-dir", dir)
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "full-00000000000000000001.rbk")
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "*.rbk"))
+	code, out = registryBackup(t, srv, append([]string{"verify"}, backups...)...)
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "contents not verified without the key")
+	code, _ = registryBackup(t, srv, append([]string{"verify", "-key", key}, backups...)...)
+	assert.Equal(t, exitOK, code)
+
+	// lose everything, then restore as of resource version 2: a at its first IP and b
+	storage.Del("a")
+	storage.Del("b")
+	storage.Add(model.Device{SerialNum: "x", Model: "switch", IP: "9.9.9.9"})
+
+	code, out = registryBackup(t, srv, "restore", "-dir", dir, "-key", key, "-at", "2", "-dry-run")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, out, "would restore 2 and delete 1 devices")
+	assert.ElementsMatch(t, []string{"x"}, serials(storage))
+
+	code, out = registryBackup(t, srv, "restore", "-dir", dir, "-key", key, "-at", "2")
+	assert.Equal(t, exitOK, code, out)
+	assert.ElementsMatch(t, []string{"a", "b"}, serials(storage))
+	d, _ := storage.Get("a")
+	assert.Equal(t, "1.1.1.1", d.IP)
+
+	// selected devices as of the newest backup
+	code, _ = registryBackup(t, srv, "restore", "-dir", dir, "-key", key, "-serial", "a")
+	assert.Equal(t, exitOK, code)
+	d, _ = storage.Get("a")
+	assert.Equal(t, "2.2.2.2", d.IP)
+
+	code, _ = registryBackup(t, srv, "restore", "-snapshot", filepath.Join(dir, "full-00000000000000000001.rbk"), "-key", key)
+	assert.Equal(t, exitOK, code)
+	assert.ElementsMatch(t, []string{"a"}, serials(storage))
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	srv, storage := newServer(t)
+	dir := t.TempDir()
+	storage.Add(model.Device{SerialNum: "a", Model: "switch", IP: "1.1.1.1"})
+	code, _ := registryBackup(t, srv, "create", "-dir", dir)
+	assert.Equal(t, exitOK, code)
+
+	path := filepath.Join(dir, "full-00000000000000000001.rbk")
+	raw, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	raw[len(raw)-2] ^= 1
+	assert.Nil(t, os.WriteFile(path, raw, 0o600))
+
+	code, out := registryBackup(t, srv, "verify", path)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, out, "FAILED")
+
+	code, _ = registryBackup(t, srv, "restore")
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestIncrementalAfterReset(t *testing.T) {
+	srv, storage := newServer(t)
+	dir := t.TempDir()
+	storage.Add(model.Device{SerialNum: "a", Model: "switch", IP: "1.1.1.1"})
+	storage.Add(model.Device{SerialNum: "b", Model: "switch", IP: "1.1.1.2"})
+	code, _ := registryBackup(t, srv, "create", "-dir", dir)
+	assert.Equal(t, exitOK, code)
+
+	// a restarted memory backend counts resource versions from 0 again
+	srv, storage = newServer(t)
+	storage.Add(model.Device{SerialNum: "c", Model: "switch", IP: "1.1.1.3"})
+	code, out := registryBackup(t, srv, "create", "-dir", dir, "-incremental")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, out, "server is at resource version 1, behind the newest backup at 2")
+}
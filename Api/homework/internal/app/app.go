@@ -0,0 +1,97 @@
+// Package app wires the device server from fx modules. Components register
+// lifecycle hooks to start and stop with the app, so tests can build the same
+// app with fx.Replace or fx.Decorate swapping in fakes.
+package app
+
+import (
+	"context"
+	"homework/internal/config"
+	"homework/internal/events"
+	"homework/internal/replication"
+	"homework/internal/report"
+	"homework/internal/retention"
+	"homework/internal/topology"
+	"homework/internal/tracing"
+	"log/slog"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+)
+
+// stopSlack is added to the HTTP shutdown delay and timeout for the other stop hooks.
+const stopSlack = 5 * time.Second
+
+// New returns the device server configured by cfg, with options such as replacements appended.
+func New(cfg config.Config, logger *slog.Logger, options ...fx.Option) *fx.App {
+	return fx.New(Options(cfg, logger), fx.Options(options...))
+}
+
+// Options returns the modules of the device server.
+func Options(cfg config.Config, logger *slog.Logger) fx.Option {
+	return fx.Options(
+		fx.Supply(cfg, logger),
+		fx.WithLogger(func() fxevent.Logger {
+			l := &fxevent.SlogLogger{Logger: logger}
+			l.UseLogLevel(slog.LevelDebug)
+			return l
+		}),
+		fx.StopTimeout(cfg.HTTP.ShutdownDelay+cfg.HTTP.ShutdownTimeout+stopSlack),
+		Tracing,
+		Storage,
+		Services,
+		// before HTTP, so that loops stop after the server drains
+		Background,
+		HTTP,
+		RESP,
+	)
+}
+
+// Tracing installs the configured trace exporter and flushes it on stop.
+var Tracing = fx.Module("tracing", fx.Invoke(func(lc fx.Lifecycle, cfg config.Config) error {
+	shutdown, err := tracing.Setup(cfg.Tracing.Exporter, cfg.Tracing.File)
+	if err != nil {
+		return err
+	}
+	lc.Append(fx.StopHook(shutdown))
+	return nil
+}))
+
+// Go runs f in a goroutine started with the app. Stopping the app cancels ctx and waits for f.
+func Go(lc fx.Lifecycle, f func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				f(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
+
+// Background replicates from the leader on followers, or else reaps expired devices,
+// runs the consistency report and drops the relationships of deleted devices.
+var Background = fx.Module("background", fx.Invoke(func(lc fx.Lifecycle, cfg config.Config, follower *replication.Follower, expiry retention.Service, reports report.Service, bus *events.Bus, topo topology.Service) {
+	if follower != nil {
+		Go(lc, follower.Run)
+	} else if cfg.Retention.Interval > 0 {
+		// followers get deletions from the leader
+		Go(lc, func(ctx context.Context) { retention.Run(ctx, expiry, cfg.Retention.Interval) })
+	}
+	if cfg.Report.Interval > 0 {
+		Go(lc, func(ctx context.Context) { report.Run(ctx, reports, cfg.Report.Interval, bus) })
+	}
+	Go(lc, topo.Watch)
+}))
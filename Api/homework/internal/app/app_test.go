@@ -0,0 +1,187 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/config"
+	"homework/internal/model"
+	"homework/internal/retention"
+	"homework/internal/service"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func testConfig() config.Config {
+	cfg := config.Default()
+	cfg.HTTP.Host = "127.0.0.1"
+	cfg.HTTP.Port = "0"
+	cfg.Retention.Interval = 0
+	return cfg
+}
+
+// start starts the app with options swapping in fakes and returns its base URL.
+func start(t *testing.T, cfg config.Config, options ...fx.Option) string {
+	var srv *Server
+	a := fxtest.New(t, Options(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))), fx.Options(options...), fx.Populate(&srv))
+	a.RequireStart()
+	t.Cleanup(a.RequireStop)
+	return "http://" + srv.Addr().String()
+}
+
+func TestFakeStorage(t *testing.T) {
+	storage := service.NewStorage()
+	storage.Add(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"})
+
+	// the file backend would fail to open, but it is replaced
+	cfg := testConfig()
+	cfg.Storage.Backend = config.StorageFile
+	cfg.Storage.Path = filepath.Join(t.TempDir(), "missing", "devices.jsonl")
+	url := start(t, cfg, fx.Replace(fx.Annotate(storage, fx.As(new(service.Storage)))))
+
+	resp, err := http.Get(url + "/v1/devices/1")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var d model.Device
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&d))
+	assert.Equal(t, "switch", d.Model)
+}
+
+func TestFileBackend(t *testing.T) {
+	cfg := testConfig()
+	cfg.Storage.Backend = config.StorageFile
+	cfg.Storage.Path = filepath.Join(t.TempDir(), "devices.jsonl")
+
+	var srv *Server
+	a := fxtest.New(t, Options(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))), fx.Populate(&srv))
+	a.RequireStart()
+	body, _ := json.Marshal(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"})
+	resp, err := http.Post("http://"+srv.Addr().String()+"/v1/devices", "application/json", bytes.NewReader(body))
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	a.RequireStop()
+
+	// stopping closed the journal, so it can be opened again
+	s, err := service.NewFileStorage(cfg.Storage.Path)
+	assert.Nil(t, err)
+	defer s.Close()
+	_, ok := s.Get("1")
+	assert.True(t, ok)
+}
+
+type reaper struct {
+	retention.Service
+	reaps atomic.Int32
+}
+
+func (r *reaper) Reap(time.Time) ([]model.Expiry, error) {
+	r.reaps.Add(1)
+	return nil, nil
+}
+
+func TestBackground(t *testing.T) {
+	cfg := testConfig()
+	cfg.Retention.Interval = time.Millisecond
+	fake := &reaper{}
+	a := fxtest.New(t, Options(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))),
+		fx.Decorate(func(s retention.Service) retention.Service {
+			fake.Service = s
+			return fake
+		}),
+	)
+	a.RequireStart()
+	assert.Eventually(t, func() bool { return fake.reaps.Load() > 0 }, time.Second, time.Millisecond)
+	a.RequireStop()
+
+	reaps := fake.reaps.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, reaps, fake.reaps.Load())
+}
+
+func TestRESP(t *testing.T) {
+	cfg := testConfig()
+	cfg.RESP.Addr = "127.0.0.1:0"
+	cfg.Auth.Tokens = []string{"secret"}
+
+	var srv *RESPServer
+	a := fxtest.New(t, Options(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))), fx.Populate(&srv))
+	a.RequireStart()
+	defer a.RequireStop()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET 1\r\nAUTH secret\r\nGET 1\r\n"))
+	assert.Nil(t, err)
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"-NOAUTH Authentication required.\r\n", "+OK\r\n", "$-1\r\n"} {
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, want, line)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	cfg := testConfig()
+	cfg.Tracing.Exporter = "jaeger"
+	assert.ErrorContains(t, New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))).Err(), "jaeger")
+
+	cfg = testConfig()
+	cfg.Report.SerialFormats = map[string]string{"acme": "("}
+	assert.NotNil(t, New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))).Err())
+}
+
+func request(t *testing.T, method, url, token string, body any) *http.Response {
+	var buf bytes.Buffer
+	if body != nil {
+		assert.Nil(t, json.NewEncoder(&buf).Encode(body))
+	}
+	r, err := http.NewRequest(method, url, &buf)
+	assert.Nil(t, err)
+	r.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(r)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestAdminTokens(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Tokens = []string{"user"}
+	cfg.Auth.AdminTokens = []string{"admin"}
+	url := start(t, cfg)
+
+	assert.Equal(t, http.StatusUnauthorized, request(t, http.MethodGet, url+"/v1/admin/snapshot", "user", nil).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, request(t, http.MethodPost, url+"/v1/admin/restore", "user", model.Restore{}).StatusCode)
+	assert.Equal(t, http.StatusOK, request(t, http.MethodGet, url+"/v1/admin/snapshot", "admin", nil).StatusCode)
+	assert.Equal(t, http.StatusOK, request(t, http.MethodGet, url+"/v1/devices", "user", nil).StatusCode)
+}
+
+func TestRestoreEvictsCache(t *testing.T) {
+	cfg := testConfig()
+	cfg.Storage.Cache.Size = 10
+	url := start(t, cfg)
+
+	d := model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}
+	assert.Equal(t, http.StatusCreated, request(t, http.MethodPost, url+"/v1/devices", "", d).StatusCode)
+	assert.Equal(t, http.StatusOK, request(t, http.MethodGet, url+"/v1/devices/1", "", nil).StatusCode)
+
+	d.IP = "2.2.2.2"
+	assert.Equal(t, http.StatusOK, request(t, http.MethodPost, url+"/v1/admin/restore", "", model.Restore{Devices: []model.Device{d}}).StatusCode)
+	resp := request(t, http.MethodGet, url+"/v1/devices/1", "", nil)
+	var got model.Device
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, "2.2.2.2", got.IP)
+}
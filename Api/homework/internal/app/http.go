@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/backup"
+	"homework/internal/catalog"
+	"homework/internal/config"
+	"homework/internal/events"
+	"homework/internal/export"
+	"homework/internal/firmware"
+	"homework/internal/freeze"
+	"homework/internal/handler"
+	"homework/internal/metrics"
+	"homework/internal/middleware"
+	"homework/internal/replication"
+	"homework/internal/report"
+	"homework/internal/retention"
+	"homework/internal/router"
+	"homework/internal/search"
+	"homework/internal/service"
+	"homework/internal/shadow"
+	"homework/internal/topology"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// publicPaths are served without authentication and limits.
+var publicPaths = []string{"/healthz", "/readyz", "/metrics"}
+
+// adminPrefix is the path of the backup and restore endpoints, which require admin tokens.
+const adminPrefix = "/v1/admin/"
+
+// windowsPrefix is the path of the freeze and maintenance windows, which only override
+// and admin tokens may change.
+const windowsPrefix = "/v1/windows"
+
+// HTTP serves the device API, with the routes of every component, from start to stop.
+var HTTP = fx.Module("http",
+	fx.Provide(
+		NewHandler,
+		NewHealth,
+		route(func(h *handler.Handler, s shadow.Service) router.Option {
+			return router.WithShadow(handler.NewShadowHandler(h, s))
+		}),
+		route(func(h *handler.Handler, s firmware.Service) router.Option {
+			return router.WithFirmware(handler.NewFirmwareHandler(h, s))
+		}),
+		route(func(h *handler.Handler, models *catalog.CatalogService) router.Option {
+			return router.WithCatalog(handler.NewCatalogHandler(h, models))
+		}),
+		route(func(h *handler.Handler, t topology.Service) router.Option {
+			return router.WithTopology(handler.NewTopologyHandler(h, t))
+		}),
+		route(func(h *handler.Handler, index *search.Index) router.Option {
+			return router.WithSearch(handler.NewSearchHandler(h, index))
+		}),
+		route(func(h *handler.Handler, s retention.Service) router.Option {
+			return router.WithRetention(handler.NewRetentionHandler(h, s))
+		}),
+		route(func(h *handler.Handler, f freeze.Service) router.Option {
+			return router.WithFreeze(handler.NewFreezeHandler(h, f))
+		}),
+		route(func(h *handler.Handler, r report.Service) router.Option {
+			return router.WithReport(handler.NewReportHandler(h, r))
+		}),
+		route(func(h *handler.Handler, b backup.Service) router.Option {
+			return router.WithBackup(handler.NewBackupHandler(h, b))
+		}),
+		route(func(h *handler.Handler, cfg config.Config) router.Option {
+			return router.WithExport(handler.NewExportHandler(h, export.PrometheusConfig{
+				DefaultPort: cfg.Prometheus.Port,
+				Ports:       cfg.Prometheus.ModelPorts,
+			}))
+		}),
+		route(func(bus *events.Bus) router.Option {
+			return router.WithEvents(handler.NewEventsHandler(bus))
+		}),
+		route(func(h *handler.Handler, f *replication.Follower) router.Option {
+			return router.WithReplication(handler.NewReplicationHandler(h, f))
+		}),
+		route(func(health *handler.HealthHandler) router.Option {
+			return router.WithHealth(health)
+		}),
+		route(func(m *metrics.Metrics) router.Option {
+			return router.WithMetrics(m.Handler())
+		}),
+		NewMux,
+		NewServer,
+	),
+	fx.Invoke(func(*Server) {}),
+)
+
+// route adds the router option returned by f to the routes of the mux.
+func route(f any) any {
+	return fx.Annotate(f, fx.ResultTags(`group:"routes"`))
+}
+
+func NewHandler(cfg config.Config, devices service.Service) *handler.Handler {
+	var options []handler.Option
+	if cfg.Limits.MaxBodyBytes > 0 {
+		options = append(options, handler.WithMaxBodyBytes(cfg.Limits.MaxBodyBytes))
+	}
+	return handler.NewHandler(devices, options...)
+}
+
+// NewHealth checks the file storage and, on followers, the connection to the leader.
+func NewHealth(h *handler.Handler, storage service.Storage, follower *replication.Follower) *handler.HealthHandler {
+	checks := map[string]handler.Check{}
+	if fs, ok := storage.(*service.FileStorage); ok {
+		checks["storage"] = fs.Err
+	}
+	if follower != nil {
+		checks["replication"] = func() error {
+			if st := follower.Status(); !st.Connected {
+				return fmt.Errorf("not connected to leader: %s", st.LastError)
+			}
+			return nil
+		}
+	}
+	return handler.NewHealthHandler(h, checks)
+}
+
+type Routes struct {
+	fx.In
+
+	Options []router.Option `group:"routes"`
+}
+
+func NewMux(h *handler.Handler, routes Routes) *http.ServeMux {
+	return router.NewRouter(h, routes.Options...)
+}
+
+// Server is the HTTP server of the app.
+type Server struct {
+	srv     *http.Server
+	health  *handler.HealthHandler
+	delay   time.Duration
+	timeout time.Duration
+	logger  *slog.Logger
+	ln      net.Listener
+}
+
+// NewServer listens on start and drains requests on stop, after failing readiness for the
+// shutdown delay. The app shuts down with exit code 1 if serving fails.
+func NewServer(lc fx.Lifecycle, shutdowner fx.Shutdowner, cfg config.Config, logger *slog.Logger, mux *http.ServeMux,
+	follower *replication.Follower, m *metrics.Metrics, health *handler.HealthHandler) (*Server, error) {
+	var root http.Handler = mux
+	if follower != nil {
+		var err error
+		if root, err = replication.ReadOnly(mux, follower); err != nil {
+			return nil, err
+		}
+	}
+	root = Middleware(cfg, logger, m, mux)(root)
+
+	// canceled on shutdown so that watch and event streams end instead of blocking the drain
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	s := &Server{
+		srv: &http.Server{
+			Addr:              cfg.Address(),
+			Handler:           root,
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			ReadTimeout:       cfg.HTTP.ReadTimeout,
+			WriteTimeout:      cfg.HTTP.WriteTimeout,
+			IdleTimeout:       cfg.HTTP.IdleTimeout,
+			BaseContext:       func(net.Listener) context.Context { return baseCtx },
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		},
+		health:  health,
+		delay:   cfg.HTTP.ShutdownDelay,
+		timeout: cfg.HTTP.ShutdownTimeout,
+		logger:  logger,
+	}
+	s.srv.RegisterOnShutdown(cancelBase)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ln, err := net.Listen("tcp", s.srv.Addr)
+			if err != nil {
+				return err
+			}
+			s.ln = ln
+			logger.Info("listening", slog.String("addr", ln.Addr().String()))
+			go func() {
+				if err := s.srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("serving", slog.String("error", err.Error()))
+					_ = shutdowner.Shutdown(fx.ExitCode(1))
+				}
+			}()
+			return nil
+		},
+		OnStop: s.shutdown,
+	})
+	return s, nil
+}
+
+// Addr returns the address the server listens on once started.
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	s.logger.Info("shutting down", slog.Duration("delay", s.delay), slog.Duration("timeout", s.timeout))
+	s.health.ShutDown()
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
+
+// Middleware wraps the API in request IDs, tracing, logging and metrics,
+// then in authentication and limits for everything but publicPaths.
+func Middleware(cfg config.Config, logger *slog.Logger, m *metrics.Metrics, mux *http.ServeMux) func(http.Handler) http.Handler {
+	var chain []func(http.Handler) http.Handler
+	if cfg.Limits.MaxBodyBytes > 0 {
+		chain = append(chain, middleware.MaxBodyBytes(cfg.Limits.MaxBodyBytes))
+	}
+	if cfg.Limits.MaxInFlight > 0 {
+		chain = append(chain, middleware.Except(publicPaths, middleware.MaxInFlight(cfg.Limits.MaxInFlight)))
+	}
+	if cfg.Limits.RequestsPerSecond > 0 {
+		chain = append(chain, middleware.Except(publicPaths, middleware.RateLimit(cfg.Limits.RequestsPerSecond, cfg.Limits.Burst)))
+	}
+	if len(cfg.Auth.Tokens) > 0 {
+		chain = append(chain,
+			freeze.OverrideTokens(cfg.Auth.OverrideTokens),
+			freeze.WindowTokens(windowsPrefix, slices.Concat(cfg.Auth.OverrideTokens, cfg.Auth.AdminTokens)),
+			middleware.Under(adminPrefix, middleware.Auth(cfg.Auth.AdminTokens)),
+			middleware.Except(publicPaths, middleware.Auth(slices.Concat(cfg.Auth.Tokens, cfg.Auth.OverrideTokens, cfg.Auth.AdminTokens))),
+		)
+	}
+	route := middleware.Route(mux)
+	chain = append(chain,
+		middleware.Metrics(m, route),
+		middleware.Logging(logger),
+		middleware.Tracing(route),
+		middleware.RequestID,
+		freeze.Overrides,
+	)
+
+	return func(h http.Handler) http.Handler {
+		for _, mw := range chain {
+			h = mw(h)
+		}
+		return h
+	}
+}
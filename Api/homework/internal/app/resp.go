@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"homework/internal/config"
+	"homework/internal/replication"
+	"homework/internal/resp"
+	"homework/internal/service"
+	"log/slog"
+	"net"
+
+	"go.uber.org/fx"
+)
+
+// RESP serves device lookups over the Redis protocol if resp.addr is set.
+var RESP = fx.Module("resp", fx.Provide(NewRESPServer), fx.Invoke(func(*RESPServer) {}))
+
+// RESPServer is the Redis protocol front-end of the app, nil if it is disabled.
+type RESPServer struct {
+	srv *resp.Server
+	ln  net.Listener
+}
+
+// NewRESPServer serves devices with the HTTP API's tokens and body limit. Followers reject writes,
+// since they can't be forwarded to the leader.
+func NewRESPServer(lc fx.Lifecycle, shutdowner fx.Shutdowner, cfg config.Config, logger *slog.Logger, devices service.Service, follower *replication.Follower) *RESPServer {
+	if cfg.RESP.Addr == "" {
+		return nil
+	}
+	options := []resp.Option{resp.WithIdleTimeout(cfg.RESP.IdleTimeout), resp.WithMaxConns(cfg.RESP.MaxConns)}
+	if len(cfg.Auth.Tokens) > 0 {
+		options = append(options, resp.WithTokens(cfg.Auth.Tokens))
+	}
+	if follower != nil {
+		options = append(options, resp.WithReadOnly())
+	}
+	if cfg.Limits.MaxBodyBytes > 0 {
+		options = append(options, resp.WithMaxBulkBytes(cfg.Limits.MaxBodyBytes))
+	}
+	s := &RESPServer{srv: resp.NewServer(devices, options...)}
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ln, err := net.Listen("tcp", cfg.RESP.Addr)
+			if err != nil {
+				return err
+			}
+			s.ln = ln
+			logger.Info("listening for RESP", slog.String("addr", ln.Addr().String()))
+			go func() {
+				if err := s.srv.Serve(ln); !errors.Is(err, resp.ErrServerClosed) {
+					logger.Error("serving RESP", slog.String("error", err.Error()))
+					_ = shutdowner.Shutdown(fx.ExitCode(1))
+				}
+			}()
+			return nil
+		},
+		OnStop: s.srv.Shutdown,
+	})
+	return s
+}
+
+// Addr returns the address the server listens on once started.
+func (s *RESPServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"homework/internal/backup"
+	"homework/internal/catalog"
+	"homework/internal/config"
+	"homework/internal/decorator"
+	"homework/internal/events"
+	"homework/internal/firmware"
+	"homework/internal/freeze"
+	"homework/internal/metrics"
+	"homework/internal/model"
+	"homework/internal/replication"
+	"homework/internal/report"
+	"homework/internal/retention"
+	"homework/internal/search"
+	"homework/internal/service"
+	"homework/internal/shadow"
+	"homework/internal/topology"
+	"homework/internal/tracing"
+	"log/slog"
+
+	"go.uber.org/fx"
+)
+
+// Services provides the device service and the components built on it.
+var Services = fx.Module("services",
+	fx.Provide(
+		events.NewBus,
+		catalog.NewService,
+		NewMetrics,
+		NewFreeze,
+		NewDevices,
+		NewFollower,
+		NewRetention,
+		NewReports,
+		func(devices service.Service, bus *events.Bus) shadow.Service { return shadow.NewService(devices, bus) },
+		func(devices service.Service, bus *events.Bus) firmware.Service {
+			return firmware.NewService(devices, bus)
+		},
+		NewBackup,
+	),
+)
+
+// NewMetrics registers the server metrics, counting the devices in storage.
+func NewMetrics(storage service.Storage) *metrics.Metrics {
+	return metrics.New(func() int {
+		n := 0
+		storage.Range(func(model.Device) bool {
+			n++
+			return true
+		})
+		return n
+	})
+}
+
+// Devices are the guarded device service and the topology enforcing its delete policy.
+type Devices struct {
+	fx.Out
+
+	Service  service.Service
+	Topology topology.Service
+	// Cache is nil unless the device cache is enabled.
+	Cache *service.CachedService
+}
+
+// NewDevices builds the device service on the index, decorated as configured,
+// and the topology, loaded from its file if there is one.
+func NewDevices(cfg config.Config, index *search.Index, models *catalog.CatalogService, changes freeze.Service, logger *slog.Logger, m *metrics.Metrics) (Devices, error) {
+	svc := service.NewService(DecorateStorage(cfg.Decorators, index, logger, m), service.WithAttributeValidator(models))
+	var cache *service.CachedService
+	if cfg.Storage.Cache.Size > 0 {
+		cache = service.NewCachedService(svc, cfg.Storage.Cache.Size, cfg.Storage.Cache.TTL)
+		svc = cache
+	}
+	var options []topology.Option
+	if cfg.Topology.Path != "" {
+		snap, err := topology.OpenSnapshot(cfg.Topology.Path)
+		if err != nil {
+			return Devices{}, err
+		}
+		options = append(options, topology.WithSnapshot(snap))
+	}
+	topo := topology.NewService(svc, topology.DeletePolicy(cfg.Topology.OnDelete), options...)
+	// cascades delete through the freeze guard, so every contained device is checked and audited
+	svc = DecorateService(cfg.Decorators, topology.Guard(freeze.Guard(svc, changes), topo), logger, m)
+	models.SetDevices(svc)
+	return Devices{Service: svc, Topology: topo, Cache: cache}, nil
+}
+
+// NewFreeze returns the freeze service, auditing overrides to the configured log, closed on stop.
+func NewFreeze(lc fx.Lifecycle, cfg config.Config, bus *events.Bus) (freeze.Service, error) {
+	if cfg.Freeze.AuditLog == "" {
+		return freeze.NewService(bus), nil
+	}
+	audit, err := freeze.OpenAuditLog(cfg.Freeze.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+	lc.Append(fx.StopHook(audit.Close))
+	return freeze.NewService(bus, freeze.WithAuditLog(audit)), nil
+}
+
+// NewBackup restores through the index to keep it current, checking freezes, evicting
+// restored devices from the cache and dropping the relationships of deleted ones.
+func NewBackup(index *search.Index, changes freeze.Service, topo topology.Service, cache *service.CachedService) backup.Service {
+	options := []backup.Option{backup.WithFreeze(changes), backup.WithForgetter(topo)}
+	if cache != nil {
+		options = append(options, backup.WithInvalidator(cache))
+	}
+	return backup.NewService(index, options...)
+}
+
+// DecorateService wraps svc in the service layers of cfg, innermost first.
+func DecorateService(cfg config.Decorators, svc service.Service, logger *slog.Logger, m *metrics.Metrics) service.Service {
+	for _, layer := range cfg.Service {
+		switch layer {
+		case config.LayerLogging:
+			svc = decorator.LoggedService(svc, logger)
+		case config.LayerMetrics:
+			svc = decorator.MetricsService(svc, m)
+		case config.LayerTimeout:
+			svc = decorator.TimeoutService(svc, cfg.Timeout)
+		case config.LayerTracing:
+			svc = tracing.Service(svc)
+		}
+	}
+	return svc
+}
+
+// DecorateStorage wraps s in the storage layers of cfg, innermost first.
+func DecorateStorage(cfg config.Decorators, s service.Storage, logger *slog.Logger, m *metrics.Metrics) service.Storage {
+	for _, layer := range cfg.Storage {
+		switch layer {
+		case config.LayerLogging:
+			s = decorator.LoggedStorage(s, logger)
+		case config.LayerMetrics:
+			s = decorator.MetricsStorage(s, m)
+		case config.LayerTracing:
+			s = tracing.Storage(s)
+		}
+	}
+	return s
+}
+
+// NewFollower returns a follower replicating the index from the leader,
+// or nil if this instance is the leader.
+func NewFollower(cfg config.Config, index *search.Index) *replication.Follower {
+	if cfg.Replication.LeaderURL == "" {
+		return nil
+	}
+	return replication.NewFollower(index, replication.FollowerConfig{
+		LeaderURL:     cfg.Replication.LeaderURL,
+		ForwardWrites: cfg.Replication.ForwardWrites,
+		Token:         cfg.Replication.Token,
+	})
+}
+
+func NewRetention(cfg config.Config, devices service.Service, bus *events.Bus) retention.Service {
+	return retention.NewService(devices, bus, model.RetentionAction(cfg.Retention.Action))
+}
+
+func NewReports(cfg config.Config, index *search.Index, models *catalog.CatalogService, shadows shadow.Service) (report.Service, error) {
+	rules, err := report.ParseRules(cfg.Report.Subnets, cfg.Report.SerialFormats, cfg.Report.HeartbeatTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return report.NewService(index, models, shadows, rules), nil
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"homework/internal/config"
+	"homework/internal/search"
+	"homework/internal/service"
+
+	"go.uber.org/fx"
+)
+
+// Storage provides the configured storage backend, closed on stop, and the search index over it.
+// Writes, including replicated ones, go through the index to keep it current.
+var Storage = fx.Module("storage", fx.Provide(NewStorage, search.NewIndex))
+
+// NewStorage opens the storage backend selected by cfg.
+func NewStorage(lc fx.Lifecycle, cfg config.Config) (service.Storage, error) {
+	if cfg.Storage.Backend != config.StorageFile {
+		return service.NewStorage(), nil
+	}
+	var options []service.FileStorageOption
+	if cfg.Storage.Sync {
+		options = append(options, service.WithSync())
+	}
+	s, err := service.NewFileStorage(cfg.Storage.Path, options...)
+	if err != nil {
+		return nil, err
+	}
+	lc.Append(fx.StopHook(s.Close))
+	return s, nil
+}
@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	KindFull        = "full"
+	KindIncremental = "incremental"
+
+	// KeySize is the AES-256 key size in bytes.
+	KeySize = 32
+
+	magic         = "REGISTRY-BACKUP"
+	formatVersion = 1
+	encryptionGCM = "aes-256-gcm"
+)
+
+var (
+	ErrNotArchive  = errors.New("not a registry backup")
+	ErrChecksum    = errors.New("backup checksum mismatch")
+	ErrKeyRequired = errors.New("backup is encrypted, a key is required")
+	ErrInvalidKey  = errors.New("invalid backup key")
+)
+
+// Header describes an archive. It is stored in the clear ahead of the payload,
+// so archives can be listed and their checksums verified without the key.
+type Header struct {
+	Format          int       `json:"format"`
+	Kind            string    `json:"kind"`
+	From            uint64    `json:"from,omitempty"`
+	ResourceVersion uint64    `json:"resource_version"`
+	CreatedAt       time.Time `json:"created_at"`
+	Devices         int       `json:"devices,omitempty"`
+	Changes         int       `json:"changes,omitempty"`
+	Encryption      string    `json:"encryption,omitempty"`
+	Nonce           []byte    `json:"nonce,omitempty"`
+	// SHA256 is the checksum of the JSON payload, left out of encrypted archives as it would
+	// confirm guesses of their contents, which GCM authenticates instead. StoredSHA256 is
+	// the checksum of the compressed and possibly encrypted bytes following the header.
+	SHA256       string `json:"sha256,omitempty"`
+	StoredSHA256 string `json:"stored_sha256"`
+}
+
+// Archive is a full snapshot or the changes between two resource versions.
+type Archive struct {
+	Header   Header
+	Snapshot model.Snapshot
+	Changes  model.ChangeSet
+}
+
+func NewFull(s model.Snapshot, at time.Time) Archive {
+	return Archive{
+		Header:   Header{Kind: KindFull, ResourceVersion: s.ResourceVersion, CreatedAt: at.UTC(), Devices: len(s.Devices)},
+		Snapshot: s,
+	}
+}
+
+func NewIncremental(c model.ChangeSet, at time.Time) Archive {
+	return Archive{
+		Header:  Header{Kind: KindIncremental, From: c.From, ResourceVersion: c.ResourceVersion, CreatedAt: at.UTC(), Changes: len(c.Changes)},
+		Changes: c,
+	}
+}
+
+// Write writes a gzip compressed archive, encrypted with AES-256-GCM if key is not nil.
+func Write(w io.Writer, a Archive, key []byte) error {
+	var v any = a.Snapshot
+	if a.Header.Kind == KindIncremental {
+		v = a.Changes
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	h := a.Header
+	h.Format = formatVersion
+	if key == nil {
+		h.SHA256 = checksum(payload)
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(payload); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	stored := compressed.Bytes()
+	if key != nil {
+		gcm, err := newGCM(key)
+		if err != nil {
+			return err
+		}
+		h.Encryption = encryptionGCM
+		h.Nonce = make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(h.Nonce); err != nil {
+			return err
+		}
+		stored = gcm.Seal(nil, h.Nonce, stored, additionalData(h))
+	}
+	h.StoredSHA256 = checksum(stored)
+
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(bw, "%s\n%s\n", magic, header)
+	_, _ = bw.Write(stored)
+	return bw.Flush()
+}
+
+// ReadHeader reads the header of an archive, leaving r at the payload.
+func ReadHeader(r *bufio.Reader) (Header, error) {
+	line, err := r.ReadString('\n')
+	if err != nil || strings.TrimSuffix(line, "\n") != magic {
+		return Header{}, ErrNotArchive
+	}
+	line, err = r.ReadString('\n')
+	if err != nil {
+		return Header{}, ErrNotArchive
+	}
+	var h Header
+	if err := json.Unmarshal([]byte(line), &h); err != nil {
+		return Header{}, fmt.Errorf("%w: %v", ErrNotArchive, err)
+	}
+	if h.Format != formatVersion {
+		return Header{}, fmt.Errorf("%w: unsupported format %d", ErrNotArchive, h.Format)
+	}
+	return h, nil
+}
+
+// Read reads and verifies an archive. Without a key an encrypted archive fails with
+// ErrKeyRequired after its stored checksum was verified, with the header read.
+func Read(r io.Reader, key []byte) (Archive, error) {
+	br := bufio.NewReader(r)
+	h, err := ReadHeader(br)
+	if err != nil {
+		return Archive{}, err
+	}
+	stored, err := io.ReadAll(br)
+	if err != nil {
+		return Archive{}, err
+	}
+	if checksum(stored) != h.StoredSHA256 {
+		return Archive{Header: h}, fmt.Errorf("%w: stored bytes", ErrChecksum)
+	}
+
+	if h.Encryption != "" {
+		if key == nil {
+			return Archive{Header: h}, ErrKeyRequired
+		}
+		gcm, err := newGCM(key)
+		if err != nil {
+			return Archive{Header: h}, err
+		}
+		if stored, err = gcm.Open(nil, h.Nonce, stored, additionalData(h)); err != nil {
+			return Archive{Header: h}, fmt.Errorf("%w: decryption failed, wrong key?", ErrChecksum)
+		}
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(stored))
+	if err != nil {
+		return Archive{Header: h}, err
+	}
+	payload, err := io.ReadAll(zr)
+	if err != nil {
+		return Archive{Header: h}, err
+	}
+	// archives encrypted by earlier versions carry the payload checksum too
+	if (h.Encryption == "" || h.SHA256 != "") && checksum(payload) != h.SHA256 {
+		return Archive{Header: h}, fmt.Errorf("%w: payload", ErrChecksum)
+	}
+
+	a := Archive{Header: h}
+	if h.Kind == KindIncremental {
+		err = json.Unmarshal(payload, &a.Changes)
+	} else {
+		err = json.Unmarshal(payload, &a.Snapshot)
+	}
+	return a, err
+}
+
+// LoadKey reads a key file holding 32 bytes, raw or hex encoded.
+func LoadKey(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == KeySize {
+		return raw, nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("%w: %s must hold %d bytes, raw or hex encoded", ErrInvalidKey, path, KeySize)
+	}
+	return key, nil
+}
+
+// GenerateKey writes a new hex encoded key to path, which must not exist.
+func GenerateKey(path string) error {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, hex.EncodeToString(key))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the ciphertext to the position in the log its header claims.
+func additionalData(h Header) []byte {
+	return []byte(fmt.Sprintf("%s %d %d %s", h.Kind, h.From, h.ResourceVersion, h.SHA256))
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+// Package backup takes consistent registry snapshots, archives them and restores them.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"homework/internal/freeze"
+	"homework/internal/model"
+	"homework/internal/service"
+	"sort"
+)
+
+// snapshotAttempts bounds retries of snapshots outrun by writes.
+const snapshotAttempts = 5
+
+var ErrSnapshotBusy = errors.New("registry changes too fast for a consistent snapshot")
+
+type Service interface {
+	// Snapshot returns every device as of a single resource version.
+	Snapshot() (model.Snapshot, error)
+	// Changes returns the mutations after rv. It fails with service.ErrResourceVersionGone
+	// if they are no longer retained.
+	Changes(rv uint64) (model.ChangeSet, error)
+	// Restore validates the devices and checks freezes of every device it changes, then
+	// writes devices to storage as they are, keeping their state and timestamps, and deletes
+	// the devices of r.Delete.
+	Restore(r model.Restore) (model.RestoreResult, error)
+}
+
+// Invalidator evicts devices written behind its back, such as service.CachedService.
+type Invalidator interface {
+	Invalidate(num string)
+}
+
+// Forgetter drops the relationships of deleted devices, such as a topology.Service.
+type Forgetter interface {
+	Forget(num string)
+}
+
+type Option func(*backupService)
+
+// WithFreeze refuses restores changing devices frozen by f.
+func WithFreeze(f freeze.Service) Option {
+	return func(s *backupService) {
+		s.freeze = f
+	}
+}
+
+// WithInvalidator evicts every restored and deleted device from i.
+func WithInvalidator(i Invalidator) Option {
+	return func(s *backupService) {
+		s.invalidators = append(s.invalidators, i)
+	}
+}
+
+// WithForgetter drops the relationships of deleted devices from f.
+func WithForgetter(f Forgetter) Option {
+	return func(s *backupService) {
+		s.forgetters = append(s.forgetters, f)
+	}
+}
+
+// NewService works on storage directly, so that restored devices keep every field.
+// Without options restores skip the caches and guards built on the service.
+func NewService(storage service.Storage, options ...Option) Service {
+	s := &backupService{storage: storage}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+type backupService struct {
+	storage      service.Storage
+	freeze       freeze.Service
+	invalidators []Invalidator
+	forgetters   []Forgetter
+}
+
+// Snapshot patches the devices Range returns with the changes made while it ran,
+// which makes them the registry as of the version read after it.
+func (s *backupService) Snapshot() (model.Snapshot, error) {
+	for i := 0; i < snapshotAttempts; i++ {
+		before := s.storage.ResourceVersion()
+		devices := make(map[string]model.Device)
+		s.storage.Range(func(d model.Device) bool {
+			devices[d.SerialNum] = d
+			return true
+		})
+		after := s.storage.ResourceVersion()
+
+		changes, _, ok := s.storage.Changes(before)
+		if !ok {
+			continue
+		}
+		for _, c := range changes {
+			if c.ResourceVersion <= after {
+				Apply(devices, c)
+			}
+		}
+		return model.Snapshot{ResourceVersion: after, Devices: sorted(devices)}, nil
+	}
+	return model.Snapshot{}, ErrSnapshotBusy
+}
+
+func (s *backupService) Changes(rv uint64) (model.ChangeSet, error) {
+	changes, _, ok := s.storage.Changes(rv)
+	if !ok {
+		return model.ChangeSet{}, service.ErrResourceVersionGone
+	}
+	set := model.ChangeSet{From: rv, ResourceVersion: rv, Changes: make([]model.Change, 0, len(changes))}
+	set.Changes = append(set.Changes, changes...)
+	if len(changes) > 0 {
+		set.ResourceVersion = changes[len(changes)-1].ResourceVersion
+	}
+	return set, nil
+}
+
+func (s *backupService) Restore(r model.Restore) (model.RestoreResult, error) {
+	// nothing is written unless the whole restore passes
+	for _, d := range r.Devices {
+		if d.SerialNum == "" {
+			return model.RestoreResult{}, service.ErrInvalidSerialNumber
+		}
+		if err := service.ValidateDevice(d); err != nil {
+			return model.RestoreResult{}, fmt.Errorf("%s: %w", d.SerialNum, err)
+		}
+		if err := s.checkFrozen(d); err != nil {
+			return model.RestoreResult{}, err
+		}
+		if old, ok := s.storage.Get(d.SerialNum); ok {
+			if err := s.checkFrozen(old); err != nil {
+				return model.RestoreResult{}, err
+			}
+		}
+	}
+	for _, num := range r.Delete {
+		if old, ok := s.storage.Get(num); ok {
+			if err := s.checkFrozen(old); err != nil {
+				return model.RestoreResult{}, err
+			}
+		}
+	}
+
+	var result model.RestoreResult
+	for _, d := range r.Devices {
+		s.storage.Add(d)
+		s.invalidate(d.SerialNum)
+		result.Restored++
+	}
+	for _, num := range r.Delete {
+		if s.storage.Del(num) {
+			result.Deleted++
+		}
+		s.invalidate(num)
+		for _, f := range s.forgetters {
+			f.Forget(num)
+		}
+	}
+	result.ResourceVersion = s.storage.ResourceVersion()
+	return result, nil
+}
+
+func (s *backupService) checkFrozen(d model.Device) error {
+	if s.freeze == nil {
+		return nil
+	}
+	if w, frozen := s.freeze.Frozen(d); frozen {
+		return fmt.Errorf("%w for %s by window %s", freeze.ErrFrozen, d.SerialNum, w.ID)
+	}
+	return nil
+}
+
+func (s *backupService) invalidate(num string) {
+	for _, i := range s.invalidators {
+		i.Invalidate(num)
+	}
+}
+
+// Apply replays a change on devices by serial number.
+func Apply(devices map[string]model.Device, c model.Change) {
+	if c.Type == model.ChangeDeleted {
+		delete(devices, c.Device.SerialNum)
+		return
+	}
+	devices[c.Device.SerialNum] = c.Device
+}
+
+func sorted(devices map[string]model.Device) []model.Device {
+	list := make([]model.Device, 0, len(devices))
+	for _, d := range devices {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SerialNum < list[j].SerialNum })
+	return list
+}
@@ -0,0 +1,211 @@
+package backup
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/freeze"
+	"homework/internal/model"
+	"homework/internal/service"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func device(num, ip string) model.Device {
+	return model.Device{SerialNum: num, Model: "switch", IP: ip}
+}
+
+func TestSnapshotConsistent(t *testing.T) {
+	storage := service.NewStorage()
+	s := NewService(storage)
+
+	// writers keep a pair of devices in step, a consistent snapshot always has both at the same IP
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ip := "10.0.0." + string(rune('0'+i%10))
+			storage.Add(device("a", ip))
+			storage.Add(device("b", ip))
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		snapshot, err := s.Snapshot()
+		if err != nil {
+			assert.ErrorIs(t, err, ErrSnapshotBusy)
+			continue
+		}
+		if len(snapshot.Devices) == 2 && snapshot.ResourceVersion%2 == 0 {
+			assert.Equal(t, snapshot.Devices[0].IP, snapshot.Devices[1].IP)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestChangesAndRestore(t *testing.T) {
+	storage := service.NewStorage()
+	s := NewService(storage)
+	storage.Add(device("a", "1.1.1.1"))
+	storage.Add(device("b", "1.1.1.2"))
+
+	changes, err := s.Changes(1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), changes.From)
+	assert.Equal(t, uint64(2), changes.ResourceVersion)
+	assert.Len(t, changes.Changes, 1)
+
+	result, err := s.Restore(model.Restore{Devices: []model.Device{device("c", "1.1.1.3")}, Delete: []string{"a", "none"}})
+	assert.Nil(t, err)
+	assert.Equal(t, model.RestoreResult{Restored: 1, Deleted: 1, ResourceVersion: 4}, result)
+
+	_, err = s.Restore(model.Restore{Devices: []model.Device{{}}, Delete: []string{"b"}})
+	assert.ErrorIs(t, err, service.ErrInvalidSerialNumber)
+	_, ok := storage.Get("b")
+	assert.True(t, ok)
+	_, err = s.Restore(model.Restore{Devices: []model.Device{device("c", "9.9.9.9"), device("d", "none")}})
+	assert.ErrorIs(t, err, service.ErrInvalidIPAddress)
+	c, _ := storage.Get("c")
+	assert.Equal(t, "1.1.1.3", c.IP)
+}
+
+type recorder []string
+
+func (r *recorder) Invalidate(num string) { *r = append(*r, "invalidate "+num) }
+func (r *recorder) Forget(num string)     { *r = append(*r, "forget "+num) }
+
+func TestRestoreGuards(t *testing.T) {
+	storage := service.NewStorage()
+	storage.Add(device("a", "1.1.1.1"))
+	storage.Add(model.Device{SerialNum: "core-1", Model: "core", IP: "1.1.1.2"})
+	f := freeze.NewService(nil, freeze.WithClock(func() time.Time { return now }))
+	_, err := f.CreateWindow(model.Window{Kind: model.WindowFreeze, Start: now.Add(-time.Hour), End: now.Add(time.Hour), Model: "core"}, "")
+	assert.Nil(t, err)
+	var calls recorder
+	s := NewService(storage, WithFreeze(f), WithInvalidator(&calls), WithForgetter(&calls))
+
+	_, err = s.Restore(model.Restore{Devices: []model.Device{device("b", "1.1.1.3")}, Delete: []string{"core-1"}})
+	assert.ErrorIs(t, err, freeze.ErrFrozen)
+	_, ok := storage.Get("b")
+	assert.False(t, ok)
+	_, err = s.Restore(model.Restore{Devices: []model.Device{{SerialNum: "a", Model: "core", IP: "1.1.1.1"}}})
+	assert.ErrorIs(t, err, freeze.ErrFrozen)
+	assert.Empty(t, calls)
+
+	_, err = s.Restore(model.Restore{Devices: []model.Device{device("b", "1.1.1.3")}, Delete: []string{"a"}})
+	assert.Nil(t, err)
+	assert.Equal(t, recorder{"invalidate b", "invalidate a", "forget a"}, calls)
+}
+
+func TestArchive(t *testing.T) {
+	snapshot := model.Snapshot{ResourceVersion: 7, Devices: []model.Device{device("a", "1.1.1.1")}}
+	key := bytes.Repeat([]byte{1}, KeySize)
+
+	for _, key := range [][]byte{nil, key} {
+		var buf bytes.Buffer
+		assert.Nil(t, Write(&buf, NewFull(snapshot, now), key))
+
+		a, err := Read(bytes.NewReader(buf.Bytes()), key)
+		assert.Nil(t, err)
+		assert.Equal(t, snapshot, a.Snapshot)
+		assert.Equal(t, now, a.Header.CreatedAt)
+		assert.Equal(t, 1, a.Header.Devices)
+
+		// flip a payload bit
+		corrupt := bytes.Clone(buf.Bytes())
+		corrupt[len(corrupt)-1] ^= 1
+		_, err = Read(bytes.NewReader(corrupt), key)
+		assert.ErrorIs(t, err, ErrChecksum)
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, Write(&buf, NewFull(snapshot, now), key))
+	a, err := Read(bytes.NewReader(buf.Bytes()), nil)
+	assert.ErrorIs(t, err, ErrKeyRequired)
+	assert.Equal(t, uint64(7), a.Header.ResourceVersion)
+	// a plaintext checksum would let anyone confirm a guess of the contents
+	assert.Empty(t, a.Header.SHA256)
+	assert.NotContains(t, buf.String(), `"sha256"`)
+	_, err = Read(bytes.NewReader(buf.Bytes()), bytes.Repeat([]byte{2}, KeySize))
+	assert.ErrorIs(t, err, ErrChecksum)
+	assert.NotContains(t, buf.String(), "1.1.1.1")
+
+	_, err = Read(bytes.NewReader([]byte("devices\n")), nil)
+	assert.ErrorIs(t, err, ErrNotArchive)
+}
+
+func TestKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.key")
+	assert.Nil(t, GenerateKey(path))
+	assert.NotNil(t, GenerateKey(path))
+
+	key, err := LoadKey(path)
+	assert.Nil(t, err)
+	assert.Len(t, key, KeySize)
+
+	_, err = LoadKey(filepath.Join("testdata", "none.key"))
+	assert.NotNil(t, err)
+}
+
+func change(rv uint64, t model.ChangeType, d model.Device) model.Change {
+	return model.Change{Type: t, ResourceVersion: rv, Device: d}
+}
+
+func TestStateAt(t *testing.T) {
+	archives := []Archive{
+		NewFull(model.Snapshot{ResourceVersion: 2, Devices: []model.Device{device("a", "1.1.1.1"), device("b", "1.1.1.2")}}, now),
+		NewIncremental(model.ChangeSet{From: 2, ResourceVersion: 4, Changes: []model.Change{
+			change(3, model.ChangeModified, device("a", "2.2.2.2")),
+			change(4, model.ChangeDeleted, device("b", "1.1.1.2")),
+		}}, now),
+		NewIncremental(model.ChangeSet{From: 4, ResourceVersion: 5, Changes: []model.Change{
+			change(5, model.ChangeAdded, device("c", "1.1.1.3")),
+		}}, now),
+		NewIncremental(model.ChangeSet{From: 8, ResourceVersion: 9, Changes: []model.Change{
+			change(9, model.ChangeAdded, device("d", "1.1.1.4")),
+		}}, now),
+	}
+
+	tests := []struct {
+		at      uint64
+		want    []model.Device
+		wantErr error
+	}{
+		{2, []model.Device{device("a", "1.1.1.1"), device("b", "1.1.1.2")}, nil},
+		{3, []model.Device{device("a", "2.2.2.2"), device("b", "1.1.1.2")}, nil},
+		{5, []model.Device{device("a", "2.2.2.2"), device("c", "1.1.1.3")}, nil},
+		// the last archive doesn't follow on
+		{0, []model.Device{device("a", "2.2.2.2"), device("c", "1.1.1.3")}, nil},
+		{9, nil, ErrNotCovered},
+		{1, nil, ErrNotCovered},
+	}
+	for _, tt := range tests {
+		s, err := StateAt(archives, tt.at)
+		assert.ErrorIs(t, err, tt.wantErr, tt.at)
+		if tt.wantErr == nil {
+			assert.Equal(t, tt.want, s.Devices, tt.at)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	current := model.Snapshot{Devices: []model.Device{device("a", "1.1.1.1"), device("b", "1.1.1.2"), device("x", "9.9.9.9")}}
+	target := model.Snapshot{Devices: []model.Device{device("a", "1.1.1.1"), device("b", "2.2.2.2"), device("c", "1.1.1.3")}}
+
+	assert.Equal(t, model.Restore{
+		Devices: []model.Device{device("b", "2.2.2.2"), device("c", "1.1.1.3")},
+		Delete:  []string{"x"},
+	}, Plan(current, target, nil))
+	assert.Equal(t, model.Restore{Devices: []model.Device{device("c", "1.1.1.3")}}, Plan(current, target, []string{"a", "c"}))
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"reflect"
+	"sort"
+)
+
+var ErrNotCovered = errors.New("no backup covers the resource version")
+
+// StateAt rebuilds the registry as of resource version at, or the newest version the
+// archives reach if at is 0: the newest full snapshot not after it, brought forward
+// with the contiguous incremental archives that follow.
+func StateAt(archives []Archive, at uint64) (model.Snapshot, error) {
+	var base *Archive
+	for i, a := range archives {
+		if a.Header.Kind != KindFull || at != 0 && a.Header.ResourceVersion > at {
+			continue
+		}
+		if base == nil || a.Header.ResourceVersion > base.Header.ResourceVersion {
+			base = &archives[i]
+		}
+	}
+	if base == nil {
+		return model.Snapshot{}, fmt.Errorf("%w %d: no full backup before it", ErrNotCovered, at)
+	}
+
+	devices := make(map[string]model.Device, len(base.Snapshot.Devices))
+	for _, d := range base.Snapshot.Devices {
+		devices[d.SerialNum] = d
+	}
+
+	var incremental []Archive
+	for _, a := range archives {
+		if a.Header.Kind == KindIncremental {
+			incremental = append(incremental, a)
+		}
+	}
+	sort.Slice(incremental, func(i, j int) bool { return incremental[i].Header.From < incremental[j].Header.From })
+
+	covered := base.Header.ResourceVersion
+	for _, a := range incremental {
+		if at != 0 && covered >= at {
+			break
+		}
+		if a.Header.ResourceVersion <= covered {
+			continue
+		}
+		if a.Header.From > covered {
+			// a gap in the log
+			break
+		}
+		for _, c := range a.Changes.Changes {
+			if c.ResourceVersion <= covered {
+				continue
+			}
+			if at != 0 && c.ResourceVersion > at {
+				break
+			}
+			Apply(devices, c)
+		}
+		covered = a.Header.ResourceVersion
+	}
+
+	if at == 0 {
+		at = covered
+	}
+	if covered < at {
+		return model.Snapshot{}, fmt.Errorf("%w %d: backups reach %d", ErrNotCovered, at, covered)
+	}
+	return model.Snapshot{ResourceVersion: at, Devices: sorted(devices)}, nil
+}
+
+// Plan returns the restore turning current into target. With serials only those devices
+// are restored, and deleted if target doesn't have them. Unchanged devices are left out.
+func Plan(current, target model.Snapshot, serials []string) model.Restore {
+	now := make(map[string]model.Device, len(current.Devices))
+	for _, d := range current.Devices {
+		now[d.SerialNum] = d
+	}
+	want := make(map[string]model.Device, len(target.Devices))
+	for _, d := range target.Devices {
+		want[d.SerialNum] = d
+	}
+
+	selected := func(string) bool { return true }
+	if len(serials) > 0 {
+		set := make(map[string]bool, len(serials))
+		for _, s := range serials {
+			set[s] = true
+		}
+		selected = func(num string) bool { return set[num] }
+	}
+
+	var r model.Restore
+	for _, d := range target.Devices {
+		if old, ok := now[d.SerialNum]; selected(d.SerialNum) && (!ok || !reflect.DeepEqual(old, d)) {
+			r.Devices = append(r.Devices, d)
+		}
+	}
+	for _, d := range current.Devices {
+		if _, ok := want[d.SerialNum]; !ok && selected(d.SerialNum) {
+			r.Delete = append(r.Delete, d.SerialNum)
+		}
+	}
+	return r
+}
@@ -0,0 +1,268 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/service"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrModelAlreadyExists = errors.New("model already exists")
+	ErrModelDoesNotExist  = errors.New("model doesn't exist")
+	ErrSchemaDoesNotExist = errors.New("schema version doesn't exist")
+)
+
+// Model is a device model known to the catalog.
+type Model struct {
+	Name          string `json:"name"`
+	Vendor        string `json:"vendor,omitempty"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+// SchemaVersion is a registered attribute schema of a model.
+type SchemaVersion struct {
+	Model     string          `json:"model"`
+	Version   int             `json:"version"`
+	Schema    json.RawMessage `json:"schema"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ValidationError lists every attribute that failed schema validation.
+type ValidationError struct {
+	Model   string       `json:"model"`
+	Version int          `json:"version"`
+	Errors  []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Path+" "+fe.Message)
+	}
+	return fmt.Sprintf("%s: %s", service.ErrInvalidAttributes, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return service.ErrInvalidAttributes
+}
+
+// MigrationReport shows which devices would fail validation against a candidate schema.
+type MigrationReport struct {
+	Model      string             `json:"model"`
+	Checked    int                `json:"checked"`
+	Compatible bool               `json:"compatible"`
+	Failures   []MigrationFailure `json:"failures"`
+}
+
+type MigrationFailure struct {
+	SerialNum     string       `json:"serial_number"`
+	SchemaVersion int          `json:"schema_version"`
+	Errors        []FieldError `json:"errors"`
+}
+
+type Service interface {
+	RegisterModel(m Model) error
+	GetModel(name string) (Model, error)
+	ListModels() []Model
+	// RegisterSchema adds a new attribute schema version for a model and makes it current.
+	RegisterSchema(modelName string, raw json.RawMessage) (SchemaVersion, error)
+	// GetSchema returns a schema version of a model, the current one if version is 0.
+	GetSchema(modelName string, version int) (SchemaVersion, error)
+	// CheckMigration validates the attributes of every device of a model against a candidate schema.
+	CheckMigration(modelName string, raw json.RawMessage) (MigrationReport, error)
+	service.AttributeValidator
+}
+
+// NewService creates an empty catalog. Devices are used by CheckMigration and
+// may be set later with SetDevices, since the device service itself validates through the catalog.
+func NewService() *CatalogService {
+	return &CatalogService{models: make(map[string]*catalogModel)}
+}
+
+type CatalogService struct {
+	devices service.Service
+
+	mu     sync.RWMutex
+	models map[string]*catalogModel
+}
+
+type catalogModel struct {
+	Model
+	schemas []compiledSchema
+}
+
+type compiledSchema struct {
+	SchemaVersion
+	schema *Schema
+}
+
+func (s *CatalogService) SetDevices(devices service.Service) {
+	s.mu.Lock()
+	s.devices = devices
+	s.mu.Unlock()
+}
+
+func (s *CatalogService) RegisterModel(m Model) error {
+	if m.Name == "" {
+		return service.ErrInvalidModel
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.models[m.Name]; ok {
+		return ErrModelAlreadyExists
+	}
+	m.SchemaVersion = 0
+	s.models[m.Name] = &catalogModel{Model: m}
+	return nil
+}
+
+func (s *CatalogService) GetModel(name string) (Model, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.models[name]
+	if !ok {
+		return Model{}, ErrModelDoesNotExist
+	}
+	return m.Model, nil
+}
+
+func (s *CatalogService) ListModels() []Model {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	models := make([]Model, 0, len(s.models))
+	for _, m := range s.models {
+		models = append(models, m.Model)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models
+}
+
+func (s *CatalogService) RegisterSchema(modelName string, raw json.RawMessage) (SchemaVersion, error) {
+	compiled, err := Compile(raw)
+	if err != nil {
+		return SchemaVersion{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.models[modelName]
+	if !ok {
+		return SchemaVersion{}, ErrModelDoesNotExist
+	}
+
+	v := SchemaVersion{
+		Model:     modelName,
+		Version:   len(m.schemas) + 1,
+		Schema:    append(json.RawMessage(nil), raw...),
+		CreatedAt: time.Now().UTC(),
+	}
+	m.schemas = append(m.schemas, compiledSchema{SchemaVersion: v, schema: compiled})
+	m.SchemaVersion = v.Version
+	return v, nil
+}
+
+func (s *CatalogService) GetSchema(modelName string, version int) (SchemaVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cs, err := s.schema(modelName, version)
+	if err != nil {
+		return SchemaVersion{}, err
+	}
+	return cs.SchemaVersion, nil
+}
+
+func (s *CatalogService) schema(modelName string, version int) (compiledSchema, error) {
+	m, ok := s.models[modelName]
+	if !ok {
+		return compiledSchema{}, ErrModelDoesNotExist
+	}
+	if version == 0 {
+		version = m.SchemaVersion
+	}
+	if version < 1 || version > len(m.schemas) {
+		return compiledSchema{}, ErrSchemaDoesNotExist
+	}
+	return m.schemas[version-1], nil
+}
+
+// ValidateAttributes checks attrs against the current schema of the model.
+// Models without a schema, or not in the catalog at all, accept any attributes.
+func (s *CatalogService) ValidateAttributes(modelName string, attrs map[string]any) (int, error) {
+	s.mu.RLock()
+	cs, err := s.schema(modelName, 0)
+	s.mu.RUnlock()
+	if err != nil {
+		return 0, nil
+	}
+
+	if errs := cs.schema.Validate(attributesDoc(attrs)); len(errs) > 0 {
+		return 0, &ValidationError{Model: modelName, Version: cs.Version, Errors: errs}
+	}
+	return cs.Version, nil
+}
+
+func (s *CatalogService) CheckMigration(modelName string, raw json.RawMessage) (MigrationReport, error) {
+	compiled, err := Compile(raw)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+
+	s.mu.RLock()
+	_, ok := s.models[modelName]
+	devices := s.devices
+	s.mu.RUnlock()
+	if !ok {
+		return MigrationReport{}, ErrModelDoesNotExist
+	}
+
+	report := MigrationReport{Model: modelName, Failures: []MigrationFailure{}}
+	if devices == nil {
+		report.Compatible = true
+		return report, nil
+	}
+
+	list, err := devices.ListDevices(service.Filter{Model: modelName})
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	for _, d := range list {
+		report.Checked++
+		if errs := compiled.Validate(attributesDoc(d.Attributes)); len(errs) > 0 {
+			report.Failures = append(report.Failures, MigrationFailure{
+				SerialNum:     d.SerialNum,
+				SchemaVersion: d.SchemaVersion,
+				Errors:        errs,
+			})
+		}
+	}
+	report.Compatible = len(report.Failures) == 0
+	return report, nil
+}
+
+// attributesDoc normalizes attributes to the form encoding/json decodes into,
+// so schemas see the same values regardless of how the device was built.
+func attributesDoc(attrs map[string]any) any {
+	if attrs == nil {
+		return map[string]any{}
+	}
+	raw, err := json.Marshal(attrs)
+	if err != nil {
+		return attrs
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return attrs
+	}
+	return doc
+}
@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"homework/internal/service"
+	"testing"
+)
+
+const switchSchema = `{
+	"type": "object",
+	"required": ["ports"],
+	"additionalProperties": false,
+	"properties": {
+		"ports": {"type": "integer", "minimum": 1, "maximum": 96},
+		"uplinks": {"type": "array", "items": {"type": "string", "pattern": "^eth[0-9]+$"}, "maxItems": 4},
+		"poe": {"type": "boolean"},
+		"role": {"enum": ["access", "core"]}
+	}
+}`
+
+func newTestCatalog(t *testing.T) (*CatalogService, service.Service) {
+	c := NewService()
+	devices := service.NewService(service.NewStorage(), service.WithAttributeValidator(c))
+	c.SetDevices(devices)
+
+	assert.Nil(t, c.RegisterModel(Model{Name: "switch", Vendor: "acme"}))
+	return c, devices
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`[]`,
+		`{"type": "decimal"}`,
+		`{"minLength": -1}`,
+		`{"pattern": "("}`,
+		`{"properties": {"a": 1}}`,
+		`{"$ref": "#/definitions/port"}`,
+		`{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+		`{"properties": {"a": {"type": "string", "format": "ipv4"}}}`,
+		`{"items": {"not": {"const": 0}}}`,
+	} {
+		_, err := Compile([]byte(raw))
+		assert.ErrorIs(t, err, ErrInvalidSchema, raw)
+	}
+}
+
+func TestCompileAnnotations(t *testing.T) {
+	_, err := Compile([]byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "switch", "properties": {"ports": {"type": "integer", "description": "port count", "default": 48}}}`))
+	assert.Nil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(switchSchema))
+	assert.Nil(t, err)
+
+	var doc any
+	_ = json.Unmarshal([]byte(`{"ports": 48.5, "uplinks": ["eth1", "ge-0"], "role": "edge", "color": "red"}`), &doc)
+
+	assert.Equal(t, []FieldError{
+		{Path: "/color", Message: "is not allowed"},
+		{Path: "/ports", Message: "expected integer, got number"},
+		{Path: "/role", Message: "must be one of [access core]"},
+		{Path: "/uplinks/1", Message: `must match pattern "^eth[0-9]+$"`},
+	}, s.Validate(doc))
+
+	_ = json.Unmarshal([]byte(`{"uplinks": []}`), &doc)
+	assert.Equal(t, []FieldError{{Path: "/ports", Message: "is required"}}, s.Validate(doc))
+
+	_ = json.Unmarshal([]byte(`{"ports": 48, "poe": true}`), &doc)
+	assert.Empty(t, s.Validate(doc))
+}
+
+func TestDeviceAttributesValidated(t *testing.T) {
+	c, devices := newTestCatalog(t)
+
+	d := model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1", Attributes: map[string]any{"ports": 200}}
+	assert.Nil(t, devices.CreateDevice(d))
+
+	v, err := c.RegisterSchema("switch", json.RawMessage(switchSchema))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Version)
+
+	d.SerialNum = "2"
+	err = devices.CreateDevice(d)
+	assert.ErrorIs(t, err, service.ErrInvalidAttributes)
+
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []FieldError{{Path: "/ports", Message: "must be <= 96"}}, validationErr.Errors)
+
+	_, err = devices.GetDevice("2")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+
+	d.Attributes = map[string]any{"ports": 48}
+	assert.Nil(t, devices.CreateDevice(d))
+
+	got, _ := devices.GetDevice("2")
+	assert.Equal(t, 1, got.SchemaVersion)
+
+	got.Attributes = map[string]any{}
+	assert.ErrorIs(t, devices.UpdateDevice(got), service.ErrInvalidAttributes)
+
+	d = model.Device{SerialNum: "3", Model: "display", IP: "1.1.1.1", Attributes: map[string]any{"any": "thing"}}
+	assert.Nil(t, devices.CreateDevice(d))
+}
+
+func TestSchemaVersions(t *testing.T) {
+	c, _ := newTestCatalog(t)
+
+	_, err := c.GetSchema("switch", 0)
+	assert.ErrorIs(t, err, ErrSchemaDoesNotExist)
+
+	_, _ = c.RegisterSchema("switch", json.RawMessage(`{"type": "object"}`))
+	_, _ = c.RegisterSchema("switch", json.RawMessage(switchSchema))
+
+	v, err := c.GetSchema("switch", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v.Version)
+
+	v, err = c.GetSchema("switch", 1)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"type": "object"}`, string(v.Schema))
+
+	m, _ := c.GetModel("switch")
+	assert.Equal(t, 2, m.SchemaVersion)
+
+	_, err = c.RegisterSchema("router", json.RawMessage(switchSchema))
+	assert.ErrorIs(t, err, ErrModelDoesNotExist)
+	assert.ErrorIs(t, c.RegisterModel(Model{Name: "switch"}), ErrModelAlreadyExists)
+}
+
+func TestCheckMigration(t *testing.T) {
+	c, devices := newTestCatalog(t)
+
+	_ = devices.CreateDevice(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1", Attributes: map[string]any{"ports": 24}})
+	_ = devices.CreateDevice(model.Device{SerialNum: "2", Model: "switch", IP: "1.1.1.1", Attributes: map[string]any{"ports": 48, "poe": true}})
+	_ = devices.CreateDevice(model.Device{SerialNum: "3", Model: "display", IP: "1.1.1.1"})
+
+	report, err := c.CheckMigration("switch", json.RawMessage(`{"type": "object", "required": ["poe"]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Checked)
+	assert.False(t, report.Compatible)
+	assert.Equal(t, []MigrationFailure{
+		{SerialNum: "1", Errors: []FieldError{{Path: "/poe", Message: "is required"}}},
+	}, report.Failures)
+
+	report, err = c.CheckMigration("switch", json.RawMessage(switchSchema))
+	assert.Nil(t, err)
+	assert.True(t, report.Compatible)
+}
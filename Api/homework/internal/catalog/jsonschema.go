@@ -0,0 +1,350 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Schema is a compiled subset of JSON Schema: type, enum, const, properties,
+// required, additionalProperties, items, minimum/maximum (and their exclusive forms),
+// minLength/maxLength, pattern and minItems/maxItems. Compile rejects any other keyword
+// but annotations, so a schema never silently validates less than it says.
+type Schema struct {
+	Types                []string
+	Enum                 []any
+	Const                any
+	HasConst             bool
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties *Schema
+	NoAdditional         bool
+	Items                *Schema
+	Minimum              *float64
+	Maximum              *float64
+	ExclusiveMinimum     *float64
+	ExclusiveMaximum     *float64
+	MinLength            *int
+	MaxLength            *int
+	Pattern              *regexp.Regexp
+	MinItems             *int
+	MaxItems             *int
+}
+
+// keywords are the keywords Compile implements, or accepts as annotations
+// that don't affect validation.
+var keywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true, "items": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"minLength": true, "maxLength": true, "pattern": true, "minItems": true, "maxItems": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true,
+}
+
+// FieldError describes a single validation failure. Path is a JSON pointer to the value.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Compile parses a JSON Schema document.
+func Compile(raw []byte) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return compile(doc, "")
+}
+
+func compile(doc any, path string) (*Schema, error) {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: schema must be an object", ErrInvalidSchema, pointer(path))
+	}
+
+	s := &Schema{}
+	fail := func(keyword string) error {
+		return fmt.Errorf("%w: %s: invalid %q", ErrInvalidSchema, pointer(path), keyword)
+	}
+
+	names := make([]string, 0, len(obj))
+	for k := range obj {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if !keywords[k] {
+			return nil, fmt.Errorf("%w: %s: unsupported keyword %q", ErrInvalidSchema, pointer(path), k)
+		}
+	}
+
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		s.Types = []string{t}
+	case []any:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fail("type")
+			}
+			s.Types = append(s.Types, name)
+		}
+	default:
+		return nil, fail("type")
+	}
+	for _, t := range s.Types {
+		switch t {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return nil, fail("type")
+		}
+	}
+
+	if v, ok := obj["enum"]; ok {
+		enum, ok := v.([]any)
+		if !ok {
+			return nil, fail("enum")
+		}
+		s.Enum = enum
+	}
+	if v, ok := obj["const"]; ok {
+		s.Const, s.HasConst = v, true
+	}
+
+	if v, ok := obj["properties"]; ok {
+		props, ok := v.(map[string]any)
+		if !ok {
+			return nil, fail("properties")
+		}
+		s.Properties = make(map[string]*Schema, len(props))
+		for name, sub := range props {
+			compiled, err := compile(sub, path+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = compiled
+		}
+	}
+	if v, ok := obj["required"]; ok {
+		required, ok := v.([]any)
+		if !ok {
+			return nil, fail("required")
+		}
+		for _, r := range required {
+			name, ok := r.(string)
+			if !ok {
+				return nil, fail("required")
+			}
+			s.Required = append(s.Required, name)
+		}
+	}
+	switch v := obj["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		s.NoAdditional = !v
+	default:
+		compiled, err := compile(v, path+"/additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+		s.AdditionalProperties = compiled
+	}
+	if v, ok := obj["items"]; ok {
+		compiled, err := compile(v, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		s.Items = compiled
+	}
+
+	numbers := map[string]**float64{
+		"minimum":          &s.Minimum,
+		"maximum":          &s.Maximum,
+		"exclusiveMinimum": &s.ExclusiveMinimum,
+		"exclusiveMaximum": &s.ExclusiveMaximum,
+	}
+	for keyword, dst := range numbers {
+		if v, ok := obj[keyword]; ok {
+			n, ok := v.(float64)
+			if !ok {
+				return nil, fail(keyword)
+			}
+			*dst = &n
+		}
+	}
+
+	counts := map[string]**int{
+		"minLength": &s.MinLength,
+		"maxLength": &s.MaxLength,
+		"minItems":  &s.MinItems,
+		"maxItems":  &s.MaxItems,
+	}
+	for keyword, dst := range counts {
+		if v, ok := obj[keyword]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != float64(int(n)) {
+				return nil, fail(keyword)
+			}
+			c := int(n)
+			*dst = &c
+		}
+	}
+
+	if v, ok := obj["pattern"]; ok {
+		p, ok := v.(string)
+		if !ok {
+			return nil, fail("pattern")
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fail("pattern")
+		}
+		s.Pattern = re
+	}
+
+	return s, nil
+}
+
+// Validate checks v, a value decoded by encoding/json, and returns every failure found.
+func (s *Schema) Validate(v any) []FieldError {
+	var errs []FieldError
+	s.validate(v, "", &errs)
+	return errs
+}
+
+func (s *Schema) validate(v any, path string, errs *[]FieldError) {
+	add := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{Path: pointer(path), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Types) > 0 && !s.matchesType(v) {
+		add("expected %s, got %s", joinTypes(s.Types), typeOf(v))
+		return
+	}
+	if s.HasConst && !reflect.DeepEqual(v, s.Const) {
+		add("must be %v", s.Const)
+	}
+	if s.Enum != nil {
+		found := false
+		for _, e := range s.Enum {
+			found = found || reflect.DeepEqual(v, e)
+		}
+		if !found {
+			add("must be one of %v", s.Enum)
+		}
+	}
+
+	switch val := v.(type) {
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			add("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			add("must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && val <= *s.ExclusiveMinimum {
+			add("must be > %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && val >= *s.ExclusiveMaximum {
+			add("must be < %v", *s.ExclusiveMaximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			add("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(val) {
+			add("must match pattern %q", s.Pattern.String())
+		}
+	case []any:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			add("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			add("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(item, path+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				*errs = append(*errs, FieldError{Path: pointer(path + "/" + name), Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if sub, ok := s.Properties[k]; ok {
+				sub.validate(val[k], path+"/"+k, errs)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(val[k], path+"/"+k, errs)
+			} else if s.NoAdditional {
+				*errs = append(*errs, FieldError{Path: pointer(path + "/" + k), Message: "is not allowed"})
+			}
+		}
+	}
+}
+
+func (s *Schema) matchesType(v any) bool {
+	actual := typeOf(v)
+	for _, t := range s.Types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == float64(int64(val)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
+
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
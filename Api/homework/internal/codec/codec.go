@@ -0,0 +1,205 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotAcceptable        = errors.New("not acceptable")
+)
+
+// Codec encodes and decodes API resources in a single media type.
+// Every codec uses the JSON field names of the resources.
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+	// Decode reads exactly one document from r into v.
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	JSON        Codec = jsonCodec{}
+	YAML        Codec = &tree{contentType: "application/yaml", marshal: marshalYAML, unmarshal: unmarshalYAML}
+	XML         Codec = &tree{contentType: "application/xml", marshal: marshalXML, unmarshal: unmarshalXML}
+	MessagePack Codec = &tree{contentType: "application/msgpack", marshal: marshalMessagePack, unmarshal: unmarshalMessagePack}
+)
+
+// codecs maps every accepted media type, including the unofficial aliases, to its codec.
+var codecs = map[string]Codec{
+	"application/json":        JSON,
+	"application/yaml":        YAML,
+	"application/x-yaml":      YAML,
+	"text/yaml":               YAML,
+	"application/xml":         XML,
+	"text/xml":                XML,
+	"application/msgpack":     MessagePack,
+	"application/x-msgpack":   MessagePack,
+	"application/vnd.msgpack": MessagePack,
+}
+
+// ranges maps the accepted media ranges to their codecs, in order of preference.
+// text/* stands for the text/yaml and text/xml aliases.
+var ranges = map[string][]Codec{
+	"*/*":           {JSON, YAML, XML, MessagePack},
+	"application/*": {JSON, YAML, XML, MessagePack},
+	"text/*":        {YAML, XML},
+}
+
+// ForContentType returns the codec of a Content-Type header. A request without one is JSON.
+func ForContentType(header string) (Codec, error) {
+	if header == "" {
+		return JSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, header)
+	}
+	if c, ok := codecs[mediaType]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
+
+// Negotiate picks the codec for an Accept header by quality, then by order.
+// Wildcards choose the first codec of the range not excluded with q=0, JSON unless
+// excluded, and a request without the header gets JSON.
+func Negotiate(accept string) (Codec, error) {
+	if strings.TrimSpace(accept) == "" {
+		return JSON, nil
+	}
+
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	// excluded holds the codecs of media types with q=0, which no wildcard selects,
+	// and rangeExcluded those of ranges with q=0, which only the broader */* doesn't select.
+	excluded := make(map[Codec]bool)
+	rangeExcluded := make(map[Codec]bool)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+			continue
+		}
+		if codec, ok := codecs[mediaType]; ok {
+			excluded[codec] = true
+		} else if mediaType != "*/*" {
+			for _, codec := range ranges[mediaType] {
+				rangeExcluded[codec] = true
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if codec, ok := codecs[c.mediaType]; ok {
+			return codec, nil
+		}
+		for _, codec := range ranges[c.mediaType] {
+			if !excluded[codec] && !(c.mediaType == "*/*" && rangeExcluded[codec]) {
+				return codec, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotAcceptable, accept)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailingData
+	}
+	return nil
+}
+
+// tree is a codec of a format converted to and from the generic form encoding/json
+// produces, so resources keep their JSON field names and custom JSON marshaling.
+type tree struct {
+	contentType string
+	marshal     func(w io.Writer, doc any) error
+	unmarshal   func(r io.Reader) (any, error)
+}
+
+func (c *tree) ContentType() string {
+	return c.contentType
+}
+
+func (c *tree) Encode(w io.Writer, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	return c.marshal(w, numbers(doc))
+}
+
+func (c *tree) Decode(r io.Reader, v any) error {
+	doc, err := c.unmarshal(r)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// numbers replaces json.Number with int64 or float64, which the other formats encode as numbers.
+func numbers(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return doc
+}
@@ -0,0 +1,101 @@
+package codec
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	d := model.Device{
+		SerialNum:  "1",
+		Model:      "panel",
+		IP:         "1.1.1.1",
+		Labels:     map[string]string{"app.example.com/rack": "a b"},
+		Attributes: map[string]any{"size": float64(3), "ratio": 0.5, "ok": true, "none": nil, "ports": []any{"a", float64(2)}},
+		State:      model.StateActive,
+		LastTransition: &model.Transition{
+			From: model.StateReceived, To: model.StateActive, Reason: "<ready>", At: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+
+	for _, c := range []Codec{JSON, YAML, XML, MessagePack} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Nil(t, c.Encode(&buf, []model.Device{d}))
+
+			var got []model.Device
+			assert.Nil(t, c.Decode(&buf, &got))
+			assert.Equal(t, []model.Device{d}, got)
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		codec Codec
+		body  string
+	}{
+		{JSON, `{"model":"a"} {"model":"b"}`},
+		{JSON, ``},
+		{YAML, "model: a\n---\nmodel: b\n"},
+		{XML, `<map><string key="model">a</string></map><map/>`},
+		{XML, `<map><string>a</string></map>`},
+		{XML, `<map><number key="n">0x10</number></map>`},
+		{XML, `<object/>`},
+	}
+	for _, tt := range tests {
+		var d model.Device
+		assert.NotNil(t, tt.codec.Decode(strings.NewReader(tt.body), &d), tt.body)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Codec
+	}{
+		{"", JSON},
+		{"*/*", JSON},
+		{"application/yaml", YAML},
+		{"text/html, application/xml;q=0.9, */*;q=0.1", XML},
+		{"application/json;q=0.5, application/msgpack", MessagePack},
+		{"application/x-msgpack;q=0, application/yaml;q=0.2", YAML},
+		{"application/json;q=0, */*", YAML},
+		{"application/json;q=0, application/yaml;q=0, application/*", XML},
+		{"text/*", YAML},
+		{"text/yaml;q=0, text/*", XML},
+		{"text/*;q=0, */*", JSON},
+		{"application/*;q=0, */*;q=0.5, application/msgpack;q=0.1", MessagePack},
+	}
+	for _, tt := range tests {
+		c, err := Negotiate(tt.accept)
+		assert.Nil(t, err, tt.accept)
+		assert.Equal(t, tt.want, c, tt.accept)
+	}
+
+	for _, accept := range []string{
+		"text/html, application/json;q=0",
+		"application/*;q=0, */*",
+		"text/yaml;q=0, text/xml;q=0, text/*",
+	} {
+		_, err := Negotiate(accept)
+		assert.ErrorIs(t, err, ErrNotAcceptable, accept)
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	c, err := ForContentType("application/json; charset=utf-8")
+	assert.Nil(t, err)
+	assert.Equal(t, JSON, c)
+
+	c, err = ForContentType("text/xml")
+	assert.Nil(t, err)
+	assert.Equal(t, XML, c)
+
+	_, err = ForContentType("application/x-www-form-urlencoded")
+	assert.ErrorIs(t, err, ErrUnsupportedMediaType)
+}
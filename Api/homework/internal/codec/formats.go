@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"errors"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+var errTrailingData = errors.New("unexpected data after the document")
+
+func marshalYAML(w io.Writer, doc any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func unmarshalYAML(r io.Reader) (any, error) {
+	dec := yaml.NewDecoder(r)
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var next any
+	if err := dec.Decode(&next); err != io.EOF {
+		return nil, errTrailingData
+	}
+	return doc, nil
+}
+
+func marshalMessagePack(w io.Writer, doc any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	return enc.Encode(doc)
+}
+
+func unmarshalMessagePack(r io.Reader) (any, error) {
+	dec := msgpack.NewDecoder(r)
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var next any
+	if err := dec.Decode(&next); err != io.EOF {
+		return nil, errTrailingData
+	}
+	return doc, nil
+}
@@ -0,0 +1,203 @@
+package codec
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xmlNamespace is the namespace of the XPath json-to-xml mapping: objects are
+// <map> elements whose members carry a key attribute, arrays are <array>, and
+// scalars are <string>, <number>, <boolean> and <null>. Unlike named elements it
+// keeps label and attribute keys that aren't valid XML names, and the value types.
+const xmlNamespace = "http://www.w3.org/2005/xpath-functions"
+
+func marshalXML(w io.Writer, doc any) error {
+	enc := xml.NewEncoder(w)
+	root := []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: xmlNamespace}}
+	if err := writeXML(enc, doc, root); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeXML(enc *xml.Encoder, doc any, attrs []xml.Attr) error {
+	start := xml.StartElement{Attr: attrs}
+	switch v := doc.(type) {
+	case map[string]any:
+		start.Name.Local = "map"
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := writeXML(enc, v[k], []xml.Attr{{Name: xml.Name{Local: "key"}, Value: k}}); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case []any:
+		start.Name.Local = "array"
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, e := range v {
+			if err := writeXML(enc, e, nil); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		start.Name.Local = "null"
+		return enc.EncodeElement("", start)
+	case string:
+		start.Name.Local = "string"
+		return enc.EncodeElement(v, start)
+	case bool:
+		start.Name.Local = "boolean"
+		return enc.EncodeElement(strconv.FormatBool(v), start)
+	case int64:
+		start.Name.Local = "number"
+		return enc.EncodeElement(strconv.FormatInt(v, 10), start)
+	case float64:
+		start.Name.Local = "number"
+		return enc.EncodeElement(strconv.FormatFloat(v, 'g', -1, 64), start)
+	default:
+		return fmt.Errorf("xml: unsupported value %T", doc)
+	}
+}
+
+func unmarshalXML(r io.Reader) (any, error) {
+	dec := xml.NewDecoder(r)
+	var doc any
+	found := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if found {
+				return nil, errTrailingData
+			}
+			if doc, err = readXML(dec, t); err != nil {
+				return nil, err
+			}
+			found = true
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				return nil, errors.New("xml: text outside of the document element")
+			}
+		}
+	}
+	if !found {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return doc, nil
+}
+
+// readXML reads the element opened by start, the decoder checks that elements are balanced.
+func readXML(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "map":
+		m := map[string]any{}
+		err := readChildren(dec, func(child xml.StartElement) error {
+			key, ok := attr(child, "key")
+			if !ok {
+				return fmt.Errorf("xml: <%s> in <map> without a key", child.Name.Local)
+			}
+			v, err := readXML(dec, child)
+			m[key] = v
+			return err
+		}, nil)
+		return m, err
+	case "array":
+		a := []any{}
+		err := readChildren(dec, func(child xml.StartElement) error {
+			v, err := readXML(dec, child)
+			a = append(a, v)
+			return err
+		}, nil)
+		return a, err
+	}
+
+	var text strings.Builder
+	if err := readChildren(dec, func(child xml.StartElement) error {
+		return fmt.Errorf("xml: <%s> in <%s>", child.Name.Local, start.Name.Local)
+	}, &text); err != nil {
+		return nil, err
+	}
+	s := text.String()
+
+	switch start.Name.Local {
+	case "string":
+		return s, nil
+	case "number":
+		s = strings.TrimSpace(s)
+		var f float64
+		if err := json.Unmarshal([]byte(s), &f); err != nil {
+			return nil, fmt.Errorf("xml: invalid number %q", s)
+		}
+		return json.Number(s), nil
+	case "boolean":
+		switch strings.TrimSpace(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("xml: invalid boolean %q", s)
+	case "null":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("xml: unknown element <%s>", start.Name.Local)
+}
+
+// readChildren calls child for every nested element until the end of the current one.
+// Text is collected into text, or must be whitespace if text is nil.
+func readChildren(dec *xml.Decoder, child func(xml.StartElement) error, text *strings.Builder) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := child(t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if text != nil {
+				text.Write(t)
+			} else if strings.TrimSpace(string(t)) != "" {
+				return errors.New("xml: text between elements")
+			}
+		}
+	}
+}
+
+func attr(e xml.StartElement, name string) (string, bool) {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,543 @@
+// Package config loads the device server configuration from defaults,
+// a YAML file, environment variables and command-line flags, in that order.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Storage backends
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Decorator layers
+const (
+	LayerLogging = "logging"
+	LayerMetrics = "metrics"
+	LayerTimeout = "timeout"
+	LayerTracing = "tracing"
+)
+
+type Config struct {
+	HTTP        HTTP        `yaml:"http"`
+	Storage     Storage     `yaml:"storage"`
+	Auth        Auth        `yaml:"auth"`
+	Limits      Limits      `yaml:"limits"`
+	Log         Log         `yaml:"log"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Replication Replication `yaml:"replication"`
+	Prometheus  Prometheus  `yaml:"prometheus"`
+	Topology    Topology    `yaml:"topology"`
+	Retention   Retention   `yaml:"retention"`
+	Report      Report      `yaml:"report"`
+	Decorators  Decorators  `yaml:"decorators"`
+	RESP        RESP        `yaml:"resp"`
+	Freeze      Freeze      `yaml:"freeze"`
+}
+
+type HTTP struct {
+	Host              string        `yaml:"host"`
+	Port              string        `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	// WriteTimeout also cuts watch and event streams, so it is off by default.
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownDelay keeps serving with /readyz failing before shutdown starts,
+	// so load balancers stop sending new requests first.
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type Storage struct {
+	// Backend is memory or file.
+	Backend string `yaml:"backend"`
+	// Path is the journal of the file backend.
+	Path string `yaml:"path"`
+	// Sync syncs the journal to disk after every write, so acknowledged writes survive power loss.
+	Sync  bool  `yaml:"sync"`
+	Cache Cache `yaml:"cache"`
+}
+
+// Cache enables the device cache when Size is positive.
+type Cache struct {
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+// Auth enables bearer token authentication when Tokens is not empty.
+type Auth struct {
+	Tokens []string `yaml:"tokens"`
+	// OverrideTokens are the only tokens that may change devices during freezes
+	// with X-Override-Reason, when authentication is enabled. They and AdminTokens
+	// are the only ones that may create and delete windows.
+	OverrideTokens []string `yaml:"override_tokens"`
+	// AdminTokens are the only tokens allowed on /v1/admin/, which can restore and wipe
+	// the registry, when authentication is enabled.
+	AdminTokens []string `yaml:"admin_tokens"`
+}
+
+// Limits are off when zero.
+type Limits struct {
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	MaxInFlight  int   `yaml:"max_in_flight"`
+	// RequestsPerSecond and Burst limit the request rate of the whole server.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is json or text.
+	Format string `yaml:"format"`
+}
+
+type Tracing struct {
+	// Exporter is none, stdout or otlp-file.
+	Exporter string `yaml:"exporter"`
+	File     string `yaml:"file"`
+}
+
+type Replication struct {
+	// LeaderURL makes this instance a follower of the leader at the URL.
+	LeaderURL     string `yaml:"leader_url"`
+	ForwardWrites bool   `yaml:"forward_writes"`
+	// Token is the bearer token sent to a leader with authentication enabled.
+	Token string `yaml:"token"`
+}
+
+type Prometheus struct {
+	Port       int            `yaml:"port"`
+	ModelPorts map[string]int `yaml:"model_ports"`
+}
+
+type Topology struct {
+	// OnDelete is block or cascade: deleting a device with links or contained devices
+	// fails, or deletes the contained devices too.
+	OnDelete string `yaml:"on_delete"`
+	// Path is the file links and containments are kept in, empty keeps them in memory only.
+	Path string `yaml:"path"`
+}
+
+type Retention struct {
+	// Interval is how often expired devices are reaped, 0 disables reaping.
+	Interval time.Duration `yaml:"interval"`
+	// Action is delete or trash, for device expiries and policies without an action.
+	Action string `yaml:"action"`
+}
+
+type Report struct {
+	// Interval is how often the consistency report runs in the background, 0 disables it.
+	Interval time.Duration `yaml:"interval"`
+	// Subnets are the CIDRs device IPs must be in, empty skips the check.
+	Subnets []string `yaml:"subnets"`
+	// SerialFormats maps vendors to regular expressions their serial numbers match in full.
+	// It is only set by the config file.
+	SerialFormats map[string]string `yaml:"serial_formats"`
+	// HeartbeatTimeout flags active devices reporting less often, 0 skips the check.
+	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout"`
+}
+
+// Decorators lists the layers wrapped around the device service and storage, innermost first.
+type Decorators struct {
+	// Service layers are logging, metrics, timeout and tracing.
+	Service []string `yaml:"service"`
+	// Storage layers are logging, metrics and tracing.
+	Storage []string `yaml:"storage"`
+	// Timeout limits reading service calls when the timeout layer is on; writes run to completion.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RESP enables the Redis protocol front-end when Addr is set. It requires AUTH with auth.tokens.
+type RESP struct {
+	Addr string `yaml:"addr"`
+	// IdleTimeout closes connections not completing a command in time.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	MaxConns    int           `yaml:"max_conns"`
+}
+
+type Freeze struct {
+	// AuditLog keeps freeze overrides and window changes across restarts, with the windows
+	// in a file next to it, e.g. audit.windows.json for audit.jsonl. Empty keeps them in memory only.
+	AuditLog string `yaml:"audit_log"`
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Host:              "localhost",
+			Port:              "8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Storage:   Storage{Backend: StorageMemory, Path: "devices.jsonl", Sync: true, Cache: Cache{TTL: time.Minute}},
+		Limits:    Limits{MaxBodyBytes: 1 << 20},
+		Log:       Log{Level: "info", Format: "json"},
+		Tracing:   Tracing{Exporter: "none", File: "traces.jsonl"},
+		Topology:  Topology{OnDelete: "block"},
+		Retention: Retention{Interval: time.Minute, Action: "delete"},
+		RESP:      RESP{IdleTimeout: 5 * time.Minute, MaxConns: 1024},
+		Decorators: Decorators{
+			Service: []string{LayerTracing},
+			Storage: []string{LayerTracing},
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c Config) Address() string {
+	return net.JoinHostPort(c.HTTP.Host, c.HTTP.Port)
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Storage.Backend != StorageMemory && c.Storage.Backend != StorageFile {
+		errs = append(errs, fmt.Errorf("storage.backend: unknown backend %q", c.Storage.Backend))
+	}
+	if c.Storage.Backend == StorageFile && c.Storage.Path == "" {
+		errs = append(errs, errors.New("storage.path: required by the file backend"))
+	}
+	if c.Limits.RequestsPerSecond < 0 || c.Limits.Burst < 0 || c.Limits.MaxInFlight < 0 || c.Limits.MaxBodyBytes < 0 {
+		errs = append(errs, errors.New("limits: must not be negative"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format: unknown format %q", c.Log.Format))
+	}
+	if c.Topology.OnDelete != "block" && c.Topology.OnDelete != "cascade" {
+		errs = append(errs, fmt.Errorf("topology.on_delete: unknown policy %q", c.Topology.OnDelete))
+	}
+	if c.Report.Interval < 0 || c.Report.HeartbeatTimeout < 0 {
+		errs = append(errs, errors.New("report: intervals must not be negative"))
+	}
+	for _, subnet := range c.Report.Subnets {
+		if _, err := netip.ParsePrefix(subnet); err != nil {
+			errs = append(errs, fmt.Errorf("report.subnets: %w", err))
+		}
+	}
+	for vendor, expr := range c.Report.SerialFormats {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = append(errs, fmt.Errorf("report.serial_formats.%s: %w", vendor, err))
+		}
+	}
+	if c.Retention.Interval < 0 {
+		errs = append(errs, errors.New("retention.interval: must not be negative"))
+	}
+	if c.Retention.Action != "delete" && c.Retention.Action != "trash" {
+		errs = append(errs, fmt.Errorf("retention.action: unknown action %q", c.Retention.Action))
+	}
+	errs = append(errs, validateLayers("decorators.service", c.Decorators.Service, LayerLogging, LayerMetrics, LayerTimeout, LayerTracing))
+	errs = append(errs, validateLayers("decorators.storage", c.Decorators.Storage, LayerLogging, LayerMetrics, LayerTracing))
+	if c.RESP.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.RESP.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("resp.addr: %w", err))
+		}
+	}
+	if c.RESP.IdleTimeout <= 0 || c.RESP.MaxConns <= 0 {
+		errs = append(errs, errors.New("resp: idle_timeout and max_conns must be positive"))
+	}
+	if len(c.Auth.OverrideTokens) > 0 && len(c.Auth.Tokens) == 0 {
+		errs = append(errs, errors.New("auth.override_tokens: require auth.tokens"))
+	}
+	if len(c.Auth.AdminTokens) > 0 && len(c.Auth.Tokens) == 0 {
+		errs = append(errs, errors.New("auth.admin_tokens: require auth.tokens"))
+	}
+	if c.Decorators.Timeout <= 0 {
+		errs = append(errs, errors.New("decorators.timeout: must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateLayers(field string, layers []string, known ...string) error {
+	var errs []error
+	for _, layer := range layers {
+		if !slices.Contains(known, layer) {
+			errs = append(errs, fmt.Errorf("%s: unknown layer %q", field, layer))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load builds the configuration from defaults, the YAML file given by
+// -config or CONFIG_FILE, environment variables and then flags in args.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("homework", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	path := fs.String("config", getenv("CONFIG_FILE"), "YAML config file")
+	flags := cfg.flags(fs)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.loadEnv(getenv); err != nil {
+		return cfg, err
+	}
+	// only flags given explicitly override file and env
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		apply, ok := flags[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		if e := apply(f.Value.String()); e != nil {
+			err = fmt.Errorf("-%s: %w", f.Name, e)
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Usage prints the command-line flags.
+func Usage(w io.Writer) {
+	cfg := Default()
+	fs := flag.NewFlagSet("homework", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.String("config", "", "YAML config file, also CONFIG_FILE")
+	cfg.flags(fs)
+	fs.PrintDefaults()
+}
+
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// setter parses a string into a config field.
+type setter func(string) error
+
+func str(p *string) setter {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func duration(p *time.Duration) setter {
+	return func(v string) (err error) {
+		*p, err = time.ParseDuration(v)
+		return err
+	}
+}
+
+func integer(p *int) setter {
+	return func(v string) (err error) {
+		*p, err = strconv.Atoi(v)
+		return err
+	}
+}
+
+func integer64(p *int64) setter {
+	return func(v string) (err error) {
+		*p, err = strconv.ParseInt(v, 10, 64)
+		return err
+	}
+}
+
+func float(p *float64) setter {
+	return func(v string) (err error) {
+		*p, err = strconv.ParseFloat(v, 64)
+		return err
+	}
+}
+
+func boolean(p *bool) setter {
+	return func(v string) (err error) {
+		*p, err = strconv.ParseBool(v)
+		return err
+	}
+}
+
+func list(p *[]string) setter {
+	return func(v string) error {
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+		return nil
+	}
+}
+
+// ports parses "switch=9116,display=9100".
+func ports(p *map[string]int) setter {
+	return func(v string) error {
+		m := make(map[string]int)
+		for _, pair := range strings.Split(v, ",") {
+			name, port, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(port)
+			if err != nil {
+				return err
+			}
+			m[strings.TrimSpace(name)] = n
+		}
+		*p = m
+		return nil
+	}
+}
+
+// env lists environment variables and the fields they set.
+func (c *Config) env() map[string]setter {
+	return map[string]setter{
+		"HTTP_HOST":                  str(&c.HTTP.Host),
+		"HTTP_PORT":                  str(&c.HTTP.Port),
+		"HTTP_READ_HEADER_TIMEOUT":   duration(&c.HTTP.ReadHeaderTimeout),
+		"HTTP_READ_TIMEOUT":          duration(&c.HTTP.ReadTimeout),
+		"HTTP_WRITE_TIMEOUT":         duration(&c.HTTP.WriteTimeout),
+		"HTTP_IDLE_TIMEOUT":          duration(&c.HTTP.IdleTimeout),
+		"HTTP_SHUTDOWN_DELAY":        duration(&c.HTTP.ShutdownDelay),
+		"HTTP_SHUTDOWN_TIMEOUT":      duration(&c.HTTP.ShutdownTimeout),
+		"STORAGE_BACKEND":            str(&c.Storage.Backend),
+		"STORAGE_PATH":               str(&c.Storage.Path),
+		"STORAGE_SYNC":               boolean(&c.Storage.Sync),
+		"CACHE_SIZE":                 integer(&c.Storage.Cache.Size),
+		"CACHE_TTL":                  duration(&c.Storage.Cache.TTL),
+		"AUTH_TOKENS":                list(&c.Auth.Tokens),
+		"LIMIT_MAX_BODY_BYTES":       integer64(&c.Limits.MaxBodyBytes),
+		"LIMIT_MAX_IN_FLIGHT":        integer(&c.Limits.MaxInFlight),
+		"LIMIT_REQUESTS_PER_SECOND":  float(&c.Limits.RequestsPerSecond),
+		"LIMIT_BURST":                integer(&c.Limits.Burst),
+		"LOG_LEVEL":                  str(&c.Log.Level),
+		"LOG_FORMAT":                 str(&c.Log.Format),
+		"TRACE_EXPORTER":             str(&c.Tracing.Exporter),
+		"TRACE_FILE":                 str(&c.Tracing.File),
+		"REPLICATION_LEADER_URL":     str(&c.Replication.LeaderURL),
+		"REPLICATION_FORWARD_WRITES": boolean(&c.Replication.ForwardWrites),
+		"REPLICATION_TOKEN":          str(&c.Replication.Token),
+		"PROMETHEUS_PORT":            integer(&c.Prometheus.Port),
+		"PROMETHEUS_MODEL_PORTS":     ports(&c.Prometheus.ModelPorts),
+		"TOPOLOGY_ON_DELETE":         str(&c.Topology.OnDelete),
+		"TOPOLOGY_PATH":              str(&c.Topology.Path),
+		"RETENTION_INTERVAL":         duration(&c.Retention.Interval),
+		"RETENTION_ACTION":           str(&c.Retention.Action),
+		"REPORT_INTERVAL":            duration(&c.Report.Interval),
+		"REPORT_SUBNETS":             list(&c.Report.Subnets),
+		"REPORT_HEARTBEAT_TIMEOUT":   duration(&c.Report.HeartbeatTimeout),
+		"DECORATE_SERVICE":           list(&c.Decorators.Service),
+		"DECORATE_STORAGE":           list(&c.Decorators.Storage),
+		"DECORATE_TIMEOUT":           duration(&c.Decorators.Timeout),
+		"RESP_ADDR":                  str(&c.RESP.Addr),
+		"RESP_IDLE_TIMEOUT":          duration(&c.RESP.IdleTimeout),
+		"RESP_MAX_CONNS":             integer(&c.RESP.MaxConns),
+		"AUTH_OVERRIDE_TOKENS":       list(&c.Auth.OverrideTokens),
+		"FREEZE_AUDIT_LOG":           str(&c.Freeze.AuditLog),
+		"AUTH_ADMIN_TOKENS":          list(&c.Auth.AdminTokens),
+	}
+}
+
+func (c *Config) loadEnv(getenv func(string) string) error {
+	for name, set := range c.env() {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		if err := set(v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// flags defines a flag per field on fs, with the current values as defaults,
+// and returns the setters by flag name.
+func (c *Config) flags(fs *flag.FlagSet) map[string]setter {
+	flags := map[string]struct {
+		set     setter
+		value   string
+		usage   string
+		envName string
+	}{
+		"host":                {str(&c.HTTP.Host), c.HTTP.Host, "listen host", "HTTP_HOST"},
+		"port":                {str(&c.HTTP.Port), c.HTTP.Port, "listen port", "HTTP_PORT"},
+		"read-header-timeout": {duration(&c.HTTP.ReadHeaderTimeout), c.HTTP.ReadHeaderTimeout.String(), "request header read timeout", "HTTP_READ_HEADER_TIMEOUT"},
+		"read-timeout":        {duration(&c.HTTP.ReadTimeout), c.HTTP.ReadTimeout.String(), "request read timeout", "HTTP_READ_TIMEOUT"},
+		"write-timeout":       {duration(&c.HTTP.WriteTimeout), c.HTTP.WriteTimeout.String(), "response write timeout, cuts streams", "HTTP_WRITE_TIMEOUT"},
+		"idle-timeout":        {duration(&c.HTTP.IdleTimeout), c.HTTP.IdleTimeout.String(), "keep-alive idle timeout", "HTTP_IDLE_TIMEOUT"},
+		"shutdown-delay":      {duration(&c.HTTP.ShutdownDelay), c.HTTP.ShutdownDelay.String(), "time /readyz fails before shutdown", "HTTP_SHUTDOWN_DELAY"},
+		"shutdown-timeout":    {duration(&c.HTTP.ShutdownTimeout), c.HTTP.ShutdownTimeout.String(), "time to drain requests on shutdown", "HTTP_SHUTDOWN_TIMEOUT"},
+		"storage":             {str(&c.Storage.Backend), c.Storage.Backend, "storage backend: memory or file", "STORAGE_BACKEND"},
+		"storage-path":        {str(&c.Storage.Path), c.Storage.Path, "file backend journal", "STORAGE_PATH"},
+		"storage-sync":        {boolean(&c.Storage.Sync), "true", "sync the journal to disk after every write", "STORAGE_SYNC"},
+		"cache-size":          {integer(&c.Storage.Cache.Size), strconv.Itoa(c.Storage.Cache.Size), "device cache size, 0 disables it", "CACHE_SIZE"},
+		"cache-ttl":           {duration(&c.Storage.Cache.TTL), c.Storage.Cache.TTL.String(), "device cache TTL", "CACHE_TTL"},
+		"auth-tokens":         {list(&c.Auth.Tokens), "", "comma-separated bearer tokens, empty disables auth", "AUTH_TOKENS"},
+		"max-body-bytes":      {integer64(&c.Limits.MaxBodyBytes), strconv.FormatInt(c.Limits.MaxBodyBytes, 10), "request body limit", "LIMIT_MAX_BODY_BYTES"},
+		"max-in-flight":       {integer(&c.Limits.MaxInFlight), strconv.Itoa(c.Limits.MaxInFlight), "concurrent request limit", "LIMIT_MAX_IN_FLIGHT"},
+		"rps":                 {float(&c.Limits.RequestsPerSecond), strconv.FormatFloat(c.Limits.RequestsPerSecond, 'g', -1, 64), "request rate limit", "LIMIT_REQUESTS_PER_SECOND"},
+		"burst":               {integer(&c.Limits.Burst), strconv.Itoa(c.Limits.Burst), "request rate burst", "LIMIT_BURST"},
+		"log-level":           {str(&c.Log.Level), c.Log.Level, "debug, info, warn or error", "LOG_LEVEL"},
+		"log-format":          {str(&c.Log.Format), c.Log.Format, "json or text", "LOG_FORMAT"},
+		"trace-exporter":      {str(&c.Tracing.Exporter), c.Tracing.Exporter, "none, stdout or otlp-file", "TRACE_EXPORTER"},
+		"trace-file":          {str(&c.Tracing.File), c.Tracing.File, "otlp-file output", "TRACE_FILE"},
+		"leader-url":          {str(&c.Replication.LeaderURL), "", "follow the leader at this URL", "REPLICATION_LEADER_URL"},
+		"forward-writes":      {boolean(&c.Replication.ForwardWrites), "false", "forward writes to the leader", "REPLICATION_FORWARD_WRITES"},
+		"replication-token":   {str(&c.Replication.Token), "", "bearer token sent to the leader", "REPLICATION_TOKEN"},
+		"topology-on-delete":  {str(&c.Topology.OnDelete), c.Topology.OnDelete, "deleting related devices: block or cascade", "TOPOLOGY_ON_DELETE"},
+		"topology-path":       {str(&c.Topology.Path), c.Topology.Path, "links and containments file, empty keeps them in memory", "TOPOLOGY_PATH"},
+		"retention-interval":  {duration(&c.Retention.Interval), c.Retention.Interval.String(), "expired device reaping interval, 0 disables it", "RETENTION_INTERVAL"},
+		"retention-action":    {str(&c.Retention.Action), c.Retention.Action, "expired devices: delete or trash", "RETENTION_ACTION"},
+		"report-interval":     {duration(&c.Report.Interval), c.Report.Interval.String(), "background consistency report interval, 0 disables it", "REPORT_INTERVAL"},
+		"report-subnets":      {list(&c.Report.Subnets), "", "comma-separated CIDRs device IPs must be in", "REPORT_SUBNETS"},
+		"heartbeat-timeout":   {duration(&c.Report.HeartbeatTimeout), c.Report.HeartbeatTimeout.String(), "report active devices silent for longer, 0 disables it", "REPORT_HEARTBEAT_TIMEOUT"},
+		"decorate-service":    {list(&c.Decorators.Service), strings.Join(c.Decorators.Service, ","), "comma-separated service layers, innermost first: logging, metrics, timeout, tracing", "DECORATE_SERVICE"},
+		"decorate-storage":    {list(&c.Decorators.Storage), strings.Join(c.Decorators.Storage, ","), "comma-separated storage layers, innermost first: logging, metrics, tracing", "DECORATE_STORAGE"},
+		"call-timeout":        {duration(&c.Decorators.Timeout), c.Decorators.Timeout.String(), "read timeout of the timeout layer", "DECORATE_TIMEOUT"},
+		"resp-addr":           {str(&c.RESP.Addr), "", "Redis protocol listen address, empty disables it", "RESP_ADDR"},
+		"resp-idle-timeout":   {duration(&c.RESP.IdleTimeout), c.RESP.IdleTimeout.String(), "closes Redis protocol connections idle for longer", "RESP_IDLE_TIMEOUT"},
+		"resp-max-conns":      {integer(&c.RESP.MaxConns), strconv.Itoa(c.RESP.MaxConns), "maximum open Redis protocol connections", "RESP_MAX_CONNS"},
+		"override-tokens":     {list(&c.Auth.OverrideTokens), "", "comma-separated bearer tokens allowed to override freezes and change windows", "AUTH_OVERRIDE_TOKENS"},
+		"freeze-audit-log":    {str(&c.Freeze.AuditLog), "", "file keeping freeze overrides and window changes, empty keeps them in memory", "FREEZE_AUDIT_LOG"},
+		"admin-tokens":        {list(&c.Auth.AdminTokens), "", "comma-separated bearer tokens allowed on /v1/admin/", "AUTH_ADMIN_TOKENS"},
+	}
+
+	setters := make(map[string]setter, len(flags))
+	for name, f := range flags {
+		fs.String(name, f.value, f.usage+", also "+f.envName)
+		setters[name] = f.set
+	}
+	return setters
+}
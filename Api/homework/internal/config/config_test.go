@@ -0,0 +1,95 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, "localhost:8080", cfg.Address())
+}
+
+func TestPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(`
+http:
+  host: 0.0.0.0
+  port: "9000"
+  read_timeout: 10s
+storage:
+  backend: file
+  path: /var/lib/devices.jsonl
+auth:
+  tokens: [a, b]
+limits:
+  max_in_flight: 100
+prometheus:
+  model_ports:
+    switch: 9116
+`), 0o644))
+
+	cfg, err := Load([]string{"-config", path, "-port", "9100", "-storage", "memory"}, env(map[string]string{
+		"HTTP_PORT":         "9001",
+		"HTTP_READ_TIMEOUT": "20s",
+		"AUTH_TOKENS":       "c, d",
+		"DECORATE_SERVICE":  "logging,timeout, metrics",
+	}))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "0.0.0.0", cfg.HTTP.Host)
+	assert.Equal(t, "9100", cfg.HTTP.Port)
+	assert.Equal(t, 20*time.Second, cfg.HTTP.ReadTimeout)
+	assert.Equal(t, 30*time.Second, cfg.HTTP.ShutdownTimeout)
+	assert.Equal(t, StorageMemory, cfg.Storage.Backend)
+	assert.Equal(t, "/var/lib/devices.jsonl", cfg.Storage.Path)
+	assert.Equal(t, []string{"c", "d"}, cfg.Auth.Tokens)
+	assert.Equal(t, 100, cfg.Limits.MaxInFlight)
+	assert.Equal(t, 9116, cfg.Prometheus.ModelPorts["switch"])
+	assert.Equal(t, []string{LayerLogging, LayerTimeout, LayerMetrics}, cfg.Decorators.Service)
+}
+
+func TestConfigFileFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("log:\n  format: text\n"), 0o644))
+
+	cfg, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}))
+	assert.Nil(t, err)
+	assert.Equal(t, "text", cfg.Log.Format)
+}
+
+func TestInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("http:\n  prot: 1\n"), 0o644))
+
+	_, err := Load([]string{"-config", path}, env(nil))
+	assert.ErrorContains(t, err, "prot")
+
+	_, err = Load([]string{"-read-timeout", "soon"}, env(nil))
+	assert.ErrorContains(t, err, "-read-timeout")
+
+	_, err = Load(nil, env(map[string]string{"CACHE_SIZE": "many"}))
+	assert.ErrorContains(t, err, "CACHE_SIZE")
+
+	_, err = Load([]string{"-storage", "tape", "-log-level", "loud"}, env(nil))
+	assert.ErrorContains(t, err, "storage.backend")
+	assert.ErrorContains(t, err, "log.level")
+
+	_, err = Load(nil, env(map[string]string{"RETENTION_ACTION": "archive"}))
+	assert.ErrorContains(t, err, "retention.action")
+
+	_, err = Load(nil, env(map[string]string{"RESP_ADDR": "6379"}))
+	assert.ErrorContains(t, err, "resp.addr")
+
+	_, err = Load([]string{"-decorate-storage", "metrics,timeout"}, env(nil))
+	assert.ErrorContains(t, err, `decorators.storage: unknown layer "timeout"`)
+}
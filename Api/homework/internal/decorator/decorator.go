@@ -0,0 +1,47 @@
+// Package decorator holds generated logging, metrics and timeout decorators
+// of the device service and storage.
+package decorator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Tracing is left to package tracing, whose decorators add device attributes to the spans.
+//go:generate go run homework/cmd/decorgen -i homework/internal/service.Service -o service_gen.go -d logging,metrics,timeout -r GetDevice,ListDevices,Changes
+//go:generate go run homework/cmd/decorgen -i homework/internal/service.Storage -o storage_gen.go -d logging,metrics
+
+// Observer records calls of decorated methods, e.g. in Prometheus.
+type Observer interface {
+	// ObserveCall records a call of op, such as service.GetDevice, failed if err is not nil.
+	ObserveCall(op string, d time.Duration, err error)
+}
+
+// bind returns v bound to ctx if it is context-aware, see service.Bind.
+func bind[T any](ctx context.Context, v T) T {
+	if b, ok := any(v).(interface{ WithContext(context.Context) T }); ok {
+		return b.WithContext(ctx)
+	}
+	return v
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, op string, args []any, start time.Time, err error) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.Any("args", args),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "call", attrs...)
+}
+
+// timedOut returns the error of a call of op cut by ctx.
+func timedOut(ctx context.Context, op string) error {
+	return fmt.Errorf("%s: %w", op, ctx.Err())
+}
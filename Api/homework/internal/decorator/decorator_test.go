@@ -0,0 +1,97 @@
+package decorator
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"homework/internal/service"
+	"homework/internal/tracing"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type call struct {
+	op  string
+	err error
+}
+
+type observer []call
+
+func (o *observer) ObserveCall(op string, _ time.Duration, err error) {
+	*o = append(*o, call{op, err})
+}
+
+// blocked blocks GetDevice until its context is done.
+type blocked struct {
+	service.Service
+	ctx context.Context
+}
+
+func (b *blocked) WithContext(ctx context.Context) service.Service {
+	return &blocked{Service: b.Service, ctx: ctx}
+}
+
+func (b *blocked) GetDevice(string) (model.Device, error) {
+	<-b.ctx.Done()
+	return model.Device{}, nil
+}
+
+func TestLoggedAndMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var o observer
+
+	storage := MetricsStorage(service.NewStorage(), &o)
+	svc := LoggedService(service.NewService(storage), logger)
+	assert.Nil(t, svc.CreateDevice(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}))
+	_, err := svc.GetDevice("2")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+
+	assert.Contains(t, buf.String(), "level=DEBUG msg=call op=service.CreateDevice")
+	assert.Contains(t, buf.String(), `level=WARN msg=call op=service.GetDevice args=[2]`)
+	assert.Contains(t, buf.String(), `error="device doesn't exist"`)
+	assert.Contains(t, o, call{"storage.Add", nil})
+	assert.Contains(t, o, call{"storage.Get", nil})
+}
+
+func TestTimeout(t *testing.T) {
+	svc := TimeoutService(&blocked{Service: service.NewService(service.NewStorage()), ctx: context.Background()}, 10*time.Millisecond)
+	_, err := svc.GetDevice("1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "service.GetDevice")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = service.Bind(ctx, TimeoutService(&blocked{ctx: context.Background()}, time.Hour)).GetDevice("1")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// calls without an error aren't limited
+	assert.Equal(t, uint64(0), svc.ResourceVersion())
+}
+
+func TestBindsThrough(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var o observer
+	storage := tracing.Storage(service.NewStorage())
+	svc := service.NewService(LoggedStorage(MetricsStorage(storage, &o), slog.Default()))
+	svc = tracing.Service(TimeoutService(MetricsService(svc, &o), time.Second))
+
+	ctx, span := tracing.Start(context.Background(), "request")
+	_, _ = service.Bind(ctx, svc).GetDevice("1")
+	span.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	assert.Equal(t, spans["request"].SpanContext().SpanID(), spans["service.GetDevice"].Parent().SpanID())
+	assert.Equal(t, spans["service.GetDevice"].SpanContext().SpanID(), spans["storage.Get"].Parent().SpanID())
+}
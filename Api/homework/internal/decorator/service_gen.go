@@ -0,0 +1,296 @@
+// Code generated by decorgen; DO NOT EDIT.
+
+package decorator
+
+import (
+	"context"
+	"homework/internal/model"
+	"homework/internal/service"
+	"log/slog"
+	"time"
+)
+
+// LoggedService logs the calls of next with their arguments and duration,
+// at debug level or warn level if they fail.
+func LoggedService(next service.Service, logger *slog.Logger) service.Service {
+	return &loggedService{next: next, ctx: context.Background(), logger: logger}
+}
+
+type loggedService struct {
+	next   service.Service
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+func (s *loggedService) WithContext(ctx context.Context) service.Service {
+	return &loggedService{next: bind(ctx, s.next), ctx: ctx, logger: s.logger}
+}
+
+func (s *loggedService) GetDevice(a0 string) (model.Device, error) {
+	start := time.Now()
+	r0, r1 := s.next.GetDevice(a0)
+	logCall(s.ctx, s.logger, "service.GetDevice", []any{a0}, start, r1)
+	return r0, r1
+}
+
+func (s *loggedService) CreateDevice(a0 model.Device) error {
+	start := time.Now()
+	r0 := s.next.CreateDevice(a0)
+	logCall(s.ctx, s.logger, "service.CreateDevice", []any{a0}, start, r0)
+	return r0
+}
+
+func (s *loggedService) RestoreDevice(a0 model.Device) error {
+	start := time.Now()
+	r0 := s.next.RestoreDevice(a0)
+	logCall(s.ctx, s.logger, "service.RestoreDevice", []any{a0}, start, r0)
+	return r0
+}
+
+func (s *loggedService) DeleteDevice(a0 string) error {
+	start := time.Now()
+	r0 := s.next.DeleteDevice(a0)
+	logCall(s.ctx, s.logger, "service.DeleteDevice", []any{a0}, start, r0)
+	return r0
+}
+
+func (s *loggedService) UpdateDevice(a0 model.Device) error {
+	start := time.Now()
+	r0 := s.next.UpdateDevice(a0)
+	logCall(s.ctx, s.logger, "service.UpdateDevice", []any{a0}, start, r0)
+	return r0
+}
+
+func (s *loggedService) TransitionDevice(a0 string, a1 model.State, a2 string) error {
+	start := time.Now()
+	r0 := s.next.TransitionDevice(a0, a1, a2)
+	logCall(s.ctx, s.logger, "service.TransitionDevice", []any{a0, a1, a2}, start, r0)
+	return r0
+}
+
+func (s *loggedService) SetFirmware(a0 string, a1 string) error {
+	start := time.Now()
+	r0 := s.next.SetFirmware(a0, a1)
+	logCall(s.ctx, s.logger, "service.SetFirmware", []any{a0, a1}, start, r0)
+	return r0
+}
+
+func (s *loggedService) ListDevices(a0 service.Filter) ([]model.Device, error) {
+	start := time.Now()
+	r0, r1 := s.next.ListDevices(a0)
+	logCall(s.ctx, s.logger, "service.ListDevices", []any{a0}, start, r1)
+	return r0, r1
+}
+
+func (s *loggedService) ResourceVersion() uint64 {
+	start := time.Now()
+	r0 := s.next.ResourceVersion()
+	logCall(s.ctx, s.logger, "service.ResourceVersion", []any{}, start, nil)
+	return r0
+}
+
+func (s *loggedService) Changes(a0 uint64) ([]model.Change, <-chan struct{}, error) {
+	start := time.Now()
+	r0, r1, r2 := s.next.Changes(a0)
+	logCall(s.ctx, s.logger, "service.Changes", []any{a0}, start, r2)
+	return r0, r1, r2
+}
+
+// MetricsService observes the duration and outcome of the calls of next.
+func MetricsService(next service.Service, o Observer) service.Service {
+	return &metricsService{next: next, o: o}
+}
+
+type metricsService struct {
+	next service.Service
+	o    Observer
+}
+
+func (s *metricsService) WithContext(ctx context.Context) service.Service {
+	return &metricsService{next: bind(ctx, s.next), o: s.o}
+}
+
+func (s *metricsService) GetDevice(a0 string) (model.Device, error) {
+	start := time.Now()
+	r0, r1 := s.next.GetDevice(a0)
+	s.o.ObserveCall("service.GetDevice", time.Since(start), r1)
+	return r0, r1
+}
+
+func (s *metricsService) CreateDevice(a0 model.Device) error {
+	start := time.Now()
+	r0 := s.next.CreateDevice(a0)
+	s.o.ObserveCall("service.CreateDevice", time.Since(start), r0)
+	return r0
+}
+
+func (s *metricsService) RestoreDevice(a0 model.Device) error {
+	start := time.Now()
+	r0 := s.next.RestoreDevice(a0)
+	s.o.ObserveCall("service.RestoreDevice", time.Since(start), r0)
+	return r0
+}
+
+func (s *metricsService) DeleteDevice(a0 string) error {
+	start := time.Now()
+	r0 := s.next.DeleteDevice(a0)
+	s.o.ObserveCall("service.DeleteDevice", time.Since(start), r0)
+	return r0
+}
+
+func (s *metricsService) UpdateDevice(a0 model.Device) error {
+	start := time.Now()
+	r0 := s.next.UpdateDevice(a0)
+	s.o.ObserveCall("service.UpdateDevice", time.Since(start), r0)
+	return r0
+}
+
+func (s *metricsService) TransitionDevice(a0 string, a1 model.State, a2 string) error {
+	start := time.Now()
+	r0 := s.next.TransitionDevice(a0, a1, a2)
+	s.o.ObserveCall("service.TransitionDevice", time.Since(start), r0)
+	return r0
+}
+
+func (s *metricsService) SetFirmware(a0 string, a1 string) error {
+	start := time.Now()
+	r0 := s.next.SetFirmware(a0, a1)
+	s.o.ObserveCall("service.SetFirmware", time.Since(start), r0)
+	return r0
+}
+
+func (s *metricsService) ListDevices(a0 service.Filter) ([]model.Device, error) {
+	start := time.Now()
+	r0, r1 := s.next.ListDevices(a0)
+	s.o.ObserveCall("service.ListDevices", time.Since(start), r1)
+	return r0, r1
+}
+
+func (s *metricsService) ResourceVersion() uint64 {
+	start := time.Now()
+	r0 := s.next.ResourceVersion()
+	s.o.ObserveCall("service.ResourceVersion", time.Since(start), nil)
+	return r0
+}
+
+func (s *metricsService) Changes(a0 uint64) ([]model.Change, <-chan struct{}, error) {
+	start := time.Now()
+	r0, r1, r2 := s.next.Changes(a0)
+	s.o.ObserveCall("service.Changes", time.Since(start), r2)
+	return r0, r1, r2
+}
+
+// TimeoutService fails the read-only calls of next once timeout passes, or once
+// the bound context is done. Timed out calls keep running in the background, so
+// writes, which would still be applied, and calls not returning an error aren't limited.
+func TimeoutService(next service.Service, timeout time.Duration) service.Service {
+	return &timeoutService{next: next, ctx: context.Background(), timeout: timeout}
+}
+
+type timeoutService struct {
+	next    service.Service
+	ctx     context.Context
+	timeout time.Duration
+}
+
+func (s *timeoutService) WithContext(ctx context.Context) service.Service {
+	return &timeoutService{next: s.next, ctx: ctx, timeout: s.timeout}
+}
+
+func (s *timeoutService) GetDevice(a0 string) (model.Device, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	type result struct {
+		r0 model.Device
+		r1 error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		res.r0, res.r1 = bind(ctx, s.next).GetDevice(a0)
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		return res.r0, res.r1
+	case <-ctx.Done():
+		var res result
+		res.r1 = timedOut(ctx, "service.GetDevice")
+		return res.r0, res.r1
+	}
+}
+
+func (s *timeoutService) CreateDevice(a0 model.Device) error {
+	return bind(s.ctx, s.next).CreateDevice(a0)
+}
+
+func (s *timeoutService) RestoreDevice(a0 model.Device) error {
+	return bind(s.ctx, s.next).RestoreDevice(a0)
+}
+
+func (s *timeoutService) DeleteDevice(a0 string) error {
+	return bind(s.ctx, s.next).DeleteDevice(a0)
+}
+
+func (s *timeoutService) UpdateDevice(a0 model.Device) error {
+	return bind(s.ctx, s.next).UpdateDevice(a0)
+}
+
+func (s *timeoutService) TransitionDevice(a0 string, a1 model.State, a2 string) error {
+	return bind(s.ctx, s.next).TransitionDevice(a0, a1, a2)
+}
+
+func (s *timeoutService) SetFirmware(a0 string, a1 string) error {
+	return bind(s.ctx, s.next).SetFirmware(a0, a1)
+}
+
+func (s *timeoutService) ListDevices(a0 service.Filter) ([]model.Device, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	type result struct {
+		r0 []model.Device
+		r1 error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		res.r0, res.r1 = bind(ctx, s.next).ListDevices(a0)
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		return res.r0, res.r1
+	case <-ctx.Done():
+		var res result
+		res.r1 = timedOut(ctx, "service.ListDevices")
+		return res.r0, res.r1
+	}
+}
+
+func (s *timeoutService) ResourceVersion() uint64 {
+	return bind(s.ctx, s.next).ResourceVersion()
+}
+
+func (s *timeoutService) Changes(a0 uint64) ([]model.Change, <-chan struct{}, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+	type result struct {
+		r0 []model.Change
+		r1 <-chan struct{}
+		r2 error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		res.r0, res.r1, res.r2 = bind(ctx, s.next).Changes(a0)
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		return res.r0, res.r1, res.r2
+	case <-ctx.Done():
+		var res result
+		res.r2 = timedOut(ctx, "service.Changes")
+		return res.r0, res.r1, res.r2
+	}
+}
@@ -0,0 +1,123 @@
+// Code generated by decorgen; DO NOT EDIT.
+
+package decorator
+
+import (
+	"context"
+	"homework/internal/model"
+	"homework/internal/service"
+	"log/slog"
+	"time"
+)
+
+// LoggedStorage logs the calls of next with their arguments and duration,
+// at debug level or warn level if they fail.
+func LoggedStorage(next service.Storage, logger *slog.Logger) service.Storage {
+	return &loggedStorage{next: next, ctx: context.Background(), logger: logger}
+}
+
+type loggedStorage struct {
+	next   service.Storage
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+func (s *loggedStorage) WithContext(ctx context.Context) service.Storage {
+	return &loggedStorage{next: bind(ctx, s.next), ctx: ctx, logger: s.logger}
+}
+
+func (s *loggedStorage) Add(a0 model.Device) bool {
+	start := time.Now()
+	r0 := s.next.Add(a0)
+	logCall(s.ctx, s.logger, "storage.Add", []any{a0}, start, nil)
+	return r0
+}
+
+func (s *loggedStorage) Get(a0 string) (model.Device, bool) {
+	start := time.Now()
+	r0, r1 := s.next.Get(a0)
+	logCall(s.ctx, s.logger, "storage.Get", []any{a0}, start, nil)
+	return r0, r1
+}
+
+func (s *loggedStorage) Del(a0 string) bool {
+	start := time.Now()
+	r0 := s.next.Del(a0)
+	logCall(s.ctx, s.logger, "storage.Del", []any{a0}, start, nil)
+	return r0
+}
+
+func (s *loggedStorage) Range(a0 func(d model.Device) bool) {
+	start := time.Now()
+	s.next.Range(a0)
+	logCall(s.ctx, s.logger, "storage.Range", []any{}, start, nil)
+}
+
+func (s *loggedStorage) ResourceVersion() uint64 {
+	start := time.Now()
+	r0 := s.next.ResourceVersion()
+	logCall(s.ctx, s.logger, "storage.ResourceVersion", []any{}, start, nil)
+	return r0
+}
+
+func (s *loggedStorage) Changes(a0 uint64) ([]model.Change, <-chan struct{}, bool) {
+	start := time.Now()
+	r0, r1, r2 := s.next.Changes(a0)
+	logCall(s.ctx, s.logger, "storage.Changes", []any{a0}, start, nil)
+	return r0, r1, r2
+}
+
+// MetricsStorage observes the duration and outcome of the calls of next.
+func MetricsStorage(next service.Storage, o Observer) service.Storage {
+	return &metricsStorage{next: next, o: o}
+}
+
+type metricsStorage struct {
+	next service.Storage
+	o    Observer
+}
+
+func (s *metricsStorage) WithContext(ctx context.Context) service.Storage {
+	return &metricsStorage{next: bind(ctx, s.next), o: s.o}
+}
+
+func (s *metricsStorage) Add(a0 model.Device) bool {
+	start := time.Now()
+	r0 := s.next.Add(a0)
+	s.o.ObserveCall("storage.Add", time.Since(start), nil)
+	return r0
+}
+
+func (s *metricsStorage) Get(a0 string) (model.Device, bool) {
+	start := time.Now()
+	r0, r1 := s.next.Get(a0)
+	s.o.ObserveCall("storage.Get", time.Since(start), nil)
+	return r0, r1
+}
+
+func (s *metricsStorage) Del(a0 string) bool {
+	start := time.Now()
+	r0 := s.next.Del(a0)
+	s.o.ObserveCall("storage.Del", time.Since(start), nil)
+	return r0
+}
+
+func (s *metricsStorage) Range(a0 func(d model.Device) bool) {
+	start := time.Now()
+	s.next.Range(a0)
+	s.o.ObserveCall("storage.Range", time.Since(start), nil)
+}
+
+func (s *metricsStorage) ResourceVersion() uint64 {
+	start := time.Now()
+	r0 := s.next.ResourceVersion()
+	s.o.ObserveCall("storage.ResourceVersion", time.Since(start), nil)
+	return r0
+}
+
+func (s *metricsStorage) Changes(a0 uint64) ([]model.Change, <-chan struct{}, bool) {
+	start := time.Now()
+	r0, r1, r2 := s.next.Changes(a0)
+	s.o.ObserveCall("storage.Changes", time.Since(start), nil)
+	return r0, r1, r2
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultBuffer = 64
+
+type Event struct {
+	Type   string    `json:"type"`
+	Serial string    `json:"serial_number,omitempty"`
+	Data   any       `json:"data,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Bus fans published events out to subscribers. Slow subscribers miss events
+// instead of blocking publishers.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a function that cancels the subscription.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, defaultBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"homework/internal/model"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const (
+	defaultHostTemplate  = "{{.SerialNum}}"
+	defaultGroupTemplate = "{{.Model}}"
+	ungroupedGroup       = "ungrouped"
+)
+
+// reservedGroups are the keys of the Ansible inventory layout itself.
+var reservedGroups = map[string]bool{"all": true, "_meta": true}
+
+// InventoryConfig controls host naming and grouping. Templates are text/template
+// templates executed against model.Device. Every group template that renders
+// a non-empty name puts the device into that group; GroupByLabels also adds
+// a "<key>_<value>" group for each device label.
+type InventoryConfig struct {
+	HostTemplate   string
+	GroupTemplates []string
+	GroupByLabels  bool
+
+	SSHUser      string
+	SSHPort      int
+	IdentityFile string
+}
+
+// Inventory renders devices as an Ansible dynamic inventory or an ssh_config fragment.
+type Inventory struct {
+	cfg    InventoryConfig
+	host   *template.Template
+	groups []*template.Template
+}
+
+func NewInventory(cfg InventoryConfig) (*Inventory, error) {
+	if cfg.HostTemplate == "" {
+		cfg.HostTemplate = defaultHostTemplate
+	}
+	if cfg.GroupTemplates == nil {
+		cfg.GroupTemplates = []string{defaultGroupTemplate}
+	}
+	// ssh_config has no quoting for these, a newline would start a new directive
+	if err := checkSSHValue("user", cfg.SSHUser); err != nil {
+		return nil, err
+	}
+	if err := checkSSHValue("identity file", cfg.IdentityFile); err != nil {
+		return nil, err
+	}
+
+	host, err := template.New("host").Option("missingkey=zero").Parse(cfg.HostTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("host template: %w", err)
+	}
+
+	inv := &Inventory{cfg: cfg, host: host}
+	for i, text := range cfg.GroupTemplates {
+		group, err := template.New(fmt.Sprintf("group%d", i)).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("group template %q: %w", text, err)
+		}
+		inv.groups = append(inv.groups, group)
+	}
+	return inv, nil
+}
+
+// Ansible returns the inventory in the JSON layout Ansible expects from an
+// inventory script called with --list, including _meta.hostvars. Devices
+// rendering the same host name, or a group named all or _meta, are an error.
+func (inv *Inventory) Ansible(devices []model.Device) (map[string]any, error) {
+	hostvars := make(map[string]any, len(devices))
+	groups := make(map[string][]string)
+	serials := make(map[string]string, len(devices))
+
+	for _, d := range devices {
+		name, err := inv.hostName(d)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := serials[name]; ok {
+			return nil, fmt.Errorf("devices %s and %s have the same host name %q", other, d.SerialNum, name)
+		}
+		serials[name] = d.SerialNum
+		hostvars[name] = map[string]any{
+			"ansible_host":  d.IP,
+			"serial_number": d.SerialNum,
+			"model":         d.Model,
+			"firmware":      d.Firmware,
+			"state":         d.State,
+			"labels":        d.Labels,
+		}
+
+		deviceGroups, err := inv.groupNames(d)
+		if err != nil {
+			return nil, err
+		}
+		if len(deviceGroups) == 0 {
+			deviceGroups = []string{ungroupedGroup}
+		}
+		for _, g := range deviceGroups {
+			if reservedGroups[g] {
+				return nil, fmt.Errorf("device %s: group name %q is reserved", d.SerialNum, g)
+			}
+			groups[g] = append(groups[g], name)
+		}
+	}
+
+	res := map[string]any{"_meta": map[string]any{"hostvars": hostvars}}
+	children := make([]string, 0, len(groups))
+	for g, hosts := range groups {
+		sort.Strings(hosts)
+		res[g] = map[string]any{"hosts": hosts}
+		children = append(children, g)
+	}
+	sort.Strings(children)
+	res["all"] = map[string]any{"children": children}
+	return res, nil
+}
+
+// SSHConfig returns a Host block per device, suitable for an ssh_config Include.
+// Devices rendering the same host name are an error, ssh would ignore all but the first.
+func (inv *Inventory) SSHConfig(devices []model.Device) (string, error) {
+	var buf bytes.Buffer
+	serials := make(map[string]string, len(devices))
+	for _, d := range devices {
+		name, err := inv.hostName(d)
+		if err != nil {
+			return "", err
+		}
+		if other, ok := serials[name]; ok {
+			return "", fmt.Errorf("devices %s and %s have the same host name %q", other, d.SerialNum, name)
+		}
+		serials[name] = d.SerialNum
+
+		fmt.Fprintf(&buf, "Host %s\n", name)
+		fmt.Fprintf(&buf, "    HostName %s\n", d.IP)
+		if inv.cfg.SSHUser != "" {
+			fmt.Fprintf(&buf, "    User %s\n", inv.cfg.SSHUser)
+		}
+		if inv.cfg.SSHPort != 0 {
+			fmt.Fprintf(&buf, "    Port %d\n", inv.cfg.SSHPort)
+		}
+		if inv.cfg.IdentityFile != "" {
+			fmt.Fprintf(&buf, "    IdentityFile %s\n", inv.cfg.IdentityFile)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
+
+func (inv *Inventory) hostName(d model.Device) (string, error) {
+	name, err := execute(inv.host, d)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = d.SerialNum
+	}
+	name = strings.Join(strings.Fields(name), "-")
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return "", fmt.Errorf("device %s: host name %q contains control characters", d.SerialNum, name)
+	}
+	// ssh_config would read these as patterns, negations, lists, comments or quoting,
+	// e.g. Host * matches every connection
+	if strings.ContainsAny(name, "*?!,#\"'") {
+		return "", fmt.Errorf("device %s: host name %q must not contain any of *?!,#\"'", d.SerialNum, name)
+	}
+	return name, nil
+}
+
+func checkSSHValue(field, v string) error {
+	if strings.ContainsFunc(v, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return fmt.Errorf("%s %q must not contain whitespace or control characters", field, v)
+	}
+	return nil
+}
+
+func (inv *Inventory) groupNames(d model.Device) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	for _, t := range inv.groups {
+		name, err := execute(t, d)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			add(LabelName(name))
+		}
+	}
+	if inv.cfg.GroupByLabels {
+		for k, v := range d.Labels {
+			add(LabelName(k + "_" + v))
+		}
+	}
+	return names, nil
+}
+
+func execute(t *template.Template, d model.Device) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("template %s: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
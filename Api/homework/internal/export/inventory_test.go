@@ -0,0 +1,110 @@
+package export
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"testing"
+)
+
+var inventoryDevices = []model.Device{
+	{SerialNum: "1", Model: "switch", IP: "10.0.0.1", Labels: map[string]string{"site": "lab", "name": "core sw"}},
+	{SerialNum: "2", Model: "display", IP: "10.0.0.2", Labels: map[string]string{"site": "hq"}},
+}
+
+func TestAnsibleInventory(t *testing.T) {
+	inv, err := NewInventory(InventoryConfig{
+		HostTemplate:   `{{with index .Labels "name"}}{{.}}{{else}}dev-{{.SerialNum}}{{end}}`,
+		GroupTemplates: []string{"{{.Model}}", `{{if eq .Model "switch"}}network{{end}}`},
+		GroupByLabels:  true,
+	})
+	assert.Nil(t, err)
+
+	res, err := inv.Ansible(inventoryDevices)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]any{"hosts": []string{"core-sw"}}, res["switch"])
+	assert.Equal(t, map[string]any{"hosts": []string{"core-sw"}}, res["network"])
+	assert.Equal(t, map[string]any{"hosts": []string{"core-sw"}}, res["site_lab"])
+	assert.Equal(t, map[string]any{"hosts": []string{"dev-2"}}, res["display"])
+	assert.Equal(t, map[string]any{"hosts": []string{"dev-2"}}, res["site_hq"])
+	assert.Equal(t, map[string]any{
+		"children": []string{"display", "name_core_sw", "network", "site_hq", "site_lab", "switch"},
+	}, res["all"])
+
+	hostvars := res["_meta"].(map[string]any)["hostvars"].(map[string]any)
+	assert.Equal(t, "10.0.0.2", hostvars["dev-2"].(map[string]any)["ansible_host"])
+}
+
+func TestAnsibleInventoryUngrouped(t *testing.T) {
+	inv, err := NewInventory(InventoryConfig{GroupTemplates: []string{}})
+	assert.Nil(t, err)
+
+	res, err := inv.Ansible(inventoryDevices)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"hosts": []string{"1", "2"}}, res["ungrouped"])
+}
+
+func TestSSHConfig(t *testing.T) {
+	inv, err := NewInventory(InventoryConfig{HostTemplate: "{{.Model}}-{{.SerialNum}}", SSHUser: "admin", SSHPort: 2222})
+	assert.Nil(t, err)
+
+	res, err := inv.SSHConfig(inventoryDevices[:1])
+	assert.Nil(t, err)
+	assert.Equal(t, "Host switch-1\n    HostName 10.0.0.1\n    User admin\n    Port 2222\n\n", res)
+}
+
+func TestInventoryInvalidTemplate(t *testing.T) {
+	_, err := NewInventory(InventoryConfig{HostTemplate: "{{.SerialNum"})
+	assert.NotNil(t, err)
+
+	inv, err := NewInventory(InventoryConfig{HostTemplate: "{{.Unknown}}"})
+	assert.Nil(t, err)
+	_, err = inv.SSHConfig(inventoryDevices)
+	assert.NotNil(t, err)
+}
+
+func TestAnsibleInventoryConflicts(t *testing.T) {
+	inv, err := NewInventory(InventoryConfig{HostTemplate: "{{.Model}}"})
+	assert.Nil(t, err)
+	_, err = inv.Ansible([]model.Device{
+		{SerialNum: "1", Model: "switch"},
+		{SerialNum: "2", Model: "switch"},
+	})
+	assert.ErrorContains(t, err, `devices 1 and 2 have the same host name "switch"`)
+
+	for _, group := range []string{"all", "_meta"} {
+		inv, err = NewInventory(InventoryConfig{GroupTemplates: []string{group}})
+		assert.Nil(t, err)
+		_, err = inv.Ansible(inventoryDevices)
+		assert.ErrorContains(t, err, "is reserved", group)
+	}
+}
+
+func TestSSHConfigInjection(t *testing.T) {
+	for _, cfg := range []InventoryConfig{
+		{SSHUser: "admin\n    ProxyCommand touch /tmp/pwned"},
+		{SSHUser: "ad min"},
+		{IdentityFile: "~/.ssh/id\rProxyCommand x"},
+	} {
+		_, err := NewInventory(cfg)
+		assert.ErrorContains(t, err, "must not contain whitespace or control characters")
+	}
+
+	inv, err := NewInventory(InventoryConfig{HostTemplate: "{{.Model}}\x00"})
+	assert.Nil(t, err)
+	_, err = inv.SSHConfig(inventoryDevices[:1])
+	assert.ErrorContains(t, err, "control characters")
+
+	for _, host := range []string{"*", "*.corp", "!foo", "a,b", "a?", "a#b", `a"b`, "a'b"} {
+		inv, err := NewInventory(InventoryConfig{HostTemplate: host})
+		assert.Nil(t, err)
+		_, err = inv.SSHConfig(inventoryDevices[:1])
+		assert.ErrorContains(t, err, "must not contain", host)
+	}
+
+	// a second Host block of the same name would be ignored by ssh
+	inv, err = NewInventory(InventoryConfig{HostTemplate: "{{.Model}}"})
+	assert.Nil(t, err)
+	_, err = inv.SSHConfig([]model.Device{{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}, {SerialNum: "2", Model: "switch", IP: "1.1.1.2"}})
+	assert.ErrorContains(t, err, "same host name")
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"homework/internal/model"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const defaultPrometheusPort = 9100
+
+// PrometheusConfig sets the scrape port of targets. Ports maps a model to its port,
+// other models use DefaultPort.
+type PrometheusConfig struct {
+	DefaultPort int
+	Ports       map[string]int
+}
+
+func (c PrometheusConfig) port(modelName string) int {
+	if p, ok := c.Ports[modelName]; ok {
+		return p
+	}
+	if c.DefaultPort != 0 {
+		return c.DefaultPort
+	}
+	return defaultPrometheusPort
+}
+
+// TargetGroup is an entry of the Prometheus http_sd response.
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// PrometheusTargets renders devices as Prometheus http_sd target groups, one per device.
+// Device labels are copied with names sanitized for Prometheus; serial and model
+// take precedence over device labels of the same name. Devices without a valid IP are skipped.
+func PrometheusTargets(devices []model.Device, cfg PrometheusConfig) []TargetGroup {
+	groups := make([]TargetGroup, 0, len(devices))
+	for _, d := range devices {
+		if net.ParseIP(d.IP) == nil {
+			continue
+		}
+
+		labels := make(map[string]string, len(d.Labels)+2)
+		for k, v := range d.Labels {
+			labels[LabelName(k)] = v
+		}
+		labels["serial"] = d.SerialNum
+		labels["model"] = d.Model
+
+		groups = append(groups, TargetGroup{
+			Targets: []string{net.JoinHostPort(d.IP, strconv.Itoa(cfg.port(d.Model)))},
+			Labels:  labels,
+		})
+	}
+	return groups
+}
+
+// LabelName replaces characters not allowed in Prometheus label names with underscores
+// and prefixes names starting with a digit. Names starting with __ are reserved for
+// meta labels such as __address__, which device labels must not override, so they
+// are prefixed with "label".
+func LabelName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	name := string(b)
+	if len(b) == 0 || (b[0] >= '0' && b[0] <= '9') {
+		name = "_" + name
+	}
+	if strings.HasPrefix(name, "__") {
+		name = "label" + name
+	}
+	return name
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusTargets(t *testing.T) {
+	devices := []model.Device{
+		{SerialNum: "1", Model: "switch", IP: "10.0.0.1", Labels: map[string]string{"site": "lab", "rack-id": "7", "model": "x"}},
+		{SerialNum: "2", Model: "display", IP: "fe80::1"},
+		{SerialNum: "3", Model: "display", IP: "not an ip"},
+	}
+	cfg := PrometheusConfig{Ports: map[string]int{"switch": 9116}}
+
+	assert.Equal(t, []TargetGroup{
+		{
+			Targets: []string{"10.0.0.1:9116"},
+			Labels:  map[string]string{"serial": "1", "model": "switch", "site": "lab", "rack_id": "7"},
+		},
+		{
+			Targets: []string{"[fe80::1]:9100"},
+			Labels:  map[string]string{"serial": "2", "model": "display"},
+		},
+	}, PrometheusTargets(devices, cfg))
+}
+
+func TestLabelName(t *testing.T) {
+	assert.Equal(t, "rack_id", LabelName("rack-id"))
+	assert.Equal(t, "_1st", LabelName("1st"))
+	assert.Equal(t, "a1", LabelName("a1"))
+	assert.Equal(t, "_", LabelName(""))
+	assert.Equal(t, "label__address__", LabelName("__address__"))
+	assert.Equal(t, "label__x", LabelName("_-x"))
+}
+
+func TestPrometheusMetaLabels(t *testing.T) {
+	devices := []model.Device{{SerialNum: "1", Model: "switch", IP: "10.0.0.1", Labels: map[string]string{
+		"__address__":      "evil.example:80",
+		"__scheme__":       "https",
+		"__metrics_path__": "/steal",
+	}}}
+
+	groups := PrometheusTargets(devices, PrometheusConfig{})
+	assert.Equal(t, []string{"10.0.0.1:9100"}, groups[0].Targets)
+	for name := range groups[0].Labels {
+		assert.False(t, strings.HasPrefix(name, "__"), name)
+	}
+	assert.Equal(t, "evil.example:80", groups[0].Labels["label__address__"])
+}
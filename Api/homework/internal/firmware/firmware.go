@@ -0,0 +1,300 @@
+package firmware
+
+import (
+	"errors"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const EventCampaignChanged = "firmware.campaign"
+
+var (
+	ErrCampaignDoesNotExist = errors.New("campaign doesn't exist")
+	ErrInvalidCampaign      = errors.New("invalid campaign")
+	ErrIllegalCampaignState = errors.New("operation not allowed in current campaign state")
+	ErrDeviceNotInCampaign  = errors.New("device is not targeted by campaign")
+	ErrInvalidRolloutStatus = errors.New("invalid rollout status")
+	ErrNoJob                = errors.New("no firmware job for device")
+)
+
+type Service interface {
+	CreateCampaign(c model.Campaign) (model.Campaign, error)
+	GetCampaign(id string) (model.Campaign, error)
+	ListCampaigns() []model.Campaign
+	PauseCampaign(id string) (model.Campaign, error)
+	ResumeCampaign(id string) (model.Campaign, error)
+	AbortCampaign(id string) (model.Campaign, error)
+	// GetJob returns the firmware a device should install, if any running campaign released it.
+	GetJob(num string) (model.FirmwareJob, error)
+	// ReportProgress records the rollout status reported by a device agent.
+	ReportProgress(id, num string, status model.RolloutStatus, message string) (model.Campaign, error)
+}
+
+func NewService(devices service.Service, bus *events.Bus) Service {
+	return &campaignService{devices: devices, bus: bus, campaigns: make(map[string]*model.Campaign)}
+}
+
+type campaignService struct {
+	devices service.Service
+	bus     *events.Bus
+
+	mu        sync.Mutex
+	campaigns map[string]*model.Campaign
+	lastID    int
+}
+
+func (s *campaignService) CreateCampaign(c model.Campaign) (model.Campaign, error) {
+	if err := verifyCampaign(c); err != nil {
+		return model.Campaign{}, err
+	}
+
+	targets, err := s.devices.ListDevices(service.Filter{Model: c.Target.Model, Labels: c.Target.Labels})
+	if err != nil {
+		return model.Campaign{}, err
+	}
+
+	now := time.Now().UTC()
+	c.State = model.CampaignRunning
+	c.Stage = 0
+	c.CreatedAt = now
+	c.Devices = make(map[string]model.DeviceRollout, len(targets))
+
+	// targets are sorted by serial number, so stage membership is deterministic
+	stage := 0
+	for i, d := range targets {
+		for stage < len(c.Stages)-1 && i >= stageSize(c.Stages[stage], len(targets)) {
+			stage++
+		}
+		c.Devices[d.SerialNum] = model.DeviceRollout{Status: model.RolloutPending, Stage: stage, UpdatedAt: now}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	c.ID = strconv.Itoa(s.lastID)
+	s.campaigns[c.ID] = &c
+	s.advance(&c)
+	s.publish(&c)
+	return copyCampaign(&c), nil
+}
+
+func verifyCampaign(c model.Campaign) error {
+	if c.Firmware == "" || len(c.Stages) == 0 || c.MaxFailureRate < 0 || c.MaxFailureRate > 1 {
+		return ErrInvalidCampaign
+	}
+	prev := 0
+	for _, pct := range c.Stages {
+		if pct <= prev || pct > 100 {
+			return ErrInvalidCampaign
+		}
+		prev = pct
+	}
+	if prev != 100 {
+		return ErrInvalidCampaign
+	}
+	return nil
+}
+
+// stageSize returns how many of n devices a cumulative percentage covers, rounding up.
+func stageSize(pct, n int) int {
+	return (pct*n + 99) / 100
+}
+
+func (s *campaignService) GetCampaign(id string) (model.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[id]
+	if !ok {
+		return model.Campaign{}, ErrCampaignDoesNotExist
+	}
+	return copyCampaign(c), nil
+}
+
+func (s *campaignService) ListCampaigns() []model.Campaign {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	campaigns := make([]model.Campaign, 0, len(s.campaigns))
+	for _, c := range s.campaigns {
+		campaigns = append(campaigns, copyCampaign(c))
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].CreatedAt.Before(campaigns[j].CreatedAt) })
+	return campaigns
+}
+
+func (s *campaignService) PauseCampaign(id string) (model.Campaign, error) {
+	return s.changeState(id, model.CampaignPaused, model.CampaignRunning)
+}
+
+func (s *campaignService) ResumeCampaign(id string) (model.Campaign, error) {
+	return s.changeState(id, model.CampaignRunning, model.CampaignPaused, model.CampaignHalted)
+}
+
+func (s *campaignService) AbortCampaign(id string) (model.Campaign, error) {
+	return s.changeState(id, model.CampaignAborted, model.CampaignRunning, model.CampaignPaused, model.CampaignHalted)
+}
+
+// changeState moves campaign id to state to if it is currently in one of from.
+func (s *campaignService) changeState(id string, to model.CampaignState, from ...model.CampaignState) (model.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[id]
+	if !ok {
+		return model.Campaign{}, ErrCampaignDoesNotExist
+	}
+
+	allowed := false
+	for _, state := range from {
+		allowed = allowed || c.State == state
+	}
+	if !allowed {
+		return model.Campaign{}, ErrIllegalCampaignState
+	}
+
+	if c.State == model.CampaignHalted && to == model.CampaignRunning {
+		acknowledgeFailures(c)
+	}
+	c.State = to
+	if to == model.CampaignRunning {
+		s.advance(c)
+	}
+	s.publish(c)
+	return copyCampaign(c), nil
+}
+
+// acknowledgeFailures stops counting the failures so far towards the failure rate,
+// so the resumed campaign halts only on new ones.
+func acknowledgeFailures(c *model.Campaign) {
+	for num, r := range c.Devices {
+		if r.Status == model.RolloutFailed {
+			r.Acknowledged = true
+			c.Devices[num] = r
+		}
+	}
+}
+
+func (s *campaignService) GetJob(num string) (model.FirmwareJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// oldest campaign first, so a device targeted by several gets the same job every time
+	for id := 1; id <= s.lastID; id++ {
+		c, ok := s.campaigns[strconv.Itoa(id)]
+		if !ok || c.State != model.CampaignRunning {
+			continue
+		}
+		r, ok := c.Devices[num]
+		if ok && r.Stage <= c.Stage && (r.Status == model.RolloutPending || r.Status == model.RolloutInProgress) {
+			return model.FirmwareJob{CampaignID: c.ID, Firmware: c.Firmware}, nil
+		}
+	}
+	return model.FirmwareJob{}, ErrNoJob
+}
+
+func (s *campaignService) ReportProgress(id, num string, status model.RolloutStatus, message string) (model.Campaign, error) {
+	switch status {
+	case model.RolloutInProgress, model.RolloutSucceeded, model.RolloutFailed:
+	default:
+		return model.Campaign{}, ErrInvalidRolloutStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[id]
+	if !ok {
+		return model.Campaign{}, ErrCampaignDoesNotExist
+	}
+	r, ok := c.Devices[num]
+	if !ok || r.Stage > c.Stage {
+		return model.Campaign{}, ErrDeviceNotInCampaign
+	}
+	if c.State == model.CampaignAborted || c.State == model.CampaignCompleted {
+		return model.Campaign{}, ErrIllegalCampaignState
+	}
+
+	if status == model.RolloutSucceeded {
+		if err := s.devices.SetFirmware(num, c.Firmware); err != nil {
+			return model.Campaign{}, err
+		}
+	}
+
+	r.Status = status
+	r.Acknowledged = false
+	r.Message = message
+	r.UpdatedAt = time.Now().UTC()
+	c.Devices[num] = r
+
+	if c.State == model.CampaignRunning {
+		s.advance(c)
+	}
+	s.publish(c)
+	return copyCampaign(c), nil
+}
+
+// advance halts a running campaign whose failure rate is over the threshold,
+// otherwise it moves on to the next stage once every released device has finished.
+func (s *campaignService) advance(c *model.Campaign) {
+	for {
+		released, finished, failed, acknowledged := 0, 0, 0, 0
+		for _, r := range c.Devices {
+			if r.Stage > c.Stage {
+				continue
+			}
+			released++
+			switch {
+			case r.Status == model.RolloutSucceeded:
+				finished++
+			case r.Status == model.RolloutFailed && r.Acknowledged:
+				finished++
+				acknowledged++
+			case r.Status == model.RolloutFailed:
+				finished++
+				failed++
+			}
+		}
+		c.Released = released
+
+		counted := released - acknowledged
+		if counted > 0 && float64(failed)/float64(counted) > c.MaxFailureRate {
+			c.State = model.CampaignHalted
+			return
+		}
+		if finished < released {
+			return
+		}
+		if c.Stage == len(c.Stages)-1 {
+			c.State = model.CampaignCompleted
+			return
+		}
+		c.Stage++
+	}
+}
+
+func (s *campaignService) publish(c *model.Campaign) {
+	if s.bus == nil {
+		return
+	}
+	s.bus.Publish(events.Event{
+		Type: EventCampaignChanged,
+		Data: map[string]any{"id": c.ID, "state": c.State, "stage": c.Stage, "released": c.Released},
+	})
+}
+
+func copyCampaign(c *model.Campaign) model.Campaign {
+	res := *c
+	res.Stages = append([]int(nil), c.Stages...)
+	res.Devices = make(map[string]model.DeviceRollout, len(c.Devices))
+	for k, v := range c.Devices {
+		res.Devices[k] = v
+	}
+	return res
+}
@@ -0,0 +1,218 @@
+package firmware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"homework/internal/service"
+	"strconv"
+	"testing"
+)
+
+func newTestService(t *testing.T, n int) (Service, service.Service) {
+	devices := service.NewService(service.NewStorage())
+	for i := 0; i < n; i++ {
+		d := model.Device{
+			SerialNum: strconv.Itoa(i),
+			Model:     "switch",
+			IP:        "1.1.1.1",
+			Firmware:  "1.0",
+			Labels:    map[string]string{"site": "lab"},
+		}
+		assert.Nil(t, devices.CreateDevice(d))
+	}
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "other", Model: "display", IP: "1.1.1.1"}))
+
+	return NewService(devices, nil), devices
+}
+
+func TestCreateCampaignInvalid(t *testing.T) {
+	s, _ := newTestService(t, 1)
+
+	for _, c := range []model.Campaign{
+		{Firmware: "", Stages: []int{100}},
+		{Firmware: "2.0", Stages: nil},
+		{Firmware: "2.0", Stages: []int{50, 20, 100}},
+		{Firmware: "2.0", Stages: []int{50}},
+		{Firmware: "2.0", Stages: []int{100}, MaxFailureRate: 2},
+	} {
+		_, err := s.CreateCampaign(c)
+		assert.ErrorIs(t, err, ErrInvalidCampaign)
+	}
+}
+
+func TestCampaignStagedRollout(t *testing.T) {
+	s, devices := newTestService(t, 10)
+
+	c, err := s.CreateCampaign(model.Campaign{
+		Firmware:       "2.0",
+		Target:         model.CampaignTarget{Model: "switch", Labels: map[string]string{"site": "lab"}},
+		Stages:         []int{10, 50, 100},
+		MaxFailureRate: 0.5,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, c.Devices, 10)
+	assert.Equal(t, 1, c.Released)
+
+	job, err := s.GetJob("0")
+	assert.Nil(t, err)
+	assert.Equal(t, model.FirmwareJob{CampaignID: c.ID, Firmware: "2.0"}, job)
+
+	_, err = s.GetJob("1")
+	assert.ErrorIs(t, err, ErrNoJob)
+	_, err = s.ReportProgress(c.ID, "1", model.RolloutSucceeded, "")
+	assert.ErrorIs(t, err, ErrDeviceNotInCampaign)
+
+	c, err = s.ReportProgress(c.ID, "0", model.RolloutSucceeded, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, c.Stage)
+	assert.Equal(t, 5, c.Released)
+
+	d, _ := devices.GetDevice("0")
+	assert.Equal(t, "2.0", d.Firmware)
+
+	for i := 1; i < 10; i++ {
+		c, err = s.ReportProgress(c.ID, strconv.Itoa(i), model.RolloutSucceeded, "")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, model.CampaignCompleted, c.State)
+
+	_, err = s.GetJob("other")
+	assert.ErrorIs(t, err, ErrNoJob)
+}
+
+func TestCampaignHaltsOnFailures(t *testing.T) {
+	s, _ := newTestService(t, 4)
+
+	c, err := s.CreateCampaign(model.Campaign{Firmware: "2.0", Target: model.CampaignTarget{Model: "switch"}, Stages: []int{50, 100}, MaxFailureRate: 0.25})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, c.Released)
+
+	c, err = s.ReportProgress(c.ID, "0", model.RolloutInProgress, "")
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignRunning, c.State)
+
+	c, err = s.ReportProgress(c.ID, "0", model.RolloutFailed, "flash error")
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignHalted, c.State)
+	assert.Equal(t, "flash error", c.Devices["0"].Message)
+
+	_, err = s.GetJob("1")
+	assert.ErrorIs(t, err, ErrNoJob)
+
+	_, err = s.ReportProgress(c.ID, "1", "done", "")
+	assert.ErrorIs(t, err, ErrInvalidRolloutStatus)
+}
+
+func TestCampaignPauseResumeAbort(t *testing.T) {
+	s, _ := newTestService(t, 2)
+
+	c, err := s.CreateCampaign(model.Campaign{Firmware: "2.0", Target: model.CampaignTarget{Model: "switch"}, Stages: []int{50, 100}})
+	assert.Nil(t, err)
+
+	_, err = s.ResumeCampaign(c.ID)
+	assert.ErrorIs(t, err, ErrIllegalCampaignState)
+
+	c, err = s.PauseCampaign(c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignPaused, c.State)
+
+	_, err = s.GetJob("0")
+	assert.ErrorIs(t, err, ErrNoJob)
+
+	c, err = s.ReportProgress(c.ID, "0", model.RolloutSucceeded, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, c.Stage)
+
+	c, err = s.ResumeCampaign(c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignRunning, c.State)
+	assert.Equal(t, 1, c.Stage)
+
+	c, err = s.AbortCampaign(c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignAborted, c.State)
+
+	_, err = s.ReportProgress(c.ID, "1", model.RolloutSucceeded, "")
+	assert.ErrorIs(t, err, ErrIllegalCampaignState)
+
+	_, err = s.AbortCampaign("000")
+	assert.ErrorIs(t, err, ErrCampaignDoesNotExist)
+}
+
+func TestCampaignResumeAfterHalt(t *testing.T) {
+	s, _ := newTestService(t, 4)
+
+	c, err := s.CreateCampaign(model.Campaign{Firmware: "2.0", Target: model.CampaignTarget{Model: "switch"}, Stages: []int{50, 100}, MaxFailureRate: 0.4})
+	assert.Nil(t, err)
+	c, err = s.ReportProgress(c.ID, "0", model.RolloutFailed, "flash error")
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignHalted, c.State)
+
+	c, err = s.ResumeCampaign(c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignRunning, c.State)
+	assert.True(t, c.Devices["0"].Acknowledged)
+
+	c, err = s.ReportProgress(c.ID, "1", model.RolloutSucceeded, "")
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignRunning, c.State)
+	assert.Equal(t, 1, c.Stage)
+
+	c, err = s.ReportProgress(c.ID, "2", model.RolloutSucceeded, "")
+	assert.Nil(t, err)
+	c, err = s.ReportProgress(c.ID, "3", model.RolloutFailed, "")
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignCompleted, c.State)
+
+	c, err = s.CreateCampaign(model.Campaign{Firmware: "3.0", Target: model.CampaignTarget{Model: "switch"}, Stages: []int{50, 100}})
+	assert.Nil(t, err)
+	c, err = s.ReportProgress(c.ID, "0", model.RolloutFailed, "")
+	assert.Nil(t, err)
+	_, err = s.ResumeCampaign(c.ID)
+	assert.Nil(t, err)
+
+	// a failure after the resume counts again
+	c, err = s.ReportProgress(c.ID, "1", model.RolloutFailed, "")
+	assert.Nil(t, err)
+	assert.Equal(t, model.CampaignHalted, c.State)
+}
+
+func TestGetJobOldestCampaign(t *testing.T) {
+	s, _ := newTestService(t, 2)
+
+	var ids []string
+	for _, firmware := range []string{"2.0", "3.0", "4.0"} {
+		c, err := s.CreateCampaign(model.Campaign{Firmware: firmware, Target: model.CampaignTarget{Model: "switch"}, Stages: []int{100}})
+		assert.Nil(t, err)
+		ids = append(ids, c.ID)
+	}
+
+	for i := 0; i < 20; i++ {
+		job, err := s.GetJob("0")
+		assert.Nil(t, err)
+		assert.Equal(t, model.FirmwareJob{CampaignID: ids[0], Firmware: "2.0"}, job)
+	}
+
+	_, err := s.PauseCampaign(ids[0])
+	assert.Nil(t, err)
+	job, err := s.GetJob("0")
+	assert.Nil(t, err)
+	assert.Equal(t, ids[1], job.CampaignID)
+}
+
+func TestReportProgressKeepsDeviceChanges(t *testing.T) {
+	s, devices := newTestService(t, 1)
+
+	c, err := s.CreateCampaign(model.Campaign{Firmware: "2.0", Target: model.CampaignTarget{Model: "switch"}, Stages: []int{100}})
+	assert.Nil(t, err)
+
+	d, _ := devices.GetDevice("0")
+	d.IP = "2.2.2.2"
+	assert.Nil(t, devices.UpdateDevice(d))
+
+	_, err = s.ReportProgress(c.ID, "0", model.RolloutSucceeded, "")
+	assert.Nil(t, err)
+	d, _ = devices.GetDevice("0")
+	assert.Equal(t, "2.0", d.Firmware)
+	assert.Equal(t, "2.2.2.2", d.IP)
+}
@@ -0,0 +1,124 @@
+package freeze
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// AuditLog persists override records as JSON lines, so the audit survives restarts.
+// The windows are kept in a file next to it, e.g. audit.windows.json for audit.jsonl.
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+	// recent are the last records in the log when it was opened.
+	recent []model.Override
+
+	windowsPath string
+	// windows are the windows saved when the log was opened.
+	windows windowState
+}
+
+type windowState struct {
+	LastID  int            `json:"last_id"`
+	Windows []model.Window `json:"windows"`
+}
+
+// OpenAuditLog opens the audit log at path, creating it if needed, and reads the windows next to it.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{windowsPath: strings.TrimSuffix(path, filepath.Ext(path)) + ".windows.json"}
+	raw, err := os.ReadFile(l.windowsPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(raw, &l.windows); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.windowsPath, err)
+		}
+	}
+
+	l.f, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l.recent, err = readOverrides(l.f, maxOverrides)
+	if err != nil {
+		_ = l.f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
+
+// readOverrides returns the last n records of f, oldest first.
+func readOverrides(f *os.File, n int) ([]model.Override, error) {
+	var overrides []model.Override
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var o model.Override
+		if err := json.Unmarshal(sc.Bytes(), &o); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(overrides) == n {
+			overrides = append(overrides[:0], overrides[1:]...)
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, sc.Err()
+}
+
+// Append writes o and syncs it to disk.
+func (l *AuditLog) Append(o model.Override) error {
+	line, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// saveWindows replaces the windows file atomically with state.
+func (l *AuditLog) saveWindows(state windowState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := l.windowsPath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(raw)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.windowsPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (l *AuditLog) Close() error {
+	return l.f.Close()
+}
@@ -0,0 +1,239 @@
+package freeze
+
+import (
+	"errors"
+	"fmt"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const EventOverride = "freeze.override"
+
+// maxOverrides bounds the overrides kept in memory, the oldest records are dropped first.
+// An AuditLog keeps all of them.
+const maxOverrides = 1000
+
+var (
+	ErrFrozen             = errors.New("changes are frozen")
+	ErrWindowDoesNotExist = errors.New("window doesn't exist")
+	ErrInvalidWindow      = errors.New("invalid window")
+)
+
+type Service interface {
+	// CreateWindow adds w and audits it as created by the request requestID.
+	CreateWindow(w model.Window, requestID string) (model.Window, error)
+	// DeleteWindow removes a window and audits it as deleted by the request requestID.
+	DeleteWindow(id, requestID string) error
+	// Schedule returns the open windows and those starting later, by start.
+	Schedule() model.Schedule
+	// Frozen returns the freeze d is in now, unless a maintenance window for d is open too.
+	Frozen(d model.Device) (model.Window, bool)
+	// RecordOverride audits a change made during a freeze and publishes an event.
+	RecordOverride(o model.Override)
+	// Overrides returns the latest audit records, overrides and window changes, oldest first.
+	Overrides() []model.Override
+}
+
+type Option func(*freezeService)
+
+func NewService(bus *events.Bus, options ...Option) Service {
+	s := &freezeService{bus: bus, now: time.Now, windows: make(map[string]model.Window)}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// WithAuditLog persists overrides, window changes and windows to l and starts
+// from the records and windows already in it.
+func WithAuditLog(l *AuditLog) Option {
+	return func(s *freezeService) {
+		s.audit = l
+		s.overrides = append(s.overrides, l.recent...)
+		s.lastID = l.windows.LastID
+		for _, w := range l.windows.Windows {
+			s.windows[w.ID] = w
+		}
+	}
+}
+
+// WithClock replaces time.Now for deciding which windows are open.
+func WithClock(now func() time.Time) Option {
+	return func(s *freezeService) {
+		s.now = now
+	}
+}
+
+type freezeService struct {
+	bus   *events.Bus
+	now   func() time.Time
+	audit *AuditLog
+
+	mu        sync.RWMutex
+	windows   map[string]model.Window
+	overrides []model.Override
+	lastID    int
+}
+
+func (s *freezeService) CreateWindow(w model.Window, requestID string) (model.Window, error) {
+	if w.Kind != model.WindowFreeze && w.Kind != model.WindowMaintenance {
+		return model.Window{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidWindow, w.Kind)
+	}
+	if w.Start.IsZero() || !w.End.After(w.Start) {
+		return model.Window{}, fmt.Errorf("%w: end must be after start", ErrInvalidWindow)
+	}
+
+	s.mu.Lock()
+	s.lastID++
+	w.ID = strconv.Itoa(s.lastID)
+	s.windows[w.ID] = w
+	if err := s.saveWindows(); err != nil {
+		delete(s.windows, w.ID)
+		s.lastID--
+		s.mu.Unlock()
+		return model.Window{}, err
+	}
+	s.mu.Unlock()
+
+	s.record(model.Override{Operation: "create window", Window: w.ID, Reason: w.Name, RequestID: requestID})
+	return w, nil
+}
+
+func (s *freezeService) DeleteWindow(id, requestID string) error {
+	s.mu.Lock()
+	w, ok := s.windows[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrWindowDoesNotExist
+	}
+	delete(s.windows, id)
+	if err := s.saveWindows(); err != nil {
+		s.windows[id] = w
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.record(model.Override{Operation: "delete window", Window: id, Reason: w.Name, RequestID: requestID})
+	return nil
+}
+
+func (s *freezeService) Schedule() model.Schedule {
+	now := s.now()
+	schedule := model.Schedule{Active: make([]model.Window, 0), Upcoming: make([]model.Window, 0)}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.windows {
+		switch {
+		case open(w, now):
+			schedule.Active = append(schedule.Active, w)
+		case w.Start.After(now):
+			schedule.Upcoming = append(schedule.Upcoming, w)
+		}
+	}
+	byStart(schedule.Active)
+	byStart(schedule.Upcoming)
+	return schedule
+}
+
+func (s *freezeService) Frozen(d model.Device) (model.Window, bool) {
+	now := s.now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var freezes []model.Window
+	for _, w := range s.windows {
+		if !open(w, now) || !covers(w, d) {
+			continue
+		}
+		if w.Kind == model.WindowMaintenance {
+			return model.Window{}, false
+		}
+		freezes = append(freezes, w)
+	}
+	if len(freezes) == 0 {
+		return model.Window{}, false
+	}
+	// the freeze lasting longest is the one to report
+	sort.Slice(freezes, func(i, j int) bool { return freezes[i].End.After(freezes[j].End) })
+	return freezes[0], true
+}
+
+func (s *freezeService) RecordOverride(o model.Override) {
+	o = s.record(o)
+	slog.Warn("change freeze overridden",
+		slog.String("operation", o.Operation),
+		slog.String("serial", o.SerialNum),
+		slog.String("window", o.Window),
+		slog.String("reason", o.Reason),
+		slog.String("request_id", o.RequestID),
+	)
+	if s.bus != nil {
+		s.bus.Publish(events.Event{Type: EventOverride, Serial: o.SerialNum, Data: o})
+	}
+}
+
+// record adds o to the audit, stamped with the current time.
+func (s *freezeService) record(o model.Override) model.Override {
+	o.Time = s.now().UTC()
+
+	s.mu.Lock()
+	if len(s.overrides) == maxOverrides {
+		s.overrides = append(s.overrides[:0], s.overrides[1:]...)
+	}
+	s.overrides = append(s.overrides, o)
+	s.mu.Unlock()
+
+	if s.audit != nil {
+		if err := s.audit.Append(o); err != nil {
+			slog.Error("audit log write failed", slog.String("operation", o.Operation), slog.String("serial", o.SerialNum), slog.Any("error", err))
+		}
+	}
+	return o
+}
+
+// saveWindows persists the windows to the audit log, if there is one. It must be called with s.mu held.
+func (s *freezeService) saveWindows() error {
+	if s.audit == nil {
+		return nil
+	}
+	state := windowState{LastID: s.lastID, Windows: make([]model.Window, 0, len(s.windows))}
+	for _, w := range s.windows {
+		state.Windows = append(state.Windows, w)
+	}
+	byStart(state.Windows)
+	return s.audit.saveWindows(state)
+}
+
+func (s *freezeService) Overrides() []model.Override {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append(make([]model.Override, 0, len(s.overrides)), s.overrides...)
+}
+
+func open(w model.Window, at time.Time) bool {
+	return !at.Before(w.Start) && at.Before(w.End)
+}
+
+func covers(w model.Window, d model.Device) bool {
+	return (w.Model == "" || w.Model == d.Model) && service.MatchLabels(d.Labels, w.Labels)
+}
+
+func byStart(windows []model.Window) {
+	sort.Slice(windows, func(i, j int) bool {
+		if !windows[i].Start.Equal(windows[j].Start) {
+			return windows[i].Start.Before(windows[j].Start)
+		}
+		return windows[i].ID < windows[j].ID
+	})
+}
@@ -0,0 +1,237 @@
+package freeze
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"homework/internal/topology"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 12, 20, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T, bus *events.Bus) Service {
+	return NewService(bus, WithClock(func() time.Time { return now }))
+}
+
+func window(t *testing.T, f Service, w model.Window) model.Window {
+	w, err := f.CreateWindow(w, "")
+	assert.Nil(t, err)
+	return w
+}
+
+func TestFrozen(t *testing.T) {
+	f := newTestService(t, nil)
+	core := model.Device{SerialNum: "core-1", Model: "core", Labels: map[string]string{"site": "ams"}}
+	edge := model.Device{SerialNum: "edge-1", Model: "edge", Labels: map[string]string{"site": "ams"}}
+
+	window(t, f, model.Window{Kind: model.WindowFreeze, Start: now.Add(-time.Hour), End: now.Add(time.Hour), Model: "core"})
+	_, frozen := f.Frozen(core)
+	assert.True(t, frozen)
+	_, frozen = f.Frozen(edge)
+	assert.False(t, frozen)
+
+	// a maintenance window lifts the freeze for the devices it covers
+	maintenance := window(t, f, model.Window{Kind: model.WindowMaintenance, Start: now, End: now.Add(time.Minute), Labels: map[string]string{"site": "ams"}})
+	_, frozen = f.Frozen(core)
+	assert.False(t, frozen)
+
+	assert.Nil(t, f.DeleteWindow(maintenance.ID, ""))
+	assert.ErrorIs(t, f.DeleteWindow(maintenance.ID, ""), ErrWindowDoesNotExist)
+	_, frozen = f.Frozen(core)
+	assert.True(t, frozen)
+}
+
+func TestSchedule(t *testing.T) {
+	f := newTestService(t, nil)
+	window(t, f, model.Window{Kind: model.WindowFreeze, Start: now.Add(-48 * time.Hour), End: now.Add(-24 * time.Hour)})
+	active := window(t, f, model.Window{Kind: model.WindowFreeze, Start: now.Add(-time.Hour), End: now.Add(time.Hour)})
+	later := window(t, f, model.Window{Kind: model.WindowMaintenance, Start: now.Add(48 * time.Hour), End: now.Add(50 * time.Hour)})
+	soon := window(t, f, model.Window{Kind: model.WindowFreeze, Start: now.Add(24 * time.Hour), End: now.Add(72 * time.Hour)})
+
+	assert.Equal(t, model.Schedule{Active: []model.Window{active}, Upcoming: []model.Window{soon, later}}, f.Schedule())
+
+	_, err := f.CreateWindow(model.Window{Kind: "holiday", Start: now, End: now.Add(time.Hour)}, "")
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+	_, err = f.CreateWindow(model.Window{Kind: model.WindowFreeze, Start: now, End: now}, "")
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+}
+
+func TestGuard(t *testing.T) {
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	f := newTestService(t, bus)
+	devices := service.NewService(service.NewStorage())
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "core-1", Model: "core", IP: "10.0.0.1"}))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "edge-1", Model: "edge", IP: "10.0.0.2"}))
+	window(t, f, model.Window{Kind: model.WindowFreeze, Start: now.Add(-time.Hour), End: now.Add(time.Hour), Model: "core"})
+	guarded := Guard(devices, f)
+
+	assert.ErrorIs(t, guarded.CreateDevice(model.Device{SerialNum: "core-2", Model: "core", IP: "10.0.0.3"}), ErrFrozen)
+	assert.ErrorIs(t, guarded.DeleteDevice("core-1"), ErrFrozen)
+	assert.ErrorIs(t, guarded.TransitionDevice("core-1", model.StateActive, ""), ErrFrozen)
+	// moving a device out of the freeze scope is a change to a frozen device too
+	assert.ErrorIs(t, guarded.UpdateDevice(model.Device{SerialNum: "core-1", Model: "edge", IP: "10.0.0.1"}), ErrFrozen)
+	assert.Nil(t, guarded.UpdateDevice(model.Device{SerialNum: "edge-1", Model: "edge", IP: "10.0.0.4"}))
+	assert.ErrorIs(t, guarded.DeleteDevice("none"), service.ErrDeviceDoesNotExist)
+	created := model.Override{Time: now, Operation: "create window", Window: "1"}
+	assert.Equal(t, []model.Override{created}, f.Overrides())
+
+	overridden := service.Bind(WithOverride(context.Background(), "outage INC-42"), guarded)
+	assert.Nil(t, overridden.DeleteDevice("core-1"))
+	_, err := devices.GetDevice("core-1")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+
+	overrides := f.Overrides()
+	assert.Equal(t, []model.Override{created, {Time: now, Operation: "delete", SerialNum: "core-1", Reason: "outage INC-42", Window: "1"}}, overrides)
+	e := <-ch
+	assert.Equal(t, EventOverride, e.Type)
+	assert.Equal(t, overrides[1], e.Data)
+}
+
+func TestOverrides(t *testing.T) {
+	var reason string
+	h := Overrides(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reason = overrideReason(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodDelete, "/v1/devices/core-1", nil)
+	r.Header.Set(OverrideHeader, "outage INC-42")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "outage INC-42", reason)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/v1/devices/core-1", nil))
+	assert.Empty(t, reason)
+}
+
+func TestCascade(t *testing.T) {
+	f := newTestService(t, nil)
+	devices := service.NewService(service.NewStorage())
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "rack-1", Model: "rack", IP: "10.0.0.1"}))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "core-1", Model: "core", IP: "10.0.0.2"}))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "edge-1", Model: "edge", IP: "10.0.0.3"}))
+	topo := topology.NewService(devices, topology.DeleteCascade)
+	assert.Nil(t, topo.SetParent(model.Containment{Parent: "rack-1", Child: "edge-1"}))
+	assert.Nil(t, topo.SetParent(model.Containment{Parent: "rack-1", Child: "core-1"}))
+	window(t, f, model.Window{Kind: model.WindowFreeze, Start: now.Add(-time.Hour), End: now.Add(time.Hour), Model: "core"})
+	guarded := topology.Guard(Guard(devices, f), topo)
+
+	// the rack isn't frozen, but a device it contains is, so nothing is deleted
+	assert.ErrorIs(t, guarded.DeleteDevice("rack-1"), ErrFrozen)
+	for _, num := range []string{"rack-1", "core-1", "edge-1"} {
+		_, err := devices.GetDevice(num)
+		assert.Nil(t, err, num)
+	}
+	created := model.Override{Time: now, Operation: "create window", Window: "1"}
+	assert.Equal(t, []model.Override{created}, f.Overrides())
+
+	overridden := service.Bind(WithOverride(context.Background(), "rack swap"), guarded)
+	assert.Nil(t, overridden.DeleteDevice("rack-1"))
+	_, err := devices.GetDevice("core-1")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+	assert.Equal(t, []model.Override{created, {Time: now, Operation: "delete", SerialNum: "core-1", Reason: "rack swap", Window: "1"}}, f.Overrides())
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.jsonl")
+	audit, err := OpenAuditLog(path)
+	assert.Nil(t, err)
+	f := NewService(nil, WithClock(func() time.Time { return now }), WithAuditLog(audit))
+	o := model.Override{Operation: "delete", SerialNum: "core-1", Reason: "outage INC-42", Window: "1"}
+	f.RecordOverride(o)
+	assert.Nil(t, audit.Close())
+
+	// a restarted service has the overrides of the log
+	audit, err = OpenAuditLog(path)
+	assert.Nil(t, err)
+	defer audit.Close()
+	o.Time = now
+	assert.Equal(t, []model.Override{o}, NewService(nil, WithAuditLog(audit)).Overrides())
+
+	assert.Nil(t, os.WriteFile(path, []byte("{\n"), 0o600))
+	_, err = OpenAuditLog(path)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestOverrideTokens(t *testing.T) {
+	h := OverrideTokens([]string{"ops"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		token, reason string
+		status        int
+	}{
+		{"dev", "", http.StatusOK},
+		{"dev", "outage INC-42", http.StatusForbidden},
+		{"ops", "outage INC-42", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodDelete, "/v1/devices/core-1", nil)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		if tc.reason != "" {
+			r.Header.Set(OverrideHeader, tc.reason)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.status, w.Code, tc)
+	}
+}
+
+func TestWindowTokens(t *testing.T) {
+	h := WindowTokens("/v1/windows", []string{"ops"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		method, target, token string
+		status                int
+	}{
+		{http.MethodGet, "/v1/windows", "dev", http.StatusOK},
+		{http.MethodGet, "/v1/windows/overrides", "dev", http.StatusOK},
+		{http.MethodPost, "/v1/windows", "dev", http.StatusForbidden},
+		{http.MethodDelete, "/v1/windows/1", "dev", http.StatusForbidden},
+		{http.MethodPost, "/v1/windows", "ops", http.StatusOK},
+		{http.MethodDelete, "/v1/windows/1", "ops", http.StatusOK},
+		{http.MethodDelete, "/v1/devices/1", "dev", http.StatusOK},
+	} {
+		r := httptest.NewRequest(tc.method, tc.target, nil)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.status, w.Code, tc)
+	}
+}
+
+func TestWindowsPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	assert.Nil(t, err)
+	f := NewService(nil, WithClock(func() time.Time { return now }), WithAuditLog(audit))
+	freeze, err := f.CreateWindow(model.Window{Name: "holidays", Kind: model.WindowFreeze, Start: now.Add(-time.Hour), End: now.Add(time.Hour)}, "req-1")
+	assert.Nil(t, err)
+	maintenance, err := f.CreateWindow(model.Window{Kind: model.WindowMaintenance, Start: now, End: now.Add(time.Minute)}, "req-2")
+	assert.Nil(t, err)
+	assert.Nil(t, f.DeleteWindow(maintenance.ID, "req-3"))
+	assert.Nil(t, audit.Close())
+	_, err = os.Stat(filepath.Join(filepath.Dir(path), "audit.windows.json"))
+	assert.Nil(t, err)
+
+	// a restart keeps the freeze, the audit of the window changes and the window IDs
+	audit, err = OpenAuditLog(path)
+	assert.Nil(t, err)
+	defer audit.Close()
+	f = NewService(nil, WithClock(func() time.Time { return now }), WithAuditLog(audit))
+	_, frozen := f.Frozen(model.Device{SerialNum: "core-1"})
+	assert.True(t, frozen)
+	assert.Equal(t, []model.Window{freeze}, f.Schedule().Active)
+	assert.Equal(t, []model.Override{
+		{Time: now, Operation: "create window", Reason: "holidays", Window: freeze.ID, RequestID: "req-1"},
+		{Time: now, Operation: "create window", Window: maintenance.ID, RequestID: "req-2"},
+		{Time: now, Operation: "delete window", Window: maintenance.ID, RequestID: "req-3"},
+	}, f.Overrides())
+	w, err := f.CreateWindow(model.Window{Kind: model.WindowFreeze, Start: now, End: now.Add(time.Minute)}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", w.ID)
+}
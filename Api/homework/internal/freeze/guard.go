@@ -0,0 +1,178 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/middleware"
+	"homework/internal/model"
+	"homework/internal/service"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OverrideHeader carries the reason for changing devices during a freeze.
+const OverrideHeader = "X-Override-Reason"
+
+type overrideKey struct{}
+
+// WithOverride returns a context whose changes pass freezes, audited with reason.
+func WithOverride(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, overrideKey{}, reason)
+}
+
+func overrideReason(ctx context.Context) string {
+	reason, _ := ctx.Value(overrideKey{}).(string)
+	return reason
+}
+
+// Overrides passes the reason in the X-Override-Reason header of requests on to Guard.
+func Overrides(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := r.Header.Get(OverrideHeader); reason != "" {
+			r = r.WithContext(WithOverride(r.Context(), reason))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// OverrideTokens lets only requests with one of tokens as bearer token override freezes,
+// others sending X-Override-Reason are forbidden. Wrap it inside authentication.
+func OverrideTokens(tokens []string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(OverrideHeader) != "" {
+				token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if !middleware.ValidToken(tokens, token) {
+					http.Error(w, "Overriding freezes requires an override token", http.StatusForbidden)
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WindowTokens lets only requests with one of tokens as bearer token change the windows
+// under prefix, as creating a maintenance window or deleting a freeze lifts freezes.
+// Others may only read them. Wrap it inside authentication.
+func WindowTokens(prefix string, tokens []string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, prefix) && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if !middleware.ValidToken(tokens, token) {
+					http.Error(w, "Changing windows requires an override or admin token", http.StatusForbidden)
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// guardedService rejects changes to frozen devices unless its context carries an override reason.
+type guardedService struct {
+	service.Service
+	freeze Service
+	ctx    context.Context
+}
+
+// Guard decorates devices so that creating, updating, transitioning and deleting
+// devices respects the freezes of f. Bind the result to a context made with
+// WithOverride to let changes through, which f audits.
+func Guard(devices service.Service, f Service) service.Service {
+	return &guardedService{Service: devices, freeze: f, ctx: context.Background()}
+}
+
+func (s *guardedService) CreateDevice(d model.Device) error {
+	return s.guard("create", func() error { return s.Service.CreateDevice(d) }, d)
+}
+
+func (s *guardedService) RestoreDevice(d model.Device) error {
+	return s.guard("restore", func() error { return s.Service.RestoreDevice(d) }, d)
+}
+
+// UpdateDevice checks the device before and after the update, so it can't be moved out of a freeze.
+func (s *guardedService) UpdateDevice(d model.Device) error {
+	devices := []model.Device{d}
+	if old, err := s.Service.GetDevice(d.SerialNum); err == nil {
+		devices = append(devices, old)
+	}
+	return s.guard("update", func() error { return s.Service.UpdateDevice(d) }, devices...)
+}
+
+func (s *guardedService) TransitionDevice(num string, to model.State, reason string) error {
+	return s.guardExisting("transition", num, func() error { return s.Service.TransitionDevice(num, to, reason) })
+}
+
+func (s *guardedService) SetFirmware(num, firmware string) error {
+	return s.guardExisting("firmware", num, func() error { return s.Service.SetFirmware(num, firmware) })
+}
+
+func (s *guardedService) DeleteDevice(num string) error {
+	return s.guardExisting("delete", num, func() error { return s.Service.DeleteDevice(num) })
+}
+
+// CheckDelete fails if one of the existing devices nums is frozen and there is no override,
+// so that a topology cascade deletes none of them.
+func (s *guardedService) CheckDelete(nums ...string) error {
+	if overrideReason(s.ctx) != "" {
+		return nil
+	}
+	for _, num := range nums {
+		d, err := s.Service.GetDevice(num)
+		if err != nil {
+			continue
+		}
+		if w, frozen := s.freeze.Frozen(d); frozen {
+			return frozenError(d, w)
+		}
+	}
+	return nil
+}
+
+// WithContext binds the decorated service to ctx, which may carry an override.
+func (s *guardedService) WithContext(ctx context.Context) service.Service {
+	return &guardedService{Service: service.Bind(ctx, s.Service), freeze: s.freeze, ctx: ctx}
+}
+
+func (s *guardedService) guardExisting(op, num string, change func() error) error {
+	d, err := s.Service.GetDevice(num)
+	if err != nil {
+		// nothing to freeze, the change fails on its own
+		return change()
+	}
+	return s.guard(op, change, d)
+}
+
+// guard makes the change unless one of devices is frozen. With an override reason
+// frozen devices are changed anyway and the override is audited once the change succeeds.
+func (s *guardedService) guard(op string, change func() error, devices ...model.Device) error {
+	for _, d := range devices {
+		w, frozen := s.freeze.Frozen(d)
+		if !frozen {
+			continue
+		}
+		reason := overrideReason(s.ctx)
+		if reason == "" {
+			return frozenError(d, w)
+		}
+		if err := change(); err != nil {
+			return err
+		}
+		s.freeze.RecordOverride(model.Override{
+			Operation: op,
+			SerialNum: d.SerialNum,
+			Reason:    reason,
+			Window:    w.ID,
+			RequestID: middleware.GetRequestID(s.ctx),
+		})
+		return nil
+	}
+	return change()
+}
+
+func frozenError(d model.Device, w model.Window) error {
+	return fmt.Errorf("%w for %s by window %s until %s", ErrFrozen, d.SerialNum, w.ID, w.End.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"homework/internal/backup"
+	"homework/internal/model"
+	"net/http"
+	"strconv"
+)
+
+type BackupHandler struct {
+	*Handler
+	Backup backup.Service
+}
+
+func NewBackupHandler(h *Handler, b backup.Service) *BackupHandler {
+	return &BackupHandler{Handler: h, Backup: b}
+}
+
+func (h *BackupHandler) HandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s, err := h.Backup.Snapshot()
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.Header().Set(resourceVersionHeader, strconv.FormatUint(s.ResourceVersion, 10))
+	h.write(w, r, http.StatusOK, s)
+}
+
+// HandleChanges returns the mutations after the resource version in the since query parameter.
+func (h *BackupHandler) HandleChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		h.ErrResponse(w, "Invalid since", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := h.Backup.Changes(since)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, changes)
+}
+
+func (h *BackupHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	req := model.Restore{}
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	result, err := h.Backup.Restore(req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, result)
+}
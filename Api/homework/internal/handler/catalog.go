@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"homework/internal/catalog"
+	"net/http"
+	"strconv"
+)
+
+type CatalogHandler struct {
+	*Handler
+	Catalog catalog.Service
+}
+
+func NewCatalogHandler(h *Handler, c catalog.Service) *CatalogHandler {
+	return &CatalogHandler{Handler: h, Catalog: c}
+}
+
+func (h *CatalogHandler) HandleRegisterModel(w http.ResponseWriter, r *http.Request) {
+	m := catalog.Model{}
+	if !h.decode(w, r, &m) {
+		return
+	}
+
+	if err := h.Catalog.RegisterModel(m); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *CatalogHandler) HandleListModels(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, http.StatusOK, h.Catalog.ListModels())
+}
+
+func (h *CatalogHandler) HandleRegisterSchema(w http.ResponseWriter, r *http.Request) {
+	raw := json.RawMessage{}
+	if !h.decode(w, r, &raw) {
+		return
+	}
+
+	v, err := h.Catalog.RegisterSchema(r.URL.Query().Get("model"), raw)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusCreated, v)
+}
+
+func (h *CatalogHandler) HandleGetSchema(w http.ResponseWriter, r *http.Request) {
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			h.ErrResponse(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	v, err := h.Catalog.GetSchema(r.URL.Query().Get("model"), version)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, v)
+}
+
+// HandleCheckMigration reports which devices of a model don't satisfy the candidate schema in the body.
+func (h *CatalogHandler) HandleCheckMigration(w http.ResponseWriter, r *http.Request) {
+	raw := json.RawMessage{}
+	if !h.decode(w, r, &raw) {
+		return
+	}
+
+	report, err := h.Catalog.CheckMigration(r.URL.Query().Get("model"), raw)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"homework/internal/codec"
+	"net/http"
+	"strconv"
+)
+
+// decode reads the request body into v in the format of its Content-Type. It also checks
+// that the response can be encoded as the Accept header asks, before the handler changes anything.
+// On failure it answers 406, 413, 415 or 400 and returns false.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	c, err := codec.ForContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		h.ErrResponse(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		return false
+	}
+	if _, err := codec.Negotiate(r.Header.Get("Accept")); err != nil {
+		h.ErrResponse(w, "Not acceptable", http.StatusNotAcceptable)
+		return false
+	}
+
+	body := http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	if err := c.Decode(body, v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.ErrResponse(w, "Request body exceeds "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		h.ErrResponse(w, "Invalid request", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// write encodes v in the format the Accept header asks for, or answers 406.
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	c, err := codec.Negotiate(r.Header.Get("Accept"))
+	if err != nil {
+		h.ErrResponse(w, "Not acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, v); err != nil {
+		h.ErrResponse(w, "Response can't be encoded", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.ContentType())
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"homework/internal/events"
+	"net/http"
+)
+
+type EventsHandler struct {
+	Bus *events.Bus
+}
+
+func NewEventsHandler(bus *events.Bus) *EventsHandler {
+	return &EventsHandler{Bus: bus}
+}
+
+// HandleStream streams bus events as Server-Sent Events until the client disconnects.
+// The optional type query parameter keeps only events of that type.
+func (h *EventsHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	eventType := r.URL.Query().Get("type")
+	ch, cancel := h.Bus.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			if eventType != "" && e.Type != eventType {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"homework/internal/export"
+	"homework/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ExportHandler struct {
+	*Handler
+	Prometheus export.PrometheusConfig
+}
+
+func NewExportHandler(h *Handler, prometheus export.PrometheusConfig) *ExportHandler {
+	return &ExportHandler{Handler: h, Prometheus: prometheus}
+}
+
+// HandlePrometheus renders the registry in Prometheus http_sd format.
+// It accepts the same filters as HandleList.
+func (h *ExportHandler) HandlePrometheus(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	devices, err := h.service(r).ListDevices(f)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	body, err := json.Marshal(export.PrometheusTargets(devices, h.Prometheus))
+	if err != nil {
+		h.ErrResponse(w, "JSON can't be marshaled", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeWithETag(w, r, body)
+}
+
+// HandleAnsible renders the registry as an Ansible dynamic inventory.
+// Host naming and grouping come from the query, see inventoryConfig.
+func (h *ExportHandler) HandleAnsible(w http.ResponseWriter, r *http.Request) {
+	inv, devices, ok := h.inventory(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := inv.Ansible(devices)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+// HandleSSHConfig renders the registry as an ssh_config fragment.
+func (h *ExportHandler) HandleSSHConfig(w http.ResponseWriter, r *http.Request) {
+	inv, devices, ok := h.inventory(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := inv.SSHConfig(devices)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(res))
+}
+
+// inventory builds an Inventory from the query and lists the filtered devices.
+// It writes the error response itself and returns false on failure.
+func (h *ExportHandler) inventory(w http.ResponseWriter, r *http.Request) (*export.Inventory, []model.Device, bool) {
+	f, err := parseFilter(r)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	cfg, err := inventoryConfig(r)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	inv, err := export.NewInventory(cfg)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	devices, err := h.service(r).ListDevices(f)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return nil, nil, false
+	}
+	return inv, devices, true
+}
+
+// inventoryConfig reads host_template, repeated group_template, group_by_labels,
+// user, port and identity_file from the query.
+func inventoryConfig(r *http.Request) (export.InventoryConfig, error) {
+	q := r.URL.Query()
+	cfg := export.InventoryConfig{
+		HostTemplate:   q.Get("host_template"),
+		GroupTemplates: q["group_template"],
+		SSHUser:        q.Get("user"),
+		IdentityFile:   q.Get("identity_file"),
+	}
+
+	var err error
+	if v := q.Get("group_by_labels"); v != "" {
+		if cfg.GroupByLabels, err = strconv.ParseBool(v); err != nil {
+			return export.InventoryConfig{}, errors.New("invalid group_by_labels")
+		}
+	}
+	if v := q.Get("port"); v != "" {
+		if cfg.SSHPort, err = strconv.Atoi(v); err != nil {
+			return export.InventoryConfig{}, errors.New("invalid port")
+		}
+	}
+	return cfg, nil
+}
+
+// writeWithETag writes body with a strong ETag, or 304 if the client already has it.
+func writeWithETag(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if noneMatch(r.Header.Values("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// noneMatch reports whether If-None-Match headers list etag, comparing weakly, or are "*".
+func noneMatch(headers []string, etag string) bool {
+	for _, header := range headers {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"homework/internal/firmware"
+	"homework/internal/model"
+	"net/http"
+)
+
+type FirmwareHandler struct {
+	*Handler
+	Firmware firmware.Service
+}
+
+func NewFirmwareHandler(h *Handler, s firmware.Service) *FirmwareHandler {
+	return &FirmwareHandler{Handler: h, Firmware: s}
+}
+
+func (h *FirmwareHandler) HandleCreateCampaign(w http.ResponseWriter, r *http.Request) {
+	c := model.Campaign{}
+	if !h.decode(w, r, &c) {
+		return
+	}
+
+	c, err := h.Firmware.CreateCampaign(c)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusCreated, c)
+}
+
+func (h *FirmwareHandler) HandleGetCampaign(w http.ResponseWriter, r *http.Request) {
+	c, err := h.Firmware.GetCampaign(r.URL.Query().Get("id"))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, c)
+}
+
+func (h *FirmwareHandler) HandleListCampaigns(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, http.StatusOK, h.Firmware.ListCampaigns())
+}
+
+func (h *FirmwareHandler) HandlePauseCampaign(w http.ResponseWriter, r *http.Request) {
+	h.handleCampaignAction(w, r, h.Firmware.PauseCampaign)
+}
+
+func (h *FirmwareHandler) HandleResumeCampaign(w http.ResponseWriter, r *http.Request) {
+	h.handleCampaignAction(w, r, h.Firmware.ResumeCampaign)
+}
+
+func (h *FirmwareHandler) HandleAbortCampaign(w http.ResponseWriter, r *http.Request) {
+	h.handleCampaignAction(w, r, h.Firmware.AbortCampaign)
+}
+
+// HandleGetJob answers a device agent polling for work: 200 with a job or 204 if there is none.
+func (h *FirmwareHandler) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.Firmware.GetJob(serial(r))
+	if errors.Is(err, firmware.ErrNoJob) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, job)
+}
+
+func (h *FirmwareHandler) HandleReportProgress(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Status  model.RolloutStatus `json:"status"`
+		Message string              `json:"message"`
+	}{}
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	q := r.URL.Query()
+	c, err := h.Firmware.ReportProgress(q.Get("id"), q.Get("num"), req.Status, req.Message)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, c.Devices[q.Get("num")])
+}
+
+func (h *FirmwareHandler) handleCampaignAction(
+	w http.ResponseWriter, r *http.Request,
+	action func(id string) (model.Campaign, error),
+) {
+	c, err := action(r.URL.Query().Get("id"))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, c)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"homework/internal/freeze"
+	"homework/internal/middleware"
+	"homework/internal/model"
+	"net/http"
+	"net/url"
+)
+
+type FreezeHandler struct {
+	*Handler
+	Freeze freeze.Service
+}
+
+func NewFreezeHandler(h *Handler, f freeze.Service) *FreezeHandler {
+	return &FreezeHandler{Handler: h, Freeze: f}
+}
+
+func (h *FreezeHandler) HandleCreateWindow(w http.ResponseWriter, r *http.Request) {
+	win := model.Window{}
+	if !h.decode(w, r, &win) {
+		return
+	}
+
+	win, err := h.Freeze.CreateWindow(win, middleware.GetRequestID(r.Context()))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/windows/"+url.PathEscape(win.ID))
+	h.write(w, r, http.StatusCreated, win)
+}
+
+// HandleSchedule returns the active and upcoming windows.
+func (h *FreezeHandler) HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, http.StatusOK, h.Freeze.Schedule())
+}
+
+func (h *FreezeHandler) HandleDeleteWindow(w http.ResponseWriter, r *http.Request) {
+	if err := h.Freeze.DeleteWindow(r.PathValue("id"), middleware.GetRequestID(r.Context())); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *FreezeHandler) HandleOverrides(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, http.StatusOK, h.Freeze.Overrides())
+}
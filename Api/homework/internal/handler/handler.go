@@ -1,57 +1,150 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"homework/internal/backup"
+	"homework/internal/catalog"
+	"homework/internal/firmware"
+	"homework/internal/freeze"
+	"homework/internal/middleware"
 	"homework/internal/model"
+	"homework/internal/retention"
+	"homework/internal/search"
 	"homework/internal/service"
+	"homework/internal/shadow"
+	"homework/internal/topology"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const resourceVersionHeader = "X-Resource-Version"
+
+// defaultMaxBodyBytes limits request bodies unless WithMaxBodyBytes sets another limit.
+const defaultMaxBodyBytes = 1 << 20
+
 type Handler struct {
 	Service service.Service
+
+	maxBodyBytes int64
 }
 
-func NewHandler(s service.Service) *Handler {
-	return &Handler{Service: s}
+type Option func(h *Handler)
+
+// WithMaxBodyBytes limits request bodies to n bytes, larger ones get 413.
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodyBytes = n
+	}
+}
+
+func NewHandler(s service.Service, options ...Option) *Handler {
+	h := &Handler{Service: s, maxBodyBytes: defaultMaxBodyBytes}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// serial reads the device serial number from the path of /v1 routes
+// or from the num query parameter of legacy ones.
+func serial(r *http.Request) string {
+	num := r.PathValue("serial")
+	if num == "" {
+		num = r.URL.Query().Get("num")
+	}
+	middleware.SetSerial(r.Context(), num)
+	return num
+}
+
+// service returns the service bound to the request context, so tracing spans nest under the request span.
+func (h *Handler) service(r *http.Request) service.Service {
+	return service.Bind(r.Context(), h.Service)
+}
+
+// deviceRequest is a device with an optional ttl, setting expires_at relative to now.
+type deviceRequest struct {
+	model.Device
+	TTL string `json:"ttl,omitempty"`
+}
+
+func (h *Handler) decodeDevice(w http.ResponseWriter, r *http.Request) (model.Device, bool) {
+	req := deviceRequest{}
+	if !h.decode(w, r, &req) {
+		return model.Device{}, false
+	}
+	d := req.Device
+	if req.TTL != "" && d.ExpiresAt == nil {
+		ttl, err := retention.ParseTTL(req.TTL)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return model.Device{}, false
+		}
+		at := time.Now().Add(ttl).UTC()
+		d.ExpiresAt = &at
+	}
+	return d, true
 }
 
 func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
-	d := model.Device{}
-	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
-		h.ErrResponse(w, "Invalid request", http.StatusBadRequest)
+	d, ok := h.decodeDevice(w, r)
+	if !ok {
 		return
 	}
+	middleware.SetSerial(r.Context(), d.SerialNum)
 
-	if err := h.Service.CreateDevice(d); err != nil {
+	if err := h.service(r).CreateDevice(d); err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
+	w.Header().Set("Location", "/v1/devices/"+url.PathEscape(d.SerialNum))
 	w.WriteHeader(http.StatusCreated)
 }
 
 func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
-	num := r.URL.Query().Get("num")
-	d, err := h.Service.GetDevice(num)
+	num := serial(r)
+	d, err := h.service(r).GetDevice(num)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
-	response, err := json.Marshal(d)
-	if err != nil {
-		h.ErrResponse(w, "JSON can't be marshaled", http.StatusInternalServerError)
+	h.write(w, r, http.StatusOK, d)
+}
+
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	num := serial(r)
+	if err := h.service(r).DeleteDevice(num); err != nil {
+		h.handleServiceError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(response)
 }
 
-func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
-	num := r.URL.Query().Get("num")
-	if err := h.Service.DeleteDevice(num); err != nil {
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.decodeDevice(w, r)
+	if !ok {
+		return
+	}
+	// on /v1/devices/{serial} the path names the device, the body may omit it
+	if num := r.PathValue("serial"); num != "" {
+		if d.SerialNum == "" {
+			d.SerialNum = num
+		}
+		if d.SerialNum != num {
+			h.ErrResponse(w, "Serial number in body doesn't match the path", http.StatusBadRequest)
+			return
+		}
+	}
+	middleware.SetSerial(r.Context(), d.SerialNum)
+
+	if err := h.service(r).UpdateDevice(d); err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
@@ -59,14 +152,17 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
-	d := model.Device{}
-	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
-		h.ErrResponse(w, "Invalid request", http.StatusBadRequest)
+func (h *Handler) HandleTransition(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		State  model.State `json:"state"`
+		Reason string      `json:"reason"`
+	}{}
+	if !h.decode(w, r, &req) {
 		return
 	}
 
-	if err := h.Service.UpdateDevice(d); err != nil {
+	num := serial(r)
+	if err := h.service(r).TransitionDevice(num, req.State, req.Reason); err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
@@ -74,10 +170,150 @@ func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// HandleList returns the filtered devices and the registry resource version in
+// the X-Resource-Version header. With watch=true it streams changes instead, see handleWatch.
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		h.ErrResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("watch") == "true" {
+		h.handleWatch(w, r, f)
+		return
+	}
+
+	// read before listing: a concurrent change is then replayed by a watch instead of being missed
+	rv := h.service(r).ResourceVersion()
+	devices, err := h.service(r).ListDevices(f)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.Header().Set(resourceVersionHeader, strconv.FormatUint(rv, 10))
+	h.write(w, r, http.StatusOK, devices)
+}
+
+// handleWatch streams every change after resourceVersion matching f as newline-delimited JSON.
+// Without resourceVersion it starts from the current version. The stream ends when the client
+// disconnects or after timeoutSeconds, so clients may long-poll and resume from the last version seen.
+// A version older than the retained history or newer than the current one, e.g. from before
+// a restart, gets 410 Gone; if history runs out mid-stream,
+// an ERROR event with code 410 is sent and the stream ends.
+func (h *Handler) handleWatch(w http.ResponseWriter, r *http.Request, f service.Filter) {
+	q := r.URL.Query()
+
+	rv := h.service(r).ResourceVersion()
+	if v := q.Get("resourceVersion"); v != "" {
+		var err error
+		if rv, err = strconv.ParseUint(v, 10, 64); err != nil {
+			h.ErrResponse(w, "Invalid resourceVersion", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var timeout <-chan time.Time
+	if v := q.Get("timeoutSeconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			h.ErrResponse(w, "Invalid timeoutSeconds", http.StatusBadRequest)
+			return
+		}
+		timer := time.NewTimer(time.Duration(seconds) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.ErrResponse(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	changes, next, err := h.service(r).Changes(rv)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		for _, c := range changes {
+			rv = c.ResourceVersion
+			if f.Match(c.Device) {
+				_ = enc.Encode(c)
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout:
+			return
+		case <-next:
+		}
+
+		if changes, next, err = h.service(r).Changes(rv); err != nil {
+			_ = enc.Encode(map[string]any{"type": "ERROR", "code": http.StatusGone, "reason": model.ReasonResourceVersionGone, "message": err.Error()})
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// parseFilter reads list filters from the query: state, model,
+// repeated label=key=value and repeated attr=path=value.
+func parseFilter(r *http.Request) (service.Filter, error) {
+	q := r.URL.Query()
+	f := service.Filter{State: model.State(q.Get("state")), Model: q.Get("model")}
+
+	var err error
+	if f.Labels, err = parseSelector(q["label"]); err != nil {
+		return service.Filter{}, err
+	}
+	if f.Attributes, err = parseSelector(q["attr"]); err != nil {
+		return service.Filter{}, err
+	}
+	return f, nil
+}
+
+// parseSelector turns key=value pairs into a map. It returns nil for no pairs.
+func parseSelector(pairs []string) (map[string]string, error) {
+	var m map[string]string
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, errors.New("invalid selector " + p)
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
 func (h *Handler) handleServiceError(w http.ResponseWriter, err error) {
 	var httpStatus int
 	var message string
 
+	var validationErr *catalog.ValidationError
+	if errors.As(err, &validationErr) {
+		h.writeJSON(w, http.StatusBadRequest, map[string]any{
+			"message": service.ErrInvalidAttributes.Error(),
+			"reason":  model.ReasonInvalidAttributes,
+			"errors":  validationErr.Errors,
+		})
+		return
+	}
+
 	switch {
 	case errors.Is(err, service.ErrDeviceAlreadyExists):
 		httpStatus = http.StatusConflict
@@ -85,6 +321,79 @@ func (h *Handler) handleServiceError(w http.ResponseWriter, err error) {
 	case errors.Is(err, service.ErrDeviceDoesNotExist):
 		httpStatus = http.StatusNotFound
 		message = "Device doesn't exist"
+	case errors.Is(err, firmware.ErrCampaignDoesNotExist):
+		fallthrough
+	case errors.Is(err, catalog.ErrModelDoesNotExist):
+		fallthrough
+	case errors.Is(err, catalog.ErrSchemaDoesNotExist):
+		fallthrough
+	case errors.Is(err, firmware.ErrDeviceNotInCampaign):
+		fallthrough
+	case errors.Is(err, topology.ErrLinkDoesNotExist):
+		fallthrough
+	case errors.Is(err, topology.ErrNotContained):
+		fallthrough
+	case errors.Is(err, topology.ErrNoPath):
+		fallthrough
+	case errors.Is(err, retention.ErrPolicyDoesNotExist):
+		fallthrough
+	case errors.Is(err, retention.ErrNotInTrash):
+		fallthrough
+	case errors.Is(err, freeze.ErrWindowDoesNotExist):
+		httpStatus = http.StatusNotFound
+		message = err.Error()
+	case errors.Is(err, service.ErrResourceVersionGone):
+		httpStatus = http.StatusGone
+		message = err.Error()
+	case errors.Is(err, backup.ErrSnapshotBusy):
+		httpStatus = http.StatusServiceUnavailable
+		message = err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		httpStatus = http.StatusGatewayTimeout
+		message = err.Error()
+	case errors.Is(err, service.ErrIllegalTransition):
+		fallthrough
+	case errors.Is(err, catalog.ErrModelAlreadyExists):
+		fallthrough
+	case errors.Is(err, service.ErrDeviceDecommissioned):
+		fallthrough
+	case errors.Is(err, shadow.ErrVersionConflict):
+		fallthrough
+	case errors.Is(err, firmware.ErrIllegalCampaignState):
+		fallthrough
+	case errors.Is(err, topology.ErrPortInUse):
+		fallthrough
+	case errors.Is(err, topology.ErrSlotInUse):
+		fallthrough
+	case errors.Is(err, topology.ErrContainmentCycle):
+		fallthrough
+	case errors.Is(err, topology.ErrDeviceInUse):
+		fallthrough
+	case errors.Is(err, freeze.ErrFrozen):
+		httpStatus = http.StatusConflict
+		message = err.Error()
+	case errors.Is(err, service.ErrInvalidState):
+		fallthrough
+	case errors.Is(err, firmware.ErrInvalidCampaign):
+		fallthrough
+	case errors.Is(err, catalog.ErrInvalidSchema):
+		fallthrough
+	case errors.Is(err, service.ErrInvalidAttributes):
+		fallthrough
+	case errors.Is(err, firmware.ErrInvalidRolloutStatus):
+		fallthrough
+	case errors.Is(err, search.ErrEmptyQuery):
+		fallthrough
+	case errors.Is(err, topology.ErrInvalidLink):
+		fallthrough
+	case errors.Is(err, topology.ErrInvalidContainment):
+		fallthrough
+	case errors.Is(err, freeze.ErrInvalidWindow):
+		fallthrough
+	case errors.Is(err, retention.ErrInvalidPolicy):
+		fallthrough
+	case errors.Is(err, retention.ErrInvalidTTL):
+		fallthrough
 	case errors.Is(err, service.ErrInvalidModel):
 		fallthrough
 	case errors.Is(err, service.ErrInvalidSerialNumber):
@@ -97,11 +406,58 @@ func (h *Handler) handleServiceError(w http.ResponseWriter, err error) {
 		message = "Internal server error"
 	}
 
-	h.ErrResponse(w, message, httpStatus)
+	reason, ok := errorReason(err)
+	if !ok {
+		h.ErrResponse(w, message, httpStatus)
+		return
+	}
+	h.writeJSON(w, httpStatus, map[string]any{"message": message, "reason": reason})
+}
+
+// reasons are the service errors clients tell apart by the reason of the response.
+var reasons = []struct {
+	err    error
+	reason model.Reason
+}{
+	{service.ErrDeviceAlreadyExists, model.ReasonDeviceAlreadyExists},
+	{service.ErrDeviceDoesNotExist, model.ReasonDeviceDoesNotExist},
+	{service.ErrInvalidModel, model.ReasonInvalidModel},
+	{service.ErrInvalidSerialNumber, model.ReasonInvalidSerialNumber},
+	{service.ErrInvalidIPAddress, model.ReasonInvalidIPAddress},
+	{service.ErrInvalidState, model.ReasonInvalidState},
+	{service.ErrIllegalTransition, model.ReasonIllegalTransition},
+	{service.ErrDeviceDecommissioned, model.ReasonDeviceDecommissioned},
+	{service.ErrInvalidAttributes, model.ReasonInvalidAttributes},
+	{service.ErrResourceVersionGone, model.ReasonResourceVersionGone},
+	{freeze.ErrFrozen, model.ReasonFrozen},
+	{topology.ErrDeviceInUse, model.ReasonDeviceInUse},
+}
+
+func errorReason(err error) (model.Reason, bool) {
+	for _, r := range reasons {
+		if errors.Is(err, r.err) {
+			return r.reason, true
+		}
+	}
+	return "", false
 }
 
+// ErrResponse writes an error message. Errors are always JSON, whatever the request accepts.
 func (h *Handler) ErrResponse(w http.ResponseWriter, message string, errStatus int) {
 	response, _ := json.Marshal(map[string]string{"message": message})
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(errStatus)
 	_, _ = w.Write(response)
 }
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		h.ErrResponse(w, "JSON can't be marshaled", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(response)
+}
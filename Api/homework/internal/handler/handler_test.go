@@ -3,19 +3,24 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"homework/internal/catalog"
+	"homework/internal/codec"
+	"homework/internal/export"
 	"homework/internal/model"
 	"homework/internal/service"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 type HandlerSuite struct {
 	suite.Suite
 	service *ServiceMock
-	h       Handler
+	h       *Handler
 	r       *httptest.ResponseRecorder
 }
 
@@ -73,6 +78,62 @@ func (s *HandlerSuite) TestHandleUpdate() {
 	assert.Equal(s.T(), http.StatusOK, s.r.Code)
 }
 
+func (s *HandlerSuite) TestHandleCreateYAML() {
+	d := model.Device{SerialNum: "12345", Model: "TestModel", IP: "1.1.1.1", Labels: map[string]string{"rack": "a"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader("serial_number: \"12345\"\nmodel: TestModel\nip: 1.1.1.1\nlabels:\n  rack: a\n"))
+	req.Header.Set("Content-Type", "application/yaml")
+
+	s.service.CreateDeviceMock.Expect(d).Return(nil)
+	s.h.HandleCreate(s.r, req)
+
+	assert.Equal(s.T(), http.StatusCreated, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleGetNegotiated() {
+	d := model.Device{SerialNum: "12345", Model: "TestModel", IP: "1.1.1.1"}
+	s.service.GetDeviceMock.Expect("12345").Return(d, nil)
+
+	for _, accept := range []string{"application/xml", "application/msgpack, application/json;q=0.5"} {
+		r := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/get?num=12345", nil)
+		req.Header.Set("Accept", accept)
+		s.h.HandleGet(r, req)
+
+		assert.Equal(s.T(), http.StatusOK, r.Code)
+		c, _ := codec.Negotiate(accept)
+		assert.Equal(s.T(), c.ContentType(), r.Header().Get("Content-Type"))
+
+		var got model.Device
+		assert.Nil(s.T(), c.Decode(r.Body, &got))
+		assert.Equal(s.T(), d, got)
+	}
+}
+
+func (s *HandlerSuite) TestHandleCreateUnsupportedMediaType() {
+	req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader("serial_number=12345"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.h.HandleCreate(s.r, req)
+
+	assert.Equal(s.T(), http.StatusUnsupportedMediaType, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleCreateNotAcceptable() {
+	req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{"serial_number":"12345"}`))
+	req.Header.Set("Accept", "text/html")
+	s.h.HandleCreate(s.r, req)
+
+	assert.Equal(s.T(), http.StatusNotAcceptable, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleCreateTooLarge() {
+	h := NewHandler(s.service, WithMaxBodyBytes(16))
+	req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{"serial_number":"12345","model":"TestModel"}`))
+	h.HandleCreate(s.r, req)
+
+	assert.Equal(s.T(), http.StatusRequestEntityTooLarge, s.r.Code)
+}
+
 func (s *HandlerSuite) TestHandleCreateInvalidRequest() {
 	req := httptest.NewRequest(http.MethodPost, "/create", nil)
 
@@ -133,3 +194,183 @@ func (s *HandlerSuite) TestInvalidIP() {
 
 	assert.Equal(s.T(), http.StatusBadRequest, s.r.Code)
 }
+
+func (s *HandlerSuite) TestHandleTransition() {
+	payload := []byte(`{"state":"received","reason":"arrived"}`)
+	req := httptest.NewRequest(http.MethodPost, "/device/transition?num=12345", bytes.NewReader(payload))
+
+	s.service.TransitionDeviceMock.Expect("12345", model.StateReceived, "arrived").Return(nil)
+	s.h.HandleTransition(s.r, req)
+
+	assert.Equal(s.T(), http.StatusOK, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleTransitionIllegal() {
+	payload := []byte(`{"state":"active","reason":""}`)
+	req := httptest.NewRequest(http.MethodPost, "/device/transition?num=12345", bytes.NewReader(payload))
+
+	s.service.TransitionDeviceMock.Expect("12345", model.StateActive, "").Return(service.ErrIllegalTransition)
+	s.h.HandleTransition(s.r, req)
+
+	assert.Equal(s.T(), http.StatusConflict, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleList() {
+	devices := []model.Device{{SerialNum: "12345", Model: "TestModel", IP: "1.1.1.1", State: model.StateActive}}
+	req := httptest.NewRequest(http.MethodGet, "/devices?state=active", nil)
+
+	s.service.ResourceVersionMock.Return(42)
+	s.service.ListDevicesMock.Expect(service.Filter{State: model.StateActive}).Return(devices, nil)
+	s.h.HandleList(s.r, req)
+
+	assert.Equal(s.T(), http.StatusOK, s.r.Code)
+	assert.Equal(s.T(), "42", s.r.Header().Get("X-Resource-Version"))
+
+	var got []model.Device
+	assert.Nil(s.T(), json.Unmarshal(s.r.Body.Bytes(), &got))
+	assert.Equal(s.T(), devices, got)
+}
+
+func (s *HandlerSuite) TestHandleListFilter() {
+	req := httptest.NewRequest(http.MethodGet, "/devices?model=switch&label=site=lab&label=rack=1", nil)
+
+	f := service.Filter{Model: "switch", Labels: map[string]string{"site": "lab", "rack": "1"}}
+	s.service.ResourceVersionMock.Return(0)
+	s.service.ListDevicesMock.Expect(f).Return([]model.Device{}, nil)
+	s.h.HandleList(s.r, req)
+
+	assert.Equal(s.T(), http.StatusOK, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleListInvalidLabel() {
+	req := httptest.NewRequest(http.MethodGet, "/devices?label=site", nil)
+
+	s.h.HandleList(s.r, req)
+
+	assert.Equal(s.T(), http.StatusBadRequest, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleCreateInvalidAttributes() {
+	d := model.Device{SerialNum: "12345", Model: "switch", IP: "1.1.1.1", Attributes: map[string]any{"ports": "many"}}
+
+	payload, _ := json.Marshal(d)
+	req := httptest.NewRequest(http.MethodPost, "/create", bytes.NewReader(payload))
+
+	validationErr := &catalog.ValidationError{
+		Model:   "switch",
+		Version: 1,
+		Errors:  []catalog.FieldError{{Path: "/ports", Message: "expected integer, got string"}},
+	}
+	s.service.CreateDeviceMock.Expect(d).Return(validationErr)
+	s.h.HandleCreate(s.r, req)
+
+	assert.Equal(s.T(), http.StatusBadRequest, s.r.Code)
+	assert.JSONEq(s.T(),
+		`{"message":"invalid attributes","reason":"invalid_attributes","errors":[{"path":"/ports","message":"expected integer, got string"}]}`,
+		s.r.Body.String())
+}
+
+func (s *HandlerSuite) TestHandlePrometheusETag() {
+	eh := NewExportHandler(s.h, export.PrometheusConfig{})
+	devices := []model.Device{{SerialNum: "12345", Model: "TestModel", IP: "1.1.1.1"}}
+	s.service.ListDevicesMock.Return(devices, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/export/prometheus", nil)
+	eh.HandlePrometheus(s.r, req)
+
+	assert.Equal(s.T(), http.StatusOK, s.r.Code)
+	assert.JSONEq(s.T(), `[{"targets":["1.1.1.1:9100"],"labels":{"serial":"12345","model":"TestModel"}}]`, s.r.Body.String())
+	etag := s.r.Header().Get("ETag")
+	assert.NotEmpty(s.T(), etag)
+
+	r := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/export/prometheus", nil)
+	req.Header.Set("If-None-Match", etag)
+	eh.HandlePrometheus(r, req)
+
+	assert.Equal(s.T(), http.StatusNotModified, r.Code)
+	assert.Empty(s.T(), r.Body.String())
+
+	for header, want := range map[string]int{
+		`"other", ` + etag:     http.StatusNotModified,
+		"W/" + etag:            http.StatusNotModified,
+		"*":                    http.StatusNotModified,
+		`"other", W/"another"`: http.StatusOK,
+	} {
+		r = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/export/prometheus", nil)
+		req.Header.Set("If-None-Match", header)
+		eh.HandlePrometheus(r, req)
+		assert.Equal(s.T(), want, r.Code, header)
+	}
+}
+
+func (s *HandlerSuite) TestHandleWatchGone() {
+	req := httptest.NewRequest(http.MethodGet, "/devices?watch=true&resourceVersion=1", nil)
+
+	s.service.ResourceVersionMock.Return(5000)
+	s.service.ChangesMock.Expect(1).Return(nil, nil, service.ErrResourceVersionGone)
+	s.h.HandleList(s.r, req)
+
+	assert.Equal(s.T(), http.StatusGone, s.r.Code)
+}
+
+func (s *HandlerSuite) TestHandleReadiness() {
+	var storageErr error
+	hh := NewHealthHandler(s.h, map[string]Check{"storage": func() error { return storageErr }})
+
+	hh.HandleReadiness(s.r, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(s.T(), http.StatusOK, s.r.Code)
+
+	storageErr = errors.New("disk full")
+	hh.ShutDown()
+	r := httptest.NewRecorder()
+	hh.HandleReadiness(r, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(s.T(), http.StatusServiceUnavailable, r.Code)
+	assert.JSONEq(s.T(), `{"status":"unavailable","checks":{"server":"shutting down","storage":"disk full"}}`, r.Body.String())
+
+	r = httptest.NewRecorder()
+	hh.HandleLiveness(r, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(s.T(), http.StatusOK, r.Code)
+}
+
+func TestHandleWatch(t *testing.T) {
+	svc := service.NewService(service.NewStorage())
+	srv := httptest.NewServer(http.HandlerFunc(NewHandler(svc).HandleList))
+	defer srv.Close()
+
+	_ = svc.CreateDevice(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"})
+
+	resp, err := http.Get(srv.URL + "/devices")
+	assert.Nil(t, err)
+	rv := resp.Header.Get("X-Resource-Version")
+	_ = resp.Body.Close()
+	assert.Equal(t, "1", rv)
+
+	_ = svc.CreateDevice(model.Device{SerialNum: "2", Model: "display", IP: "1.1.1.1"})
+
+	resp, err = http.Get(srv.URL + "/devices?watch=true&model=switch&timeoutSeconds=5&resourceVersion=" + rv)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	go func() {
+		_ = svc.TransitionDevice("1", model.StateReceived, "")
+		_ = svc.DeleteDevice("2")
+		_ = svc.DeleteDevice("1")
+	}()
+
+	dec := json.NewDecoder(resp.Body)
+	var changes []model.Change
+	for len(changes) < 2 {
+		var c model.Change
+		assert.Nil(t, dec.Decode(&c))
+		changes = append(changes, c)
+	}
+
+	assert.Equal(t, model.ChangeModified, changes[0].Type)
+	assert.Equal(t, uint64(3), changes[0].ResourceVersion)
+	assert.Equal(t, model.StateReceived, changes[0].Device.State)
+	assert.Equal(t, model.ChangeDeleted, changes[1].Type)
+	assert.Equal(t, uint64(5), changes[1].ResourceVersion)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+var errShuttingDown = errors.New("shutting down")
+
+// Check reports why the server can't serve traffic, or nil if it can.
+type Check func() error
+
+type HealthHandler struct {
+	*Handler
+	Checks map[string]Check
+
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(h *Handler, checks map[string]Check) *HealthHandler {
+	return &HealthHandler{Handler: h, Checks: checks}
+}
+
+// ShutDown makes readiness fail so that load balancers stop sending requests.
+func (h *HealthHandler) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+// HandleLiveness reports that the process is up.
+func (h *HealthHandler) HandleLiveness(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// HandleReadiness runs the checks and answers 503 with the failed ones.
+func (h *HealthHandler) HandleReadiness(w http.ResponseWriter, _ *http.Request) {
+	failed := make(map[string]string)
+	if h.shuttingDown.Load() {
+		failed["server"] = errShuttingDown.Error()
+	}
+	for name, check := range h.Checks {
+		if err := check(); err != nil {
+			failed[name] = err.Error()
+		}
+	}
+
+	if len(failed) > 0 {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "checks": failed})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"homework/internal/replication"
+	"net/http"
+)
+
+type ReplicationHandler struct {
+	*Handler
+	// Follower is nil on the leader.
+	Follower *replication.Follower
+}
+
+func NewReplicationHandler(h *Handler, f *replication.Follower) *ReplicationHandler {
+	return &ReplicationHandler{Handler: h, Follower: f}
+}
+
+func (h *ReplicationHandler) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	if h.Follower != nil {
+		h.writeJSON(w, http.StatusOK, h.Follower.Status())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, replication.Status{
+		Role:            "leader",
+		ResourceVersion: h.Service.ResourceVersion(),
+		Connected:       true,
+	})
+}
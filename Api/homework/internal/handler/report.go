@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"homework/internal/report"
+	"net/http"
+)
+
+type ReportHandler struct {
+	*Handler
+	Report report.Service
+}
+
+func NewReportHandler(h *Handler, r report.Service) *ReportHandler {
+	return &ReportHandler{Handler: h, Report: r}
+}
+
+// HandleReport checks the registry now. format=text renders the report for humans.
+func (h *ReportHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	rep := h.Report.Generate()
+	if r.URL.Query().Get("format") != "text" {
+		h.write(w, r, http.StatusOK, rep)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = report.WriteText(w, rep)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"homework/internal/model"
+	"homework/internal/retention"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultPreviewDays = 7
+
+type RetentionHandler struct {
+	*Handler
+	Retention retention.Service
+}
+
+func NewRetentionHandler(h *Handler, s retention.Service) *RetentionHandler {
+	return &RetentionHandler{Handler: h, Retention: s}
+}
+
+func (h *RetentionHandler) HandleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	p := model.RetentionPolicy{}
+	if !h.decode(w, r, &p) {
+		return
+	}
+
+	p, err := h.Retention.CreatePolicy(p)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/retention/policies/"+url.PathEscape(p.ID))
+	h.write(w, r, http.StatusCreated, p)
+}
+
+func (h *RetentionHandler) HandleListPolicies(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, http.StatusOK, h.Retention.ListPolicies())
+}
+
+func (h *RetentionHandler) HandleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	if err := h.Retention.DeletePolicy(r.PathValue("id")); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandlePreview returns the devices expiring within the days query parameter, without reaping them.
+func (h *RetentionHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	days := defaultPreviewDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil || days < 0 {
+			h.ErrResponse(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	expiries, err := h.Retention.Preview(time.Now().AddDate(0, 0, days))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, expiries)
+}
+
+func (h *RetentionHandler) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, http.StatusOK, h.Retention.ListTrash())
+}
+
+func (h *RetentionHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	num := serial(r)
+	if err := h.Retention.RestoreDevice(num); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/devices/"+url.PathEscape(num))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *RetentionHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+	if err := h.Retention.PurgeDevice(serial(r)); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
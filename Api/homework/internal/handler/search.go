@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"homework/internal/search"
+	"net/http"
+	"strconv"
+)
+
+const defaultSearchLimit = 20
+
+type SearchHandler struct {
+	*Handler
+	Search search.Service
+}
+
+func NewSearchHandler(h *Handler, s search.Service) *SearchHandler {
+	return &SearchHandler{Handler: h, Search: s}
+}
+
+// HandleSearch returns the devices best matching the q query parameter, at most limit of them.
+func (h *SearchHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := defaultSearchLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			h.ErrResponse(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.Search.Search(q.Get("q"), limit)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, results)
+}
@@ -6,6 +6,7 @@ package handler
 
 import (
 	"homework/internal/model"
+	mm_service "homework/internal/service"
 	"sync"
 	mm_atomic "sync/atomic"
 	mm_time "time"
@@ -13,10 +14,17 @@ import (
 	"github.com/gojuno/minimock/v3"
 )
 
-// ServiceMock implements Service
+// ServiceMock implements service.Service
 type ServiceMock struct {
 	t minimock.Tester
 
+	funcChanges func(rv uint64) (ca1 []model.Change, ch1 <-chan struct {
+	}, err error)
+	inspectFuncChanges   func(rv uint64)
+	afterChangesCounter  uint64
+	beforeChangesCounter uint64
+	ChangesMock          mServiceMockChanges
+
 	funcCreateDevice          func(d1 model.Device) (err error)
 	inspectFuncCreateDevice   func(d1 model.Device)
 	afterCreateDeviceCounter  uint64
@@ -35,6 +43,36 @@ type ServiceMock struct {
 	beforeGetDeviceCounter uint64
 	GetDeviceMock          mServiceMockGetDevice
 
+	funcListDevices          func(f1 mm_service.Filter) (da1 []model.Device, err error)
+	inspectFuncListDevices   func(f1 mm_service.Filter)
+	afterListDevicesCounter  uint64
+	beforeListDevicesCounter uint64
+	ListDevicesMock          mServiceMockListDevices
+
+	funcResourceVersion          func() (u1 uint64)
+	inspectFuncResourceVersion   func()
+	afterResourceVersionCounter  uint64
+	beforeResourceVersionCounter uint64
+	ResourceVersionMock          mServiceMockResourceVersion
+
+	funcRestoreDevice          func(d1 model.Device) (err error)
+	inspectFuncRestoreDevice   func(d1 model.Device)
+	afterRestoreDeviceCounter  uint64
+	beforeRestoreDeviceCounter uint64
+	RestoreDeviceMock          mServiceMockRestoreDevice
+
+	funcSetFirmware          func(num string, firmware string) (err error)
+	inspectFuncSetFirmware   func(num string, firmware string)
+	afterSetFirmwareCounter  uint64
+	beforeSetFirmwareCounter uint64
+	SetFirmwareMock          mServiceMockSetFirmware
+
+	funcTransitionDevice          func(num string, to model.State, reason string) (err error)
+	inspectFuncTransitionDevice   func(num string, to model.State, reason string)
+	afterTransitionDeviceCounter  uint64
+	beforeTransitionDeviceCounter uint64
+	TransitionDeviceMock          mServiceMockTransitionDevice
+
 	funcUpdateDevice          func(d1 model.Device) (err error)
 	inspectFuncUpdateDevice   func(d1 model.Device)
 	afterUpdateDeviceCounter  uint64
@@ -42,13 +80,16 @@ type ServiceMock struct {
 	UpdateDeviceMock          mServiceMockUpdateDevice
 }
 
-// NewServiceMock returns a mock for Service
+// NewServiceMock returns a mock for service.Service
 func NewServiceMock(t minimock.Tester) *ServiceMock {
 	m := &ServiceMock{t: t}
 	if controller, ok := t.(minimock.MockController); ok {
 		controller.RegisterMocker(m)
 	}
 
+	m.ChangesMock = mServiceMockChanges{mock: m}
+	m.ChangesMock.callArgs = []*ServiceMockChangesParams{}
+
 	m.CreateDeviceMock = mServiceMockCreateDevice{mock: m}
 	m.CreateDeviceMock.callArgs = []*ServiceMockCreateDeviceParams{}
 
@@ -58,12 +99,248 @@ func NewServiceMock(t minimock.Tester) *ServiceMock {
 	m.GetDeviceMock = mServiceMockGetDevice{mock: m}
 	m.GetDeviceMock.callArgs = []*ServiceMockGetDeviceParams{}
 
+	m.ListDevicesMock = mServiceMockListDevices{mock: m}
+	m.ListDevicesMock.callArgs = []*ServiceMockListDevicesParams{}
+
+	m.ResourceVersionMock = mServiceMockResourceVersion{mock: m}
+
+	m.RestoreDeviceMock = mServiceMockRestoreDevice{mock: m}
+	m.RestoreDeviceMock.callArgs = []*ServiceMockRestoreDeviceParams{}
+
+	m.SetFirmwareMock = mServiceMockSetFirmware{mock: m}
+	m.SetFirmwareMock.callArgs = []*ServiceMockSetFirmwareParams{}
+
+	m.TransitionDeviceMock = mServiceMockTransitionDevice{mock: m}
+	m.TransitionDeviceMock.callArgs = []*ServiceMockTransitionDeviceParams{}
+
 	m.UpdateDeviceMock = mServiceMockUpdateDevice{mock: m}
 	m.UpdateDeviceMock.callArgs = []*ServiceMockUpdateDeviceParams{}
 
 	return m
 }
 
+type mServiceMockChanges struct {
+	mock               *ServiceMock
+	defaultExpectation *ServiceMockChangesExpectation
+	expectations       []*ServiceMockChangesExpectation
+
+	callArgs []*ServiceMockChangesParams
+	mutex    sync.RWMutex
+}
+
+// ServiceMockChangesExpectation specifies expectation struct of the Service.Changes
+type ServiceMockChangesExpectation struct {
+	mock    *ServiceMock
+	params  *ServiceMockChangesParams
+	results *ServiceMockChangesResults
+	Counter uint64
+}
+
+// ServiceMockChangesParams contains parameters of the Service.Changes
+type ServiceMockChangesParams struct {
+	rv uint64
+}
+
+// ServiceMockChangesResults contains results of the Service.Changes
+type ServiceMockChangesResults struct {
+	ca1 []model.Change
+	ch1 <-chan struct {
+	}
+	err error
+}
+
+// Expect sets up expected params for Service.Changes
+func (mmChanges *mServiceMockChanges) Expect(rv uint64) *mServiceMockChanges {
+	if mmChanges.mock.funcChanges != nil {
+		mmChanges.mock.t.Fatalf("ServiceMock.Changes mock is already set by Set")
+	}
+
+	if mmChanges.defaultExpectation == nil {
+		mmChanges.defaultExpectation = &ServiceMockChangesExpectation{}
+	}
+
+	mmChanges.defaultExpectation.params = &ServiceMockChangesParams{rv}
+	for _, e := range mmChanges.expectations {
+		if minimock.Equal(e.params, mmChanges.defaultExpectation.params) {
+			mmChanges.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmChanges.defaultExpectation.params)
+		}
+	}
+
+	return mmChanges
+}
+
+// Inspect accepts an inspector function that has same arguments as the Service.Changes
+func (mmChanges *mServiceMockChanges) Inspect(f func(rv uint64)) *mServiceMockChanges {
+	if mmChanges.mock.inspectFuncChanges != nil {
+		mmChanges.mock.t.Fatalf("Inspect function is already set for ServiceMock.Changes")
+	}
+
+	mmChanges.mock.inspectFuncChanges = f
+
+	return mmChanges
+}
+
+// Return sets up results that will be returned by Service.Changes
+func (mmChanges *mServiceMockChanges) Return(ca1 []model.Change, ch1 <-chan struct {
+}, err error) *ServiceMock {
+	if mmChanges.mock.funcChanges != nil {
+		mmChanges.mock.t.Fatalf("ServiceMock.Changes mock is already set by Set")
+	}
+
+	if mmChanges.defaultExpectation == nil {
+		mmChanges.defaultExpectation = &ServiceMockChangesExpectation{mock: mmChanges.mock}
+	}
+	mmChanges.defaultExpectation.results = &ServiceMockChangesResults{ca1, ch1, err}
+	return mmChanges.mock
+}
+
+// Set uses given function f to mock the Service.Changes method
+func (mmChanges *mServiceMockChanges) Set(f func(rv uint64) (ca1 []model.Change, ch1 <-chan struct {
+}, err error)) *ServiceMock {
+	if mmChanges.defaultExpectation != nil {
+		mmChanges.mock.t.Fatalf("Default expectation is already set for the Service.Changes method")
+	}
+
+	if len(mmChanges.expectations) > 0 {
+		mmChanges.mock.t.Fatalf("Some expectations are already set for the Service.Changes method")
+	}
+
+	mmChanges.mock.funcChanges = f
+	return mmChanges.mock
+}
+
+// When sets expectation for the Service.Changes which will trigger the result defined by the following
+// Then helper
+func (mmChanges *mServiceMockChanges) When(rv uint64) *ServiceMockChangesExpectation {
+	if mmChanges.mock.funcChanges != nil {
+		mmChanges.mock.t.Fatalf("ServiceMock.Changes mock is already set by Set")
+	}
+
+	expectation := &ServiceMockChangesExpectation{
+		mock:   mmChanges.mock,
+		params: &ServiceMockChangesParams{rv},
+	}
+	mmChanges.expectations = append(mmChanges.expectations, expectation)
+	return expectation
+}
+
+// Then sets up Service.Changes return parameters for the expectation previously defined by the When method
+func (e *ServiceMockChangesExpectation) Then(ca1 []model.Change, ch1 <-chan struct {
+}, err error) *ServiceMock {
+	e.results = &ServiceMockChangesResults{ca1, ch1, err}
+	return e.mock
+}
+
+// Changes implements service.Service
+func (mmChanges *ServiceMock) Changes(rv uint64) (ca1 []model.Change, ch1 <-chan struct {
+}, err error) {
+	mm_atomic.AddUint64(&mmChanges.beforeChangesCounter, 1)
+	defer mm_atomic.AddUint64(&mmChanges.afterChangesCounter, 1)
+
+	if mmChanges.inspectFuncChanges != nil {
+		mmChanges.inspectFuncChanges(rv)
+	}
+
+	mm_params := &ServiceMockChangesParams{rv}
+
+	// Record call args
+	mmChanges.ChangesMock.mutex.Lock()
+	mmChanges.ChangesMock.callArgs = append(mmChanges.ChangesMock.callArgs, mm_params)
+	mmChanges.ChangesMock.mutex.Unlock()
+
+	for _, e := range mmChanges.ChangesMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.ca1, e.results.ch1, e.results.err
+		}
+	}
+
+	if mmChanges.ChangesMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmChanges.ChangesMock.defaultExpectation.Counter, 1)
+		mm_want := mmChanges.ChangesMock.defaultExpectation.params
+		mm_got := ServiceMockChangesParams{rv}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmChanges.t.Errorf("ServiceMock.Changes got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmChanges.ChangesMock.defaultExpectation.results
+		if mm_results == nil {
+			mmChanges.t.Fatal("No results are set for the ServiceMock.Changes")
+		}
+		return (*mm_results).ca1, (*mm_results).ch1, (*mm_results).err
+	}
+	if mmChanges.funcChanges != nil {
+		return mmChanges.funcChanges(rv)
+	}
+	mmChanges.t.Fatalf("Unexpected call to ServiceMock.Changes. %v", rv)
+	return
+}
+
+// ChangesAfterCounter returns a count of finished ServiceMock.Changes invocations
+func (mmChanges *ServiceMock) ChangesAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmChanges.afterChangesCounter)
+}
+
+// ChangesBeforeCounter returns a count of ServiceMock.Changes invocations
+func (mmChanges *ServiceMock) ChangesBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmChanges.beforeChangesCounter)
+}
+
+// Calls returns a list of arguments used in each call to ServiceMock.Changes.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmChanges *mServiceMockChanges) Calls() []*ServiceMockChangesParams {
+	mmChanges.mutex.RLock()
+
+	argCopy := make([]*ServiceMockChangesParams, len(mmChanges.callArgs))
+	copy(argCopy, mmChanges.callArgs)
+
+	mmChanges.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockChangesDone returns true if the count of the Changes invocations corresponds
+// the number of defined expectations
+func (m *ServiceMock) MinimockChangesDone() bool {
+	for _, e := range m.ChangesMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ChangesMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcChanges != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockChangesInspect logs each unmet expectation
+func (m *ServiceMock) MinimockChangesInspect() {
+	for _, e := range m.ChangesMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to ServiceMock.Changes with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ChangesMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		if m.ChangesMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to ServiceMock.Changes")
+		} else {
+			m.t.Errorf("Expected call to ServiceMock.Changes with params: %#v", *m.ChangesMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcChanges != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.Changes")
+	}
+}
+
 type mServiceMockCreateDevice struct {
 	mock               *ServiceMock
 	defaultExpectation *ServiceMockCreateDeviceExpectation
@@ -170,7 +447,7 @@ func (e *ServiceMockCreateDeviceExpectation) Then(err error) *ServiceMock {
 	return e.mock
 }
 
-// CreateDevice implements Service
+// CreateDevice implements service.Service
 func (mmCreateDevice *ServiceMock) CreateDevice(d1 model.Device) (err error) {
 	mm_atomic.AddUint64(&mmCreateDevice.beforeCreateDeviceCounter, 1)
 	defer mm_atomic.AddUint64(&mmCreateDevice.afterCreateDeviceCounter, 1)
@@ -385,7 +662,7 @@ func (e *ServiceMockDeleteDeviceExpectation) Then(err error) *ServiceMock {
 	return e.mock
 }
 
-// DeleteDevice implements Service
+// DeleteDevice implements service.Service
 func (mmDeleteDevice *ServiceMock) DeleteDevice(s1 string) (err error) {
 	mm_atomic.AddUint64(&mmDeleteDevice.beforeDeleteDeviceCounter, 1)
 	defer mm_atomic.AddUint64(&mmDeleteDevice.afterDeleteDeviceCounter, 1)
@@ -601,7 +878,7 @@ func (e *ServiceMockGetDeviceExpectation) Then(d1 model.Device, err error) *Serv
 	return e.mock
 }
 
-// GetDevice implements Service
+// GetDevice implements service.Service
 func (mmGetDevice *ServiceMock) GetDevice(s1 string) (d1 model.Device, err error) {
 	mm_atomic.AddUint64(&mmGetDevice.beforeGetDeviceCounter, 1)
 	defer mm_atomic.AddUint64(&mmGetDevice.afterGetDeviceCounter, 1)
@@ -710,229 +987,1248 @@ func (m *ServiceMock) MinimockGetDeviceInspect() {
 	}
 }
 
-type mServiceMockUpdateDevice struct {
+type mServiceMockListDevices struct {
 	mock               *ServiceMock
-	defaultExpectation *ServiceMockUpdateDeviceExpectation
-	expectations       []*ServiceMockUpdateDeviceExpectation
+	defaultExpectation *ServiceMockListDevicesExpectation
+	expectations       []*ServiceMockListDevicesExpectation
 
-	callArgs []*ServiceMockUpdateDeviceParams
+	callArgs []*ServiceMockListDevicesParams
 	mutex    sync.RWMutex
 }
 
-// ServiceMockUpdateDeviceExpectation specifies expectation struct of the Service.UpdateDevice
-type ServiceMockUpdateDeviceExpectation struct {
+// ServiceMockListDevicesExpectation specifies expectation struct of the Service.ListDevices
+type ServiceMockListDevicesExpectation struct {
 	mock    *ServiceMock
-	params  *ServiceMockUpdateDeviceParams
-	results *ServiceMockUpdateDeviceResults
+	params  *ServiceMockListDevicesParams
+	results *ServiceMockListDevicesResults
 	Counter uint64
 }
 
-// ServiceMockUpdateDeviceParams contains parameters of the Service.UpdateDevice
-type ServiceMockUpdateDeviceParams struct {
-	d1 model.Device
+// ServiceMockListDevicesParams contains parameters of the Service.ListDevices
+type ServiceMockListDevicesParams struct {
+	f1 mm_service.Filter
 }
 
-// ServiceMockUpdateDeviceResults contains results of the Service.UpdateDevice
-type ServiceMockUpdateDeviceResults struct {
+// ServiceMockListDevicesResults contains results of the Service.ListDevices
+type ServiceMockListDevicesResults struct {
+	da1 []model.Device
 	err error
 }
 
-// Expect sets up expected params for Service.UpdateDevice
-func (mmUpdateDevice *mServiceMockUpdateDevice) Expect(d1 model.Device) *mServiceMockUpdateDevice {
-	if mmUpdateDevice.mock.funcUpdateDevice != nil {
-		mmUpdateDevice.mock.t.Fatalf("ServiceMock.UpdateDevice mock is already set by Set")
+// Expect sets up expected params for Service.ListDevices
+func (mmListDevices *mServiceMockListDevices) Expect(f1 mm_service.Filter) *mServiceMockListDevices {
+	if mmListDevices.mock.funcListDevices != nil {
+		mmListDevices.mock.t.Fatalf("ServiceMock.ListDevices mock is already set by Set")
 	}
 
-	if mmUpdateDevice.defaultExpectation == nil {
-		mmUpdateDevice.defaultExpectation = &ServiceMockUpdateDeviceExpectation{}
+	if mmListDevices.defaultExpectation == nil {
+		mmListDevices.defaultExpectation = &ServiceMockListDevicesExpectation{}
 	}
 
-	mmUpdateDevice.defaultExpectation.params = &ServiceMockUpdateDeviceParams{d1}
-	for _, e := range mmUpdateDevice.expectations {
-		if minimock.Equal(e.params, mmUpdateDevice.defaultExpectation.params) {
-			mmUpdateDevice.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmUpdateDevice.defaultExpectation.params)
+	mmListDevices.defaultExpectation.params = &ServiceMockListDevicesParams{f1}
+	for _, e := range mmListDevices.expectations {
+		if minimock.Equal(e.params, mmListDevices.defaultExpectation.params) {
+			mmListDevices.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmListDevices.defaultExpectation.params)
 		}
 	}
 
-	return mmUpdateDevice
+	return mmListDevices
 }
 
-// Inspect accepts an inspector function that has same arguments as the Service.UpdateDevice
-func (mmUpdateDevice *mServiceMockUpdateDevice) Inspect(f func(d1 model.Device)) *mServiceMockUpdateDevice {
-	if mmUpdateDevice.mock.inspectFuncUpdateDevice != nil {
-		mmUpdateDevice.mock.t.Fatalf("Inspect function is already set for ServiceMock.UpdateDevice")
+// Inspect accepts an inspector function that has same arguments as the Service.ListDevices
+func (mmListDevices *mServiceMockListDevices) Inspect(f func(f1 mm_service.Filter)) *mServiceMockListDevices {
+	if mmListDevices.mock.inspectFuncListDevices != nil {
+		mmListDevices.mock.t.Fatalf("Inspect function is already set for ServiceMock.ListDevices")
 	}
 
-	mmUpdateDevice.mock.inspectFuncUpdateDevice = f
+	mmListDevices.mock.inspectFuncListDevices = f
 
-	return mmUpdateDevice
+	return mmListDevices
 }
 
-// Return sets up results that will be returned by Service.UpdateDevice
-func (mmUpdateDevice *mServiceMockUpdateDevice) Return(err error) *ServiceMock {
-	if mmUpdateDevice.mock.funcUpdateDevice != nil {
-		mmUpdateDevice.mock.t.Fatalf("ServiceMock.UpdateDevice mock is already set by Set")
+// Return sets up results that will be returned by Service.ListDevices
+func (mmListDevices *mServiceMockListDevices) Return(da1 []model.Device, err error) *ServiceMock {
+	if mmListDevices.mock.funcListDevices != nil {
+		mmListDevices.mock.t.Fatalf("ServiceMock.ListDevices mock is already set by Set")
 	}
 
-	if mmUpdateDevice.defaultExpectation == nil {
-		mmUpdateDevice.defaultExpectation = &ServiceMockUpdateDeviceExpectation{mock: mmUpdateDevice.mock}
+	if mmListDevices.defaultExpectation == nil {
+		mmListDevices.defaultExpectation = &ServiceMockListDevicesExpectation{mock: mmListDevices.mock}
 	}
-	mmUpdateDevice.defaultExpectation.results = &ServiceMockUpdateDeviceResults{err}
-	return mmUpdateDevice.mock
+	mmListDevices.defaultExpectation.results = &ServiceMockListDevicesResults{da1, err}
+	return mmListDevices.mock
 }
 
-// Set uses given function f to mock the Service.UpdateDevice method
-func (mmUpdateDevice *mServiceMockUpdateDevice) Set(f func(d1 model.Device) (err error)) *ServiceMock {
-	if mmUpdateDevice.defaultExpectation != nil {
-		mmUpdateDevice.mock.t.Fatalf("Default expectation is already set for the Service.UpdateDevice method")
+// Set uses given function f to mock the Service.ListDevices method
+func (mmListDevices *mServiceMockListDevices) Set(f func(f1 mm_service.Filter) (da1 []model.Device, err error)) *ServiceMock {
+	if mmListDevices.defaultExpectation != nil {
+		mmListDevices.mock.t.Fatalf("Default expectation is already set for the Service.ListDevices method")
 	}
 
-	if len(mmUpdateDevice.expectations) > 0 {
-		mmUpdateDevice.mock.t.Fatalf("Some expectations are already set for the Service.UpdateDevice method")
+	if len(mmListDevices.expectations) > 0 {
+		mmListDevices.mock.t.Fatalf("Some expectations are already set for the Service.ListDevices method")
 	}
 
-	mmUpdateDevice.mock.funcUpdateDevice = f
-	return mmUpdateDevice.mock
+	mmListDevices.mock.funcListDevices = f
+	return mmListDevices.mock
 }
 
-// When sets expectation for the Service.UpdateDevice which will trigger the result defined by the following
+// When sets expectation for the Service.ListDevices which will trigger the result defined by the following
 // Then helper
-func (mmUpdateDevice *mServiceMockUpdateDevice) When(d1 model.Device) *ServiceMockUpdateDeviceExpectation {
-	if mmUpdateDevice.mock.funcUpdateDevice != nil {
-		mmUpdateDevice.mock.t.Fatalf("ServiceMock.UpdateDevice mock is already set by Set")
+func (mmListDevices *mServiceMockListDevices) When(f1 mm_service.Filter) *ServiceMockListDevicesExpectation {
+	if mmListDevices.mock.funcListDevices != nil {
+		mmListDevices.mock.t.Fatalf("ServiceMock.ListDevices mock is already set by Set")
 	}
 
-	expectation := &ServiceMockUpdateDeviceExpectation{
-		mock:   mmUpdateDevice.mock,
-		params: &ServiceMockUpdateDeviceParams{d1},
+	expectation := &ServiceMockListDevicesExpectation{
+		mock:   mmListDevices.mock,
+		params: &ServiceMockListDevicesParams{f1},
 	}
-	mmUpdateDevice.expectations = append(mmUpdateDevice.expectations, expectation)
+	mmListDevices.expectations = append(mmListDevices.expectations, expectation)
 	return expectation
 }
 
-// Then sets up Service.UpdateDevice return parameters for the expectation previously defined by the When method
-func (e *ServiceMockUpdateDeviceExpectation) Then(err error) *ServiceMock {
-	e.results = &ServiceMockUpdateDeviceResults{err}
+// Then sets up Service.ListDevices return parameters for the expectation previously defined by the When method
+func (e *ServiceMockListDevicesExpectation) Then(da1 []model.Device, err error) *ServiceMock {
+	e.results = &ServiceMockListDevicesResults{da1, err}
 	return e.mock
 }
 
-// UpdateDevice implements Service
-func (mmUpdateDevice *ServiceMock) UpdateDevice(d1 model.Device) (err error) {
-	mm_atomic.AddUint64(&mmUpdateDevice.beforeUpdateDeviceCounter, 1)
-	defer mm_atomic.AddUint64(&mmUpdateDevice.afterUpdateDeviceCounter, 1)
+// ListDevices implements service.Service
+func (mmListDevices *ServiceMock) ListDevices(f1 mm_service.Filter) (da1 []model.Device, err error) {
+	mm_atomic.AddUint64(&mmListDevices.beforeListDevicesCounter, 1)
+	defer mm_atomic.AddUint64(&mmListDevices.afterListDevicesCounter, 1)
 
-	if mmUpdateDevice.inspectFuncUpdateDevice != nil {
-		mmUpdateDevice.inspectFuncUpdateDevice(d1)
+	if mmListDevices.inspectFuncListDevices != nil {
+		mmListDevices.inspectFuncListDevices(f1)
 	}
 
-	mm_params := &ServiceMockUpdateDeviceParams{d1}
+	mm_params := &ServiceMockListDevicesParams{f1}
 
 	// Record call args
-	mmUpdateDevice.UpdateDeviceMock.mutex.Lock()
-	mmUpdateDevice.UpdateDeviceMock.callArgs = append(mmUpdateDevice.UpdateDeviceMock.callArgs, mm_params)
-	mmUpdateDevice.UpdateDeviceMock.mutex.Unlock()
+	mmListDevices.ListDevicesMock.mutex.Lock()
+	mmListDevices.ListDevicesMock.callArgs = append(mmListDevices.ListDevicesMock.callArgs, mm_params)
+	mmListDevices.ListDevicesMock.mutex.Unlock()
 
-	for _, e := range mmUpdateDevice.UpdateDeviceMock.expectations {
+	for _, e := range mmListDevices.ListDevicesMock.expectations {
 		if minimock.Equal(e.params, mm_params) {
 			mm_atomic.AddUint64(&e.Counter, 1)
-			return e.results.err
+			return e.results.da1, e.results.err
 		}
 	}
 
-	if mmUpdateDevice.UpdateDeviceMock.defaultExpectation != nil {
-		mm_atomic.AddUint64(&mmUpdateDevice.UpdateDeviceMock.defaultExpectation.Counter, 1)
-		mm_want := mmUpdateDevice.UpdateDeviceMock.defaultExpectation.params
-		mm_got := ServiceMockUpdateDeviceParams{d1}
+	if mmListDevices.ListDevicesMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmListDevices.ListDevicesMock.defaultExpectation.Counter, 1)
+		mm_want := mmListDevices.ListDevicesMock.defaultExpectation.params
+		mm_got := ServiceMockListDevicesParams{f1}
 		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
-			mmUpdateDevice.t.Errorf("ServiceMock.UpdateDevice got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+			mmListDevices.t.Errorf("ServiceMock.ListDevices got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
 		}
 
-		mm_results := mmUpdateDevice.UpdateDeviceMock.defaultExpectation.results
+		mm_results := mmListDevices.ListDevicesMock.defaultExpectation.results
 		if mm_results == nil {
-			mmUpdateDevice.t.Fatal("No results are set for the ServiceMock.UpdateDevice")
+			mmListDevices.t.Fatal("No results are set for the ServiceMock.ListDevices")
 		}
-		return (*mm_results).err
+		return (*mm_results).da1, (*mm_results).err
 	}
-	if mmUpdateDevice.funcUpdateDevice != nil {
-		return mmUpdateDevice.funcUpdateDevice(d1)
+	if mmListDevices.funcListDevices != nil {
+		return mmListDevices.funcListDevices(f1)
 	}
-	mmUpdateDevice.t.Fatalf("Unexpected call to ServiceMock.UpdateDevice. %v", d1)
+	mmListDevices.t.Fatalf("Unexpected call to ServiceMock.ListDevices. %v", f1)
 	return
 }
 
-// UpdateDeviceAfterCounter returns a count of finished ServiceMock.UpdateDevice invocations
-func (mmUpdateDevice *ServiceMock) UpdateDeviceAfterCounter() uint64 {
-	return mm_atomic.LoadUint64(&mmUpdateDevice.afterUpdateDeviceCounter)
+// ListDevicesAfterCounter returns a count of finished ServiceMock.ListDevices invocations
+func (mmListDevices *ServiceMock) ListDevicesAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmListDevices.afterListDevicesCounter)
 }
 
-// UpdateDeviceBeforeCounter returns a count of ServiceMock.UpdateDevice invocations
-func (mmUpdateDevice *ServiceMock) UpdateDeviceBeforeCounter() uint64 {
-	return mm_atomic.LoadUint64(&mmUpdateDevice.beforeUpdateDeviceCounter)
+// ListDevicesBeforeCounter returns a count of ServiceMock.ListDevices invocations
+func (mmListDevices *ServiceMock) ListDevicesBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmListDevices.beforeListDevicesCounter)
 }
 
-// Calls returns a list of arguments used in each call to ServiceMock.UpdateDevice.
+// Calls returns a list of arguments used in each call to ServiceMock.ListDevices.
 // The list is in the same order as the calls were made (i.e. recent calls have a higher index)
-func (mmUpdateDevice *mServiceMockUpdateDevice) Calls() []*ServiceMockUpdateDeviceParams {
-	mmUpdateDevice.mutex.RLock()
+func (mmListDevices *mServiceMockListDevices) Calls() []*ServiceMockListDevicesParams {
+	mmListDevices.mutex.RLock()
 
-	argCopy := make([]*ServiceMockUpdateDeviceParams, len(mmUpdateDevice.callArgs))
-	copy(argCopy, mmUpdateDevice.callArgs)
+	argCopy := make([]*ServiceMockListDevicesParams, len(mmListDevices.callArgs))
+	copy(argCopy, mmListDevices.callArgs)
 
-	mmUpdateDevice.mutex.RUnlock()
+	mmListDevices.mutex.RUnlock()
 
 	return argCopy
 }
 
-// MinimockUpdateDeviceDone returns true if the count of the UpdateDevice invocations corresponds
+// MinimockListDevicesDone returns true if the count of the ListDevices invocations corresponds
 // the number of defined expectations
-func (m *ServiceMock) MinimockUpdateDeviceDone() bool {
-	for _, e := range m.UpdateDeviceMock.expectations {
+func (m *ServiceMock) MinimockListDevicesDone() bool {
+	for _, e := range m.ListDevicesMock.expectations {
 		if mm_atomic.LoadUint64(&e.Counter) < 1 {
 			return false
 		}
 	}
 
 	// if default expectation was set then invocations count should be greater than zero
-	if m.UpdateDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
+	if m.ListDevicesMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterListDevicesCounter) < 1 {
 		return false
 	}
 	// if func was set then invocations count should be greater than zero
-	if m.funcUpdateDevice != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
+	if m.funcListDevices != nil && mm_atomic.LoadUint64(&m.afterListDevicesCounter) < 1 {
 		return false
 	}
 	return true
 }
 
-// MinimockUpdateDeviceInspect logs each unmet expectation
-func (m *ServiceMock) MinimockUpdateDeviceInspect() {
-	for _, e := range m.UpdateDeviceMock.expectations {
+// MinimockListDevicesInspect logs each unmet expectation
+func (m *ServiceMock) MinimockListDevicesInspect() {
+	for _, e := range m.ListDevicesMock.expectations {
 		if mm_atomic.LoadUint64(&e.Counter) < 1 {
-			m.t.Errorf("Expected call to ServiceMock.UpdateDevice with params: %#v", *e.params)
+			m.t.Errorf("Expected call to ServiceMock.ListDevices with params: %#v", *e.params)
 		}
 	}
 
 	// if default expectation was set then invocations count should be greater than zero
-	if m.UpdateDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
-		if m.UpdateDeviceMock.defaultExpectation.params == nil {
-			m.t.Error("Expected call to ServiceMock.UpdateDevice")
+	if m.ListDevicesMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterListDevicesCounter) < 1 {
+		if m.ListDevicesMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to ServiceMock.ListDevices")
 		} else {
-			m.t.Errorf("Expected call to ServiceMock.UpdateDevice with params: %#v", *m.UpdateDeviceMock.defaultExpectation.params)
+			m.t.Errorf("Expected call to ServiceMock.ListDevices with params: %#v", *m.ListDevicesMock.defaultExpectation.params)
 		}
 	}
 	// if func was set then invocations count should be greater than zero
-	if m.funcUpdateDevice != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
-		m.t.Error("Expected call to ServiceMock.UpdateDevice")
+	if m.funcListDevices != nil && mm_atomic.LoadUint64(&m.afterListDevicesCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.ListDevices")
 	}
 }
 
-// MinimockFinish checks that all mocked methods have been called the expected number of times
-func (m *ServiceMock) MinimockFinish() {
-	if !m.minimockDone() {
-		m.MinimockCreateDeviceInspect()
+type mServiceMockResourceVersion struct {
+	mock               *ServiceMock
+	defaultExpectation *ServiceMockResourceVersionExpectation
+	expectations       []*ServiceMockResourceVersionExpectation
+}
 
-		m.MinimockDeleteDeviceInspect()
+// ServiceMockResourceVersionExpectation specifies expectation struct of the Service.ResourceVersion
+type ServiceMockResourceVersionExpectation struct {
+	mock *ServiceMock
 
-		m.MinimockGetDeviceInspect()
+	results *ServiceMockResourceVersionResults
+	Counter uint64
+}
+
+// ServiceMockResourceVersionResults contains results of the Service.ResourceVersion
+type ServiceMockResourceVersionResults struct {
+	u1 uint64
+}
+
+// Expect sets up expected params for Service.ResourceVersion
+func (mmResourceVersion *mServiceMockResourceVersion) Expect() *mServiceMockResourceVersion {
+	if mmResourceVersion.mock.funcResourceVersion != nil {
+		mmResourceVersion.mock.t.Fatalf("ServiceMock.ResourceVersion mock is already set by Set")
+	}
+
+	if mmResourceVersion.defaultExpectation == nil {
+		mmResourceVersion.defaultExpectation = &ServiceMockResourceVersionExpectation{}
+	}
+
+	return mmResourceVersion
+}
+
+// Inspect accepts an inspector function that has same arguments as the Service.ResourceVersion
+func (mmResourceVersion *mServiceMockResourceVersion) Inspect(f func()) *mServiceMockResourceVersion {
+	if mmResourceVersion.mock.inspectFuncResourceVersion != nil {
+		mmResourceVersion.mock.t.Fatalf("Inspect function is already set for ServiceMock.ResourceVersion")
+	}
+
+	mmResourceVersion.mock.inspectFuncResourceVersion = f
+
+	return mmResourceVersion
+}
+
+// Return sets up results that will be returned by Service.ResourceVersion
+func (mmResourceVersion *mServiceMockResourceVersion) Return(u1 uint64) *ServiceMock {
+	if mmResourceVersion.mock.funcResourceVersion != nil {
+		mmResourceVersion.mock.t.Fatalf("ServiceMock.ResourceVersion mock is already set by Set")
+	}
+
+	if mmResourceVersion.defaultExpectation == nil {
+		mmResourceVersion.defaultExpectation = &ServiceMockResourceVersionExpectation{mock: mmResourceVersion.mock}
+	}
+	mmResourceVersion.defaultExpectation.results = &ServiceMockResourceVersionResults{u1}
+	return mmResourceVersion.mock
+}
+
+// Set uses given function f to mock the Service.ResourceVersion method
+func (mmResourceVersion *mServiceMockResourceVersion) Set(f func() (u1 uint64)) *ServiceMock {
+	if mmResourceVersion.defaultExpectation != nil {
+		mmResourceVersion.mock.t.Fatalf("Default expectation is already set for the Service.ResourceVersion method")
+	}
+
+	if len(mmResourceVersion.expectations) > 0 {
+		mmResourceVersion.mock.t.Fatalf("Some expectations are already set for the Service.ResourceVersion method")
+	}
+
+	mmResourceVersion.mock.funcResourceVersion = f
+	return mmResourceVersion.mock
+}
+
+// ResourceVersion implements service.Service
+func (mmResourceVersion *ServiceMock) ResourceVersion() (u1 uint64) {
+	mm_atomic.AddUint64(&mmResourceVersion.beforeResourceVersionCounter, 1)
+	defer mm_atomic.AddUint64(&mmResourceVersion.afterResourceVersionCounter, 1)
+
+	if mmResourceVersion.inspectFuncResourceVersion != nil {
+		mmResourceVersion.inspectFuncResourceVersion()
+	}
+
+	if mmResourceVersion.ResourceVersionMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmResourceVersion.ResourceVersionMock.defaultExpectation.Counter, 1)
+
+		mm_results := mmResourceVersion.ResourceVersionMock.defaultExpectation.results
+		if mm_results == nil {
+			mmResourceVersion.t.Fatal("No results are set for the ServiceMock.ResourceVersion")
+		}
+		return (*mm_results).u1
+	}
+	if mmResourceVersion.funcResourceVersion != nil {
+		return mmResourceVersion.funcResourceVersion()
+	}
+	mmResourceVersion.t.Fatalf("Unexpected call to ServiceMock.ResourceVersion.")
+	return
+}
+
+// ResourceVersionAfterCounter returns a count of finished ServiceMock.ResourceVersion invocations
+func (mmResourceVersion *ServiceMock) ResourceVersionAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmResourceVersion.afterResourceVersionCounter)
+}
+
+// ResourceVersionBeforeCounter returns a count of ServiceMock.ResourceVersion invocations
+func (mmResourceVersion *ServiceMock) ResourceVersionBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmResourceVersion.beforeResourceVersionCounter)
+}
+
+// MinimockResourceVersionDone returns true if the count of the ResourceVersion invocations corresponds
+// the number of defined expectations
+func (m *ServiceMock) MinimockResourceVersionDone() bool {
+	for _, e := range m.ResourceVersionMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ResourceVersionMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcResourceVersion != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockResourceVersionInspect logs each unmet expectation
+func (m *ServiceMock) MinimockResourceVersionInspect() {
+	for _, e := range m.ResourceVersionMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Error("Expected call to ServiceMock.ResourceVersion")
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ResourceVersionMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.ResourceVersion")
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcResourceVersion != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.ResourceVersion")
+	}
+}
+
+type mServiceMockRestoreDevice struct {
+	mock               *ServiceMock
+	defaultExpectation *ServiceMockRestoreDeviceExpectation
+	expectations       []*ServiceMockRestoreDeviceExpectation
+
+	callArgs []*ServiceMockRestoreDeviceParams
+	mutex    sync.RWMutex
+}
+
+// ServiceMockRestoreDeviceExpectation specifies expectation struct of the Service.RestoreDevice
+type ServiceMockRestoreDeviceExpectation struct {
+	mock    *ServiceMock
+	params  *ServiceMockRestoreDeviceParams
+	results *ServiceMockRestoreDeviceResults
+	Counter uint64
+}
+
+// ServiceMockRestoreDeviceParams contains parameters of the Service.RestoreDevice
+type ServiceMockRestoreDeviceParams struct {
+	d1 model.Device
+}
+
+// ServiceMockRestoreDeviceResults contains results of the Service.RestoreDevice
+type ServiceMockRestoreDeviceResults struct {
+	err error
+}
+
+// Expect sets up expected params for Service.RestoreDevice
+func (mmRestoreDevice *mServiceMockRestoreDevice) Expect(d1 model.Device) *mServiceMockRestoreDevice {
+	if mmRestoreDevice.mock.funcRestoreDevice != nil {
+		mmRestoreDevice.mock.t.Fatalf("ServiceMock.RestoreDevice mock is already set by Set")
+	}
+
+	if mmRestoreDevice.defaultExpectation == nil {
+		mmRestoreDevice.defaultExpectation = &ServiceMockRestoreDeviceExpectation{}
+	}
+
+	mmRestoreDevice.defaultExpectation.params = &ServiceMockRestoreDeviceParams{d1}
+	for _, e := range mmRestoreDevice.expectations {
+		if minimock.Equal(e.params, mmRestoreDevice.defaultExpectation.params) {
+			mmRestoreDevice.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmRestoreDevice.defaultExpectation.params)
+		}
+	}
+
+	return mmRestoreDevice
+}
+
+// Inspect accepts an inspector function that has same arguments as the Service.RestoreDevice
+func (mmRestoreDevice *mServiceMockRestoreDevice) Inspect(f func(d1 model.Device)) *mServiceMockRestoreDevice {
+	if mmRestoreDevice.mock.inspectFuncRestoreDevice != nil {
+		mmRestoreDevice.mock.t.Fatalf("Inspect function is already set for ServiceMock.RestoreDevice")
+	}
+
+	mmRestoreDevice.mock.inspectFuncRestoreDevice = f
+
+	return mmRestoreDevice
+}
+
+// Return sets up results that will be returned by Service.RestoreDevice
+func (mmRestoreDevice *mServiceMockRestoreDevice) Return(err error) *ServiceMock {
+	if mmRestoreDevice.mock.funcRestoreDevice != nil {
+		mmRestoreDevice.mock.t.Fatalf("ServiceMock.RestoreDevice mock is already set by Set")
+	}
+
+	if mmRestoreDevice.defaultExpectation == nil {
+		mmRestoreDevice.defaultExpectation = &ServiceMockRestoreDeviceExpectation{mock: mmRestoreDevice.mock}
+	}
+	mmRestoreDevice.defaultExpectation.results = &ServiceMockRestoreDeviceResults{err}
+	return mmRestoreDevice.mock
+}
+
+// Set uses given function f to mock the Service.RestoreDevice method
+func (mmRestoreDevice *mServiceMockRestoreDevice) Set(f func(d1 model.Device) (err error)) *ServiceMock {
+	if mmRestoreDevice.defaultExpectation != nil {
+		mmRestoreDevice.mock.t.Fatalf("Default expectation is already set for the Service.RestoreDevice method")
+	}
+
+	if len(mmRestoreDevice.expectations) > 0 {
+		mmRestoreDevice.mock.t.Fatalf("Some expectations are already set for the Service.RestoreDevice method")
+	}
+
+	mmRestoreDevice.mock.funcRestoreDevice = f
+	return mmRestoreDevice.mock
+}
+
+// When sets expectation for the Service.RestoreDevice which will trigger the result defined by the following
+// Then helper
+func (mmRestoreDevice *mServiceMockRestoreDevice) When(d1 model.Device) *ServiceMockRestoreDeviceExpectation {
+	if mmRestoreDevice.mock.funcRestoreDevice != nil {
+		mmRestoreDevice.mock.t.Fatalf("ServiceMock.RestoreDevice mock is already set by Set")
+	}
+
+	expectation := &ServiceMockRestoreDeviceExpectation{
+		mock:   mmRestoreDevice.mock,
+		params: &ServiceMockRestoreDeviceParams{d1},
+	}
+	mmRestoreDevice.expectations = append(mmRestoreDevice.expectations, expectation)
+	return expectation
+}
+
+// Then sets up Service.RestoreDevice return parameters for the expectation previously defined by the When method
+func (e *ServiceMockRestoreDeviceExpectation) Then(err error) *ServiceMock {
+	e.results = &ServiceMockRestoreDeviceResults{err}
+	return e.mock
+}
+
+// RestoreDevice implements service.Service
+func (mmRestoreDevice *ServiceMock) RestoreDevice(d1 model.Device) (err error) {
+	mm_atomic.AddUint64(&mmRestoreDevice.beforeRestoreDeviceCounter, 1)
+	defer mm_atomic.AddUint64(&mmRestoreDevice.afterRestoreDeviceCounter, 1)
+
+	if mmRestoreDevice.inspectFuncRestoreDevice != nil {
+		mmRestoreDevice.inspectFuncRestoreDevice(d1)
+	}
+
+	mm_params := &ServiceMockRestoreDeviceParams{d1}
+
+	// Record call args
+	mmRestoreDevice.RestoreDeviceMock.mutex.Lock()
+	mmRestoreDevice.RestoreDeviceMock.callArgs = append(mmRestoreDevice.RestoreDeviceMock.callArgs, mm_params)
+	mmRestoreDevice.RestoreDeviceMock.mutex.Unlock()
+
+	for _, e := range mmRestoreDevice.RestoreDeviceMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmRestoreDevice.RestoreDeviceMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmRestoreDevice.RestoreDeviceMock.defaultExpectation.Counter, 1)
+		mm_want := mmRestoreDevice.RestoreDeviceMock.defaultExpectation.params
+		mm_got := ServiceMockRestoreDeviceParams{d1}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmRestoreDevice.t.Errorf("ServiceMock.RestoreDevice got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmRestoreDevice.RestoreDeviceMock.defaultExpectation.results
+		if mm_results == nil {
+			mmRestoreDevice.t.Fatal("No results are set for the ServiceMock.RestoreDevice")
+		}
+		return (*mm_results).err
+	}
+	if mmRestoreDevice.funcRestoreDevice != nil {
+		return mmRestoreDevice.funcRestoreDevice(d1)
+	}
+	mmRestoreDevice.t.Fatalf("Unexpected call to ServiceMock.RestoreDevice. %v", d1)
+	return
+}
+
+// RestoreDeviceAfterCounter returns a count of finished ServiceMock.RestoreDevice invocations
+func (mmRestoreDevice *ServiceMock) RestoreDeviceAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRestoreDevice.afterRestoreDeviceCounter)
+}
+
+// RestoreDeviceBeforeCounter returns a count of ServiceMock.RestoreDevice invocations
+func (mmRestoreDevice *ServiceMock) RestoreDeviceBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRestoreDevice.beforeRestoreDeviceCounter)
+}
+
+// Calls returns a list of arguments used in each call to ServiceMock.RestoreDevice.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmRestoreDevice *mServiceMockRestoreDevice) Calls() []*ServiceMockRestoreDeviceParams {
+	mmRestoreDevice.mutex.RLock()
+
+	argCopy := make([]*ServiceMockRestoreDeviceParams, len(mmRestoreDevice.callArgs))
+	copy(argCopy, mmRestoreDevice.callArgs)
+
+	mmRestoreDevice.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockRestoreDeviceDone returns true if the count of the RestoreDevice invocations corresponds
+// the number of defined expectations
+func (m *ServiceMock) MinimockRestoreDeviceDone() bool {
+	for _, e := range m.RestoreDeviceMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RestoreDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRestoreDeviceCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRestoreDevice != nil && mm_atomic.LoadUint64(&m.afterRestoreDeviceCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockRestoreDeviceInspect logs each unmet expectation
+func (m *ServiceMock) MinimockRestoreDeviceInspect() {
+	for _, e := range m.RestoreDeviceMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to ServiceMock.RestoreDevice with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RestoreDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRestoreDeviceCounter) < 1 {
+		if m.RestoreDeviceMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to ServiceMock.RestoreDevice")
+		} else {
+			m.t.Errorf("Expected call to ServiceMock.RestoreDevice with params: %#v", *m.RestoreDeviceMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRestoreDevice != nil && mm_atomic.LoadUint64(&m.afterRestoreDeviceCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.RestoreDevice")
+	}
+}
+
+type mServiceMockSetFirmware struct {
+	mock               *ServiceMock
+	defaultExpectation *ServiceMockSetFirmwareExpectation
+	expectations       []*ServiceMockSetFirmwareExpectation
+
+	callArgs []*ServiceMockSetFirmwareParams
+	mutex    sync.RWMutex
+}
+
+// ServiceMockSetFirmwareExpectation specifies expectation struct of the Service.SetFirmware
+type ServiceMockSetFirmwareExpectation struct {
+	mock    *ServiceMock
+	params  *ServiceMockSetFirmwareParams
+	results *ServiceMockSetFirmwareResults
+	Counter uint64
+}
+
+// ServiceMockSetFirmwareParams contains parameters of the Service.SetFirmware
+type ServiceMockSetFirmwareParams struct {
+	num      string
+	firmware string
+}
+
+// ServiceMockSetFirmwareResults contains results of the Service.SetFirmware
+type ServiceMockSetFirmwareResults struct {
+	err error
+}
+
+// Expect sets up expected params for Service.SetFirmware
+func (mmSetFirmware *mServiceMockSetFirmware) Expect(num string, firmware string) *mServiceMockSetFirmware {
+	if mmSetFirmware.mock.funcSetFirmware != nil {
+		mmSetFirmware.mock.t.Fatalf("ServiceMock.SetFirmware mock is already set by Set")
+	}
+
+	if mmSetFirmware.defaultExpectation == nil {
+		mmSetFirmware.defaultExpectation = &ServiceMockSetFirmwareExpectation{}
+	}
+
+	mmSetFirmware.defaultExpectation.params = &ServiceMockSetFirmwareParams{num, firmware}
+	for _, e := range mmSetFirmware.expectations {
+		if minimock.Equal(e.params, mmSetFirmware.defaultExpectation.params) {
+			mmSetFirmware.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmSetFirmware.defaultExpectation.params)
+		}
+	}
+
+	return mmSetFirmware
+}
+
+// Inspect accepts an inspector function that has same arguments as the Service.SetFirmware
+func (mmSetFirmware *mServiceMockSetFirmware) Inspect(f func(num string, firmware string)) *mServiceMockSetFirmware {
+	if mmSetFirmware.mock.inspectFuncSetFirmware != nil {
+		mmSetFirmware.mock.t.Fatalf("Inspect function is already set for ServiceMock.SetFirmware")
+	}
+
+	mmSetFirmware.mock.inspectFuncSetFirmware = f
+
+	return mmSetFirmware
+}
+
+// Return sets up results that will be returned by Service.SetFirmware
+func (mmSetFirmware *mServiceMockSetFirmware) Return(err error) *ServiceMock {
+	if mmSetFirmware.mock.funcSetFirmware != nil {
+		mmSetFirmware.mock.t.Fatalf("ServiceMock.SetFirmware mock is already set by Set")
+	}
+
+	if mmSetFirmware.defaultExpectation == nil {
+		mmSetFirmware.defaultExpectation = &ServiceMockSetFirmwareExpectation{mock: mmSetFirmware.mock}
+	}
+	mmSetFirmware.defaultExpectation.results = &ServiceMockSetFirmwareResults{err}
+	return mmSetFirmware.mock
+}
+
+// Set uses given function f to mock the Service.SetFirmware method
+func (mmSetFirmware *mServiceMockSetFirmware) Set(f func(num string, firmware string) (err error)) *ServiceMock {
+	if mmSetFirmware.defaultExpectation != nil {
+		mmSetFirmware.mock.t.Fatalf("Default expectation is already set for the Service.SetFirmware method")
+	}
+
+	if len(mmSetFirmware.expectations) > 0 {
+		mmSetFirmware.mock.t.Fatalf("Some expectations are already set for the Service.SetFirmware method")
+	}
+
+	mmSetFirmware.mock.funcSetFirmware = f
+	return mmSetFirmware.mock
+}
+
+// When sets expectation for the Service.SetFirmware which will trigger the result defined by the following
+// Then helper
+func (mmSetFirmware *mServiceMockSetFirmware) When(num string, firmware string) *ServiceMockSetFirmwareExpectation {
+	if mmSetFirmware.mock.funcSetFirmware != nil {
+		mmSetFirmware.mock.t.Fatalf("ServiceMock.SetFirmware mock is already set by Set")
+	}
+
+	expectation := &ServiceMockSetFirmwareExpectation{
+		mock:   mmSetFirmware.mock,
+		params: &ServiceMockSetFirmwareParams{num, firmware},
+	}
+	mmSetFirmware.expectations = append(mmSetFirmware.expectations, expectation)
+	return expectation
+}
+
+// Then sets up Service.SetFirmware return parameters for the expectation previously defined by the When method
+func (e *ServiceMockSetFirmwareExpectation) Then(err error) *ServiceMock {
+	e.results = &ServiceMockSetFirmwareResults{err}
+	return e.mock
+}
+
+// SetFirmware implements service.Service
+func (mmSetFirmware *ServiceMock) SetFirmware(num string, firmware string) (err error) {
+	mm_atomic.AddUint64(&mmSetFirmware.beforeSetFirmwareCounter, 1)
+	defer mm_atomic.AddUint64(&mmSetFirmware.afterSetFirmwareCounter, 1)
+
+	if mmSetFirmware.inspectFuncSetFirmware != nil {
+		mmSetFirmware.inspectFuncSetFirmware(num, firmware)
+	}
+
+	mm_params := &ServiceMockSetFirmwareParams{num, firmware}
+
+	// Record call args
+	mmSetFirmware.SetFirmwareMock.mutex.Lock()
+	mmSetFirmware.SetFirmwareMock.callArgs = append(mmSetFirmware.SetFirmwareMock.callArgs, mm_params)
+	mmSetFirmware.SetFirmwareMock.mutex.Unlock()
+
+	for _, e := range mmSetFirmware.SetFirmwareMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmSetFirmware.SetFirmwareMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmSetFirmware.SetFirmwareMock.defaultExpectation.Counter, 1)
+		mm_want := mmSetFirmware.SetFirmwareMock.defaultExpectation.params
+		mm_got := ServiceMockSetFirmwareParams{num, firmware}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmSetFirmware.t.Errorf("ServiceMock.SetFirmware got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmSetFirmware.SetFirmwareMock.defaultExpectation.results
+		if mm_results == nil {
+			mmSetFirmware.t.Fatal("No results are set for the ServiceMock.SetFirmware")
+		}
+		return (*mm_results).err
+	}
+	if mmSetFirmware.funcSetFirmware != nil {
+		return mmSetFirmware.funcSetFirmware(num, firmware)
+	}
+	mmSetFirmware.t.Fatalf("Unexpected call to ServiceMock.SetFirmware. %v %v", num, firmware)
+	return
+}
+
+// SetFirmwareAfterCounter returns a count of finished ServiceMock.SetFirmware invocations
+func (mmSetFirmware *ServiceMock) SetFirmwareAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmSetFirmware.afterSetFirmwareCounter)
+}
+
+// SetFirmwareBeforeCounter returns a count of ServiceMock.SetFirmware invocations
+func (mmSetFirmware *ServiceMock) SetFirmwareBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmSetFirmware.beforeSetFirmwareCounter)
+}
+
+// Calls returns a list of arguments used in each call to ServiceMock.SetFirmware.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmSetFirmware *mServiceMockSetFirmware) Calls() []*ServiceMockSetFirmwareParams {
+	mmSetFirmware.mutex.RLock()
+
+	argCopy := make([]*ServiceMockSetFirmwareParams, len(mmSetFirmware.callArgs))
+	copy(argCopy, mmSetFirmware.callArgs)
+
+	mmSetFirmware.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockSetFirmwareDone returns true if the count of the SetFirmware invocations corresponds
+// the number of defined expectations
+func (m *ServiceMock) MinimockSetFirmwareDone() bool {
+	for _, e := range m.SetFirmwareMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.SetFirmwareMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterSetFirmwareCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcSetFirmware != nil && mm_atomic.LoadUint64(&m.afterSetFirmwareCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockSetFirmwareInspect logs each unmet expectation
+func (m *ServiceMock) MinimockSetFirmwareInspect() {
+	for _, e := range m.SetFirmwareMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to ServiceMock.SetFirmware with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.SetFirmwareMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterSetFirmwareCounter) < 1 {
+		if m.SetFirmwareMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to ServiceMock.SetFirmware")
+		} else {
+			m.t.Errorf("Expected call to ServiceMock.SetFirmware with params: %#v", *m.SetFirmwareMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcSetFirmware != nil && mm_atomic.LoadUint64(&m.afterSetFirmwareCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.SetFirmware")
+	}
+}
+
+type mServiceMockTransitionDevice struct {
+	mock               *ServiceMock
+	defaultExpectation *ServiceMockTransitionDeviceExpectation
+	expectations       []*ServiceMockTransitionDeviceExpectation
+
+	callArgs []*ServiceMockTransitionDeviceParams
+	mutex    sync.RWMutex
+}
+
+// ServiceMockTransitionDeviceExpectation specifies expectation struct of the Service.TransitionDevice
+type ServiceMockTransitionDeviceExpectation struct {
+	mock    *ServiceMock
+	params  *ServiceMockTransitionDeviceParams
+	results *ServiceMockTransitionDeviceResults
+	Counter uint64
+}
+
+// ServiceMockTransitionDeviceParams contains parameters of the Service.TransitionDevice
+type ServiceMockTransitionDeviceParams struct {
+	num    string
+	to     model.State
+	reason string
+}
+
+// ServiceMockTransitionDeviceResults contains results of the Service.TransitionDevice
+type ServiceMockTransitionDeviceResults struct {
+	err error
+}
+
+// Expect sets up expected params for Service.TransitionDevice
+func (mmTransitionDevice *mServiceMockTransitionDevice) Expect(num string, to model.State, reason string) *mServiceMockTransitionDevice {
+	if mmTransitionDevice.mock.funcTransitionDevice != nil {
+		mmTransitionDevice.mock.t.Fatalf("ServiceMock.TransitionDevice mock is already set by Set")
+	}
+
+	if mmTransitionDevice.defaultExpectation == nil {
+		mmTransitionDevice.defaultExpectation = &ServiceMockTransitionDeviceExpectation{}
+	}
+
+	mmTransitionDevice.defaultExpectation.params = &ServiceMockTransitionDeviceParams{num, to, reason}
+	for _, e := range mmTransitionDevice.expectations {
+		if minimock.Equal(e.params, mmTransitionDevice.defaultExpectation.params) {
+			mmTransitionDevice.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmTransitionDevice.defaultExpectation.params)
+		}
+	}
+
+	return mmTransitionDevice
+}
+
+// Inspect accepts an inspector function that has same arguments as the Service.TransitionDevice
+func (mmTransitionDevice *mServiceMockTransitionDevice) Inspect(f func(num string, to model.State, reason string)) *mServiceMockTransitionDevice {
+	if mmTransitionDevice.mock.inspectFuncTransitionDevice != nil {
+		mmTransitionDevice.mock.t.Fatalf("Inspect function is already set for ServiceMock.TransitionDevice")
+	}
+
+	mmTransitionDevice.mock.inspectFuncTransitionDevice = f
+
+	return mmTransitionDevice
+}
+
+// Return sets up results that will be returned by Service.TransitionDevice
+func (mmTransitionDevice *mServiceMockTransitionDevice) Return(err error) *ServiceMock {
+	if mmTransitionDevice.mock.funcTransitionDevice != nil {
+		mmTransitionDevice.mock.t.Fatalf("ServiceMock.TransitionDevice mock is already set by Set")
+	}
+
+	if mmTransitionDevice.defaultExpectation == nil {
+		mmTransitionDevice.defaultExpectation = &ServiceMockTransitionDeviceExpectation{mock: mmTransitionDevice.mock}
+	}
+	mmTransitionDevice.defaultExpectation.results = &ServiceMockTransitionDeviceResults{err}
+	return mmTransitionDevice.mock
+}
+
+// Set uses given function f to mock the Service.TransitionDevice method
+func (mmTransitionDevice *mServiceMockTransitionDevice) Set(f func(num string, to model.State, reason string) (err error)) *ServiceMock {
+	if mmTransitionDevice.defaultExpectation != nil {
+		mmTransitionDevice.mock.t.Fatalf("Default expectation is already set for the Service.TransitionDevice method")
+	}
+
+	if len(mmTransitionDevice.expectations) > 0 {
+		mmTransitionDevice.mock.t.Fatalf("Some expectations are already set for the Service.TransitionDevice method")
+	}
+
+	mmTransitionDevice.mock.funcTransitionDevice = f
+	return mmTransitionDevice.mock
+}
+
+// When sets expectation for the Service.TransitionDevice which will trigger the result defined by the following
+// Then helper
+func (mmTransitionDevice *mServiceMockTransitionDevice) When(num string, to model.State, reason string) *ServiceMockTransitionDeviceExpectation {
+	if mmTransitionDevice.mock.funcTransitionDevice != nil {
+		mmTransitionDevice.mock.t.Fatalf("ServiceMock.TransitionDevice mock is already set by Set")
+	}
+
+	expectation := &ServiceMockTransitionDeviceExpectation{
+		mock:   mmTransitionDevice.mock,
+		params: &ServiceMockTransitionDeviceParams{num, to, reason},
+	}
+	mmTransitionDevice.expectations = append(mmTransitionDevice.expectations, expectation)
+	return expectation
+}
+
+// Then sets up Service.TransitionDevice return parameters for the expectation previously defined by the When method
+func (e *ServiceMockTransitionDeviceExpectation) Then(err error) *ServiceMock {
+	e.results = &ServiceMockTransitionDeviceResults{err}
+	return e.mock
+}
+
+// TransitionDevice implements service.Service
+func (mmTransitionDevice *ServiceMock) TransitionDevice(num string, to model.State, reason string) (err error) {
+	mm_atomic.AddUint64(&mmTransitionDevice.beforeTransitionDeviceCounter, 1)
+	defer mm_atomic.AddUint64(&mmTransitionDevice.afterTransitionDeviceCounter, 1)
+
+	if mmTransitionDevice.inspectFuncTransitionDevice != nil {
+		mmTransitionDevice.inspectFuncTransitionDevice(num, to, reason)
+	}
+
+	mm_params := &ServiceMockTransitionDeviceParams{num, to, reason}
+
+	// Record call args
+	mmTransitionDevice.TransitionDeviceMock.mutex.Lock()
+	mmTransitionDevice.TransitionDeviceMock.callArgs = append(mmTransitionDevice.TransitionDeviceMock.callArgs, mm_params)
+	mmTransitionDevice.TransitionDeviceMock.mutex.Unlock()
+
+	for _, e := range mmTransitionDevice.TransitionDeviceMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmTransitionDevice.TransitionDeviceMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmTransitionDevice.TransitionDeviceMock.defaultExpectation.Counter, 1)
+		mm_want := mmTransitionDevice.TransitionDeviceMock.defaultExpectation.params
+		mm_got := ServiceMockTransitionDeviceParams{num, to, reason}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmTransitionDevice.t.Errorf("ServiceMock.TransitionDevice got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmTransitionDevice.TransitionDeviceMock.defaultExpectation.results
+		if mm_results == nil {
+			mmTransitionDevice.t.Fatal("No results are set for the ServiceMock.TransitionDevice")
+		}
+		return (*mm_results).err
+	}
+	if mmTransitionDevice.funcTransitionDevice != nil {
+		return mmTransitionDevice.funcTransitionDevice(num, to, reason)
+	}
+	mmTransitionDevice.t.Fatalf("Unexpected call to ServiceMock.TransitionDevice. %v %v %v", num, to, reason)
+	return
+}
+
+// TransitionDeviceAfterCounter returns a count of finished ServiceMock.TransitionDevice invocations
+func (mmTransitionDevice *ServiceMock) TransitionDeviceAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmTransitionDevice.afterTransitionDeviceCounter)
+}
+
+// TransitionDeviceBeforeCounter returns a count of ServiceMock.TransitionDevice invocations
+func (mmTransitionDevice *ServiceMock) TransitionDeviceBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmTransitionDevice.beforeTransitionDeviceCounter)
+}
+
+// Calls returns a list of arguments used in each call to ServiceMock.TransitionDevice.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmTransitionDevice *mServiceMockTransitionDevice) Calls() []*ServiceMockTransitionDeviceParams {
+	mmTransitionDevice.mutex.RLock()
+
+	argCopy := make([]*ServiceMockTransitionDeviceParams, len(mmTransitionDevice.callArgs))
+	copy(argCopy, mmTransitionDevice.callArgs)
+
+	mmTransitionDevice.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockTransitionDeviceDone returns true if the count of the TransitionDevice invocations corresponds
+// the number of defined expectations
+func (m *ServiceMock) MinimockTransitionDeviceDone() bool {
+	for _, e := range m.TransitionDeviceMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.TransitionDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterTransitionDeviceCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcTransitionDevice != nil && mm_atomic.LoadUint64(&m.afterTransitionDeviceCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockTransitionDeviceInspect logs each unmet expectation
+func (m *ServiceMock) MinimockTransitionDeviceInspect() {
+	for _, e := range m.TransitionDeviceMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to ServiceMock.TransitionDevice with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.TransitionDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterTransitionDeviceCounter) < 1 {
+		if m.TransitionDeviceMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to ServiceMock.TransitionDevice")
+		} else {
+			m.t.Errorf("Expected call to ServiceMock.TransitionDevice with params: %#v", *m.TransitionDeviceMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcTransitionDevice != nil && mm_atomic.LoadUint64(&m.afterTransitionDeviceCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.TransitionDevice")
+	}
+}
+
+type mServiceMockUpdateDevice struct {
+	mock               *ServiceMock
+	defaultExpectation *ServiceMockUpdateDeviceExpectation
+	expectations       []*ServiceMockUpdateDeviceExpectation
+
+	callArgs []*ServiceMockUpdateDeviceParams
+	mutex    sync.RWMutex
+}
+
+// ServiceMockUpdateDeviceExpectation specifies expectation struct of the Service.UpdateDevice
+type ServiceMockUpdateDeviceExpectation struct {
+	mock    *ServiceMock
+	params  *ServiceMockUpdateDeviceParams
+	results *ServiceMockUpdateDeviceResults
+	Counter uint64
+}
+
+// ServiceMockUpdateDeviceParams contains parameters of the Service.UpdateDevice
+type ServiceMockUpdateDeviceParams struct {
+	d1 model.Device
+}
+
+// ServiceMockUpdateDeviceResults contains results of the Service.UpdateDevice
+type ServiceMockUpdateDeviceResults struct {
+	err error
+}
+
+// Expect sets up expected params for Service.UpdateDevice
+func (mmUpdateDevice *mServiceMockUpdateDevice) Expect(d1 model.Device) *mServiceMockUpdateDevice {
+	if mmUpdateDevice.mock.funcUpdateDevice != nil {
+		mmUpdateDevice.mock.t.Fatalf("ServiceMock.UpdateDevice mock is already set by Set")
+	}
+
+	if mmUpdateDevice.defaultExpectation == nil {
+		mmUpdateDevice.defaultExpectation = &ServiceMockUpdateDeviceExpectation{}
+	}
+
+	mmUpdateDevice.defaultExpectation.params = &ServiceMockUpdateDeviceParams{d1}
+	for _, e := range mmUpdateDevice.expectations {
+		if minimock.Equal(e.params, mmUpdateDevice.defaultExpectation.params) {
+			mmUpdateDevice.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmUpdateDevice.defaultExpectation.params)
+		}
+	}
+
+	return mmUpdateDevice
+}
+
+// Inspect accepts an inspector function that has same arguments as the Service.UpdateDevice
+func (mmUpdateDevice *mServiceMockUpdateDevice) Inspect(f func(d1 model.Device)) *mServiceMockUpdateDevice {
+	if mmUpdateDevice.mock.inspectFuncUpdateDevice != nil {
+		mmUpdateDevice.mock.t.Fatalf("Inspect function is already set for ServiceMock.UpdateDevice")
+	}
+
+	mmUpdateDevice.mock.inspectFuncUpdateDevice = f
+
+	return mmUpdateDevice
+}
+
+// Return sets up results that will be returned by Service.UpdateDevice
+func (mmUpdateDevice *mServiceMockUpdateDevice) Return(err error) *ServiceMock {
+	if mmUpdateDevice.mock.funcUpdateDevice != nil {
+		mmUpdateDevice.mock.t.Fatalf("ServiceMock.UpdateDevice mock is already set by Set")
+	}
+
+	if mmUpdateDevice.defaultExpectation == nil {
+		mmUpdateDevice.defaultExpectation = &ServiceMockUpdateDeviceExpectation{mock: mmUpdateDevice.mock}
+	}
+	mmUpdateDevice.defaultExpectation.results = &ServiceMockUpdateDeviceResults{err}
+	return mmUpdateDevice.mock
+}
+
+// Set uses given function f to mock the Service.UpdateDevice method
+func (mmUpdateDevice *mServiceMockUpdateDevice) Set(f func(d1 model.Device) (err error)) *ServiceMock {
+	if mmUpdateDevice.defaultExpectation != nil {
+		mmUpdateDevice.mock.t.Fatalf("Default expectation is already set for the Service.UpdateDevice method")
+	}
+
+	if len(mmUpdateDevice.expectations) > 0 {
+		mmUpdateDevice.mock.t.Fatalf("Some expectations are already set for the Service.UpdateDevice method")
+	}
+
+	mmUpdateDevice.mock.funcUpdateDevice = f
+	return mmUpdateDevice.mock
+}
+
+// When sets expectation for the Service.UpdateDevice which will trigger the result defined by the following
+// Then helper
+func (mmUpdateDevice *mServiceMockUpdateDevice) When(d1 model.Device) *ServiceMockUpdateDeviceExpectation {
+	if mmUpdateDevice.mock.funcUpdateDevice != nil {
+		mmUpdateDevice.mock.t.Fatalf("ServiceMock.UpdateDevice mock is already set by Set")
+	}
+
+	expectation := &ServiceMockUpdateDeviceExpectation{
+		mock:   mmUpdateDevice.mock,
+		params: &ServiceMockUpdateDeviceParams{d1},
+	}
+	mmUpdateDevice.expectations = append(mmUpdateDevice.expectations, expectation)
+	return expectation
+}
+
+// Then sets up Service.UpdateDevice return parameters for the expectation previously defined by the When method
+func (e *ServiceMockUpdateDeviceExpectation) Then(err error) *ServiceMock {
+	e.results = &ServiceMockUpdateDeviceResults{err}
+	return e.mock
+}
+
+// UpdateDevice implements service.Service
+func (mmUpdateDevice *ServiceMock) UpdateDevice(d1 model.Device) (err error) {
+	mm_atomic.AddUint64(&mmUpdateDevice.beforeUpdateDeviceCounter, 1)
+	defer mm_atomic.AddUint64(&mmUpdateDevice.afterUpdateDeviceCounter, 1)
+
+	if mmUpdateDevice.inspectFuncUpdateDevice != nil {
+		mmUpdateDevice.inspectFuncUpdateDevice(d1)
+	}
+
+	mm_params := &ServiceMockUpdateDeviceParams{d1}
+
+	// Record call args
+	mmUpdateDevice.UpdateDeviceMock.mutex.Lock()
+	mmUpdateDevice.UpdateDeviceMock.callArgs = append(mmUpdateDevice.UpdateDeviceMock.callArgs, mm_params)
+	mmUpdateDevice.UpdateDeviceMock.mutex.Unlock()
+
+	for _, e := range mmUpdateDevice.UpdateDeviceMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmUpdateDevice.UpdateDeviceMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmUpdateDevice.UpdateDeviceMock.defaultExpectation.Counter, 1)
+		mm_want := mmUpdateDevice.UpdateDeviceMock.defaultExpectation.params
+		mm_got := ServiceMockUpdateDeviceParams{d1}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmUpdateDevice.t.Errorf("ServiceMock.UpdateDevice got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmUpdateDevice.UpdateDeviceMock.defaultExpectation.results
+		if mm_results == nil {
+			mmUpdateDevice.t.Fatal("No results are set for the ServiceMock.UpdateDevice")
+		}
+		return (*mm_results).err
+	}
+	if mmUpdateDevice.funcUpdateDevice != nil {
+		return mmUpdateDevice.funcUpdateDevice(d1)
+	}
+	mmUpdateDevice.t.Fatalf("Unexpected call to ServiceMock.UpdateDevice. %v", d1)
+	return
+}
+
+// UpdateDeviceAfterCounter returns a count of finished ServiceMock.UpdateDevice invocations
+func (mmUpdateDevice *ServiceMock) UpdateDeviceAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmUpdateDevice.afterUpdateDeviceCounter)
+}
+
+// UpdateDeviceBeforeCounter returns a count of ServiceMock.UpdateDevice invocations
+func (mmUpdateDevice *ServiceMock) UpdateDeviceBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmUpdateDevice.beforeUpdateDeviceCounter)
+}
+
+// Calls returns a list of arguments used in each call to ServiceMock.UpdateDevice.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmUpdateDevice *mServiceMockUpdateDevice) Calls() []*ServiceMockUpdateDeviceParams {
+	mmUpdateDevice.mutex.RLock()
+
+	argCopy := make([]*ServiceMockUpdateDeviceParams, len(mmUpdateDevice.callArgs))
+	copy(argCopy, mmUpdateDevice.callArgs)
+
+	mmUpdateDevice.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockUpdateDeviceDone returns true if the count of the UpdateDevice invocations corresponds
+// the number of defined expectations
+func (m *ServiceMock) MinimockUpdateDeviceDone() bool {
+	for _, e := range m.UpdateDeviceMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.UpdateDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcUpdateDevice != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockUpdateDeviceInspect logs each unmet expectation
+func (m *ServiceMock) MinimockUpdateDeviceInspect() {
+	for _, e := range m.UpdateDeviceMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to ServiceMock.UpdateDevice with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.UpdateDeviceMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
+		if m.UpdateDeviceMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to ServiceMock.UpdateDevice")
+		} else {
+			m.t.Errorf("Expected call to ServiceMock.UpdateDevice with params: %#v", *m.UpdateDeviceMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcUpdateDevice != nil && mm_atomic.LoadUint64(&m.afterUpdateDeviceCounter) < 1 {
+		m.t.Error("Expected call to ServiceMock.UpdateDevice")
+	}
+}
+
+// MinimockFinish checks that all mocked methods have been called the expected number of times
+func (m *ServiceMock) MinimockFinish() {
+	if !m.minimockDone() {
+		m.MinimockChangesInspect()
+
+		m.MinimockCreateDeviceInspect()
+
+		m.MinimockDeleteDeviceInspect()
+
+		m.MinimockGetDeviceInspect()
+
+		m.MinimockListDevicesInspect()
+
+		m.MinimockResourceVersionInspect()
+
+		m.MinimockRestoreDeviceInspect()
+
+		m.MinimockSetFirmwareInspect()
+
+		m.MinimockTransitionDeviceInspect()
 
 		m.MinimockUpdateDeviceInspect()
 		m.t.FailNow()
@@ -958,8 +2254,14 @@ func (m *ServiceMock) MinimockWait(timeout mm_time.Duration) {
 func (m *ServiceMock) minimockDone() bool {
 	done := true
 	return done &&
+		m.MinimockChangesDone() &&
 		m.MinimockCreateDeviceDone() &&
 		m.MinimockDeleteDeviceDone() &&
 		m.MinimockGetDeviceDone() &&
+		m.MinimockListDevicesDone() &&
+		m.MinimockResourceVersionDone() &&
+		m.MinimockRestoreDeviceDone() &&
+		m.MinimockSetFirmwareDone() &&
+		m.MinimockTransitionDeviceDone() &&
 		m.MinimockUpdateDeviceDone()
 }
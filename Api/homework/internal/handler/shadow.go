@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"homework/internal/model"
+	"homework/internal/shadow"
+	"net/http"
+	"strconv"
+)
+
+type ShadowHandler struct {
+	*Handler
+	Shadow shadow.Service
+}
+
+func NewShadowHandler(h *Handler, s shadow.Service) *ShadowHandler {
+	return &ShadowHandler{Handler: h, Shadow: s}
+}
+
+func (h *ShadowHandler) HandleGetShadow(w http.ResponseWriter, r *http.Request) {
+	sh, err := h.Shadow.GetShadow(serial(r))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, sh)
+}
+
+func (h *ShadowHandler) HandleSetDesired(w http.ResponseWriter, r *http.Request) {
+	h.handleSetDocument(w, r, h.Shadow.SetDesired)
+}
+
+func (h *ShadowHandler) HandleSetReported(w http.ResponseWriter, r *http.Request) {
+	h.handleSetDocument(w, r, h.Shadow.SetReported)
+}
+
+func (h *ShadowHandler) HandleGetDelta(w http.ResponseWriter, r *http.Request) {
+	delta, err := h.Shadow.GetDelta(serial(r))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, delta)
+}
+
+func (h *ShadowHandler) HandleAckDelta(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Version int `json:"version"`
+	}{}
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	sh, err := h.Shadow.AckDelta(serial(r), req.Version)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, sh)
+}
+
+func (h *ShadowHandler) handleSetDocument(
+	w http.ResponseWriter, r *http.Request,
+	set func(num string, doc map[string]any, version int) (model.Shadow, error),
+) {
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			h.ErrResponse(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+	}
+
+	doc := map[string]any{}
+	if !h.decode(w, r, &doc) {
+		return
+	}
+
+	sh, err := set(serial(r), doc, version)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, sh)
+}
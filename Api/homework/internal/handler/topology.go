@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"homework/internal/model"
+	"homework/internal/topology"
+	"net/http"
+	"net/url"
+)
+
+type TopologyHandler struct {
+	*Handler
+	Topology topology.Service
+}
+
+func NewTopologyHandler(h *Handler, t topology.Service) *TopologyHandler {
+	return &TopologyHandler{Handler: h, Topology: t}
+}
+
+func (h *TopologyHandler) HandleCreateLink(w http.ResponseWriter, r *http.Request) {
+	l := model.Link{}
+	if !h.decode(w, r, &l) {
+		return
+	}
+
+	l, err := h.Topology.CreateLink(l)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/links/"+url.PathEscape(l.ID))
+	h.write(w, r, http.StatusCreated, l)
+}
+
+// HandleListLinks returns the links of the device in the serial query parameter, or every link.
+func (h *TopologyHandler) HandleListLinks(w http.ResponseWriter, r *http.Request) {
+	links, err := h.Topology.ListLinks(r.URL.Query().Get("serial"))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, links)
+}
+
+func (h *TopologyHandler) HandleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	if err := h.Topology.DeleteLink(r.PathValue("id")); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TopologyHandler) HandleSetParent(w http.ResponseWriter, r *http.Request) {
+	c := model.Containment{}
+	if !h.decode(w, r, &c) {
+		return
+	}
+	c.Child = serial(r)
+
+	if err := h.Topology.SetParent(c); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TopologyHandler) HandleRemoveParent(w http.ResponseWriter, r *http.Request) {
+	if err := h.Topology.RemoveParent(serial(r)); err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TopologyHandler) HandleNeighbors(w http.ResponseWriter, r *http.Request) {
+	neighbors, err := h.Topology.Neighbors(serial(r))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, neighbors)
+}
+
+func (h *TopologyHandler) HandleSubtree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.Topology.Subtree(serial(r))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, tree)
+}
+
+// HandlePath returns the serial numbers on a shortest path between the from and to devices.
+func (h *TopologyHandler) HandlePath(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	path, err := h.Topology.Path(q.Get("from"), q.Get("to"))
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	h.write(w, r, http.StatusOK, path)
+}
+
+func (h *TopologyHandler) HandleDOT(w http.ResponseWriter, r *http.Request) {
+	dot, err := h.Topology.DOT()
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	_, _ = w.Write([]byte(dot))
+}
@@ -0,0 +1,74 @@
+// Package metrics exposes Prometheus metrics of the device server.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.HistogramVec
+	calls    *prometheus.HistogramVec
+}
+
+// New registers the request metrics and a device count gauge reading devices on scrape.
+func New(devices func() int) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "http",
+			Subsystem: "server",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		calls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "registry",
+			Name:      "call_duration_seconds",
+			Help:      "Latency of device service and storage calls by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"op", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.calls,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "registry",
+			Name:      "devices",
+			Help:      "Number of devices in the registry.",
+		}, func() float64 { return float64(devices()) }),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest records the latency of a request served by route.
+func (m *Metrics) ObserveRequest(route, method string, status int, d time.Duration) {
+	m.requests.With(prometheus.Labels{
+		"route":  route,
+		"method": method,
+		"status": strconv.Itoa(status),
+	}).Observe(d.Seconds())
+}
+
+// ObserveCall records the latency of a decorated service or storage call.
+func (m *Metrics) ObserveCall(op string, d time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.calls.With(prometheus.Labels{"op": op, "outcome": outcome}).Observe(d.Seconds())
+}
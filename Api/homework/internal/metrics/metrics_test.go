@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	m := New(func() int { return 3 })
+	m.ObserveRequest("/device", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	m.ObserveCall("service.GetDevice", time.Millisecond, errors.New("device doesn't exist"))
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	assert.Contains(t, string(body), "registry_devices 3")
+	assert.Contains(t, string(body), `http_server_request_duration_seconds_bucket{method="GET",route="/device",status="200",le="0.025"} 1`)
+	assert.Contains(t, string(body), `registry_call_duration_seconds_count{op="service.GetDevice",outcome="error"} 1`)
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Auth requires one of tokens as a bearer token.
+func Auth(tokens []string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !ValidToken(tokens, token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="devices"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ValidToken reports whether token is one of tokens, in constant time.
+func ValidToken(tokens []string, token string) bool {
+	valid := false
+	for _, t := range tokens {
+		// compare against every token to not leak which one matched
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// MaxBodyBytes limits request bodies to n bytes; decoding a larger body fails.
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxInFlight answers 503 while n requests are being served.
+// Open watch and event streams take a slot each.
+func MaxInFlight(n int) func(http.Handler) http.Handler {
+	sem := make(chan struct{}, n)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				h.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests in flight", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// RateLimit answers 429 to requests over rps with the given burst.
+func RateLimit(rps float64, burst int) func(http.Handler) http.Handler {
+	if burst <= 0 {
+		burst = max(1, int(rps))
+	}
+	limiter := rate.NewLimiter(rate.Limit(rps), burst)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow() {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
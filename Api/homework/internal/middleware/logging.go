@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logging writes a structured log record per request with its status and latency.
+func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := wrap(w)
+			h.ServeHTTP(sw, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.code()),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int("bytes", sw.bytes),
+			}
+			if id := GetRequestID(r.Context()); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			if num := serial(r); num != "" {
+				attrs = append(attrs, slog.String("serial", num))
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+			}
+
+			level := slog.LevelInfo
+			if sw.code() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
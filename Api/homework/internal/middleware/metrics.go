@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"homework/internal/metrics"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Metrics observes request latency by route. route maps a request to the
+// pattern serving it, which keeps the label cardinality bounded.
+func Metrics(m *metrics.Metrics, route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := wrap(w)
+			h.ServeHTTP(sw, r)
+			m.ObserveRequest(route(r), r.Method, sw.code(), time.Since(start))
+		})
+	}
+}
+
+// Route returns the path of the mux pattern serving r, or "unmatched".
+// The method of the pattern is dropped, it is recorded separately.
+func Route(mux *http.ServeMux) func(*http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			return "unmatched"
+		}
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			return path
+		}
+		return pattern
+	}
+}
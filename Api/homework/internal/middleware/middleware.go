@@ -0,0 +1,188 @@
+// Package middleware holds HTTP middlewares of the device server.
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RequestIDHeader carries the request ID, accepted from the client or generated.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLen = 128
+
+type ctxKey struct{}
+
+// request is per-request state shared by the middlewares and handlers.
+type request struct {
+	id string
+
+	mu     sync.Mutex
+	serial string
+}
+
+func fromContext(ctx context.Context) *request {
+	req, _ := ctx.Value(ctxKey{}).(*request)
+	return req
+}
+
+// RequestID accepts X-Request-ID from the client or generates one,
+// echoes it in the response and stores it in the request context.
+// Client IDs with other characters than letters, digits and -._:/+= are replaced,
+// as they end up in logs and trace attributes.
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), ctxKey{}, &request{id: id})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Except applies mw to every request but those to paths, e.g. to keep
+// health checks and metrics out of authentication and limits.
+func Except(paths []string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		wrapped := mw(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range paths {
+				if r.URL.Path == p {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Under applies mw only to requests to paths starting with prefix, e.g. to require
+// admin tokens for admin endpoints.
+func Under(prefix string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		wrapped := mw(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetRequestID returns the ID of the request ctx belongs to, or "".
+func GetRequestID(ctx context.Context) string {
+	if req := fromContext(ctx); req != nil {
+		return req.id
+	}
+	return ""
+}
+
+// SetSerial records the serial number of the device a request is about,
+// for handlers that read it from the body rather than the num query parameter.
+func SetSerial(ctx context.Context, num string) {
+	if req := fromContext(ctx); req != nil {
+		req.mu.Lock()
+		req.serial = num
+		req.mu.Unlock()
+	}
+	setSpanSerial(ctx, num)
+}
+
+func serial(r *http.Request) string {
+	if req := fromContext(r.Context()); req != nil {
+		req.mu.Lock()
+		defer req.mu.Unlock()
+		if req.serial != "" {
+			return req.serial
+		}
+	}
+	return r.URL.Query().Get("num")
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._:/+=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusWriter records the response status and size.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush keeps streaming responses (watch, events) working through the middleware.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijacking not supported")
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func wrap(w http.ResponseWriter) *statusWriter {
+	if sw, ok := w.(*statusWriter); ok {
+		return sw
+	}
+	return &statusWriter{ResponseWriter: w}
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func logRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	var rec map[string]any
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &rec))
+	return rec
+}
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetRequestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices", nil))
+	assert.Len(t, got, 32)
+	assert.Equal(t, got, w.Header().Get(RequestIDHeader))
+
+	r := httptest.NewRequest(http.MethodGet, "/devices", nil)
+	r.Header.Set(RequestIDHeader, "abc")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "abc", got)
+	assert.Equal(t, "abc", w.Header().Get(RequestIDHeader))
+
+	for _, id := range []string{"a b", "a\x1b[31m", "a\"b", "é", strings.Repeat("a", 129)} {
+		r = httptest.NewRequest(http.MethodGet, "/devices", nil)
+		r.Header.Set(RequestIDHeader, id)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Len(t, got, 32, id)
+		assert.Equal(t, got, w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := RequestID(Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetSerial(r.Context(), "42")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("ok"))
+	})))
+
+	r := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(`{"serial_number":"42"}`))
+	r.Header.Set(RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	rec := logRecord(t, &buf)
+	assert.Equal(t, "INFO", rec["level"])
+	assert.Equal(t, "POST", rec["method"])
+	assert.Equal(t, "/device", rec["path"])
+	assert.Equal(t, float64(http.StatusCreated), rec["status"])
+	assert.Equal(t, float64(2), rec["bytes"])
+	assert.Equal(t, "req-1", rec["request_id"])
+	assert.Equal(t, "42", rec["serial"])
+	assert.Contains(t, rec, "latency")
+}
+
+func TestLoggingSerialFromQuery(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/device?num=7", nil))
+
+	rec := logRecord(t, &buf)
+	assert.Equal(t, "ERROR", rec["level"])
+	assert.Equal(t, "7", rec["serial"])
+	assert.NotContains(t, rec, "request_id")
+}
+
+func TestStatusWriterFlushes(t *testing.T) {
+	h := Tracing(func(r *http.Request) string { return r.URL.Path })(Logging(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		assert.Nil(t, http.NewResponseController(w).Flush())
+	})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices?watch=true", nil))
+	assert.True(t, w.Flushed)
+}
+
+func TestAuth(t *testing.T) {
+	h := Except([]string{"/healthz"}, Auth([]string{"secret", "other"}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		path, auth string
+		want       int
+	}{
+		{"/devices", "", http.StatusUnauthorized},
+		{"/devices", "Bearer wrong", http.StatusUnauthorized},
+		{"/devices", "Basic secret", http.StatusUnauthorized},
+		{"/devices", "Bearer secret", http.StatusOK},
+		{"/devices", "Bearer other", http.StatusOK},
+		{"/healthz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tt.want, w.Code, tt.path+" "+tt.auth)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	inFlight := MaxInFlight(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	go inFlight.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+	w := httptest.NewRecorder()
+	inFlight.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	close(release)
+
+	limited := RateLimit(1, 2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	codes := make([]int, 3)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		limited.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes[i] = w.Code
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+
+	body := MaxBodyBytes(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v any
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}))
+	w = httptest.NewRecorder()
+	body.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"serial_number":"1"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"homework/internal/tracing"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span per request, continuing the trace of the
+// caller if the request carries a traceparent header. Spans are named after
+// the route serving the request, see Route.
+func Tracing(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracing.Start(ctx, "HTTP "+r.Method+" "+route(r),
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+			)
+			defer span.End()
+			if num := r.URL.Query().Get("num"); num != "" {
+				span.SetAttributes(tracing.SerialKey.String(num))
+			}
+			if id := GetRequestID(ctx); id != "" {
+				span.SetAttributes(attribute.String("http.request_id", id))
+			}
+
+			sw := wrap(w)
+			h.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.status_code", sw.code()))
+			if sw.code() >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.code()))
+			}
+		})
+	}
+}
+
+func setSpanSerial(ctx context.Context, num string) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.SerialKey.String(num))
+}
@@ -0,0 +1,26 @@
+package model
+
+// Snapshot is the registry at a resource version.
+type Snapshot struct {
+	ResourceVersion uint64   `json:"resource_version"`
+	Devices         []Device `json:"devices"`
+}
+
+// ChangeSet holds the mutations after resource version From up to ResourceVersion.
+type ChangeSet struct {
+	From            uint64   `json:"from"`
+	ResourceVersion uint64   `json:"resource_version"`
+	Changes         []Change `json:"changes"`
+}
+
+// Restore puts Devices into the registry as they are and deletes the devices in Delete.
+type Restore struct {
+	Devices []Device `json:"devices,omitempty"`
+	Delete  []string `json:"delete,omitempty"`
+}
+
+type RestoreResult struct {
+	Restored        int    `json:"restored"`
+	Deleted         int    `json:"deleted"`
+	ResourceVersion uint64 `json:"resource_version"`
+}
@@ -0,0 +1,16 @@
+package model
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "ADDED"
+	ChangeModified ChangeType = "MODIFIED"
+	ChangeDeleted  ChangeType = "DELETED"
+)
+
+// Change is a single storage mutation. Deleted changes carry the last state of the device.
+type Change struct {
+	Type            ChangeType `json:"type"`
+	ResourceVersion uint64     `json:"resource_version"`
+	Device          Device     `json:"object"`
+}
@@ -1,7 +1,27 @@
 package model
 
+import "time"
+
 type Device struct {
-	SerialNum string `json:"serial_number"`
-	Model     string `json:"model"`
-	IP        string `json:"ip"`
+	SerialNum      string            `json:"serial_number"`
+	Model          string            `json:"model"`
+	IP             string            `json:"ip"`
+	Firmware       string            `json:"firmware,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Attributes     map[string]any    `json:"attributes,omitempty"`
+	SchemaVersion  int               `json:"schema_version,omitempty"`
+	State          State             `json:"state,omitempty"`
+	LastTransition *Transition       `json:"last_transition,omitempty"`
+	// CreatedAt is set by the registry; retention policy TTLs count from it.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// ExpiresAt makes the device expire at that time, overriding retention policies.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Transition records the latest lifecycle state change of a device.
+type Transition struct {
+	From   State     `json:"from"`
+	To     State     `json:"to"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
 }
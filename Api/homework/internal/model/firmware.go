@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// RolloutStatus is the upgrade status of a single device in a campaign.
+type RolloutStatus string
+
+const (
+	RolloutPending    RolloutStatus = "pending"
+	RolloutInProgress RolloutStatus = "in_progress"
+	RolloutSucceeded  RolloutStatus = "succeeded"
+	RolloutFailed     RolloutStatus = "failed"
+)
+
+type CampaignState string
+
+const (
+	CampaignRunning   CampaignState = "running"
+	CampaignPaused    CampaignState = "paused"
+	CampaignHalted    CampaignState = "halted"
+	CampaignAborted   CampaignState = "aborted"
+	CampaignCompleted CampaignState = "completed"
+)
+
+// CampaignTarget selects devices by model and labels. Empty fields match any device.
+type CampaignTarget struct {
+	Model  string            `json:"model,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Campaign rolls Firmware out to the targeted devices in stages. Stages are
+// cumulative percentages of targeted devices, e.g. [10, 50, 100]. The campaign
+// halts once the share of failed devices among released ones exceeds MaxFailureRate.
+// Resuming a halted campaign acknowledges the failures so far, which no longer count.
+type Campaign struct {
+	ID             string                   `json:"id"`
+	Name           string                   `json:"name"`
+	Firmware       string                   `json:"firmware"`
+	Target         CampaignTarget           `json:"target"`
+	Stages         []int                    `json:"stages"`
+	MaxFailureRate float64                  `json:"max_failure_rate"`
+	State          CampaignState            `json:"state"`
+	Stage          int                      `json:"stage"`
+	Released       int                      `json:"released"`
+	Devices        map[string]DeviceRollout `json:"devices"`
+	CreatedAt      time.Time                `json:"created_at"`
+}
+
+// DeviceRollout is the progress of one device within a campaign.
+type DeviceRollout struct {
+	Status    RolloutStatus `json:"status"`
+	Stage     int           `json:"stage"`
+	Message   string        `json:"message,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	// Acknowledged marks a failure accepted by resuming the halted campaign.
+	Acknowledged bool `json:"acknowledged,omitempty"`
+}
+
+// FirmwareJob tells a device agent which firmware to install.
+type FirmwareJob struct {
+	CampaignID string `json:"campaign_id"`
+	Firmware   string `json:"firmware"`
+}
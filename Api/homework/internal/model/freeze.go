@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+type WindowKind string
+
+const (
+	// WindowFreeze rejects changes to the devices in scope.
+	WindowFreeze WindowKind = "freeze"
+	// WindowMaintenance allows changes to the devices in scope during freezes.
+	WindowMaintenance WindowKind = "maintenance"
+)
+
+// Window is a maintenance window or change freeze from Start until End.
+// Zero Model and Labels scope it to every device.
+type Window struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name,omitempty"`
+	Kind   WindowKind        `json:"kind"`
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+	Model  string            `json:"model,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type Schedule struct {
+	Active   []Window `json:"active"`
+	Upcoming []Window `json:"upcoming"`
+}
+
+// Override is the audit record of a change let through a freeze.
+type Override struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	SerialNum string    `json:"serial_number"`
+	Reason    string    `json:"reason"`
+	Window    string    `json:"window"`
+	RequestID string    `json:"request_id,omitempty"`
+}
@@ -0,0 +1,19 @@
+package model
+
+// Reason is the machine-readable cause of an API error, sent as "reason" next to its message.
+type Reason string
+
+const (
+	ReasonDeviceAlreadyExists  Reason = "device_already_exists"
+	ReasonDeviceDoesNotExist   Reason = "device_does_not_exist"
+	ReasonInvalidModel         Reason = "invalid_model"
+	ReasonInvalidSerialNumber  Reason = "invalid_serial_number"
+	ReasonInvalidIPAddress     Reason = "invalid_ip_address"
+	ReasonInvalidState         Reason = "invalid_state"
+	ReasonIllegalTransition    Reason = "illegal_transition"
+	ReasonDeviceDecommissioned Reason = "device_decommissioned"
+	ReasonInvalidAttributes    Reason = "invalid_attributes"
+	ReasonResourceVersionGone  Reason = "resource_version_gone"
+	ReasonFrozen               Reason = "frozen"
+	ReasonDeviceInUse          Reason = "device_in_use"
+)
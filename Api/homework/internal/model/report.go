@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type IssueKind string
+
+const (
+	IssueDuplicateIP     IssueKind = "duplicate_ip"
+	IssueIPOutsideSubnet IssueKind = "ip_outside_subnets"
+	IssueSerialFormat    IssueKind = "serial_format"
+	IssueNoHeartbeat     IssueKind = "no_heartbeat"
+	IssueUnknownModel    IssueKind = "unknown_model"
+)
+
+// Issue is an anomaly of one device, or of several for duplicates.
+type Issue struct {
+	Kind       IssueKind `json:"kind"`
+	SerialNums []string  `json:"serial_numbers"`
+	Message    string    `json:"message"`
+}
+
+// Report is the result of a registry consistency check.
+type Report struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Devices     int               `json:"devices"`
+	Summary     map[IssueKind]int `json:"summary"`
+	Issues      []Issue           `json:"issues"`
+}
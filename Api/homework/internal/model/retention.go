@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+type RetentionAction string
+
+const (
+	RetentionDelete RetentionAction = "delete"
+	// RetentionTrash deletes the device but keeps it in the trash, from which it can be restored.
+	RetentionTrash RetentionAction = "trash"
+)
+
+// RetentionPolicy expires devices of a model and labels TTL after their creation.
+// Zero Model and Labels match any device.
+type RetentionPolicy struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name,omitempty"`
+	Model  string            `json:"model,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// TTL is a duration such as 72h or a number of days such as 30d.
+	TTL string `json:"ttl"`
+	// Action defaults to the action configured for the server.
+	Action RetentionAction `json:"action,omitempty"`
+}
+
+// Expiry is when and how a device expires. Policy is empty if the device's own ExpiresAt applies.
+type Expiry struct {
+	Device    Device          `json:"device"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Action    RetentionAction `json:"action"`
+	Policy    string          `json:"policy,omitempty"`
+}
+
+type TrashedDevice struct {
+	Device    Device    `json:"device"`
+	TrashedAt time.Time `json:"trashed_at"`
+	Policy    string    `json:"policy,omitempty"`
+}
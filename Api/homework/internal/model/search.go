@@ -0,0 +1,25 @@
+package model
+
+type MatchKind string
+
+const (
+	MatchExact     MatchKind = "exact"
+	MatchPrefix    MatchKind = "prefix"
+	MatchSubstring MatchKind = "substring"
+	MatchFuzzy     MatchKind = "fuzzy"
+)
+
+// SearchMatch is the best indexed term a query word matched.
+type SearchMatch struct {
+	Word  string    `json:"word"`
+	Field string    `json:"field"`
+	Term  string    `json:"term"`
+	Kind  MatchKind `json:"kind"`
+}
+
+// SearchResult is a device matching every word of a query; higher scores rank first.
+type SearchResult struct {
+	Device  Device        `json:"device"`
+	Score   float64       `json:"score"`
+	Matches []SearchMatch `json:"matches"`
+}
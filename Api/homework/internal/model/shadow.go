@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Shadow holds the configuration a device should have (desired) and
+// the configuration it reports (reported).
+type Shadow struct {
+	Desired         map[string]any `json:"desired"`
+	DesiredVersion  int            `json:"desired_version"`
+	Reported        map[string]any `json:"reported"`
+	ReportedVersion int            `json:"reported_version"`
+	// ReportedAt is when the device last reported, its heartbeat.
+	ReportedAt *time.Time     `json:"reported_at,omitempty"`
+	Delta      map[string]any `json:"delta,omitempty"`
+}
+
+// Delta is the part of the desired configuration that is not reported yet.
+// Version is the desired version it was computed from.
+type Delta struct {
+	Version int            `json:"version"`
+	State   map[string]any `json:"state"`
+}
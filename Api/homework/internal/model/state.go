@@ -0,0 +1,37 @@
+package model
+
+// State is a lifecycle stage of a device.
+type State string
+
+const (
+	StateOrdered        State = "ordered"
+	StateReceived       State = "received"
+	StateProvisioned    State = "provisioned"
+	StateActive         State = "active"
+	StateMaintenance    State = "maintenance"
+	StateDecommissioned State = "decommissioned"
+)
+
+// transitions lists states reachable from each state.
+var transitions = map[State][]State{
+	StateOrdered:        {StateReceived, StateDecommissioned},
+	StateReceived:       {StateProvisioned, StateDecommissioned},
+	StateProvisioned:    {StateActive, StateMaintenance, StateDecommissioned},
+	StateActive:         {StateMaintenance, StateDecommissioned},
+	StateMaintenance:    {StateActive, StateProvisioned, StateDecommissioned},
+	StateDecommissioned: {},
+}
+
+func (s State) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+func (s State) CanTransitionTo(to State) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
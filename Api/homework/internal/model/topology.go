@@ -0,0 +1,54 @@
+package model
+
+type LinkType string
+
+const (
+	LinkEthernet LinkType = "ethernet"
+	LinkFiber    LinkType = "fiber"
+	LinkSerial   LinkType = "serial"
+	LinkPower    LinkType = "power"
+)
+
+// Endpoint is one end of a link: a port of a device.
+type Endpoint struct {
+	SerialNum string `json:"serial_number"`
+	Port      string `json:"port"`
+}
+
+// Link is an undirected connection between ports of two devices.
+type Link struct {
+	ID   string   `json:"id"`
+	Type LinkType `json:"type"`
+	A    Endpoint `json:"a"`
+	B    Endpoint `json:"b"`
+}
+
+// Containment places a child device, e.g. a line card, in a slot of its parent chassis.
+type Containment struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+	Slot   string `json:"slot,omitempty"`
+}
+
+type Relation string
+
+const (
+	RelationLink   Relation = "link"
+	RelationParent Relation = "parent"
+	RelationChild  Relation = "child"
+)
+
+// Neighbor is a device directly related to another one. Link is set for RelationLink.
+type Neighbor struct {
+	SerialNum string   `json:"serial_number"`
+	Relation  Relation `json:"relation"`
+	Slot      string   `json:"slot,omitempty"`
+	Link      *Link    `json:"link,omitempty"`
+}
+
+// TopologyNode is a device with everything it contains.
+type TopologyNode struct {
+	SerialNum string         `json:"serial_number"`
+	Slot      string         `json:"slot,omitempty"`
+	Children  []TopologyNode `json:"children,omitempty"`
+}
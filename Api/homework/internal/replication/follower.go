@@ -0,0 +1,340 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"homework/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryInterval = time.Second
+	defaultPollInterval  = time.Second
+	watchTimeoutSeconds  = 30
+	resourceVersionHdr   = "X-Resource-Version"
+)
+
+var errResync = errors.New("leader history is gone, resync needed")
+
+type FollowerConfig struct {
+	// LeaderURL is the base URL of the leader device API.
+	LeaderURL string
+	// ForwardWrites proxies writes to the leader instead of rejecting them.
+	ForwardWrites bool
+	// Token is the bearer token sent to a leader requiring authentication.
+	Token         string
+	Client        *http.Client
+	RetryInterval time.Duration
+	PollInterval  time.Duration
+}
+
+// Status describes replication progress. Lag is the number of leader
+// changes not applied yet; LagSeconds is how long the follower has been behind.
+type Status struct {
+	Role            string    `json:"role"`
+	LeaderURL       string    `json:"leader_url,omitempty"`
+	ResourceVersion uint64    `json:"resource_version"`
+	LeaderVersion   uint64    `json:"leader_version,omitempty"`
+	Lag             uint64    `json:"lag"`
+	LagSeconds      float64   `json:"lag_seconds"`
+	Connected       bool      `json:"connected"`
+	LastSync        time.Time `json:"last_sync,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// Follower replicates the leader registry into local storage by listing it
+// once and then tailing its watch API. Only devices are replicated: freezes,
+// shadows, firmware jobs, the catalog and topology links stay local to each
+// instance, so a follower serves its own, usually empty, view of them.
+type Follower struct {
+	storage service.Storage
+	cfg     FollowerConfig
+
+	mu            sync.Mutex
+	synced        bool
+	applied       uint64
+	leaderVersion uint64
+	behindSince   time.Time
+	lastSync      time.Time
+	connected     bool
+	lastErr       error
+	// stopWatch ends the running watch, e.g. when the leader turns out to be behind.
+	stopWatch context.CancelFunc
+}
+
+func NewFollower(storage service.Storage, cfg FollowerConfig) *Follower {
+	cfg.LeaderURL = strings.TrimRight(cfg.LeaderURL, "/")
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{}
+	}
+	if cfg.RetryInterval == 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	return &Follower{storage: storage, cfg: cfg}
+}
+
+// Run replicates until ctx is canceled. It resumes from the last applied
+// version, so calling Run again after it returns catches up on missed changes.
+func (f *Follower) Run(ctx context.Context) {
+	go f.pollLeaderVersion(ctx)
+
+	for ctx.Err() == nil {
+		f.mu.Lock()
+		synced, applied := f.synced, f.applied
+		f.mu.Unlock()
+
+		var err error
+		if !synced {
+			err = f.resync(ctx)
+		} else {
+			watchCtx, cancel := context.WithCancel(ctx)
+			f.mu.Lock()
+			f.stopWatch = cancel
+			f.mu.Unlock()
+			err = f.watch(watchCtx, applied)
+			stopped := watchCtx.Err() != nil && ctx.Err() == nil
+			cancel()
+			if stopped {
+				// by pollLeaderVersion, to resync
+				continue
+			}
+		}
+		if errors.Is(err, errResync) {
+			f.mu.Lock()
+			f.synced = false
+			f.mu.Unlock()
+			continue
+		}
+		if err != nil && ctx.Err() == nil {
+			f.setError(err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(f.cfg.RetryInterval):
+			}
+		}
+	}
+}
+
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	st := Status{
+		Role:            "follower",
+		LeaderURL:       f.cfg.LeaderURL,
+		ResourceVersion: f.applied,
+		LeaderVersion:   f.leaderVersion,
+		Connected:       f.connected,
+		LastSync:        f.lastSync,
+	}
+	if f.leaderVersion > f.applied {
+		st.Lag = f.leaderVersion - f.applied
+	}
+	if !f.behindSince.IsZero() {
+		st.LagSeconds = time.Since(f.behindSince).Seconds()
+	}
+	if f.lastErr != nil {
+		st.LastError = f.lastErr.Error()
+	}
+	return st
+}
+
+// resync replaces local storage with the leader's device list.
+func (f *Follower) resync(ctx context.Context) error {
+	resp, err := f.get(ctx, f.cfg.LeaderURL+"/v1/devices")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("list devices: %s", resp.Status)
+	}
+	rv, err := strconv.ParseUint(resp.Header.Get(resourceVersionHdr), 10, 64)
+	if err != nil {
+		return fmt.Errorf("list devices: invalid resource version: %w", err)
+	}
+	var devices []model.Device
+	if err := json.NewDecoder(resp.Body).Decode(&devices); err != nil {
+		return fmt.Errorf("list devices: %w", err)
+	}
+
+	keep := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		keep[d.SerialNum] = true
+		f.storage.Add(d)
+	}
+	var stale []string
+	f.storage.Range(func(d model.Device) bool {
+		if !keep[d.SerialNum] {
+			stale = append(stale, d.SerialNum)
+		}
+		return true
+	})
+	for _, num := range stale {
+		f.storage.Del(num)
+	}
+
+	f.setApplied(rv)
+	f.mu.Lock()
+	f.synced = true
+	f.mu.Unlock()
+	return nil
+}
+
+// watch applies leader changes after rv until the watch request ends.
+func (f *Follower) watch(ctx context.Context, rv uint64) error {
+	u := fmt.Sprintf("%s/v1/devices?watch=true&resourceVersion=%d&timeoutSeconds=%d", f.cfg.LeaderURL, rv, watchTimeoutSeconds)
+	resp, err := f.get(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return errResync
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("watch devices: %s", resp.Status)
+	}
+	f.setConnected()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			model.Change
+			Code int `json:"code"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				// the leader closes the stream after timeoutSeconds, the next watch resumes from the applied version
+				return nil
+			}
+			return fmt.Errorf("watch devices: %w", err)
+		}
+
+		switch event.Type {
+		case model.ChangeAdded, model.ChangeModified:
+			f.storage.Add(event.Device)
+		case model.ChangeDeleted:
+			f.storage.Del(event.Device.SerialNum)
+		default:
+			if event.Code == http.StatusGone {
+				return errResync
+			}
+			continue
+		}
+		f.setApplied(event.ResourceVersion)
+	}
+}
+
+// pollLeaderVersion keeps track of the leader version to compute lag.
+func (f *Follower) pollLeaderVersion(ctx context.Context) {
+	ticker := time.NewTicker(f.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		f.mu.Lock()
+		applied := f.applied
+		f.mu.Unlock()
+
+		if rv, err := f.fetchLeaderVersion(ctx); err == nil {
+			f.mu.Lock()
+			// a leader behind the version applied before asking lost changes, e.g. restarted
+			// on the memory backend, so versions no longer line up and only a resync is consistent
+			if rv < applied && f.synced {
+				f.synced = false
+				if f.stopWatch != nil {
+					f.stopWatch()
+				}
+			}
+			f.leaderVersion = rv
+			f.updateBehind()
+			f.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Follower) fetchLeaderVersion(ctx context.Context) (uint64, error) {
+	resp, err := f.get(ctx, f.cfg.LeaderURL+"/replication/status")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("leader status: %s", resp.Status)
+	}
+	var st Status
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return 0, err
+	}
+	return st.ResourceVersion, nil
+}
+
+// get requests u from the leader, authenticated with the configured token.
+func (f *Follower) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.Token)
+	}
+	return f.cfg.Client.Do(req)
+}
+
+func (f *Follower) setApplied(rv uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.applied = rv
+	if rv > f.leaderVersion {
+		f.leaderVersion = rv
+	}
+	f.lastSync = time.Now().UTC()
+	f.connected = true
+	f.lastErr = nil
+	f.updateBehind()
+}
+
+func (f *Follower) setConnected() {
+	f.mu.Lock()
+	f.connected = true
+	f.lastErr = nil
+	f.mu.Unlock()
+}
+
+func (f *Follower) setError(err error) {
+	f.mu.Lock()
+	f.connected = false
+	f.lastErr = err
+	f.mu.Unlock()
+}
+
+// updateBehind must be called with f.mu held.
+func (f *Follower) updateBehind() {
+	switch {
+	case f.applied >= f.leaderVersion:
+		f.behindSince = time.Time{}
+	case f.behindSince.IsZero():
+		f.behindSince = time.Now()
+	}
+}
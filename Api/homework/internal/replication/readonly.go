@@ -0,0 +1,32 @@
+package replication
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ReadOnly serves reads from next and forwards writes to the leader or rejects
+// them with 403, depending on FollowerConfig.ForwardWrites. Forwarded writes of
+// anything but devices apply to the leader only, see Follower.
+func ReadOnly(next http.Handler, f *Follower) (http.Handler, error) {
+	leader, err := url.Parse(f.cfg.LeaderURL)
+	if err != nil {
+		return nil, err
+	}
+	proxy := httputil.NewSingleHostReverseProxy(leader)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			if f.cfg.ForwardWrites {
+				proxy.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-Leader", f.cfg.LeaderURL)
+			http.Error(w, "Read-only follower, send writes to the leader; only devices are replicated", http.StatusForbidden)
+		}
+	}), nil
+}
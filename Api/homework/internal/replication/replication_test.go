@@ -0,0 +1,276 @@
+package replication_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/handler"
+	"homework/internal/middleware"
+	"homework/internal/model"
+	"homework/internal/replication"
+	"homework/internal/router"
+	"homework/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type node struct {
+	storage  service.Storage
+	service  service.Service
+	follower *replication.Follower
+	server   *httptest.Server
+	// restart replaces a leader with an empty one on the memory backend, behind the same URL.
+	restart func()
+}
+
+func newLeader(t *testing.T) *node {
+	n := &node{}
+	var current atomic.Pointer[http.Handler]
+	n.restart = func() {
+		n.storage = service.NewStorage()
+		n.service = service.NewService(n.storage)
+		h := handler.NewHandler(n.service)
+		var mux http.Handler = router.NewRouter(h, router.WithReplication(handler.NewReplicationHandler(h, nil)))
+		current.Store(&mux)
+	}
+	n.restart()
+	n.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		(*current.Load()).ServeHTTP(w, r)
+	}))
+	t.Cleanup(n.server.Close)
+	return n
+}
+
+func newFollower(t *testing.T, leader *node, forward bool) *node {
+	storage := service.NewStorage()
+	svc := service.NewService(storage)
+	f := replication.NewFollower(storage, replication.FollowerConfig{
+		LeaderURL:     leader.server.URL,
+		ForwardWrites: forward,
+		RetryInterval: 10 * time.Millisecond,
+		PollInterval:  10 * time.Millisecond,
+	})
+
+	h := handler.NewHandler(svc)
+	mux := router.NewRouter(h, router.WithReplication(handler.NewReplicationHandler(h, f)))
+	root, err := replication.ReadOnly(mux, f)
+	assert.Nil(t, err)
+	srv := httptest.NewServer(root)
+	t.Cleanup(srv.Close)
+
+	return &node{storage: storage, service: svc, follower: f, server: srv}
+}
+
+func (n *node) run(t *testing.T) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.follower.Run(ctx)
+		close(done)
+	}()
+
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func (n *node) caughtUp(leader *node) func() bool {
+	return func() bool {
+		st := n.follower.Status()
+		return st.ResourceVersion == leader.service.ResourceVersion() && st.Lag == 0
+	}
+}
+
+func createDevices(t *testing.T, n *node, from, to int) {
+	for i := from; i < to; i++ {
+		assert.Nil(t, n.service.CreateDevice(model.Device{SerialNum: strconv.Itoa(i), Model: "switch", IP: "1.1.1.1"}))
+	}
+}
+
+func TestFollowerReplicates(t *testing.T) {
+	leader := newLeader(t)
+	createDevices(t, leader, 0, 3)
+
+	follower := newFollower(t, leader, false)
+	follower.run(t)
+
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+	devices, _ := follower.service.ListDevices(service.Filter{})
+	assert.Len(t, devices, 3)
+
+	assert.Nil(t, leader.service.TransitionDevice("1", model.StateReceived, "arrived"))
+	assert.Nil(t, leader.service.DeleteDevice("2"))
+
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+
+	resp, err := http.Get(follower.server.URL + "/device?num=1")
+	assert.Nil(t, err)
+	var d model.Device
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&d))
+	_ = resp.Body.Close()
+	assert.Equal(t, model.StateReceived, d.State)
+
+	_, err = follower.service.GetDevice("2")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+
+	resp, err = http.Get(follower.server.URL + "/replication/status")
+	assert.Nil(t, err)
+	var st replication.Status
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&st))
+	_ = resp.Body.Close()
+	assert.Equal(t, "follower", st.Role)
+	assert.True(t, st.Connected)
+}
+
+func TestFollowerWrites(t *testing.T) {
+	leader := newLeader(t)
+	payload, _ := json.Marshal(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"})
+
+	rejecting := newFollower(t, leader, false)
+	resp, err := http.Post(rejecting.server.URL+"/device", "application/json", bytes.NewReader(payload))
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, leader.server.URL, resp.Header.Get("X-Leader"))
+
+	forwarding := newFollower(t, leader, true)
+	forwarding.run(t)
+	resp, err = http.Post(forwarding.server.URL+"/device", "application/json", bytes.NewReader(payload))
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	_, err = leader.service.GetDevice("1")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := forwarding.service.GetDevice("1")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestFollowerCatchesUpAfterRestart(t *testing.T) {
+	leader := newLeader(t)
+	createDevices(t, leader, 0, 3)
+
+	follower := newFollower(t, leader, false)
+	stop := follower.run(t)
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+	stop()
+
+	createDevices(t, leader, 3, 5)
+	assert.Nil(t, leader.service.DeleteDevice("0"))
+
+	follower.run(t)
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+
+	devices, _ := follower.service.ListDevices(service.Filter{})
+	assert.Len(t, devices, 4)
+	assert.Equal(t, "1", devices[0].SerialNum)
+}
+
+func TestFollowerResyncsWhenHistoryIsGone(t *testing.T) {
+	leader := newLeader(t)
+	createDevices(t, leader, 0, 2)
+
+	follower := newFollower(t, leader, false)
+	stop := follower.run(t)
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+	stop()
+
+	assert.Nil(t, leader.service.DeleteDevice("0"))
+	createDevices(t, leader, 2, 2000)
+
+	follower.run(t)
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+
+	devices, _ := follower.service.ListDevices(service.Filter{})
+	assert.Len(t, devices, 1999)
+	_, err := follower.service.GetDevice("0")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+}
+
+func TestFollowerResyncsAfterLeaderRestart(t *testing.T) {
+	leader := newLeader(t)
+	createDevices(t, leader, 0, 5)
+
+	follower := newFollower(t, leader, false)
+	follower.run(t)
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+
+	// the open watch stays on the old leader, only its version tells the restart
+	leader.restart()
+	createDevices(t, leader, 10, 12)
+	assert.Eventually(t, follower.caughtUp(leader), 5*time.Second, 10*time.Millisecond)
+
+	devices, _ := follower.service.ListDevices(service.Filter{})
+	assert.Len(t, devices, 2)
+	assert.Equal(t, "10", devices[0].SerialNum)
+}
+
+func TestFollowerToken(t *testing.T) {
+	svc := service.NewService(service.NewStorage())
+	h := handler.NewHandler(svc)
+	leader := httptest.NewServer(middleware.Auth([]string{"secret"})(router.NewRouter(h, router.WithReplication(handler.NewReplicationHandler(h, nil)))))
+	t.Cleanup(leader.Close)
+	assert.Nil(t, svc.CreateDevice(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}))
+
+	storage := service.NewStorage()
+	f := replication.NewFollower(storage, replication.FollowerConfig{LeaderURL: leader.URL, Token: "secret", RetryInterval: 10 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	assert.Nil(t, svc.CreateDevice(model.Device{SerialNum: "2", Model: "switch", IP: "1.1.1.1"}))
+	assert.Eventually(t, func() bool {
+		st := f.Status()
+		return st.ResourceVersion == 2 && st.LeaderVersion == 2 && st.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+	_, ok := storage.Get("2")
+	assert.True(t, ok)
+}
+
+func TestFollowerBacksOffOnCorruptWatch(t *testing.T) {
+	var watches atomic.Int32
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Resource-Version", "1")
+		if r.URL.Query().Get("watch") == "" {
+			_, _ = w.Write([]byte("[]"))
+			return
+		}
+		watches.Add(1)
+		_, _ = w.Write([]byte(`{"type":`))
+	}))
+	t.Cleanup(leader.Close)
+
+	f := replication.NewFollower(service.NewStorage(), replication.FollowerConfig{LeaderURL: leader.URL, RetryInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	assert.Eventually(t, func() bool { return f.Status().LastError != "" }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, f.Status().LastError, "watch devices")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), watches.Load())
+}
@@ -0,0 +1,189 @@
+// Package report checks the registry for duplicates, conflicts and anomalies.
+package report
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/catalog"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"log/slog"
+	"net/netip"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const EventGenerated = "report.generated"
+
+// Rules configure the checks, zero fields skip them.
+type Rules struct {
+	// Subnets are the networks device IPs must belong to.
+	Subnets []netip.Prefix
+	// SerialFormats maps vendors to the pattern serial numbers of their models match in full.
+	SerialFormats map[string]*regexp.Regexp
+	// HeartbeatTimeout flags active devices that haven't reported for longer.
+	HeartbeatTimeout time.Duration
+}
+
+// ParseRules builds rules from CIDRs and regular expressions.
+func ParseRules(subnets []string, formats map[string]string, heartbeatTimeout time.Duration) (Rules, error) {
+	rules := Rules{SerialFormats: make(map[string]*regexp.Regexp), HeartbeatTimeout: heartbeatTimeout}
+	for _, s := range subnets {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return Rules{}, err
+		}
+		rules.Subnets = append(rules.Subnets, p.Masked())
+	}
+	for vendor, expr := range formats {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return Rules{}, fmt.Errorf("serial format of %s: %w", vendor, err)
+		}
+		rules.SerialFormats[vendor] = re
+	}
+	return rules, nil
+}
+
+// Catalog lists the known models, see catalog.Service.
+type Catalog interface {
+	ListModels() []catalog.Model
+}
+
+// Heartbeats tells when devices last reported, see shadow.Service.
+type Heartbeats interface {
+	LastReported(num string) (time.Time, bool)
+	// TrackedSince returns when heartbeats started being tracked.
+	TrackedSince() time.Time
+}
+
+type Service interface {
+	// Generate checks every stored device against the rules.
+	Generate() model.Report
+}
+
+type Option func(*reportService)
+
+// NewService creates a report over the devices of storage. A nil models or
+// heartbeats skips the checks that need them.
+func NewService(devices service.Storage, models Catalog, heartbeats Heartbeats, rules Rules, options ...Option) Service {
+	s := &reportService{devices: devices, models: models, heartbeats: heartbeats, rules: rules, now: time.Now}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// WithClock replaces time.Now for report times and heartbeat ages.
+func WithClock(now func() time.Time) Option {
+	return func(s *reportService) {
+		s.now = now
+	}
+}
+
+// Run generates a report every interval until ctx is done, logs its summary and publishes it on bus.
+func Run(ctx context.Context, s Service, interval time.Duration, bus *events.Bus) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r := s.Generate()
+			slog.Info("registry report", slog.Int("devices", r.Devices), slog.Int("issues", len(r.Issues)))
+			if bus != nil {
+				bus.Publish(events.Event{Type: EventGenerated, Data: r})
+			}
+		}
+	}
+}
+
+type reportService struct {
+	devices    service.Storage
+	models     Catalog
+	heartbeats Heartbeats
+	rules      Rules
+	now        func() time.Time
+}
+
+func (s *reportService) Generate() model.Report {
+	now := s.now().UTC()
+	r := model.Report{GeneratedAt: now, Summary: make(map[model.IssueKind]int), Issues: make([]model.Issue, 0)}
+	add := func(kind model.IssueKind, message string, serials ...string) {
+		r.Issues = append(r.Issues, model.Issue{Kind: kind, SerialNums: serials, Message: message})
+		r.Summary[kind]++
+	}
+
+	var vendors map[string]string
+	if s.models != nil {
+		vendors = make(map[string]string)
+		for _, m := range s.models.ListModels() {
+			vendors[m.Name] = m.Vendor
+		}
+	}
+
+	byIP := make(map[netip.Addr][]string)
+	s.devices.Range(func(d model.Device) bool {
+		r.Devices++
+
+		if vendors != nil {
+			vendor, ok := vendors[d.Model]
+			if !ok {
+				add(model.IssueUnknownModel, fmt.Sprintf("model %q is not in the catalog", d.Model), d.SerialNum)
+			} else if re := s.rules.SerialFormats[vendor]; re != nil && !re.MatchString(d.SerialNum) {
+				add(model.IssueSerialFormat, fmt.Sprintf("serial number doesn't match the %s format %s", vendor, re), d.SerialNum)
+			}
+		}
+
+		if addr, err := netip.ParseAddr(d.IP); err == nil {
+			addr = addr.Unmap()
+			byIP[addr] = append(byIP[addr], d.SerialNum)
+			if len(s.rules.Subnets) > 0 && !contains(s.rules.Subnets, addr) {
+				add(model.IssueIPOutsideSubnet, fmt.Sprintf("%s is outside every configured subnet", addr), d.SerialNum)
+			}
+		}
+
+		// only active devices are expected to report
+		if s.heartbeats != nil && s.rules.HeartbeatTimeout > 0 && d.State == model.StateActive {
+			last, ok := s.heartbeats.LastReported(d.SerialNum)
+			switch {
+			case !ok:
+				// devices may have reported before tracking started, give them the timeout to report again
+				if since := s.heartbeats.TrackedSince(); now.Sub(since) > s.rules.HeartbeatTimeout {
+					add(model.IssueNoHeartbeat, "not reported since "+since.UTC().Format(time.RFC3339), d.SerialNum)
+				}
+			case now.Sub(last) > s.rules.HeartbeatTimeout:
+				add(model.IssueNoHeartbeat, fmt.Sprintf("last reported %s ago", now.Sub(last).Round(time.Second)), d.SerialNum)
+			}
+		}
+		return true
+	})
+
+	for addr, serials := range byIP {
+		if len(serials) > 1 {
+			sort.Strings(serials)
+			add(model.IssueDuplicateIP, fmt.Sprintf("%s is used by %d devices", addr, len(serials)), serials...)
+		}
+	}
+
+	sort.Slice(r.Issues, func(i, j int) bool {
+		a, b := r.Issues[i], r.Issues[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.SerialNums[0] < b.SerialNums[0]
+	})
+	return r
+}
+
+func contains(subnets []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range subnets {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
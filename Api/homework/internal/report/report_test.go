@@ -0,0 +1,109 @@
+package report
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/catalog"
+	"homework/internal/model"
+	"homework/internal/service"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type models []catalog.Model
+
+func (m models) ListModels() []catalog.Model {
+	return m
+}
+
+type heartbeats map[string]time.Time
+
+func (h heartbeats) LastReported(num string) (time.Time, bool) {
+	t, ok := h[num]
+	return t, ok
+}
+
+// TrackedSince is the time of the empty serial number.
+func (h heartbeats) TrackedSince() time.Time {
+	return h[""]
+}
+
+func newTestReport(t *testing.T, devices ...model.Device) Service {
+	storage := service.NewStorage()
+	for _, d := range devices {
+		storage.Add(d)
+	}
+	rules, err := ParseRules([]string{"10.0.0.0/16"}, map[string]string{"acme": "AC-[0-9]{4}"}, time.Hour)
+	assert.Nil(t, err)
+	catalog := models{{Name: "switch", Vendor: "acme"}, {Name: "router"}}
+	return NewService(storage, catalog, heartbeats{"": now.Add(-24 * time.Hour), "AC-0001": now.Add(-time.Minute), "AC-0002": now.Add(-2 * time.Hour)}, rules,
+		WithClock(func() time.Time { return now }))
+}
+
+func TestGenerate(t *testing.T) {
+	s := newTestReport(t,
+		model.Device{SerialNum: "AC-0001", Model: "switch", IP: "10.0.0.1", State: model.StateActive},
+		model.Device{SerialNum: "AC-0002", Model: "switch", IP: "10.0.0.1", State: model.StateActive},
+		model.Device{SerialNum: "AC-0003", Model: "switch", IP: "10.0.0.3", State: model.StateActive},
+		model.Device{SerialNum: "AC-12345", Model: "switch", IP: "192.168.0.1"},
+		model.Device{SerialNum: "R-1", Model: "router", IP: "::ffff:10.0.0.3"},
+		model.Device{SerialNum: "X-1", Model: "toaster", IP: "10.0.1.1"},
+	)
+
+	r := s.Generate()
+	assert.Equal(t, now, r.GeneratedAt)
+	assert.Equal(t, 6, r.Devices)
+	assert.Equal(t, []model.Issue{
+		{Kind: model.IssueDuplicateIP, SerialNums: []string{"AC-0001", "AC-0002"}, Message: "10.0.0.1 is used by 2 devices"},
+		{Kind: model.IssueDuplicateIP, SerialNums: []string{"AC-0003", "R-1"}, Message: "10.0.0.3 is used by 2 devices"},
+		{Kind: model.IssueIPOutsideSubnet, SerialNums: []string{"AC-12345"}, Message: "192.168.0.1 is outside every configured subnet"},
+		{Kind: model.IssueNoHeartbeat, SerialNums: []string{"AC-0002"}, Message: "last reported 2h0m0s ago"},
+		{Kind: model.IssueNoHeartbeat, SerialNums: []string{"AC-0003"}, Message: "not reported since 2024-05-31T12:00:00Z"},
+		{Kind: model.IssueSerialFormat, SerialNums: []string{"AC-12345"}, Message: "serial number doesn't match the acme format ^(?:AC-[0-9]{4})$"},
+		{Kind: model.IssueUnknownModel, SerialNums: []string{"X-1"}, Message: `model "toaster" is not in the catalog`},
+	}, r.Issues)
+	assert.Equal(t, map[model.IssueKind]int{
+		model.IssueDuplicateIP:     2,
+		model.IssueIPOutsideSubnet: 1,
+		model.IssueNoHeartbeat:     2,
+		model.IssueSerialFormat:    1,
+		model.IssueUnknownModel:    1,
+	}, r.Summary)
+}
+
+func TestHeartbeatsAfterRestart(t *testing.T) {
+	storage := service.NewStorage()
+	storage.Add(model.Device{SerialNum: "AC-0001", Model: "switch", IP: "10.0.0.1", State: model.StateActive})
+	rules, err := ParseRules(nil, nil, time.Hour)
+	assert.Nil(t, err)
+
+	// devices aren't flagged until they had the timeout to report since tracking started
+	s := NewService(storage, nil, heartbeats{"": now.Add(-time.Minute)}, rules, WithClock(func() time.Time { return now }))
+	assert.Empty(t, s.Generate().Issues)
+}
+
+func TestParseRules(t *testing.T) {
+	_, err := ParseRules([]string{"10.0.0.0/33"}, nil, 0)
+	assert.NotNil(t, err)
+	_, err = ParseRules(nil, map[string]string{"acme": "("}, 0)
+	assert.ErrorContains(t, err, "acme")
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	assert.Nil(t, WriteText(&out, newTestReport(t).Generate()))
+	assert.Equal(t, "Registry report of 2024-06-01T12:00:00Z: 0 devices, 0 issues\n", out.String())
+
+	out.Reset()
+	s := newTestReport(t, model.Device{SerialNum: "X-1", Model: "toaster", IP: "10.0.0.1"})
+	assert.Nil(t, WriteText(&out, s.Generate()))
+	assert.Equal(t, `Registry report of 2024-06-01T12:00:00Z: 1 devices, 1 issues
+
+unknown_model  1
+
+KIND           DEVICES  MESSAGE
+unknown_model  X-1      model "toaster" is not in the catalog
+`, out.String())
+}
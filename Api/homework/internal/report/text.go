@@ -0,0 +1,38 @@
+package report
+
+import (
+	"fmt"
+	"homework/internal/model"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteText writes r for humans: a summary line, the issue counts and a table of issues.
+func WriteText(w io.Writer, r model.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Registry report of %s: %d devices, %d issues\n",
+		r.GeneratedAt.Format(time.RFC3339), r.Devices, len(r.Issues))
+	if len(r.Issues) == 0 {
+		return tw.Flush()
+	}
+
+	kinds := make([]string, 0, len(r.Summary))
+	for kind := range r.Summary {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	_, _ = fmt.Fprintln(tw)
+	for _, kind := range kinds {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\n", kind, r.Summary[model.IssueKind(kind)])
+	}
+
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "KIND\tDEVICES\tMESSAGE")
+	for _, issue := range r.Issues {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", issue.Kind, strings.Join(issue.SerialNums, ","), issue.Message)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,139 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxArgs limits the number of arguments of a command.
+const maxArgs = 1024
+
+// errProtocol is a malformed request, after which the connection is closed.
+var errProtocol = errors.New("Protocol error")
+
+type reader struct {
+	r *bufio.Reader
+	// maxBulk limits the length of an argument and maxCommand the total length of the arguments of a command.
+	maxBulk    int64
+	maxCommand int64
+}
+
+// command reads a command sent as an array of bulk strings, or inline as words on a line.
+func (r *reader) command() ([]string, error) {
+	line, err := r.line()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	args := make([]string, 0, max(n, 0))
+	var total int64
+	for range n {
+		line, err := r.line()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("%w: expected '$', got '%.1s'", errProtocol, line)
+		}
+		size, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil || size < 0 || size > r.maxBulk {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		if total += size; total > r.maxCommand {
+			return nil, fmt.Errorf("%w: too big request", errProtocol)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r.r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// line reads a line of at most maxBulk bytes without its line ending.
+func (r *reader) line() (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if int64(len(line)) > r.maxBulk {
+			return "", fmt.Errorf("%w: too big inline request", errProtocol)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// writer writes replies in RESP2, or RESP3 after HELLO 3.
+type writer struct {
+	w     *bufio.Writer
+	proto int
+}
+
+func (w *writer) simple(s string) {
+	w.line("+", s)
+}
+
+// error writes an error starting with its code, such as ERR or NOAUTH.
+func (w *writer) error(code, message string) {
+	w.line("-", code+" "+message)
+}
+
+func (w *writer) integer(n int) {
+	w.line(":", strconv.Itoa(n))
+}
+
+func (w *writer) bulk(s string) {
+	w.line("$", strconv.Itoa(len(s)))
+	_, _ = w.w.WriteString(s)
+	_, _ = w.w.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	if w.proto == 3 {
+		w.line("_", "")
+		return
+	}
+	w.line("$", "-1")
+}
+
+func (w *writer) array(n int) {
+	w.line("*", strconv.Itoa(n))
+}
+
+// mapHeader starts a map of n pairs, which RESP2 gets as a flat array.
+func (w *writer) mapHeader(n int) {
+	if w.proto == 3 {
+		w.line("%", strconv.Itoa(n))
+		return
+	}
+	w.array(2 * n)
+}
+
+func (w *writer) line(prefix, s string) {
+	// simple strings and errors can't span lines
+	s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	_, _ = w.w.WriteString(prefix)
+	_, _ = w.w.WriteString(s)
+	_, _ = w.w.WriteString("\r\n")
+}
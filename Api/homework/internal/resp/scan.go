@@ -0,0 +1,81 @@
+package resp
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxScans bounds the snapshots kept for SCAN, dropping the least recently used.
+	maxScans = 64
+	scanTTL  = 5 * time.Minute
+)
+
+// scans keeps the sorted serial numbers SCAN pages through. A cursor is the snapshot
+// id in the high 32 bits and the position of the next page in the low 32 bits, so
+// repeating a cursor returns the same page, from any connection.
+type scans struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	lastID    uint32
+	snapshots map[uint32]*snapshot
+}
+
+type snapshot struct {
+	nums []string
+	used time.Time
+}
+
+func newScans(now func() time.Time) *scans {
+	return &scans{now: now, snapshots: make(map[uint32]*snapshot)}
+}
+
+// get returns the snapshot of cursor and the position it points at, or nil if it expired or is invalid.
+func (s *scans) get(cursor uint64) ([]string, int) {
+	id, pos := uint32(cursor>>32), int(uint32(cursor))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, ok := s.snapshots[id]
+	if !ok || pos > len(snap.nums) {
+		return nil, 0
+	}
+	snap.used = s.now()
+	return snap.nums, pos
+}
+
+// next returns the cursor of the page of nums at pos, after cursor, keeping nums if cursor started the scan.
+func (s *scans) next(cursor uint64, nums []string, pos int) uint64 {
+	id := uint32(cursor >> 32)
+	if cursor == 0 {
+		s.mu.Lock()
+		s.expire()
+		s.lastID++
+		if s.lastID == 0 {
+			s.lastID++
+		}
+		id = s.lastID
+		s.snapshots[id] = &snapshot{nums: nums, used: s.now()}
+		s.mu.Unlock()
+	}
+	return uint64(id)<<32 | uint64(pos)
+}
+
+// expire drops the snapshots not used within scanTTL, and the least recently used one
+// if there's no room for another. It must be called with s.mu held.
+func (s *scans) expire() {
+	var oldest uint32
+	for id, snap := range s.snapshots {
+		if s.now().Sub(snap.used) > scanTTL {
+			delete(s.snapshots, id)
+			continue
+		}
+		if oldest == 0 || snap.used.Before(s.snapshots[oldest].used) {
+			oldest = id
+		}
+	}
+	if len(s.snapshots) >= maxScans {
+		delete(s.snapshots, oldest)
+	}
+}
@@ -0,0 +1,659 @@
+// Package resp serves the device registry over a subset of the Redis protocol, RESP2 and RESP3,
+// for tooling that only talks to Redis. Keys are serial numbers and values are device JSON.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"homework/internal/catalog"
+	"homework/internal/freeze"
+	"homework/internal/middleware"
+	"homework/internal/model"
+	"homework/internal/service"
+	"homework/internal/topology"
+	"log/slog"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown.
+var ErrServerClosed = errors.New("resp: server closed")
+
+const (
+	defaultMaxBulkBytes = 1 << 20
+	defaultIdleTimeout  = 5 * time.Minute
+	defaultMaxConns     = 1024
+	// maxUpsertAttempts bounds the retries of SET racing with other writers.
+	maxUpsertAttempts = 3
+)
+
+type Option func(*Server)
+
+// WithTokens requires clients to AUTH with one of tokens, as the HTTP API requires bearer tokens.
+func WithTokens(tokens []string) Option {
+	return func(s *Server) {
+		s.tokens = tokens
+	}
+}
+
+// WithReadOnly rejects writes, for followers replicating from a leader.
+func WithReadOnly() Option {
+	return func(s *Server) {
+		s.readOnly = true
+	}
+}
+
+// WithMaxBulkBytes limits the size of command arguments, 1 MiB by default.
+func WithMaxBulkBytes(n int64) Option {
+	return func(s *Server) {
+		s.maxBulk = n
+	}
+}
+
+// WithMaxCommandBytes limits the total size of the arguments of a command, twice the bulk limit by default.
+func WithMaxCommandBytes(n int64) Option {
+	return func(s *Server) {
+		s.maxCommand = n
+	}
+}
+
+// WithIdleTimeout closes connections that don't complete a command within d, 5 minutes by default.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+// WithMaxConns limits the number of open connections, 1024 by default. Clients over
+// the limit get an error and are disconnected, like Redis clients over maxclients.
+func WithMaxConns(n int) Option {
+	return func(s *Server) {
+		s.maxConns = n
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+type Server struct {
+	devices     service.Service
+	tokens      []string
+	readOnly    bool
+	maxBulk     int64
+	maxCommand  int64
+	idleTimeout time.Duration
+	maxConns    int
+	now         func() time.Time
+	scans       *scans
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+func NewServer(devices service.Service, options ...Option) *Server {
+	s := &Server{
+		devices:     devices,
+		maxBulk:     defaultMaxBulkBytes,
+		idleTimeout: defaultIdleTimeout,
+		maxConns:    defaultMaxConns,
+		now:         time.Now,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.maxCommand == 0 {
+		s.maxCommand = 2 * s.maxBulk
+	}
+	s.scans = newScans(s.now)
+	return s
+}
+
+// Serve accepts connections on ln until Shutdown, and then returns ErrServerClosed.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, ln)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = c.Close()
+			return ErrServerClosed
+		}
+		if len(s.conns) >= s.maxConns {
+			s.mu.Unlock()
+			_ = c.SetWriteDeadline(time.Now().Add(time.Second))
+			_, _ = c.Write([]byte("-ERR max number of clients reached\r\n"))
+			_ = c.Close()
+			continue
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(c)
+		}()
+	}
+}
+
+// Shutdown closes the listeners and connections and waits for commands in progress to finish.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		_ = ln.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// conn is the state of a client connection.
+type conn struct {
+	*Server
+	devices service.Service
+	w       *writer
+	authed  bool
+	quit    bool
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &reader{r: bufio.NewReader(c), maxBulk: s.maxBulk, maxCommand: s.maxCommand}
+	cn := &conn{
+		Server:  s,
+		devices: service.Bind(ctx, s.devices),
+		w:       &writer{w: bufio.NewWriter(c), proto: 2},
+		authed:  len(s.tokens) == 0,
+	}
+	for !cn.quit {
+		if err := c.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			return
+		}
+		args, err := r.command()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				cn.w.error("ERR", err.Error())
+				_ = cn.w.w.Flush()
+			}
+			return
+		}
+		if len(args) > 0 {
+			cn.exec(args)
+		}
+		// replies to pipelined commands go out together
+		if r.r.Buffered() == 0 {
+			if err := cn.w.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	_ = cn.w.w.Flush()
+}
+
+type command struct {
+	// arity is the number of arguments including the command name, or the minimum if negative.
+	arity int
+	write bool
+	run   func(c *conn, args []string)
+}
+
+var commands = map[string]command{
+	"ping":    {-1, false, (*conn).ping},
+	"hello":   {-1, false, (*conn).hello},
+	"auth":    {-2, false, (*conn).auth},
+	"quit":    {1, false, (*conn).quitCmd},
+	"command": {-1, false, (*conn).commandDocs},
+	"get":     {2, false, (*conn).get},
+	"set":     {-3, true, (*conn).set},
+	"del":     {-2, true, (*conn).del},
+	"scan":    {-2, false, (*conn).scan},
+	"hgetall": {2, false, (*conn).hgetall},
+}
+
+// public commands are served before AUTH.
+var public = map[string]bool{"hello": true, "auth": true, "quit": true}
+
+func (c *conn) exec(args []string) {
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.error("ERR", "unknown command '"+args[0]+"'")
+		return
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		c.w.error("ERR", "wrong number of arguments for '"+name+"' command")
+		return
+	}
+	if !c.authed && !public[name] {
+		c.w.error("NOAUTH", "Authentication required.")
+		return
+	}
+	if cmd.write && c.readOnly {
+		c.w.error("READONLY", "You can't write against a read only replica.")
+		return
+	}
+	cmd.run(c, args)
+}
+
+func (c *conn) ping(args []string) {
+	switch len(args) {
+	case 1:
+		c.w.simple("PONG")
+	case 2:
+		c.w.bulk(args[1])
+	default:
+		c.w.error("ERR", "wrong number of arguments for 'ping' command")
+	}
+}
+
+// hello negotiates the protocol version, optionally authenticating: HELLO [protover [AUTH username password]].
+func (c *conn) hello(args []string) {
+	proto := c.w.proto
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 2 || n > 3 {
+			c.w.error("NOPROTO", "unsupported protocol version")
+			return
+		}
+		proto = n
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "auth":
+			if i+2 >= len(args) {
+				c.w.error("ERR", "syntax error")
+				return
+			}
+			if !c.authenticate(args[i+2]) {
+				return
+			}
+			i += 2
+		case "setname":
+			if i+1 >= len(args) {
+				c.w.error("ERR", "syntax error")
+				return
+			}
+			i++
+		default:
+			c.w.error("ERR", "syntax error")
+			return
+		}
+	}
+	if !c.authed {
+		c.w.error("NOAUTH", "HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used")
+		return
+	}
+
+	c.w.proto = proto
+	role := "master"
+	if c.readOnly {
+		role = "replica"
+	}
+	c.w.mapHeader(6)
+	c.w.bulk("server")
+	c.w.bulk("device-registry")
+	c.w.bulk("version")
+	c.w.bulk("1.0.0")
+	c.w.bulk("proto")
+	c.w.integer(proto)
+	c.w.bulk("mode")
+	c.w.bulk("standalone")
+	c.w.bulk("role")
+	c.w.bulk(role)
+	c.w.bulk("modules")
+	c.w.array(0)
+}
+
+// auth checks AUTH [username] password. Usernames are ignored.
+func (c *conn) auth(args []string) {
+	if len(args) > 3 {
+		c.w.error("ERR", "syntax error")
+		return
+	}
+	if len(c.tokens) == 0 {
+		c.w.error("ERR", "AUTH called without any password configured")
+		return
+	}
+	if c.authenticate(args[len(args)-1]) {
+		c.w.simple("OK")
+	}
+}
+
+// authenticate checks password and writes the error if it isn't valid.
+func (c *conn) authenticate(password string) bool {
+	if len(c.tokens) == 0 {
+		return true
+	}
+	c.authed = middleware.ValidToken(c.tokens, password)
+	if !c.authed {
+		c.w.error("WRONGPASS", "invalid username-password pair or user is disabled.")
+	}
+	return c.authed
+}
+
+func (c *conn) quitCmd([]string) {
+	c.w.simple("OK")
+	c.quit = true
+}
+
+// commandDocs answers the COMMAND DOCS clients send on connect with nothing.
+func (c *conn) commandDocs([]string) {
+	c.w.array(0)
+}
+
+func (c *conn) get(args []string) {
+	d, err := c.devices.GetDevice(args[1])
+	if errors.Is(err, service.ErrDeviceDoesNotExist) {
+		c.w.null()
+		return
+	}
+	if err != nil {
+		c.serviceError(err)
+		return
+	}
+	raw, err := json.Marshal(d)
+	if err != nil {
+		c.serviceError(err)
+		return
+	}
+	c.w.bulk(string(raw))
+}
+
+// set creates or updates a device: SET serial json [NX|XX] [EX seconds|PX milliseconds].
+// NX only creates and XX only updates, replying null otherwise. EX and PX set the device expiry.
+func (c *conn) set(args []string) {
+	var d model.Device
+	if err := json.Unmarshal([]byte(args[2]), &d); err != nil {
+		c.w.error("INVALID", "value is not a device: "+err.Error())
+		return
+	}
+	if d.SerialNum == "" {
+		d.SerialNum = args[1]
+	}
+	if d.SerialNum != args[1] {
+		c.w.error("INVALID", "serial number in value doesn't match the key")
+		return
+	}
+
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(args[i]); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				c.w.error("ERR", "syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				c.w.error("ERR", "invalid expire time in 'set' command")
+				return
+			}
+			ttl := time.Duration(n) * time.Second
+			if opt == "px" {
+				ttl = time.Duration(n) * time.Millisecond
+			}
+			at := c.now().Add(ttl).UTC()
+			d.ExpiresAt = &at
+			i++
+		default:
+			c.w.error("ERR", "syntax error")
+			return
+		}
+	}
+	if nx && xx {
+		c.w.error("ERR", "syntax error")
+		return
+	}
+
+	var err error
+	switch {
+	case nx:
+		err = c.devices.CreateDevice(d)
+	case xx:
+		err = c.devices.UpdateDevice(d)
+	default:
+		err = c.upsert(d)
+	}
+	if nx && errors.Is(err, service.ErrDeviceAlreadyExists) || xx && errors.Is(err, service.ErrDeviceDoesNotExist) {
+		c.w.null()
+		return
+	}
+	if err != nil {
+		c.serviceError(err)
+		return
+	}
+	c.w.simple("OK")
+}
+
+// upsert updates d or creates it if it doesn't exist, retrying if another writer
+// creates or deletes it in between.
+func (c *conn) upsert(d model.Device) error {
+	var err error
+	for range maxUpsertAttempts {
+		if err = c.devices.UpdateDevice(d); !errors.Is(err, service.ErrDeviceDoesNotExist) {
+			return err
+		}
+		if err = c.devices.CreateDevice(d); !errors.Is(err, service.ErrDeviceAlreadyExists) {
+			return err
+		}
+	}
+	return err
+}
+
+// del deletes devices and replies with the number deleted. It stops at the first error.
+func (c *conn) del(args []string) {
+	n := 0
+	for _, num := range args[1:] {
+		err := c.devices.DeleteDevice(num)
+		if errors.Is(err, service.ErrDeviceDoesNotExist) {
+			continue
+		}
+		if err != nil {
+			c.serviceError(err)
+			return
+		}
+		n++
+	}
+	c.w.integer(n)
+}
+
+// scan pages through serial numbers in order: SCAN cursor [MATCH pattern] [COUNT count].
+// SCAN 0 takes a sorted snapshot of the serial numbers that later cursors page through,
+// so each page costs O(count) and devices added or deleted meanwhile don't shift pages.
+func (c *conn) scan(args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.w.error("ERR", "invalid cursor")
+		return
+	}
+	pattern, count := "", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.w.error("ERR", "syntax error")
+			return
+		}
+		switch strings.ToLower(args[i]) {
+		case "match":
+			pattern = args[i+1]
+			if _, err := path.Match(pattern, ""); err != nil {
+				c.w.error("ERR", "invalid pattern")
+				return
+			}
+		case "count":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				c.w.error("ERR", "value is not an integer or out of range")
+				return
+			}
+		case "type":
+			// every key holds a device
+		default:
+			c.w.error("ERR", "syntax error")
+			return
+		}
+	}
+
+	var nums []string
+	var pos int
+	if cursor == 0 {
+		devices, err := c.devices.ListDevices(service.Filter{})
+		if err != nil {
+			c.serviceError(err)
+			return
+		}
+		nums = make([]string, 0, len(devices))
+		for _, d := range devices {
+			nums = append(nums, d.SerialNum)
+		}
+		sort.Strings(nums)
+	} else if nums, pos = c.scans.get(cursor); nums == nil {
+		c.w.error("ERR", "invalid cursor")
+		return
+	}
+
+	// like Redis, COUNT bounds the keys looked at, so a page may match fewer
+	end := min(pos+count, len(nums))
+	var page []string
+	for _, num := range nums[pos:end] {
+		if ok, _ := path.Match(pattern, num); pattern == "" || ok {
+			page = append(page, num)
+		}
+	}
+	var next uint64
+	if end < len(nums) {
+		next = c.scans.next(cursor, nums, end)
+	}
+
+	c.w.array(2)
+	c.w.bulk(strconv.FormatUint(next, 10))
+	c.w.array(len(page))
+	for _, num := range page {
+		c.w.bulk(num)
+	}
+}
+
+// hgetall replies with the top-level device fields, encoding values that aren't strings as JSON.
+func (c *conn) hgetall(args []string) {
+	d, err := c.devices.GetDevice(args[1])
+	if errors.Is(err, service.ErrDeviceDoesNotExist) {
+		c.w.mapHeader(0)
+		return
+	}
+	if err != nil {
+		c.serviceError(err)
+		return
+	}
+
+	raw, _ := json.Marshal(d)
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		c.serviceError(err)
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.w.mapHeader(len(names))
+	for _, name := range names {
+		c.w.bulk(name)
+		var s string
+		if json.Unmarshal(fields[name], &s) == nil {
+			c.w.bulk(s)
+		} else {
+			c.w.bulk(string(fields[name]))
+		}
+	}
+}
+
+// serviceError writes err with a code clients can match on, e.g. EXISTS for ErrDeviceAlreadyExists.
+func (c *conn) serviceError(err error) {
+	var validationErr *catalog.ValidationError
+	switch {
+	case errors.Is(err, service.ErrDeviceAlreadyExists):
+		c.w.error("EXISTS", err.Error())
+	case errors.Is(err, service.ErrDeviceDoesNotExist):
+		c.w.error("NOTFOUND", err.Error())
+	case errors.As(err, &validationErr),
+		errors.Is(err, service.ErrInvalidModel),
+		errors.Is(err, service.ErrInvalidSerialNumber),
+		errors.Is(err, service.ErrInvalidIPAddress),
+		errors.Is(err, service.ErrInvalidState),
+		errors.Is(err, service.ErrInvalidAttributes),
+		errors.Is(err, catalog.ErrModelDoesNotExist),
+		errors.Is(err, catalog.ErrSchemaDoesNotExist):
+		c.w.error("INVALID", err.Error())
+	case errors.Is(err, freeze.ErrFrozen):
+		c.w.error("FROZEN", err.Error())
+	case errors.Is(err, topology.ErrDeviceInUse):
+		c.w.error("INUSE", err.Error())
+	case errors.Is(err, service.ErrDeviceDecommissioned):
+		c.w.error("DECOMMISSIONED", err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		c.w.error("TIMEOUT", err.Error())
+	default:
+		slog.Warn("resp command failed", slog.String("error", err.Error()))
+		c.w.error("ERR", err.Error())
+	}
+}
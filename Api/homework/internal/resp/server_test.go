@@ -0,0 +1,272 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"homework/internal/service"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is a minimal RESP client.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// respError is an error reply.
+type respError string
+
+func (e respError) Error() string { return string(e) }
+
+func dial(t *testing.T, s *Server) *client {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() { _ = s.Serve(ln) }()
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, _ = c.conn.Write([]byte(b.String()))
+}
+
+func (c *client) do(args ...string) any {
+	c.send(args...)
+	return c.read()
+}
+
+// read returns a reply as a string, respError, int, nil, []any or map[string]any.
+func (c *client) read() any {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return respError(line[1:])
+	case ':':
+		n, _ := strconv.Atoi(line[1:])
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		_, _ = c.r.Read(buf)
+		return string(buf[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		items := make([]any, n)
+		for i := range items {
+			items[i] = c.read()
+		}
+		return items
+	case '%':
+		n, _ := strconv.Atoi(line[1:])
+		m := make(map[string]any, n)
+		for range n {
+			k := c.read()
+			m[k.(string)] = c.read()
+		}
+		return m
+	}
+	return errors.New("unknown reply " + line)
+}
+
+func newService() service.Service {
+	return service.NewService(service.NewStorage(), service.WithClock(func() time.Time {
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}))
+}
+
+func device(num string) string {
+	raw, _ := json.Marshal(model.Device{SerialNum: num, Model: "switch", IP: "10.0.0.1"})
+	return string(raw)
+}
+
+func TestCommands(t *testing.T) {
+	c := dial(t, NewServer(newService()))
+
+	assert.Equal(t, "PONG", c.do("PING"))
+	assert.Nil(t, c.do("GET", "a1"))
+	assert.Equal(t, "OK", c.do("SET", "a1", device("")))
+	assert.Equal(t, "OK", c.do("set", "a2", device("a2"), "NX"))
+	assert.Nil(t, c.do("SET", "a2", device("a2"), "NX"))
+	assert.Nil(t, c.do("SET", "a3", device("a3"), "XX"))
+
+	var d model.Device
+	assert.Nil(t, json.Unmarshal([]byte(c.do("GET", "a1").(string)), &d))
+	assert.Equal(t, "a1", d.SerialNum)
+
+	// updates
+	assert.Equal(t, "OK", c.do("SET", "a1", `{"model":"router","ip":"10.0.0.2"}`))
+	assert.Equal(t, []any{"created_at", "2024-01-01T00:00:00Z", "ip", "10.0.0.2", "model", "router", "serial_number", "a1", "state", "ordered"}, c.do("HGETALL", "a1"))
+	assert.Equal(t, []any{}, c.do("HGETALL", "b"))
+
+	for i := range 5 {
+		assert.Equal(t, "OK", c.do("SET", fmt.Sprintf("b%d", i), device("")))
+	}
+	page := c.do("SCAN", "0", "COUNT", "3").([]any)
+	assert.Equal(t, []any{"a1", "a2", "b0"}, page[1])
+	// later pages come from the snapshot, so deleting a scanned device doesn't shift them
+	assert.Equal(t, 1, c.do("DEL", "b0"))
+	page = c.do("SCAN", page[0].(string), "COUNT", "3", "MATCH", "b*").([]any)
+	assert.Equal(t, []any{"b1", "b2", "b3"}, page[1])
+	assert.Equal(t, []any{"0", []any{"b4"}}, c.do("SCAN", page[0].(string), "MATCH", "b*"))
+	assert.Equal(t, []any{"0", []any{"b4"}}, c.do("SCAN", page[0].(string), "MATCH", "b*"))
+	assert.Equal(t, respError("ERR invalid cursor"), c.do("SCAN", "12345"))
+
+	assert.Equal(t, 2, c.do("DEL", "a1", "a2", "a3"))
+	assert.Nil(t, c.do("GET", "a1"))
+
+	// errors map to codes
+	assert.Equal(t, respError("INVALID serial number in value doesn't match the key"), c.do("SET", "a1", device("a2")))
+	assert.ErrorContains(t, c.do("SET", "a1", `{"model":"switch","ip":"nope"}`).(error), "INVALID invalid IP address")
+	assert.ErrorContains(t, c.do("SET", "a1", "{").(error), "INVALID value is not a device")
+	assert.Equal(t, respError("ERR syntax error"), c.do("SET", "a1", device(""), "KEEPTTL"))
+	assert.Equal(t, respError("ERR wrong number of arguments for 'get' command"), c.do("GET"))
+	assert.Equal(t, respError("ERR unknown command 'FLUSHALL'"), c.do("FLUSHALL"))
+
+	// inline commands and pipelining
+	_, _ = c.conn.Write([]byte("PING\r\nPING hello\r\n"))
+	assert.Equal(t, "PONG", c.read())
+	assert.Equal(t, "hello", c.read())
+
+	assert.Equal(t, "OK", c.do("QUIT"))
+	assert.Equal(t, "EOF", fmt.Sprint(c.read()))
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := service.NewService(service.NewStorage())
+	c := dial(t, NewServer(svc, WithClock(func() time.Time { return now })))
+
+	assert.Equal(t, "OK", c.do("SET", "a1", device(""), "EX", "60"))
+	d, err := svc.GetDevice("a1")
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(time.Minute), *d.ExpiresAt)
+	assert.Equal(t, respError("ERR invalid expire time in 'set' command"), c.do("SET", "a1", device(""), "PX", "0"))
+}
+
+func TestRESP3(t *testing.T) {
+	c := dial(t, NewServer(newService()))
+
+	hello := c.do("HELLO", "3").(map[string]any)
+	assert.Equal(t, 3, hello["proto"])
+	assert.Equal(t, "master", hello["role"])
+
+	assert.Nil(t, c.do("GET", "a1"))
+	assert.Equal(t, "OK", c.do("SET", "a1", device("")))
+	assert.Equal(t, map[string]any{"created_at": "2024-01-01T00:00:00Z", "ip": "10.0.0.1", "model": "switch", "serial_number": "a1", "state": "ordered"}, c.do("HGETALL", "a1"))
+	assert.Equal(t, respError("NOPROTO unsupported protocol version"), c.do("HELLO", "4"))
+}
+
+func TestAuth(t *testing.T) {
+	c := dial(t, NewServer(newService(), WithTokens([]string{"secret"}), WithReadOnly()))
+
+	assert.Equal(t, respError("NOAUTH Authentication required."), c.do("GET", "a1"))
+	assert.ErrorContains(t, c.do("HELLO", "3").(error), "NOAUTH")
+	assert.Equal(t, respError("WRONGPASS invalid username-password pair or user is disabled."), c.do("AUTH", "guess"))
+	assert.Equal(t, "replica", c.do("HELLO", "3", "AUTH", "default", "secret").(map[string]any)["role"])
+	assert.Nil(t, c.do("GET", "a1"))
+
+	assert.Equal(t, respError("READONLY You can't write against a read only replica."), c.do("SET", "a1", device("")))
+	assert.Equal(t, respError("READONLY You can't write against a read only replica."), c.do("DEL", "a1"))
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := dial(t, NewServer(newService(), WithMaxBulkBytes(16)))
+
+	_, _ = c.conn.Write([]byte("*1\r\n$17\r\n"))
+	assert.Equal(t, respError("ERR Protocol error: invalid bulk length"), c.read())
+	assert.Equal(t, "EOF", fmt.Sprint(c.read()))
+}
+
+func TestLimits(t *testing.T) {
+	s := NewServer(newService(), WithMaxBulkBytes(16), WithMaxCommandBytes(24), WithIdleTimeout(50*time.Millisecond), WithMaxConns(1))
+	c := dial(t, s)
+	assert.Equal(t, "PONG", c.do("PING"))
+
+	other, err := net.Dial("tcp", c.conn.RemoteAddr().String())
+	assert.Nil(t, err)
+	defer other.Close()
+	oc := &client{conn: other, r: bufio.NewReader(other)}
+	assert.Equal(t, respError("ERR max number of clients reached"), oc.read())
+
+	assert.Equal(t, respError("ERR Protocol error: too big request"), c.do("SET", "0123456789", "0123456789abcdef"))
+	assert.Equal(t, "EOF", fmt.Sprint(c.read()))
+
+	// the closed connection made room for another, which is closed once idle
+	idle := dial(t, s)
+	assert.Equal(t, "PONG", idle.do("PING"))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "EOF", fmt.Sprint(idle.read()))
+}
+
+// racingService creates a device concurrently with the first CreateDevice call.
+type racingService struct {
+	service.Service
+	raced bool
+}
+
+func (s *racingService) CreateDevice(d model.Device) error {
+	if !s.raced {
+		s.raced = true
+		_ = s.Service.CreateDevice(d)
+		return service.ErrDeviceAlreadyExists
+	}
+	return s.Service.CreateDevice(d)
+}
+
+func TestSetRetriesRace(t *testing.T) {
+	svc := &racingService{Service: newService()}
+	c := dial(t, NewServer(svc))
+
+	assert.Equal(t, "OK", c.do("SET", "a1", `{"model":"router","ip":"10.0.0.2"}`))
+	d, err := svc.GetDevice("a1")
+	assert.Nil(t, err)
+	assert.Equal(t, "router", d.Model)
+	assert.True(t, svc.raced)
+}
+
+func TestShutdown(t *testing.T) {
+	s := NewServer(newService())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ln) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+	assert.Equal(t, "PONG", c.do("PING"))
+
+	assert.Nil(t, s.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-served, ErrServerClosed)
+	assert.Equal(t, "EOF", fmt.Sprint(c.read()))
+}
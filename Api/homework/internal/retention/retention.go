@@ -0,0 +1,303 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const EventExpired = "retention.expired"
+
+var (
+	ErrPolicyDoesNotExist = errors.New("retention policy doesn't exist")
+	ErrInvalidPolicy      = errors.New("invalid retention policy")
+	ErrInvalidTTL         = errors.New("invalid ttl")
+	ErrNotInTrash         = errors.New("device is not in the trash")
+)
+
+type Service interface {
+	CreatePolicy(p model.RetentionPolicy) (model.RetentionPolicy, error)
+	ListPolicies() []model.RetentionPolicy
+	DeletePolicy(id string) error
+	// Preview returns the devices that expire before until, soonest first, without touching them.
+	Preview(until time.Time) ([]model.Expiry, error)
+	// Reap deletes or trashes the devices expired at now and publishes an event for each.
+	// Devices that can't be deleted are skipped and their errors joined.
+	Reap(now time.Time) ([]model.Expiry, error)
+	ListTrash() []model.TrashedDevice
+	// RestoreDevice recreates a trashed device. It starts a new retention period
+	// and loses its own expiry, or it would expire again right away.
+	RestoreDevice(num string) error
+	PurgeDevice(num string) error
+}
+
+// NewService creates a retention service deleting devices through devices, so that
+// decorators such as the topology guard apply. action is used by device expiries
+// and policies without an action.
+func NewService(devices service.Service, bus *events.Bus, action model.RetentionAction) Service {
+	if action == "" {
+		action = model.RetentionDelete
+	}
+	return &retentionService{
+		devices:  devices,
+		bus:      bus,
+		action:   action,
+		policies: make(map[string]policy),
+		trash:    make(map[string]model.TrashedDevice),
+	}
+}
+
+// Run reaps expired devices every interval until ctx is done.
+func Run(ctx context.Context, s Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reaped, err := s.Reap(now)
+			if len(reaped) > 0 {
+				slog.Info("reaped expired devices", slog.Int("count", len(reaped)))
+			}
+			if err != nil {
+				slog.Warn("reaping expired devices", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// ParseTTL parses a positive duration such as 72h, or a number of days such as 30d.
+func ParseTTL(s string) (time.Duration, error) {
+	var ttl time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidTTL, s)
+		}
+		ttl = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if ttl, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidTTL, s)
+		}
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidTTL, s)
+	}
+	return ttl, nil
+}
+
+type policy struct {
+	model.RetentionPolicy
+	ttl time.Duration
+}
+
+type retentionService struct {
+	devices service.Service
+	bus     *events.Bus
+	action  model.RetentionAction
+
+	mu       sync.Mutex
+	policies map[string]policy
+	trash    map[string]model.TrashedDevice
+	lastID   int
+}
+
+func (s *retentionService) CreatePolicy(p model.RetentionPolicy) (model.RetentionPolicy, error) {
+	ttl, err := ParseTTL(p.TTL)
+	if err != nil {
+		return model.RetentionPolicy{}, err
+	}
+	if p.Action != "" && p.Action != model.RetentionDelete && p.Action != model.RetentionTrash {
+		return model.RetentionPolicy{}, fmt.Errorf("%w: unknown action %q", ErrInvalidPolicy, p.Action)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	p.ID = strconv.Itoa(s.lastID)
+	s.policies[p.ID] = policy{RetentionPolicy: p, ttl: ttl}
+	return p, nil
+}
+
+func (s *retentionService) ListPolicies() []model.RetentionPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies := make([]model.RetentionPolicy, 0, len(s.policies))
+	for _, p := range s.sortedPolicies() {
+		policies = append(policies, p.RetentionPolicy)
+	}
+	return policies
+}
+
+func (s *retentionService) DeletePolicy(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[id]; !ok {
+		return ErrPolicyDoesNotExist
+	}
+	delete(s.policies, id)
+	return nil
+}
+
+func (s *retentionService) Preview(until time.Time) ([]model.Expiry, error) {
+	devices, err := s.devices.ListDevices(service.Filter{})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	policies := s.sortedPolicies()
+	s.mu.Unlock()
+
+	expiries := make([]model.Expiry, 0)
+	for _, d := range devices {
+		if e, ok := s.expiry(d, policies); ok && !e.ExpiresAt.After(until) {
+			expiries = append(expiries, e)
+		}
+	}
+	sort.SliceStable(expiries, func(i, j int) bool { return expiries[i].ExpiresAt.Before(expiries[j].ExpiresAt) })
+	return expiries, nil
+}
+
+func (s *retentionService) Reap(now time.Time) ([]model.Expiry, error) {
+	expired, err := s.Preview(now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reaped []model.Expiry
+	var errs []error
+	policies := s.sortedPolicies()
+	for _, e := range expired {
+		// the device may have changed since it was listed, e.g. got a later expiry
+		d, err := s.devices.GetDevice(e.Device.SerialNum)
+		if err != nil {
+			continue
+		}
+		if e, ok := s.expiry(d, policies); !ok || e.ExpiresAt.After(now) {
+			continue
+		}
+
+		if err := s.devices.DeleteDevice(d.SerialNum); err != nil {
+			if !errors.Is(err, service.ErrDeviceDoesNotExist) {
+				errs = append(errs, fmt.Errorf("%s: %w", d.SerialNum, err))
+			}
+			continue
+		}
+		e.Device = d
+		if e.Action == model.RetentionTrash {
+			s.trash[d.SerialNum] = model.TrashedDevice{Device: d, TrashedAt: now.UTC(), Policy: e.Policy}
+		}
+		reaped = append(reaped, e)
+
+		if s.bus != nil {
+			s.bus.Publish(events.Event{Type: EventExpired, Serial: d.SerialNum, Data: e})
+		}
+	}
+	return reaped, errors.Join(errs...)
+}
+
+func (s *retentionService) ListTrash() []model.TrashedDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trash := make([]model.TrashedDevice, 0, len(s.trash))
+	for _, t := range s.trash {
+		trash = append(trash, t)
+	}
+	sort.Slice(trash, func(i, j int) bool { return trash[i].Device.SerialNum < trash[j].Device.SerialNum })
+	return trash
+}
+
+func (s *retentionService) RestoreDevice(num string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trash[num]
+	if !ok {
+		return ErrNotInTrash
+	}
+	if _, err := s.devices.GetDevice(num); err == nil {
+		return service.ErrDeviceAlreadyExists
+	}
+
+	d := t.Device
+	d.CreatedAt = nil
+	d.ExpiresAt = nil
+	if err := s.devices.RestoreDevice(d); err != nil {
+		return err
+	}
+	delete(s.trash, num)
+	return nil
+}
+
+func (s *retentionService) PurgeDevice(num string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.trash[num]; !ok {
+		return ErrNotInTrash
+	}
+	delete(s.trash, num)
+	return nil
+}
+
+// expiry returns the device's own expiry, or else the earliest one of the matching policies.
+// Policy TTLs count from creation, so devices without CreatedAt don't expire by policy.
+func (s *retentionService) expiry(d model.Device, policies []policy) (model.Expiry, bool) {
+	if d.ExpiresAt != nil {
+		return model.Expiry{Device: d, ExpiresAt: *d.ExpiresAt, Action: s.action}, true
+	}
+	if d.CreatedAt == nil {
+		return model.Expiry{}, false
+	}
+
+	var best model.Expiry
+	found := false
+	for _, p := range policies {
+		if p.Model != "" && p.Model != d.Model || !service.MatchLabels(d.Labels, p.Labels) {
+			continue
+		}
+		at := d.CreatedAt.Add(p.ttl)
+		if !found || at.Before(best.ExpiresAt) {
+			action := p.Action
+			if action == "" {
+				action = s.action
+			}
+			best = model.Expiry{Device: d, ExpiresAt: at, Action: action, Policy: p.ID}
+			found = true
+		}
+	}
+	return best, found
+}
+
+// sortedPolicies returns the policies by ID, so ties go to the oldest. s.mu must be held.
+func (s *retentionService) sortedPolicies() []policy {
+	policies := make([]policy, 0, len(s.policies))
+	for _, p := range s.policies {
+		policies = append(policies, p)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		a, _ := strconv.Atoi(policies[i].ID)
+		b, _ := strconv.Atoi(policies[j].ID)
+		return a < b
+	})
+	return policies
+}
@@ -0,0 +1,128 @@
+package retention
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"testing"
+	"time"
+)
+
+var created = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T, action model.RetentionAction) (Service, service.Service, *events.Bus) {
+	devices := service.NewService(service.NewStorage(), service.WithClock(func() time.Time { return created }))
+	bus := events.NewBus()
+	return NewService(devices, bus, action), devices, bus
+}
+
+func TestParseTTL(t *testing.T) {
+	ttl, err := ParseTTL("30d")
+	assert.Nil(t, err)
+	assert.Equal(t, 30*24*time.Hour, ttl)
+
+	ttl, err = ParseTTL("90m")
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Minute, ttl)
+
+	for _, s := range []string{"", "d", "-1d", "0s", "soon"} {
+		_, err := ParseTTL(s)
+		assert.ErrorIs(t, err, ErrInvalidTTL, s)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	s, devices, _ := newTestService(t, model.RetentionDelete)
+	expires := created.Add(time.Hour)
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "lab-1", Model: "lab", IP: "1.1.1.1", Labels: map[string]string{"env": "lab"}}))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "lab-2", Model: "lab", IP: "1.1.1.2", ExpiresAt: &expires}))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "prod-1", Model: "core", IP: "1.1.1.3"}))
+
+	_, err := s.CreatePolicy(model.RetentionPolicy{Model: "lab", TTL: "30d"})
+	assert.Nil(t, err)
+	_, err = s.CreatePolicy(model.RetentionPolicy{Labels: map[string]string{"env": "lab"}, TTL: "7d", Action: model.RetentionTrash})
+	assert.Nil(t, err)
+	_, err = s.CreatePolicy(model.RetentionPolicy{TTL: "1d", Action: "archive"})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+
+	expiries, err := s.Preview(created.AddDate(0, 0, 60))
+	assert.Nil(t, err)
+	// the device's own expiry wins over policies, the earliest matching policy over later ones
+	assert.Equal(t, []model.Expiry{
+		{Device: expiries[0].Device, ExpiresAt: expires, Action: model.RetentionDelete},
+		{Device: expiries[1].Device, ExpiresAt: created.AddDate(0, 0, 7), Action: model.RetentionTrash, Policy: "2"},
+	}, expiries)
+	assert.Equal(t, "lab-2", expiries[0].Device.SerialNum)
+	assert.Equal(t, "lab-1", expiries[1].Device.SerialNum)
+
+	expiries, err = s.Preview(created.AddDate(0, 0, 1))
+	assert.Nil(t, err)
+	assert.Len(t, expiries, 1)
+
+	assert.Nil(t, s.DeletePolicy("2"))
+	assert.ErrorIs(t, s.DeletePolicy("2"), ErrPolicyDoesNotExist)
+	assert.Len(t, s.ListPolicies(), 1)
+}
+
+func TestReap(t *testing.T) {
+	s, devices, bus := newTestService(t, model.RetentionTrash)
+	ch, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	expires := created.Add(time.Hour)
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "lab-1", Model: "lab", IP: "1.1.1.1", ExpiresAt: &expires}))
+	assert.Nil(t, devices.TransitionDevice("lab-1", model.StateReceived, "arrived"))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "lab-2", Model: "lab", IP: "1.1.1.2"}))
+
+	reaped, err := s.Reap(created)
+	assert.Nil(t, err)
+	assert.Empty(t, reaped)
+
+	reaped, err = s.Reap(expires)
+	assert.Nil(t, err)
+	if assert.Len(t, reaped, 1) {
+		assert.Equal(t, "lab-1", reaped[0].Device.SerialNum)
+	}
+	e := <-ch
+	assert.Equal(t, EventExpired, e.Type)
+	assert.Equal(t, "lab-1", e.Serial)
+
+	_, err = devices.GetDevice("lab-1")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+	if trash := s.ListTrash(); assert.Len(t, trash, 1) {
+		assert.Equal(t, expires, trash[0].TrashedAt)
+	}
+
+	// restored devices start over without their expiry, keeping their state
+	assert.Nil(t, s.RestoreDevice("lab-1"))
+	d, err := devices.GetDevice("lab-1")
+	assert.Nil(t, err)
+	assert.Nil(t, d.ExpiresAt)
+	assert.Equal(t, model.StateReceived, d.State)
+	assert.ErrorIs(t, s.RestoreDevice("lab-1"), ErrNotInTrash)
+	assert.ErrorIs(t, s.PurgeDevice("lab-1"), ErrNotInTrash)
+}
+
+type blockingService struct {
+	service.Service
+}
+
+var errBlocked = errors.New("blocked")
+
+func (s blockingService) DeleteDevice(string) error {
+	return errBlocked
+}
+
+func TestReapSkipsUndeletable(t *testing.T) {
+	devices := service.NewService(service.NewStorage())
+	expires := created
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "lab-1", Model: "lab", IP: "1.1.1.1", ExpiresAt: &expires}))
+	s := NewService(blockingService{devices}, nil, "")
+
+	reaped, err := s.Reap(created)
+	assert.Empty(t, reaped)
+	assert.ErrorIs(t, err, errBlocked)
+	assert.Empty(t, s.ListTrash())
+}
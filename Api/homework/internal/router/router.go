@@ -3,12 +3,26 @@ package router
 import (
 	"homework/internal/handler"
 	"net/http"
+	"strings"
 )
 
-func NewRouter(h *handler.Handler) *http.ServeMux {
+type Option func(mux *http.ServeMux)
+
+// NewRouter serves the device API under /v1 and the legacy query-parameter routes,
+// which answer with a Deprecation header.
+func NewRouter(h *handler.Handler, options ...Option) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+	// GET patterns also serve HEAD; other methods get 405 with an Allow header
+	mux.HandleFunc("GET /v1/devices", h.HandleList)
+	mux.HandleFunc("POST /v1/devices", h.HandleCreate)
+	mux.HandleFunc("GET /v1/devices/{serial}", h.HandleGet)
+	mux.HandleFunc("PUT /v1/devices/{serial}", h.HandleUpdate)
+	mux.HandleFunc("DELETE /v1/devices/{serial}", h.HandleDelete)
+	mux.HandleFunc("POST /v1/devices/{serial}/transition", h.HandleTransition)
+	mux.HandleFunc("OPTIONS /v1/", allowed(mux))
+
+	mux.HandleFunc("/device", deprecated("/v1/devices/{serial}", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
 			h.HandleCreate(w, r)
@@ -19,9 +33,212 @@ func NewRouter(h *handler.Handler) *http.ServeMux {
 		case http.MethodDelete:
 			h.HandleDelete(w, r)
 		default:
-			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			notAllowed(w, http.MethodPost, http.MethodGet, http.MethodPut, http.MethodDelete)
 		}
-	})
+	}))
+	mux.HandleFunc("/device/transition", deprecated("/v1/devices/{serial}/transition", method(http.MethodPost, h.HandleTransition)))
+	mux.HandleFunc("/devices", deprecated("/v1/devices", method(http.MethodGet, h.HandleList)))
+
+	for _, option := range options {
+		option(mux)
+	}
 
 	return mux
 }
+
+func WithShadow(sh *handler.ShadowHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/device/shadow", method(http.MethodGet, sh.HandleGetShadow))
+		mux.HandleFunc("/device/shadow/desired", method(http.MethodPut, sh.HandleSetDesired))
+		mux.HandleFunc("/device/shadow/reported", method(http.MethodPut, sh.HandleSetReported))
+		mux.HandleFunc("/device/shadow/delta", method(http.MethodGet, sh.HandleGetDelta))
+		mux.HandleFunc("/device/shadow/delta/ack", method(http.MethodPost, sh.HandleAckDelta))
+	}
+}
+
+func WithFirmware(fh *handler.FirmwareHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/firmware/campaigns", func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost:
+				fh.HandleCreateCampaign(w, r)
+			case http.MethodGet:
+				fh.HandleListCampaigns(w, r)
+			default:
+				notAllowed(w, http.MethodPost, http.MethodGet)
+			}
+		})
+		mux.HandleFunc("/firmware/campaign", method(http.MethodGet, fh.HandleGetCampaign))
+		mux.HandleFunc("/firmware/campaign/pause", method(http.MethodPost, fh.HandlePauseCampaign))
+		mux.HandleFunc("/firmware/campaign/resume", method(http.MethodPost, fh.HandleResumeCampaign))
+		mux.HandleFunc("/firmware/campaign/abort", method(http.MethodPost, fh.HandleAbortCampaign))
+		mux.HandleFunc("/firmware/job", method(http.MethodGet, fh.HandleGetJob))
+		mux.HandleFunc("/firmware/report", method(http.MethodPost, fh.HandleReportProgress))
+	}
+}
+
+func WithCatalog(ch *handler.CatalogHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/catalog/models", func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost:
+				ch.HandleRegisterModel(w, r)
+			case http.MethodGet:
+				ch.HandleListModels(w, r)
+			default:
+				notAllowed(w, http.MethodPost, http.MethodGet)
+			}
+		})
+		mux.HandleFunc("/catalog/schema", func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost:
+				ch.HandleRegisterSchema(w, r)
+			case http.MethodGet:
+				ch.HandleGetSchema(w, r)
+			default:
+				notAllowed(w, http.MethodPost, http.MethodGet)
+			}
+		})
+		mux.HandleFunc("/catalog/schema/check", method(http.MethodPost, ch.HandleCheckMigration))
+	}
+}
+
+func WithTopology(th *handler.TopologyHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /v1/links", th.HandleListLinks)
+		mux.HandleFunc("POST /v1/links", th.HandleCreateLink)
+		mux.HandleFunc("DELETE /v1/links/{id}", th.HandleDeleteLink)
+		mux.HandleFunc("PUT /v1/devices/{serial}/parent", th.HandleSetParent)
+		mux.HandleFunc("DELETE /v1/devices/{serial}/parent", th.HandleRemoveParent)
+		mux.HandleFunc("GET /v1/devices/{serial}/neighbors", th.HandleNeighbors)
+		mux.HandleFunc("GET /v1/devices/{serial}/subtree", th.HandleSubtree)
+		mux.HandleFunc("GET /v1/topology/path", th.HandlePath)
+		mux.HandleFunc("GET /v1/topology/dot", th.HandleDOT)
+	}
+}
+
+func WithSearch(sh *handler.SearchHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /v1/search", sh.HandleSearch)
+	}
+}
+
+func WithRetention(rh *handler.RetentionHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /v1/retention/policies", rh.HandleListPolicies)
+		mux.HandleFunc("POST /v1/retention/policies", rh.HandleCreatePolicy)
+		mux.HandleFunc("DELETE /v1/retention/policies/{id}", rh.HandleDeletePolicy)
+		mux.HandleFunc("GET /v1/retention/preview", rh.HandlePreview)
+		mux.HandleFunc("GET /v1/trash", rh.HandleListTrash)
+		mux.HandleFunc("POST /v1/trash/{serial}/restore", rh.HandleRestore)
+		mux.HandleFunc("DELETE /v1/trash/{serial}", rh.HandlePurge)
+	}
+}
+
+func WithFreeze(fh *handler.FreezeHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /v1/windows", fh.HandleSchedule)
+		mux.HandleFunc("POST /v1/windows", fh.HandleCreateWindow)
+		mux.HandleFunc("DELETE /v1/windows/{id}", fh.HandleDeleteWindow)
+		mux.HandleFunc("GET /v1/windows/overrides", fh.HandleOverrides)
+	}
+}
+
+func WithReport(rh *handler.ReportHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /v1/report", rh.HandleReport)
+	}
+}
+
+func WithBackup(bh *handler.BackupHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /v1/admin/snapshot", bh.HandleSnapshot)
+		mux.HandleFunc("GET /v1/admin/changes", bh.HandleChanges)
+		mux.HandleFunc("POST /v1/admin/restore", bh.HandleRestore)
+	}
+}
+
+func WithExport(eh *handler.ExportHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/export/prometheus", method(http.MethodGet, eh.HandlePrometheus))
+		mux.HandleFunc("/export/ansible", method(http.MethodGet, eh.HandleAnsible))
+		mux.HandleFunc("/export/ssh-config", method(http.MethodGet, eh.HandleSSHConfig))
+	}
+}
+
+func WithReplication(rh *handler.ReplicationHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/replication/status", method(http.MethodGet, rh.HandleStatus))
+	}
+}
+
+func WithHealth(hh *handler.HealthHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/healthz", method(http.MethodGet, hh.HandleLiveness))
+		mux.HandleFunc("/readyz", method(http.MethodGet, hh.HandleReadiness))
+	}
+}
+
+func WithMetrics(metrics http.Handler) Option {
+	return func(mux *http.ServeMux) {
+		mux.Handle("/metrics", method(http.MethodGet, metrics.ServeHTTP))
+	}
+}
+
+func WithEvents(eh *handler.EventsHandler) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc("/events", method(http.MethodGet, eh.HandleStream))
+	}
+}
+
+// method rejects requests with any method other than m.
+func method(m string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			notAllowed(w, m)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// notAllowed answers 405, listing the methods the route accepts.
+func notAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+}
+
+// deprecated marks responses of a legacy route and links its successor.
+func deprecated(successor string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		f(w, r)
+	}
+}
+
+var methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// allowed answers OPTIONS with the methods mux serves for the path.
+func allowed(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allow []string
+		for _, m := range methods {
+			probe := r.Clone(r.Context())
+			probe.Method = m
+			if _, pattern := mux.Handler(probe); pattern != "" && pattern != "OPTIONS /v1/" {
+				allow = append(allow, m)
+			}
+		}
+		if len(allow) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
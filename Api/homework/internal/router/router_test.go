@@ -0,0 +1,256 @@
+package router
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/freeze"
+	"homework/internal/handler"
+	"homework/internal/model"
+	"homework/internal/report"
+	"homework/internal/retention"
+	"homework/internal/search"
+	"homework/internal/service"
+	"homework/internal/topology"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(mux *http.ServeMux, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestV1Devices(t *testing.T) {
+	mux := NewRouter(handler.NewHandler(service.NewService(service.NewStorage())))
+
+	w := serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"a/1","model":"switch","ip":"1.1.1.1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v1/devices/a%2F1", w.Header().Get("Location"))
+
+	w = serve(mux, http.MethodGet, "/v1/devices/a%2F1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var d model.Device
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &d))
+	assert.Equal(t, "a/1", d.SerialNum)
+
+	w = serve(mux, http.MethodPut, "/v1/devices/a%2F1", `{"model":"switch","ip":"2.2.2.2"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(mux, http.MethodPut, "/v1/devices/a%2F1", `{"serial_number":"b","model":"switch","ip":"2.2.2.2"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(mux, http.MethodPost, "/v1/devices/a%2F1/transition", `{"state":"received"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(mux, http.MethodHead, "/v1/devices", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("X-Resource-Version"))
+
+	w = serve(mux, http.MethodDelete, "/v1/devices/a%2F1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(mux, http.MethodGet, "/v1/devices/a%2F1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMethods(t *testing.T) {
+	mux := NewRouter(handler.NewHandler(service.NewService(service.NewStorage())))
+
+	tests := []struct {
+		method, target string
+		code           int
+		allow          string
+	}{
+		{http.MethodPatch, "/v1/devices/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PUT"},
+		{http.MethodDelete, "/v1/devices", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodOptions, "/v1/devices", http.StatusNoContent, "GET, HEAD, POST, OPTIONS"},
+		{http.MethodOptions, "/v1/devices/1", http.StatusNoContent, "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{http.MethodOptions, "/v1/devices/1/transition", http.StatusNoContent, "POST, OPTIONS"},
+		{http.MethodOptions, "/v1/unknown", http.StatusNotFound, ""},
+		{http.MethodPatch, "/device", http.StatusMethodNotAllowed, "POST, GET, PUT, DELETE"},
+		{http.MethodGet, "/device/transition", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodPost, "/devices", http.StatusMethodNotAllowed, "GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			w := serve(mux, tt.method, tt.target, "")
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.allow, w.Header().Get("Allow"))
+		})
+	}
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	mux := NewRouter(handler.NewHandler(service.NewService(service.NewStorage())))
+
+	w := serve(mux, http.MethodPost, "/device", `{"serial_number":"1","model":"switch","ip":"1.1.1.1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	for _, target := range []string{"/device?num=1", "/devices"} {
+		w = serve(mux, http.MethodGet, target, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		assert.Contains(t, w.Header().Get("Link"), `rel="successor-version"`)
+	}
+
+	w = serve(mux, http.MethodGet, "/v1/devices", "")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestTopology(t *testing.T) {
+	devices := service.NewService(service.NewStorage())
+	topo := topology.NewService(devices, topology.DeleteBlock)
+	h := handler.NewHandler(topology.Guard(devices, topo))
+	mux := NewRouter(h, WithTopology(handler.NewTopologyHandler(h, topo)))
+
+	for _, num := range []string{"chassis", "card", "switch"} {
+		w := serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"`+num+`","model":"m","ip":"1.1.1.1"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := serve(mux, http.MethodPut, "/v1/devices/card/parent", `{"parent":"chassis","slot":"1"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(mux, http.MethodPost, "/v1/links", `{"type":"ethernet","a":{"serial_number":"card","port":"p1"},"b":{"serial_number":"switch","port":"p2"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v1/links/1", w.Header().Get("Location"))
+	w = serve(mux, http.MethodPost, "/v1/links", `{"type":"ethernet","a":{"serial_number":"card","port":"p1"},"b":{"serial_number":"chassis","port":"p2"}}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(mux, http.MethodGet, "/v1/topology/path?from=chassis&to=switch", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `["chassis","card","switch"]`, w.Body.String())
+
+	w = serve(mux, http.MethodGet, "/v1/topology/dot", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/vnd.graphviz; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = serve(mux, http.MethodDelete, "/v1/devices/chassis", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = serve(mux, http.MethodDelete, "/v1/links/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(mux, http.MethodDelete, "/v1/devices/switch", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSearch(t *testing.T) {
+	index := search.NewIndex(service.NewStorage())
+	h := handler.NewHandler(service.NewService(index))
+	mux := NewRouter(h, WithSearch(handler.NewSearchHandler(h, index)))
+
+	w := serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"SN-1","model":"catalyst","ip":"1.1.1.1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(mux, http.MethodGet, "/v1/search?q=catalsyt", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []model.SearchResult
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results, 1)
+	assert.Equal(t, model.MatchFuzzy, results[0].Matches[0].Kind)
+
+	w = serve(mux, http.MethodGet, "/v1/search", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRetention(t *testing.T) {
+	h := handler.NewHandler(service.NewService(service.NewStorage()))
+	expiry := retention.NewService(h.Service, nil, model.RetentionTrash)
+	mux := NewRouter(h, WithRetention(handler.NewRetentionHandler(h, expiry)))
+
+	w := serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"SN-1","model":"lab","ip":"1.1.1.1","ttl":"2d"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"SN-2","model":"lab","ip":"1.1.1.2","ttl":"soon"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(mux, http.MethodPost, "/v1/retention/policies", `{"model":"lab","ttl":"30d"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v1/retention/policies/1", w.Header().Get("Location"))
+
+	var expiries []model.Expiry
+	w = serve(mux, http.MethodGet, "/v1/retention/preview?days=1", "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &expiries))
+	assert.Empty(t, expiries)
+	w = serve(mux, http.MethodGet, "/v1/retention/preview?days=3", "")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &expiries))
+	if assert.Len(t, expiries, 1) {
+		assert.Equal(t, "SN-1", expiries[0].Device.SerialNum)
+		assert.Empty(t, expiries[0].Policy)
+	}
+	w = serve(mux, http.MethodGet, "/v1/retention/preview?days=-1", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	_, err := expiry.Reap(time.Now().AddDate(0, 0, 3))
+	assert.Nil(t, err)
+	w = serve(mux, http.MethodGet, "/v1/devices/SN-1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(mux, http.MethodPost, "/v1/trash/SN-1/restore", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = serve(mux, http.MethodGet, "/v1/devices/SN-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(mux, http.MethodDelete, "/v1/trash/SN-1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(mux, http.MethodDelete, "/v1/retention/policies/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(mux, http.MethodDelete, "/v1/retention/policies/1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFreeze(t *testing.T) {
+	changes := freeze.NewService(nil)
+	h := handler.NewHandler(freeze.Guard(service.NewService(service.NewStorage()), changes))
+	mux := NewRouter(h, WithFreeze(handler.NewFreezeHandler(h, changes)))
+
+	start := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	end := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w := serve(mux, http.MethodPost, "/v1/windows", `{"kind":"freeze","start":"`+start+`","end":"`+end+`"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v1/windows/1", w.Header().Get("Location"))
+
+	w = serve(mux, http.MethodGet, "/v1/windows", "")
+	var schedule model.Schedule
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &schedule))
+	assert.Len(t, schedule.Active, 1)
+	assert.Empty(t, schedule.Upcoming)
+
+	w = serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"SN-1","model":"lab","ip":"1.1.1.1"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/devices", strings.NewReader(`{"serial_number":"SN-1","model":"lab","ip":"1.1.1.1"}`))
+	r.Header.Set(freeze.OverrideHeader, "urgent")
+	w = httptest.NewRecorder()
+	freeze.Overrides(mux).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(mux, http.MethodGet, "/v1/windows/overrides", "")
+	var overrides []model.Override
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &overrides))
+	// the window creation is audited too
+	assert.Len(t, overrides, 2)
+	assert.Equal(t, "create", overrides[1].Operation)
+
+	w = serve(mux, http.MethodDelete, "/v1/windows/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestReport(t *testing.T) {
+	storage := service.NewStorage()
+	h := handler.NewHandler(service.NewService(storage))
+	mux := NewRouter(h, WithReport(handler.NewReportHandler(h, report.NewService(storage, nil, nil, report.Rules{}))))
+
+	w := serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"SN-1","model":"lab","ip":"1.1.1.1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = serve(mux, http.MethodPost, "/v1/devices", `{"serial_number":"SN-2","model":"lab","ip":"1.1.1.1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(mux, http.MethodGet, "/v1/report", "")
+	var r model.Report
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &r))
+	assert.Equal(t, map[model.IssueKind]int{model.IssueDuplicateIP: 1}, r.Summary)
+
+	w = serve(mux, http.MethodGet, "/v1/report?format=text", "")
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "1.1.1.1 is used by 2 devices")
+}
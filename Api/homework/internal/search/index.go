@@ -0,0 +1,244 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"homework/internal/model"
+	"homework/internal/service"
+	"strings"
+	"sync"
+)
+
+var ErrEmptyQuery = errors.New("empty search query")
+
+type Service interface {
+	// Search returns up to limit devices matching every word of query by serial number,
+	// model, IP or labels, best first. Words match terms exactly, by prefix, as a substring
+	// or with a few typos; limit 0 returns every match.
+	Search(query string, limit int) ([]model.SearchResult, error)
+}
+
+// Index is a storage decorator keeping an inverted index of the stored devices.
+// Mutations update the index under its lock together with the storage, so searches
+// never see the index and storage disagree, whatever the order of concurrent writes.
+type Index struct {
+	next service.Storage
+	*index
+}
+
+type index struct {
+	mu sync.RWMutex
+	// docs holds the indexed devices and their terms by serial number
+	docs  map[string]document
+	terms map[string]map[string]fieldSet
+	// grams maps trigrams of the terms, see grams, to the terms
+	grams map[string]map[string]bool
+}
+
+type document struct {
+	device model.Device
+	terms  map[string]fieldSet
+}
+
+// NewIndex indexes the devices of next and keeps the index up to date with writes made through it.
+func NewIndex(next service.Storage) *Index {
+	ix := &Index{next: next, index: &index{
+		docs:  make(map[string]document),
+		terms: make(map[string]map[string]fieldSet),
+		grams: make(map[string]map[string]bool),
+	}}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	next.Range(func(d model.Device) bool {
+		ix.put(d)
+		return true
+	})
+	return ix
+}
+
+func (ix *Index) Add(d model.Device) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ok := ix.next.Add(d)
+	ix.put(d)
+	return ok
+}
+
+func (ix *Index) Del(num string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ok := ix.next.Del(num)
+	if ok {
+		ix.remove(num)
+	}
+	return ok
+}
+
+func (ix *Index) Get(num string) (model.Device, bool) {
+	return ix.next.Get(num)
+}
+
+func (ix *Index) Range(f func(d model.Device) bool) {
+	ix.next.Range(f)
+}
+
+func (ix *Index) ResourceVersion() uint64 {
+	return ix.next.ResourceVersion()
+}
+
+func (ix *Index) Changes(rv uint64) ([]model.Change, <-chan struct{}, bool) {
+	return ix.next.Changes(rv)
+}
+
+// WithContext binds the decorated storage to ctx, the index is shared.
+func (ix *Index) WithContext(ctx context.Context) service.Storage {
+	return &Index{next: service.BindStorage(ctx, ix.next), index: ix.index}
+}
+
+func (ix *Index) Search(query string, limit int) ([]model.SearchResult, error) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	type hit struct {
+		score   float64
+		matches []model.SearchMatch
+	}
+	var hits map[string]*hit
+	for i, word := range words {
+		best := make(map[string]model.SearchMatch)
+		scores := make(map[string]float64)
+		for term := range ix.candidates(word) {
+			kind, typos, ok := classify(word, term)
+			if !ok {
+				continue
+			}
+			for num, set := range ix.terms[term] {
+				for _, f := range fields {
+					if set&fieldSet(f.field) == 0 {
+						continue
+					}
+					if s := kindWeight(kind, typos) * f.weight; s > scores[num] {
+						scores[num] = s
+						best[num] = model.SearchMatch{Word: word, Field: f.name, Term: term, Kind: kind}
+					}
+				}
+			}
+		}
+
+		// every word has to match
+		next := make(map[string]*hit, len(scores))
+		for num, s := range scores {
+			h := &hit{}
+			if i > 0 {
+				prev, ok := hits[num]
+				if !ok {
+					continue
+				}
+				h = prev
+			}
+			h.score += s
+			h.matches = append(h.matches, best[num])
+			next[num] = h
+		}
+		hits = next
+	}
+
+	results := make([]model.SearchResult, 0, len(hits))
+	for num, h := range hits {
+		results = append(results, model.SearchResult{Device: ix.docs[num].device, Score: h.score, Matches: h.matches})
+	}
+	sortResults(results)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// candidates returns the terms that may match word: those containing all its trigrams,
+// and for typo-tolerant words those sharing any padded trigram. Short words scan every term.
+func (ix *index) candidates(word string) map[string]bool {
+	if len([]rune(word)) < 3 {
+		all := make(map[string]bool, len(ix.terms))
+		for term := range ix.terms {
+			all[term] = true
+		}
+		return all
+	}
+
+	var found map[string]bool
+	for _, g := range grams(word, false) {
+		next := make(map[string]bool)
+		for term := range ix.grams[g] {
+			if found == nil || found[term] {
+				next[term] = true
+			}
+		}
+		found = next
+	}
+
+	if k := maxTypos(word); k > 0 {
+		n := len([]rune(word))
+		for _, g := range grams(word, true) {
+			for term := range ix.grams[g] {
+				if abs(len([]rune(term))-n) <= k {
+					found[term] = true
+				}
+			}
+		}
+	}
+	return found
+}
+
+// put indexes d, replacing its previous version. ix.mu must be held.
+func (ix *index) put(d model.Device) {
+	ix.remove(d.SerialNum)
+
+	doc := document{device: d, terms: terms(d)}
+	ix.docs[d.SerialNum] = doc
+	for term, set := range doc.terms {
+		postings, ok := ix.terms[term]
+		if !ok {
+			postings = make(map[string]fieldSet)
+			ix.terms[term] = postings
+			for _, g := range grams(term, true) {
+				if ix.grams[g] == nil {
+					ix.grams[g] = make(map[string]bool)
+				}
+				ix.grams[g][term] = true
+			}
+		}
+		postings[d.SerialNum] = set
+	}
+}
+
+// remove drops num from the index and the terms no other device has. ix.mu must be held.
+func (ix *index) remove(num string) {
+	doc, ok := ix.docs[num]
+	if !ok {
+		return
+	}
+	delete(ix.docs, num)
+
+	for term := range doc.terms {
+		postings := ix.terms[term]
+		delete(postings, num)
+		if len(postings) > 0 {
+			continue
+		}
+		delete(ix.terms, term)
+		for _, g := range grams(term, true) {
+			delete(ix.grams[g], term)
+			if len(ix.grams[g]) == 0 {
+				delete(ix.grams, g)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"homework/internal/service"
+	"sync"
+	"testing"
+)
+
+func newTestIndex(t *testing.T) *Index {
+	storage := service.NewStorage()
+	storage.Add(model.Device{SerialNum: "SN-1000", Model: "catalyst-9300", IP: "10.0.0.1", Labels: map[string]string{"rack": "a1"}})
+	ix := NewIndex(storage)
+	ix.Add(model.Device{SerialNum: "SN-2000", Model: "nexus-9000", IP: "10.0.1.1", Labels: map[string]string{"rack": "b2", "site": "amsterdam"}})
+	ix.Add(model.Device{SerialNum: "XK-1001", Model: "catalyst-2960", IP: "192.168.1.1"})
+	return ix
+}
+
+func serials(results []model.SearchResult) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.Device.SerialNum)
+	}
+	return s
+}
+
+func TestSearch(t *testing.T) {
+	ix := newTestIndex(t)
+
+	tests := []struct {
+		query string
+		want  []string
+		kind  model.MatchKind
+	}{
+		{"sn-1000", []string{"SN-1000", "SN-2000"}, model.MatchExact},
+		{"catal", []string{"SN-1000", "XK-1001"}, model.MatchPrefix},
+		{"100", []string{"SN-1000", "XK-1001"}, model.MatchPrefix},
+		{"talys", []string{"SN-1000", "XK-1001"}, model.MatchSubstring},
+		{"amsterdan", []string{"SN-2000"}, model.MatchFuzzy},
+		{"nexsu", []string{"SN-2000"}, model.MatchFuzzy},
+		{"10.0.", []string{"SN-1000", "SN-2000"}, model.MatchPrefix},
+		{"rack=b2", []string{"SN-2000"}, model.MatchExact},
+		{"catalyst 2960", []string{"XK-1001"}, model.MatchExact},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := ix.Search(tt.query, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, serials(results))
+			if assert.NotEmpty(t, results) {
+				assert.Equal(t, tt.kind, results[0].Matches[len(results[0].Matches)-1].Kind)
+			}
+		})
+	}
+
+	_, err := ix.Search("  ", 0)
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := newTestIndex(t)
+	ix.Add(model.Device{SerialNum: "nexus", Model: "other", IP: "1.1.1.1"})
+
+	// a serial number outranks a model, an exact match outranks a prefix
+	results, err := ix.Search("nexus", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nexus"}, serials(results))
+	assert.Equal(t, model.SearchMatch{Word: "nexus", Field: "serial_number", Term: "nexus", Kind: model.MatchExact}, results[0].Matches[0])
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := newTestIndex(t)
+
+	ix.Add(model.Device{SerialNum: "SN-1000", Model: "arista-7050", IP: "10.0.0.1"})
+	results, _ := ix.Search("catalyst", 0)
+	assert.Equal(t, []string{"XK-1001"}, serials(results))
+	results, _ = ix.Search("arista", 0)
+	assert.Equal(t, []string{"SN-1000"}, serials(results))
+
+	ix.Del("XK-1001")
+	results, _ = ix.Search("catalyst", 0)
+	assert.Empty(t, results)
+	assert.Empty(t, ix.terms["catalyst"])
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ix := NewIndex(service.NewStorage())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				num := fmt.Sprint(i % 10)
+				ix.Add(model.Device{SerialNum: num, Model: fmt.Sprintf("model-%d", w), IP: "1.1.1.1"})
+				if i%7 == 0 {
+					ix.Del(num)
+				}
+				_, _ = ix.Search("model", 0)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// the index holds exactly the stored devices, in their stored versions
+	n := 0
+	ix.Range(func(d model.Device) bool {
+		n++
+		assert.Equal(t, d, ix.docs[d.SerialNum].device)
+		return true
+	})
+	assert.Equal(t, n, len(ix.docs))
+}
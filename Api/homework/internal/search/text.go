@@ -0,0 +1,177 @@
+package search
+
+import (
+	"homework/internal/model"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type field uint8
+
+const (
+	fieldSerial field = 1 << iota
+	fieldModel
+	fieldIP
+	fieldLabels
+)
+
+var fields = []struct {
+	field  field
+	name   string
+	weight float64
+}{
+	{fieldSerial, "serial_number", 4},
+	{fieldModel, "model", 3},
+	{fieldIP, "ip", 2},
+	{fieldLabels, "labels", 1},
+}
+
+// fieldSet is the set of fields a term occurs in.
+type fieldSet uint8
+
+// terms returns the lowercase terms of a device: whole values and their
+// alphanumeric words, and for labels also key=value.
+func terms(d model.Device) map[string]fieldSet {
+	t := make(map[string]fieldSet)
+	add := func(f field, values ...string) {
+		for _, v := range values {
+			v = strings.ToLower(v)
+			if v == "" {
+				continue
+			}
+			t[v] |= fieldSet(f)
+			for _, w := range strings.FieldsFunc(v, notAlnum) {
+				t[w] |= fieldSet(f)
+			}
+		}
+	}
+
+	add(fieldSerial, d.SerialNum)
+	add(fieldModel, d.Model)
+	if ip := strings.ToLower(d.IP); ip != "" {
+		t[ip] |= fieldSet(fieldIP)
+	}
+	for k, v := range d.Labels {
+		add(fieldLabels, k+"="+v, k, v)
+	}
+	return t
+}
+
+func notAlnum(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// grams returns the distinct trigrams of s. Padded ones also mark the start and end of s,
+// so a single typo in a short term still leaves a trigram in common.
+func grams(s string, padded bool) []string {
+	if padded {
+		s = " " + s + " "
+	}
+	r := []rune(s)
+	seen := make(map[string]bool)
+	var g []string
+	for i := 0; i+3 <= len(r); i++ {
+		gram := string(r[i : i+3])
+		if !seen[gram] {
+			seen[gram] = true
+			g = append(g, gram)
+		}
+	}
+	return g
+}
+
+// maxTypos is the edit distance fuzzy matching tolerates for a query word.
+func maxTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// classify tells how term matches word, and the edit distance of fuzzy matches.
+func classify(word, term string) (model.MatchKind, int, bool) {
+	switch {
+	case term == word:
+		return model.MatchExact, 0, true
+	case strings.HasPrefix(term, word):
+		return model.MatchPrefix, 0, true
+	case len(word) >= 2 && strings.Contains(term, word):
+		return model.MatchSubstring, 0, true
+	}
+	if k := maxTypos(word); k > 0 {
+		if d := distance(word, term, k); d <= k {
+			return model.MatchFuzzy, d, true
+		}
+	}
+	return "", 0, false
+}
+
+// kindWeight ranks exact matches over prefixes over substrings over typos.
+func kindWeight(kind model.MatchKind, typos int) float64 {
+	switch kind {
+	case model.MatchExact:
+		return 1
+	case model.MatchPrefix:
+		return 0.75
+	case model.MatchSubstring:
+		return 0.5
+	default:
+		return 0.4 / float64(typos)
+	}
+}
+
+// distance is the edit distance of a and b counting adjacent transpositions as one edit
+// (optimal string alignment), or max+1 once it exceeds max.
+func distance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+
+	rows := [3][]int{make([]int, len(rb)+1), make([]int, len(rb)+1), make([]int, len(rb)+1)}
+	for j := range rows[1] {
+		rows[1][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev2, prev, cur := rows[0], rows[1], rows[2]
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			best = min(best, cur[j])
+		}
+		if best > max {
+			return max + 1
+		}
+		rows = [3][]int{prev, cur, prev2}
+	}
+	return rows[1][len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sortResults(results []model.SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Device.SerialNum < results[j].Device.SerialNum
+	})
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"container/list"
+	"homework/internal/model"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheSize = 1024
+	defaultCacheTTL  = time.Minute
+)
+
+// CacheStats holds hit and miss counters of CachedService.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Size   int    `json:"size"`
+}
+
+// CachedService is a read-through Service decorator that keeps GetDevice results
+// in a size-bounded LRU with TTL. Writes made through it invalidate cached entries.
+type CachedService struct {
+	s    Service
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	order    *list.List
+	inflight map[string]*cacheCall
+	stats    CacheStats
+}
+
+// cacheEntry is a single LRU element.
+type cacheEntry struct {
+	num     string
+	device  model.Device
+	expires time.Time
+}
+
+// cacheCall is an in-flight GetDevice shared by concurrent misses on the same key.
+type cacheCall struct {
+	wg     sync.WaitGroup
+	device model.Device
+	err    error
+	stale  bool
+}
+
+func NewCachedService(s Service, size int, ttl time.Duration) *CachedService {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &CachedService{
+		s:        s,
+		size:     size,
+		ttl:      ttl,
+		now:      time.Now,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+func (c *CachedService) GetDevice(num string) (model.Device, error) {
+	c.mu.Lock()
+	if el, ok := c.items[num]; ok {
+		e := el.Value.(*cacheEntry)
+		if c.now().Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.device, nil
+		}
+		c.removeElement(el)
+	}
+	c.stats.Misses++
+
+	if call, ok := c.inflight[num]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.device, call.err
+	}
+
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.inflight[num] = call
+	c.mu.Unlock()
+
+	call.device, call.err = c.s.GetDevice(num)
+
+	c.mu.Lock()
+	delete(c.inflight, num)
+	if call.err == nil && !call.stale {
+		c.store(num, call.device)
+	}
+	c.mu.Unlock()
+	call.wg.Done()
+
+	return call.device, call.err
+}
+
+func (c *CachedService) CreateDevice(d model.Device) error {
+	defer c.Invalidate(d.SerialNum)
+	return c.s.CreateDevice(d)
+}
+
+func (c *CachedService) RestoreDevice(d model.Device) error {
+	defer c.Invalidate(d.SerialNum)
+	return c.s.RestoreDevice(d)
+}
+
+func (c *CachedService) DeleteDevice(num string) error {
+	defer c.Invalidate(num)
+	return c.s.DeleteDevice(num)
+}
+
+func (c *CachedService) UpdateDevice(d model.Device) error {
+	defer c.Invalidate(d.SerialNum)
+	return c.s.UpdateDevice(d)
+}
+
+func (c *CachedService) TransitionDevice(num string, to model.State, reason string) error {
+	defer c.Invalidate(num)
+	return c.s.TransitionDevice(num, to, reason)
+}
+
+func (c *CachedService) SetFirmware(num, firmware string) error {
+	defer c.Invalidate(num)
+	return c.s.SetFirmware(num, firmware)
+}
+
+func (c *CachedService) ListDevices(f Filter) ([]model.Device, error) {
+	return c.s.ListDevices(f)
+}
+
+func (c *CachedService) ResourceVersion() uint64 {
+	return c.s.ResourceVersion()
+}
+
+func (c *CachedService) Changes(rv uint64) ([]model.Change, <-chan struct{}, error) {
+	return c.s.Changes(rv)
+}
+
+// Invalidate drops the cached entry for num. A GetDevice already in flight
+// for num still returns its result, but the result is not cached.
+func (c *CachedService) Invalidate(num string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[num]; ok {
+		c.removeElement(el)
+	}
+	if call, ok := c.inflight[num]; ok {
+		call.stale = true
+	}
+}
+
+// Stats returns a snapshot of cache counters.
+func (c *CachedService) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+func (c *CachedService) store(num string, d model.Device) {
+	if el, ok := c.items[num]; ok {
+		c.removeElement(el)
+	}
+	c.items[num] = c.order.PushFront(&cacheEntry{num: num, device: d, expires: c.now().Add(c.ttl)})
+
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *CachedService) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).num)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingService counts GetDevice calls and can block them until released.
+type countingService struct {
+	Service
+	gets    atomic.Int64
+	release chan struct{}
+}
+
+func (s *countingService) GetDevice(num string) (model.Device, error) {
+	s.gets.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Service.GetDevice(num)
+}
+
+func newCountingService(t *testing.T, devices ...model.Device) *countingService {
+	s := &countingService{Service: NewService(NewStorage())}
+	for _, d := range devices {
+		assert.Nil(t, s.CreateDevice(d))
+	}
+	return s
+}
+
+func TestCachedServiceHitMiss(t *testing.T) {
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	s := newCountingService(t, d)
+	c := NewCachedService(s, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		got, err := c.GetDevice(d.SerialNum)
+		assert.Nil(t, err)
+		assert.Equal(t, d.Model, got.Model)
+	}
+
+	assert.Equal(t, int64(1), s.gets.Load())
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Size: 1}, c.Stats())
+}
+
+func TestCachedServiceDoesNotCacheErrors(t *testing.T) {
+	s := newCountingService(t)
+	c := NewCachedService(s, 10, time.Minute)
+
+	_, err := c.GetDevice("000")
+	assert.ErrorIs(t, err, ErrDeviceDoesNotExist)
+	_, err = c.GetDevice("000")
+	assert.ErrorIs(t, err, ErrDeviceDoesNotExist)
+
+	assert.Equal(t, int64(2), s.gets.Load())
+}
+
+func TestCachedServiceWritesInvalidate(t *testing.T) {
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	s := newCountingService(t, d)
+	c := NewCachedService(s, 10, time.Minute)
+
+	_, _ = c.GetDevice(d.SerialNum)
+
+	d.Model = "model2"
+	assert.Nil(t, c.UpdateDevice(d))
+
+	got, err := c.GetDevice(d.SerialNum)
+	assert.Nil(t, err)
+	assert.Equal(t, "model2", got.Model)
+
+	assert.Nil(t, c.DeleteDevice(d.SerialNum))
+	_, err = c.GetDevice(d.SerialNum)
+	assert.ErrorIs(t, err, ErrDeviceDoesNotExist)
+}
+
+func TestCachedServiceEvictsLeastRecentlyUsed(t *testing.T) {
+	devices := []model.Device{
+		{SerialNum: "1", Model: "model1", IP: "1.1.1.1"},
+		{SerialNum: "2", Model: "model1", IP: "1.1.1.1"},
+		{SerialNum: "3", Model: "model1", IP: "1.1.1.1"},
+	}
+	s := newCountingService(t, devices...)
+	c := NewCachedService(s, 2, time.Minute)
+
+	_, _ = c.GetDevice("1")
+	_, _ = c.GetDevice("2")
+	_, _ = c.GetDevice("1")
+	_, _ = c.GetDevice("3")
+
+	assert.Equal(t, 2, c.Stats().Size)
+
+	_, _ = c.GetDevice("1")
+	assert.Equal(t, int64(3), s.gets.Load())
+
+	_, _ = c.GetDevice("2")
+	assert.Equal(t, int64(4), s.gets.Load())
+}
+
+func TestCachedServiceExpires(t *testing.T) {
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	s := newCountingService(t, d)
+	c := NewCachedService(s, 10, time.Second)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	_, _ = c.GetDevice(d.SerialNum)
+	_, _ = c.GetDevice(d.SerialNum)
+	assert.Equal(t, int64(1), s.gets.Load())
+
+	now = now.Add(2 * time.Second)
+	_, _ = c.GetDevice(d.SerialNum)
+	assert.Equal(t, int64(2), s.gets.Load())
+}
+
+func TestCachedServiceCoalescesMisses(t *testing.T) {
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	s := newCountingService(t, d)
+	s.release = make(chan struct{})
+	c := NewCachedService(s, 10, time.Minute)
+
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			got, err := c.GetDevice(d.SerialNum)
+			assert.Nil(t, err)
+			assert.Equal(t, d.Model, got.Model)
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return c.Stats().Misses == n }, time.Second, time.Millisecond)
+	close(s.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), s.gets.Load())
+}
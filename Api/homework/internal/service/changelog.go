@@ -0,0 +1,58 @@
+package service
+
+import "homework/internal/model"
+
+const defaultHistory = 1024
+
+// changeLog is a bounded history of storage mutations. Every mutation gets
+// the next resource version. It is guarded by the lock of its storage.
+type changeLog struct {
+	version uint64
+	changes []model.Change
+	start   int
+	size    int
+	next    chan struct{}
+}
+
+func newChangeLog(capacity int) *changeLog {
+	return &changeLog{changes: make([]model.Change, capacity), next: make(chan struct{})}
+}
+
+func (l *changeLog) append(t model.ChangeType, d model.Device) {
+	l.version++
+	c := model.Change{Type: t, ResourceVersion: l.version, Device: d}
+
+	if l.size < len(l.changes) {
+		l.changes[(l.start+l.size)%len(l.changes)] = c
+		l.size++
+	} else {
+		l.changes[l.start] = c
+		l.start = (l.start + 1) % len(l.changes)
+	}
+
+	close(l.next)
+	l.next = make(chan struct{})
+}
+
+// since returns changes newer than rv. ok is false if changes right after rv were already dropped,
+// or if rv is newer than the log, e.g. handed out before a restart that lost changes.
+func (l *changeLog) since(rv uint64) ([]model.Change, <-chan struct{}, bool) {
+	if rv > l.version {
+		return nil, l.next, false
+	}
+	if rv == l.version {
+		return nil, l.next, true
+	}
+
+	oldest := l.version - uint64(l.size) + 1
+	if rv+1 < oldest {
+		return nil, l.next, false
+	}
+
+	n := int(l.version - rv)
+	changes := make([]model.Change, 0, n)
+	for i := l.size - n; i < l.size; i++ {
+		changes = append(changes, l.changes[(l.start+i)%len(l.changes)])
+	}
+	return changes, l.next, true
+}
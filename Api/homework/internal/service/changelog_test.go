@@ -0,0 +1,66 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"testing"
+)
+
+func TestChangeLog(t *testing.T) {
+	l := newChangeLog(3)
+
+	changes, next, ok := l.since(0)
+	assert.True(t, ok)
+	assert.Empty(t, changes)
+
+	for i := 0; i < 5; i++ {
+		l.append(model.ChangeAdded, model.Device{SerialNum: string(rune('a' + i))})
+	}
+
+	select {
+	case <-next:
+	default:
+		t.Fatal("next wasn't closed on append")
+	}
+
+	changes, _, ok = l.since(2)
+	assert.True(t, ok)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, uint64(3), changes[0].ResourceVersion)
+	assert.Equal(t, "e", changes[2].Device.SerialNum)
+
+	changes, _, ok = l.since(4)
+	assert.True(t, ok)
+	assert.Len(t, changes, 1)
+
+	_, _, ok = l.since(1)
+	assert.False(t, ok)
+
+	changes, _, ok = l.since(5)
+	assert.True(t, ok)
+	assert.Empty(t, changes)
+
+	// a version from before a restart is as unusable as one older than the history
+	_, _, ok = l.since(500)
+	assert.False(t, ok)
+}
+
+func TestSafeMapChanges(t *testing.T) {
+	m := NewStorage()
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+
+	m.Add(d)
+	m.Add(d)
+	m.Del(d.SerialNum)
+	m.Del(d.SerialNum)
+
+	assert.Equal(t, uint64(3), m.ResourceVersion())
+
+	changes, _, ok := m.Changes(0)
+	assert.True(t, ok)
+	assert.Equal(t, []model.Change{
+		{Type: model.ChangeAdded, ResourceVersion: 1, Device: d},
+		{Type: model.ChangeModified, ResourceVersion: 2, Device: d},
+		{Type: model.ChangeDeleted, ResourceVersion: 3, Device: d},
+	}, changes)
+}
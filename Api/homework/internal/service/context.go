@@ -0,0 +1,36 @@
+package service
+
+import "context"
+
+// contextBinder is implemented by decorators that need the caller's context,
+// e.g. to start tracing spans as children of the request span.
+type contextBinder[T any] interface {
+	WithContext(ctx context.Context) T
+}
+
+// Bind returns svc bound to ctx if it is context-aware, and svc itself otherwise.
+func Bind(ctx context.Context, svc Service) Service {
+	return bind(ctx, svc)
+}
+
+// BindStorage is Bind for storage.
+func BindStorage(ctx context.Context, s Storage) Storage {
+	return bind(ctx, s)
+}
+
+func bind[T any](ctx context.Context, v T) T {
+	if b, ok := any(v).(contextBinder[T]); ok {
+		return b.WithContext(ctx)
+	}
+	return v
+}
+
+// WithContext binds the underlying storage to ctx.
+func (s *storageService) WithContext(ctx context.Context) Service {
+	if _, ok := s.devices.(contextBinder[Storage]); !ok {
+		return s
+	}
+	bound := *s
+	bound.devices = BindStorage(ctx, s.devices)
+	return &bound
+}
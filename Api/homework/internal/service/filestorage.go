@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// journal operations
+const (
+	opPut     = "put"
+	opDel     = "del"
+	opVersion = "version"
+)
+
+type journalEntry struct {
+	Op              string        `json:"op"`
+	ResourceVersion uint64        `json:"rv"`
+	Device          *model.Device `json:"device,omitempty"`
+	SerialNum       string        `json:"serial_number,omitempty"`
+}
+
+// FileStorage keeps devices in memory and appends every mutation to a JSON
+// lines journal. The journal is replayed and compacted when the storage is opened,
+// resource versions continue from where they were. Without WithSync, mutations
+// not yet flushed by the OS are lost on power failure.
+type FileStorage struct {
+	*SafeMap
+
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	err  error
+	sync bool
+}
+
+type FileStorageOption func(*FileStorage)
+
+// WithSync syncs the journal to disk after every mutation.
+func WithSync() FileStorageOption {
+	return func(s *FileStorage) {
+		s.sync = true
+	}
+}
+
+func NewFileStorage(path string, options ...FileStorageOption) (*FileStorage, error) {
+	s := &FileStorage{SafeMap: NewStorage().(*SafeMap), path: path}
+	for _, opt := range options {
+		opt(s)
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStorage) Add(d model.Device) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ok := s.SafeMap.Add(d)
+	s.write(journalEntry{Op: opPut, ResourceVersion: s.SafeMap.ResourceVersion(), Device: &d})
+	return ok
+}
+
+func (s *FileStorage) Del(num string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ok := s.SafeMap.Del(num)
+	if ok {
+		s.write(journalEntry{Op: opDel, ResourceVersion: s.SafeMap.ResourceVersion(), SerialNum: num})
+	}
+	return ok
+}
+
+// Err returns the first journal write error. After it the journal misses mutations.
+func (s *FileStorage) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.f.Sync(); err != nil {
+		_ = s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// write must be called with s.mu held.
+func (s *FileStorage) write(e journalEntry) {
+	if s.err != nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err == nil {
+		_, err = s.f.Write(append(line, '\n'))
+	}
+	if err == nil && s.sync {
+		err = s.f.Sync()
+	}
+	if err != nil {
+		s.err = fmt.Errorf("storage journal %s: %w", s.path, err)
+		slog.Error(s.err.Error())
+	}
+}
+
+func (s *FileStorage) replay() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var corrupt error
+	line := 1
+	for ; sc.Scan(); line++ {
+		// only the last line may be torn by a crash mid-write, anything before it is damage
+		if corrupt != nil {
+			return fmt.Errorf("storage journal %s: line %d: %w", s.path, line-1, corrupt)
+		}
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			corrupt = err
+			continue
+		}
+
+		switch e.Op {
+		case opPut:
+			if e.Device != nil {
+				s.devices[e.Device.SerialNum] = *e.Device
+			}
+		case opDel:
+			delete(s.devices, e.SerialNum)
+		}
+		if e.ResourceVersion > s.log.version {
+			s.log.version = e.ResourceVersion
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if corrupt != nil {
+		slog.Warn("dropping torn storage journal line", slog.String("path", s.path), slog.Int("line", line-1))
+	}
+	return nil
+}
+
+// compact rewrites the journal with the current devices and opens it for appending.
+func (s *FileStorage) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	err = enc.Encode(journalEntry{Op: opVersion, ResourceVersion: s.log.version})
+	for _, d := range s.devices {
+		if err != nil {
+			break
+		}
+		d := d
+		err = enc.Encode(journalEntry{Op: opPut, ResourceVersion: s.log.version, Device: &d})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	s.f, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	return err
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStorageReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.jsonl")
+
+	s, err := NewFileStorage(path)
+	assert.Nil(t, err)
+	assert.True(t, s.Add(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}))
+	assert.True(t, s.Add(model.Device{SerialNum: "2", Model: "switch", IP: "1.1.1.1"}))
+	assert.False(t, s.Add(model.Device{SerialNum: "2", Model: "router", IP: "2.2.2.2"}))
+	assert.True(t, s.Del("1"))
+	assert.Nil(t, s.Err())
+	assert.Nil(t, s.Close())
+
+	s, err = NewFileStorage(path)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	_, ok := s.Get("1")
+	assert.False(t, ok)
+	d, ok := s.Get("2")
+	assert.True(t, ok)
+	assert.Equal(t, "router", d.Model)
+	assert.Equal(t, uint64(4), s.ResourceVersion())
+
+	// history before the restart is gone, newer changes are watched as usual
+	_, _, ok = s.Changes(3)
+	assert.False(t, ok)
+	s.Add(model.Device{SerialNum: "3", Model: "switch", IP: "1.1.1.1"})
+	changes, _, ok := s.Changes(4)
+	assert.True(t, ok)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, uint64(5), changes[0].ResourceVersion)
+
+	raw, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	// compacted on open: version, device 2, then device 3
+	assert.Equal(t, 3, strings.Count(string(raw), "\n"))
+}
+
+func TestFileStorageTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.jsonl")
+	journal := `{"op":"put","rv":1,"device":{"serial_number":"1","model":"switch","ip":"1.1.1.1"}}
+{"op":"put","rv":2,"device":{"serial_num`
+	assert.Nil(t, os.WriteFile(path, []byte(journal), 0o644))
+
+	s, err := NewFileStorage(path)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	_, ok := s.Get("1")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), s.ResourceVersion())
+}
+
+func TestFileStorageCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.jsonl")
+	journal := `{"op":"put","rv":1,"device":{"serial_number":"1","model":"switch","ip":"1.1.1.1"}}
+{"op":"put","rv":2,"device":{"serial_num
+{"op":"del","rv":3,"serial_number":"1"}
+`
+	assert.Nil(t, os.WriteFile(path, []byte(journal), 0o644))
+
+	_, err := NewFileStorage(path, WithSync())
+	assert.ErrorContains(t, err, "line 2")
+
+	// the journal is left for repair
+	raw, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, journal, string(raw))
+}
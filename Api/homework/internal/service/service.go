@@ -2,32 +2,148 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"homework/internal/model"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
-	ErrDeviceAlreadyExists = errors.New("device already exists")
-	ErrDeviceDoesNotExist  = errors.New("device doesn't exist")
-	ErrInvalidModel        = errors.New("invalid model")
-	ErrInvalidSerialNumber = errors.New("invalid serial number")
-	ErrInvalidIPAddress    = errors.New("invalid IP address")
+	ErrDeviceAlreadyExists  = errors.New("device already exists")
+	ErrDeviceDoesNotExist   = errors.New("device doesn't exist")
+	ErrInvalidModel         = errors.New("invalid model")
+	ErrInvalidSerialNumber  = errors.New("invalid serial number")
+	ErrInvalidIPAddress     = errors.New("invalid IP address")
+	ErrInvalidState         = errors.New("invalid device state")
+	ErrIllegalTransition    = errors.New("illegal state transition")
+	ErrDeviceDecommissioned = errors.New("device is decommissioned")
+	ErrInvalidAttributes    = errors.New("invalid attributes")
+	ErrResourceVersionGone  = errors.New("resource version is too old")
 )
 
 type Service interface {
 	GetDevice(string) (model.Device, error)
+	// CreateDevice creates a device in the initial state, ordered.
 	CreateDevice(model.Device) error
+	// RestoreDevice creates a device that existed before, such as one back from the trash,
+	// keeping its lifecycle state.
+	RestoreDevice(model.Device) error
 	DeleteDevice(string) error
 	UpdateDevice(model.Device) error
+	TransitionDevice(num string, to model.State, reason string) error
+	// SetFirmware records the firmware a device runs, keeping the rest of the device.
+	SetFirmware(num, firmware string) error
+	ListDevices(Filter) ([]model.Device, error)
+	// ResourceVersion returns the version of the latest registry mutation.
+	ResourceVersion() uint64
+	// Changes returns mutations newer than rv and a channel closed on the next mutation.
+	// It fails with ErrResourceVersionGone if rv is older than the retained history or newer
+	// than the latest version, as after a restart.
+	Changes(rv uint64) ([]model.Change, <-chan struct{}, error)
 }
 
-func NewService(s Storage) Service {
-	return &storageService{devices: s}
+// Filter narrows ListDevices results. Zero fields match any device.
+type Filter struct {
+	State  model.State
+	Model  string
+	Labels map[string]string
+	// Attributes maps dot-separated attribute paths to expected values, e.g. "panel.size": "55".
+	Attributes map[string]string
+}
+
+// Match reports whether d satisfies every set field of f.
+func (f Filter) Match(d model.Device) bool {
+	if f.State != "" && d.State != f.State {
+		return false
+	}
+	if f.Model != "" && d.Model != f.Model {
+		return false
+	}
+	for path, want := range f.Attributes {
+		got, ok := LookupAttribute(d.Attributes, path)
+		if !ok || got != want {
+			return false
+		}
+	}
+	return MatchLabels(d.Labels, f.Labels)
+}
+
+// LookupAttribute returns the value at a dot-separated path of attrs formatted as a string.
+func LookupAttribute(attrs map[string]any, path string) (string, bool) {
+	var v any = attrs
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]any, []any, nil:
+		return "", false
+	case string:
+		return val, true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	default:
+		return fmt.Sprint(val), true
+	}
+}
+
+// MatchLabels reports whether labels contain every key-value pair of selector.
+func MatchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// AttributeValidator checks device attributes against the schema of the device model
+// and returns the schema version used, or 0 if the model has no schema.
+type AttributeValidator interface {
+	ValidateAttributes(model string, attrs map[string]any) (int, error)
+}
+
+type Option func(*storageService)
+
+func NewService(s Storage, options ...Option) Service {
+	service := &storageService{devices: s, now: time.Now, mu: &sync.Mutex{}}
+	for _, option := range options {
+		option(service)
+	}
+	return service
+}
+
+func WithAttributeValidator(v AttributeValidator) Option {
+	return func(s *storageService) {
+		s.attributes = v
+	}
+}
+
+// WithClock replaces time.Now for creation and transition timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(s *storageService) {
+		s.now = now
+	}
 }
 
 type storageService struct {
-	devices Storage
+	devices    Storage
+	attributes AttributeValidator
+	now        func() time.Time
+
+	// mu serializes changes reading the device they write, so none of them
+	// is lost or brings a deleted device back. Copies bound to a context share it.
+	mu *sync.Mutex
 }
 
 func (s *storageService) GetDevice(num string) (model.Device, error) {
@@ -39,17 +155,64 @@ func (s *storageService) GetDevice(num string) (model.Device, error) {
 }
 
 func (s *storageService) CreateDevice(d model.Device) error {
+	if d.State == "" {
+		d.State = model.StateOrdered
+	}
+	if d.State.Valid() && d.State != model.StateOrdered {
+		return fmt.Errorf("%w: new devices start %s", ErrInvalidState, model.StateOrdered)
+	}
+	return s.create(d)
+}
+
+func (s *storageService) RestoreDevice(d model.Device) error {
+	if d.State == "" {
+		d.State = model.StateOrdered
+	}
+	return s.create(d)
+}
+
+func (s *storageService) create(d model.Device) error {
+	if !d.State.Valid() {
+		return ErrInvalidState
+	}
+	if err := verifyDeviceData(d); err != nil {
+		return err
+	}
+	if err := s.validateAttributes(&d); err != nil {
+		return err
+	}
+	created := s.now().UTC()
+	d.CreatedAt = &created
+
 	ok := s.devices.Add(d)
 	if !ok {
 		return ErrDeviceAlreadyExists
 	}
 
-	if err := verifyDeviceData(d); err != nil {
+	return nil
+}
+
+func (s *storageService) validateAttributes(d *model.Device) error {
+	if s.attributes == nil {
+		return nil
+	}
+	version, err := s.attributes.ValidateAttributes(d.Model, d.Attributes)
+	if err != nil {
 		return err
 	}
-
+	d.SchemaVersion = version
 	return nil
 }
+
+// ValidateDevice checks the fields of a device written without CreateDevice, such as a
+// restored one. Unlike CreateDevice it accepts any valid state.
+func ValidateDevice(d model.Device) error {
+	if d.State != "" && !d.State.Valid() {
+		return ErrInvalidState
+	}
+	return verifyDeviceData(d)
+}
+
 func verifyDeviceData(d model.Device) error {
 	if d.Model == "" {
 		return ErrInvalidModel
@@ -64,6 +227,9 @@ func verifyDeviceData(d model.Device) error {
 }
 
 func (s *storageService) DeleteDevice(num string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	ok := s.devices.Del(num)
 	if !ok {
 		return ErrDeviceDoesNotExist
@@ -72,38 +238,124 @@ func (s *storageService) DeleteDevice(num string) error {
 }
 
 func (s *storageService) UpdateDevice(updDev model.Device) error {
-	_, ok := s.devices.Get(updDev.SerialNum)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices.Get(updDev.SerialNum)
 	if !ok {
 		return ErrDeviceDoesNotExist
 	}
 	if err := verifyDeviceData(updDev); err != nil {
 		return err
 	}
+	if d.State == model.StateDecommissioned && d.IP != updDev.IP {
+		return ErrDeviceDecommissioned
+	}
+	if err := s.validateAttributes(&updDev); err != nil {
+		return err
+	}
+	// state is changed only through TransitionDevice
+	updDev.State = d.State
+	updDev.LastTransition = d.LastTransition
+	updDev.CreatedAt = d.CreatedAt
 	s.devices.Add(updDev)
 	return nil
 }
 
+func (s *storageService) TransitionDevice(num string, to model.State, reason string) error {
+	if !to.Valid() {
+		return ErrInvalidState
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices.Get(num)
+	if !ok {
+		return ErrDeviceDoesNotExist
+	}
+	if !d.State.CanTransitionTo(to) {
+		return ErrIllegalTransition
+	}
+
+	d.LastTransition = &model.Transition{From: d.State, To: to, Reason: reason, At: s.now().UTC()}
+	d.State = to
+	s.devices.Add(d)
+	return nil
+}
+
+func (s *storageService) SetFirmware(num, firmware string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices.Get(num)
+	if !ok {
+		return ErrDeviceDoesNotExist
+	}
+	d.Firmware = firmware
+	s.devices.Add(d)
+	return nil
+}
+
+func (s *storageService) ListDevices(f Filter) ([]model.Device, error) {
+	if f.State != "" && !f.State.Valid() {
+		return nil, ErrInvalidState
+	}
+
+	devices := make([]model.Device, 0)
+	s.devices.Range(func(d model.Device) bool {
+		if f.Match(d) {
+			devices = append(devices, d)
+		}
+		return true
+	})
+	sort.Slice(devices, func(i, j int) bool { return devices[i].SerialNum < devices[j].SerialNum })
+	return devices, nil
+}
+
+func (s *storageService) ResourceVersion() uint64 {
+	return s.devices.ResourceVersion()
+}
+
+func (s *storageService) Changes(rv uint64) ([]model.Change, <-chan struct{}, error) {
+	changes, next, ok := s.devices.Changes(rv)
+	if !ok {
+		return nil, nil, ErrResourceVersionGone
+	}
+	return changes, next, nil
+}
+
 type Storage interface {
 	Add(d model.Device) bool
 	Get(num string) (model.Device, bool)
 	Del(num string) bool
+	Range(f func(d model.Device) bool)
+	// ResourceVersion returns the version of the latest mutation.
+	ResourceVersion() uint64
+	// Changes returns retained mutations newer than rv and a channel closed on the next mutation.
+	// ok is false if some of those mutations are no longer retained, or rv is newer than the latest version.
+	Changes(rv uint64) (changes []model.Change, next <-chan struct{}, ok bool)
 }
 
 func NewStorage() Storage {
-	return &SafeMap{devices: make(map[string]model.Device), mu: sync.RWMutex{}}
+	return &SafeMap{devices: make(map[string]model.Device), mu: sync.RWMutex{}, log: newChangeLog(defaultHistory)}
 }
 
 type SafeMap struct {
 	devices map[string]model.Device
 	mu      sync.RWMutex
+	log     *changeLog
 }
 
 func (m *SafeMap) Add(d model.Device) bool {
-	m.mu.RLock()
-	_, ok := m.devices[d.SerialNum]
-	m.mu.RUnlock()
 	m.mu.Lock()
+	_, ok := m.devices[d.SerialNum]
 	m.devices[d.SerialNum] = d
+	if ok {
+		m.log.append(model.ChangeModified, d)
+	} else {
+		m.log.append(model.ChangeAdded, d)
+	}
 	m.mu.Unlock()
 	return !ok
 }
@@ -119,14 +371,42 @@ func (m *SafeMap) Get(num string) (model.Device, bool) {
 }
 
 func (m *SafeMap) Del(num string) bool {
-	m.mu.RLock()
-	_, ok := m.devices[num]
-	m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.devices[num]
 	if !ok {
 		return false
 	}
-	m.mu.Lock()
 	delete(m.devices, num)
-	m.mu.Unlock()
+	m.log.append(model.ChangeDeleted, d)
 	return true
 }
+
+// Range calls f for each stored device until f returns false.
+func (m *SafeMap) Range(f func(d model.Device) bool) {
+	m.mu.RLock()
+	devices := make([]model.Device, 0, len(m.devices))
+	for _, d := range m.devices {
+		devices = append(devices, d)
+	}
+	m.mu.RUnlock()
+
+	for _, d := range devices {
+		if !f(d) {
+			return
+		}
+	}
+}
+
+func (m *SafeMap) ResourceVersion() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.log.version
+}
+
+func (m *SafeMap) Changes(rv uint64) ([]model.Change, <-chan struct{}, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.log.since(rv)
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestSafeMapAdd(t *testing.T) {
@@ -56,8 +57,8 @@ func TestSafeMapDel(t *testing.T) {
 }
 
 func FuzzVerifyDeviceData(f *testing.F) {
-	f.Fuzz(func(t *testing.T, serialNum, model, ip string) {
-		d := model.Device{SerialNum: serialNum, Model: model, IP: ip}
+	f.Fuzz(func(t *testing.T, serialNum, modelName, ip string) {
+		d := model.Device{SerialNum: serialNum, Model: modelName, IP: ip}
 		res := verifyDeviceData(d)
 		if res != nil {
 			if d.Model == "" {
@@ -71,13 +72,26 @@ func FuzzVerifyDeviceData(f *testing.F) {
 	})
 }
 
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func clock() time.Time {
+	return testTime
+}
+
+// created returns d as CreateDevice stores it at testTime.
+func created(d model.Device) model.Device {
+	at := testTime
+	d.CreatedAt = &at
+	return d
+}
+
 func TestCreateDevice(t *testing.T) {
 	storage := NewStorageMock(t)
-	s := NewService(storage)
+	s := NewService(storage, WithClock(clock))
 
-	wantDevice := model.Device{SerialNum: "123", Model: "model1", IP: "1.1.1.1"}
+	wantDevice := model.Device{SerialNum: "123", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
-	storage.AddMock.Expect(wantDevice).Return(true)
+	storage.AddMock.Expect(created(wantDevice)).Return(true)
 
 	err := s.CreateDevice(wantDevice)
 	assert.Nil(t, err)
@@ -92,25 +106,25 @@ func TestCreateDevice(t *testing.T) {
 
 func TestCreateMultipleDevices(t *testing.T) {
 	storage := NewStorageMock(t)
-	s := NewService(storage)
+	s := NewService(storage, WithClock(clock))
 
 	devices := []model.Device{
 		{
-			SerialNum: "1", Model: "model1", IP: "1.1.1.1",
+			SerialNum: "1", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered,
 		},
 		{
-			SerialNum: "2", Model: "model1", IP: "1.1.1.1",
+			SerialNum: "2", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered,
 		},
 		{
-			SerialNum: "3", Model: "model2", IP: "1.1.1.1",
+			SerialNum: "3", Model: "model2", IP: "1.1.1.1", State: model.StateOrdered,
 		},
 		{
-			SerialNum: "3", Model: "model3", IP: "123.123.123.123",
+			SerialNum: "3", Model: "model3", IP: "123.123.123.123", State: model.StateOrdered,
 		},
 	}
 
 	for _, d := range devices {
-		storage.AddMock.Expect(d).Return(true)
+		storage.AddMock.Expect(created(d)).Return(true)
 		err := s.CreateDevice(d)
 		assert.Nil(t, err)
 	}
@@ -128,7 +142,7 @@ func TestCreateInvalidSerialNum(t *testing.T) {
 	storage := NewStorageMock(t)
 	s := NewService(storage)
 
-	invalidDevice := model.Device{SerialNum: "", Model: "model1", IP: "1.1.1.1"}
+	invalidDevice := model.Device{SerialNum: "", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
 	storage.AddMock.Expect(invalidDevice).Return(true)
 
@@ -139,7 +153,7 @@ func TestCreateInvalidIP(t *testing.T) {
 	storage := NewStorageMock(t)
 	s := NewService(storage)
 
-	invalidDevice := model.Device{SerialNum: "1", Model: "model1", IP: "1.99999.1.1"}
+	invalidDevice := model.Device{SerialNum: "1", Model: "model1", IP: "1.99999.1.1", State: model.StateOrdered}
 
 	storage.AddMock.Expect(invalidDevice).Return(true)
 
@@ -149,15 +163,15 @@ func TestCreateInvalidIP(t *testing.T) {
 
 func TestCreateDuplicate(t *testing.T) {
 	storage := NewStorageMock(t)
-	s := NewService(storage)
+	s := NewService(storage, WithClock(clock))
 
-	d := model.Device{SerialNum: "123", Model: "model1", IP: "1.1.1.1"}
+	d := model.Device{SerialNum: "123", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
-	storage.AddMock.Expect(d).Return(true)
+	storage.AddMock.Expect(created(d)).Return(true)
 	err := s.CreateDevice(d)
 	assert.Nil(t, err)
 
-	storage.AddMock.Expect(d).Return(false)
+	storage.AddMock.Expect(created(d)).Return(false)
 	err = s.CreateDevice(d)
 	assert.NotNil(t, err)
 }
@@ -178,11 +192,11 @@ func TestGetDeviceUnexisting(t *testing.T) {
 
 func TestDeleteDevice(t *testing.T) {
 	storage := NewStorageMock(t)
-	s := NewService(storage)
+	s := NewService(storage, WithClock(clock))
 
-	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
-	storage.AddMock.Expect(d).Return(true)
+	storage.AddMock.Expect(created(d)).Return(true)
 
 	_ = s.CreateDevice(d)
 
@@ -206,15 +220,15 @@ func TestDeleteDeviceUnexisting(t *testing.T) {
 
 func TestUpdateDevice(t *testing.T) {
 	storage := NewStorageMock(t)
-	s := NewService(storage)
+	s := NewService(storage, WithClock(clock))
 
-	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
-	storage.AddMock.Expect(d).Return(true)
+	storage.AddMock.Expect(created(d)).Return(true)
 
 	_ = s.CreateDevice(d)
 
-	updDevice := model.Device{SerialNum: "1", Model: "model2 pro max", IP: "1.1.1.1"}
+	updDevice := model.Device{SerialNum: "1", Model: "model2 pro max", IP: "1.1.1.1", State: model.StateOrdered}
 
 	storage.GetMock.Expect(updDevice.SerialNum).Return(d, true)
 	storage.AddMock.Expect(updDevice).Return(true)
@@ -227,7 +241,7 @@ func TestUpdateDeviceUnexsting(t *testing.T) {
 	storage := NewStorageMock(t)
 	s := NewService(storage)
 
-	updDevice := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	updDevice := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
 	storage.GetMock.Expect(updDevice.SerialNum).Return(model.Device{}, false)
 
@@ -241,7 +255,7 @@ func BenchmarkServiceUpdateDevice(b *testing.B) {
 	storage := NewStorage()
 	s := NewService(storage)
 
-	d := model.Device{SerialNum: "123", Model: "model1", IP: "1.1.1.1"}
+	d := model.Device{SerialNum: "123", Model: "model1", IP: "1.1.1.1", State: model.StateOrdered}
 
 	_ = s.CreateDevice(d)
 
@@ -253,3 +267,105 @@ func BenchmarkServiceUpdateDevice(b *testing.B) {
 		assert.Nil(b, err)
 	}
 }
+
+func TestCreateDeviceDefaultState(t *testing.T) {
+	s := NewService(NewStorage())
+
+	assert.Nil(t, s.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}))
+
+	d, err := s.GetDevice("1")
+	assert.Nil(t, err)
+	assert.Equal(t, model.StateOrdered, d.State)
+
+	err = s.CreateDevice(model.Device{SerialNum: "2", Model: "model1", IP: "1.1.1.1", State: "broken"})
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// only restored devices skip the lifecycle
+	err = s.CreateDevice(model.Device{SerialNum: "2", Model: "model1", IP: "1.1.1.1", State: model.StateActive})
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.Nil(t, s.RestoreDevice(model.Device{SerialNum: "2", Model: "model1", IP: "1.1.1.1", State: model.StateActive}))
+	d, err = s.GetDevice("2")
+	assert.Nil(t, err)
+	assert.Equal(t, model.StateActive, d.State)
+	assert.NotNil(t, d.CreatedAt)
+}
+
+func TestTransitionDevice(t *testing.T) {
+	s := NewService(NewStorage())
+	_ = s.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"})
+
+	err := s.TransitionDevice("1", model.StateActive, "skip provisioning")
+	assert.ErrorIs(t, err, ErrIllegalTransition)
+
+	err = s.TransitionDevice("1", model.StateReceived, "arrived at warehouse")
+	assert.Nil(t, err)
+
+	d, _ := s.GetDevice("1")
+	assert.Equal(t, model.StateReceived, d.State)
+	assert.Equal(t, model.StateOrdered, d.LastTransition.From)
+	assert.Equal(t, "arrived at warehouse", d.LastTransition.Reason)
+
+	err = s.TransitionDevice("1", "broken", "")
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	err = s.TransitionDevice("000", model.StateReceived, "")
+	assert.ErrorIs(t, err, ErrDeviceDoesNotExist)
+}
+
+func TestUpdateDecommissionedDevice(t *testing.T) {
+	s := NewService(NewStorage())
+	d := model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}
+	_ = s.CreateDevice(d)
+	_ = s.TransitionDevice("1", model.StateDecommissioned, "broken on arrival")
+
+	d.IP = "2.2.2.2"
+	assert.ErrorIs(t, s.UpdateDevice(d), ErrDeviceDecommissioned)
+
+	d.IP = "1.1.1.1"
+	d.Model = "model2"
+	d.State = model.StateActive
+	assert.Nil(t, s.UpdateDevice(d))
+
+	got, _ := s.GetDevice("1")
+	assert.Equal(t, model.StateDecommissioned, got.State)
+	assert.Equal(t, "model2", got.Model)
+}
+
+func TestListDevices(t *testing.T) {
+	s := NewService(NewStorage())
+	_ = s.CreateDevice(model.Device{SerialNum: "2", Model: "model1", IP: "1.1.1.1"})
+	_ = s.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"})
+	_ = s.RestoreDevice(model.Device{SerialNum: "3", Model: "model1", IP: "1.1.1.1", State: model.StateActive})
+
+	devices, err := s.ListDevices(Filter{})
+	assert.Nil(t, err)
+	assert.Len(t, devices, 3)
+	assert.Equal(t, "1", devices[0].SerialNum)
+
+	devices, err = s.ListDevices(Filter{State: model.StateActive})
+	assert.Nil(t, err)
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "3", devices[0].SerialNum)
+
+	_, err = s.ListDevices(Filter{State: "broken"})
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestListDevicesByAttribute(t *testing.T) {
+	s := NewService(NewStorage())
+	_ = s.CreateDevice(model.Device{SerialNum: "1", Model: "display", IP: "1.1.1.1",
+		Attributes: map[string]any{"panel": map[string]any{"size": 55.0, "type": "oled"}}})
+	_ = s.CreateDevice(model.Device{SerialNum: "2", Model: "display", IP: "1.1.1.1",
+		Attributes: map[string]any{"panel": map[string]any{"size": 65.0, "type": "oled"}}})
+
+	devices, err := s.ListDevices(Filter{Attributes: map[string]string{"panel.size": "55"}})
+	assert.Nil(t, err)
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "1", devices[0].SerialNum)
+
+	devices, _ = s.ListDevices(Filter{Attributes: map[string]string{"panel.type": "oled"}})
+	assert.Len(t, devices, 2)
+
+	devices, _ = s.ListDevices(Filter{Attributes: map[string]string{"panel": "oled"}})
+	assert.Len(t, devices, 0)
+}
@@ -23,6 +23,13 @@ type StorageMock struct {
 	beforeAddCounter uint64
 	AddMock          mStorageMockAdd
 
+	funcChanges func(rv uint64) (changes []model.Change, next <-chan struct {
+	}, ok bool)
+	inspectFuncChanges   func(rv uint64)
+	afterChangesCounter  uint64
+	beforeChangesCounter uint64
+	ChangesMock          mStorageMockChanges
+
 	funcDel          func(num string) (b1 bool)
 	inspectFuncDel   func(num string)
 	afterDelCounter  uint64
@@ -34,6 +41,18 @@ type StorageMock struct {
 	afterGetCounter  uint64
 	beforeGetCounter uint64
 	GetMock          mStorageMockGet
+
+	funcRange          func(f func(d model.Device) bool)
+	inspectFuncRange   func(f func(d model.Device) bool)
+	afterRangeCounter  uint64
+	beforeRangeCounter uint64
+	RangeMock          mStorageMockRange
+
+	funcResourceVersion          func() (u1 uint64)
+	inspectFuncResourceVersion   func()
+	afterResourceVersionCounter  uint64
+	beforeResourceVersionCounter uint64
+	ResourceVersionMock          mStorageMockResourceVersion
 }
 
 // NewStorageMock returns a mock for Storage
@@ -46,12 +65,20 @@ func NewStorageMock(t minimock.Tester) *StorageMock {
 	m.AddMock = mStorageMockAdd{mock: m}
 	m.AddMock.callArgs = []*StorageMockAddParams{}
 
+	m.ChangesMock = mStorageMockChanges{mock: m}
+	m.ChangesMock.callArgs = []*StorageMockChangesParams{}
+
 	m.DelMock = mStorageMockDel{mock: m}
 	m.DelMock.callArgs = []*StorageMockDelParams{}
 
 	m.GetMock = mStorageMockGet{mock: m}
 	m.GetMock.callArgs = []*StorageMockGetParams{}
 
+	m.RangeMock = mStorageMockRange{mock: m}
+	m.RangeMock.callArgs = []*StorageMockRangeParams{}
+
+	m.ResourceVersionMock = mStorageMockResourceVersion{mock: m}
+
 	return m
 }
 
@@ -270,6 +297,228 @@ func (m *StorageMock) MinimockAddInspect() {
 	}
 }
 
+type mStorageMockChanges struct {
+	mock               *StorageMock
+	defaultExpectation *StorageMockChangesExpectation
+	expectations       []*StorageMockChangesExpectation
+
+	callArgs []*StorageMockChangesParams
+	mutex    sync.RWMutex
+}
+
+// StorageMockChangesExpectation specifies expectation struct of the Storage.Changes
+type StorageMockChangesExpectation struct {
+	mock    *StorageMock
+	params  *StorageMockChangesParams
+	results *StorageMockChangesResults
+	Counter uint64
+}
+
+// StorageMockChangesParams contains parameters of the Storage.Changes
+type StorageMockChangesParams struct {
+	rv uint64
+}
+
+// StorageMockChangesResults contains results of the Storage.Changes
+type StorageMockChangesResults struct {
+	changes []model.Change
+	next    <-chan struct {
+	}
+	ok bool
+}
+
+// Expect sets up expected params for Storage.Changes
+func (mmChanges *mStorageMockChanges) Expect(rv uint64) *mStorageMockChanges {
+	if mmChanges.mock.funcChanges != nil {
+		mmChanges.mock.t.Fatalf("StorageMock.Changes mock is already set by Set")
+	}
+
+	if mmChanges.defaultExpectation == nil {
+		mmChanges.defaultExpectation = &StorageMockChangesExpectation{}
+	}
+
+	mmChanges.defaultExpectation.params = &StorageMockChangesParams{rv}
+	for _, e := range mmChanges.expectations {
+		if minimock.Equal(e.params, mmChanges.defaultExpectation.params) {
+			mmChanges.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmChanges.defaultExpectation.params)
+		}
+	}
+
+	return mmChanges
+}
+
+// Inspect accepts an inspector function that has same arguments as the Storage.Changes
+func (mmChanges *mStorageMockChanges) Inspect(f func(rv uint64)) *mStorageMockChanges {
+	if mmChanges.mock.inspectFuncChanges != nil {
+		mmChanges.mock.t.Fatalf("Inspect function is already set for StorageMock.Changes")
+	}
+
+	mmChanges.mock.inspectFuncChanges = f
+
+	return mmChanges
+}
+
+// Return sets up results that will be returned by Storage.Changes
+func (mmChanges *mStorageMockChanges) Return(changes []model.Change, next <-chan struct {
+}, ok bool) *StorageMock {
+	if mmChanges.mock.funcChanges != nil {
+		mmChanges.mock.t.Fatalf("StorageMock.Changes mock is already set by Set")
+	}
+
+	if mmChanges.defaultExpectation == nil {
+		mmChanges.defaultExpectation = &StorageMockChangesExpectation{mock: mmChanges.mock}
+	}
+	mmChanges.defaultExpectation.results = &StorageMockChangesResults{changes, next, ok}
+	return mmChanges.mock
+}
+
+// Set uses given function f to mock the Storage.Changes method
+func (mmChanges *mStorageMockChanges) Set(f func(rv uint64) (changes []model.Change, next <-chan struct {
+}, ok bool)) *StorageMock {
+	if mmChanges.defaultExpectation != nil {
+		mmChanges.mock.t.Fatalf("Default expectation is already set for the Storage.Changes method")
+	}
+
+	if len(mmChanges.expectations) > 0 {
+		mmChanges.mock.t.Fatalf("Some expectations are already set for the Storage.Changes method")
+	}
+
+	mmChanges.mock.funcChanges = f
+	return mmChanges.mock
+}
+
+// When sets expectation for the Storage.Changes which will trigger the result defined by the following
+// Then helper
+func (mmChanges *mStorageMockChanges) When(rv uint64) *StorageMockChangesExpectation {
+	if mmChanges.mock.funcChanges != nil {
+		mmChanges.mock.t.Fatalf("StorageMock.Changes mock is already set by Set")
+	}
+
+	expectation := &StorageMockChangesExpectation{
+		mock:   mmChanges.mock,
+		params: &StorageMockChangesParams{rv},
+	}
+	mmChanges.expectations = append(mmChanges.expectations, expectation)
+	return expectation
+}
+
+// Then sets up Storage.Changes return parameters for the expectation previously defined by the When method
+func (e *StorageMockChangesExpectation) Then(changes []model.Change, next <-chan struct {
+}, ok bool) *StorageMock {
+	e.results = &StorageMockChangesResults{changes, next, ok}
+	return e.mock
+}
+
+// Changes implements Storage
+func (mmChanges *StorageMock) Changes(rv uint64) (changes []model.Change, next <-chan struct {
+}, ok bool) {
+	mm_atomic.AddUint64(&mmChanges.beforeChangesCounter, 1)
+	defer mm_atomic.AddUint64(&mmChanges.afterChangesCounter, 1)
+
+	if mmChanges.inspectFuncChanges != nil {
+		mmChanges.inspectFuncChanges(rv)
+	}
+
+	mm_params := &StorageMockChangesParams{rv}
+
+	// Record call args
+	mmChanges.ChangesMock.mutex.Lock()
+	mmChanges.ChangesMock.callArgs = append(mmChanges.ChangesMock.callArgs, mm_params)
+	mmChanges.ChangesMock.mutex.Unlock()
+
+	for _, e := range mmChanges.ChangesMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.changes, e.results.next, e.results.ok
+		}
+	}
+
+	if mmChanges.ChangesMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmChanges.ChangesMock.defaultExpectation.Counter, 1)
+		mm_want := mmChanges.ChangesMock.defaultExpectation.params
+		mm_got := StorageMockChangesParams{rv}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmChanges.t.Errorf("StorageMock.Changes got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmChanges.ChangesMock.defaultExpectation.results
+		if mm_results == nil {
+			mmChanges.t.Fatal("No results are set for the StorageMock.Changes")
+		}
+		return (*mm_results).changes, (*mm_results).next, (*mm_results).ok
+	}
+	if mmChanges.funcChanges != nil {
+		return mmChanges.funcChanges(rv)
+	}
+	mmChanges.t.Fatalf("Unexpected call to StorageMock.Changes. %v", rv)
+	return
+}
+
+// ChangesAfterCounter returns a count of finished StorageMock.Changes invocations
+func (mmChanges *StorageMock) ChangesAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmChanges.afterChangesCounter)
+}
+
+// ChangesBeforeCounter returns a count of StorageMock.Changes invocations
+func (mmChanges *StorageMock) ChangesBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmChanges.beforeChangesCounter)
+}
+
+// Calls returns a list of arguments used in each call to StorageMock.Changes.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmChanges *mStorageMockChanges) Calls() []*StorageMockChangesParams {
+	mmChanges.mutex.RLock()
+
+	argCopy := make([]*StorageMockChangesParams, len(mmChanges.callArgs))
+	copy(argCopy, mmChanges.callArgs)
+
+	mmChanges.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockChangesDone returns true if the count of the Changes invocations corresponds
+// the number of defined expectations
+func (m *StorageMock) MinimockChangesDone() bool {
+	for _, e := range m.ChangesMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ChangesMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcChanges != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockChangesInspect logs each unmet expectation
+func (m *StorageMock) MinimockChangesInspect() {
+	for _, e := range m.ChangesMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to StorageMock.Changes with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ChangesMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		if m.ChangesMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to StorageMock.Changes")
+		} else {
+			m.t.Errorf("Expected call to StorageMock.Changes with params: %#v", *m.ChangesMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcChanges != nil && mm_atomic.LoadUint64(&m.afterChangesCounter) < 1 {
+		m.t.Error("Expected call to StorageMock.Changes")
+	}
+}
+
 type mStorageMockDel struct {
 	mock               *StorageMock
 	defaultExpectation *StorageMockDelExpectation
@@ -701,14 +950,350 @@ func (m *StorageMock) MinimockGetInspect() {
 	}
 }
 
+type mStorageMockRange struct {
+	mock               *StorageMock
+	defaultExpectation *StorageMockRangeExpectation
+	expectations       []*StorageMockRangeExpectation
+
+	callArgs []*StorageMockRangeParams
+	mutex    sync.RWMutex
+}
+
+// StorageMockRangeExpectation specifies expectation struct of the Storage.Range
+type StorageMockRangeExpectation struct {
+	mock   *StorageMock
+	params *StorageMockRangeParams
+
+	Counter uint64
+}
+
+// StorageMockRangeParams contains parameters of the Storage.Range
+type StorageMockRangeParams struct {
+	f func(d model.Device) bool
+}
+
+// Expect sets up expected params for Storage.Range
+func (mmRange *mStorageMockRange) Expect(f func(d model.Device) bool) *mStorageMockRange {
+	if mmRange.mock.funcRange != nil {
+		mmRange.mock.t.Fatalf("StorageMock.Range mock is already set by Set")
+	}
+
+	if mmRange.defaultExpectation == nil {
+		mmRange.defaultExpectation = &StorageMockRangeExpectation{}
+	}
+
+	mmRange.defaultExpectation.params = &StorageMockRangeParams{f}
+	for _, e := range mmRange.expectations {
+		if minimock.Equal(e.params, mmRange.defaultExpectation.params) {
+			mmRange.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmRange.defaultExpectation.params)
+		}
+	}
+
+	return mmRange
+}
+
+// Inspect accepts an inspector function that has same arguments as the Storage.Range
+func (mmRange *mStorageMockRange) Inspect(f func(f func(d model.Device) bool)) *mStorageMockRange {
+	if mmRange.mock.inspectFuncRange != nil {
+		mmRange.mock.t.Fatalf("Inspect function is already set for StorageMock.Range")
+	}
+
+	mmRange.mock.inspectFuncRange = f
+
+	return mmRange
+}
+
+// Return sets up results that will be returned by Storage.Range
+func (mmRange *mStorageMockRange) Return() *StorageMock {
+	if mmRange.mock.funcRange != nil {
+		mmRange.mock.t.Fatalf("StorageMock.Range mock is already set by Set")
+	}
+
+	if mmRange.defaultExpectation == nil {
+		mmRange.defaultExpectation = &StorageMockRangeExpectation{mock: mmRange.mock}
+	}
+
+	return mmRange.mock
+}
+
+// Set uses given function f to mock the Storage.Range method
+func (mmRange *mStorageMockRange) Set(f func(f func(d model.Device) bool)) *StorageMock {
+	if mmRange.defaultExpectation != nil {
+		mmRange.mock.t.Fatalf("Default expectation is already set for the Storage.Range method")
+	}
+
+	if len(mmRange.expectations) > 0 {
+		mmRange.mock.t.Fatalf("Some expectations are already set for the Storage.Range method")
+	}
+
+	mmRange.mock.funcRange = f
+	return mmRange.mock
+}
+
+// Range implements Storage
+func (mmRange *StorageMock) Range(f func(d model.Device) bool) {
+	mm_atomic.AddUint64(&mmRange.beforeRangeCounter, 1)
+	defer mm_atomic.AddUint64(&mmRange.afterRangeCounter, 1)
+
+	if mmRange.inspectFuncRange != nil {
+		mmRange.inspectFuncRange(f)
+	}
+
+	mm_params := &StorageMockRangeParams{f}
+
+	// Record call args
+	mmRange.RangeMock.mutex.Lock()
+	mmRange.RangeMock.callArgs = append(mmRange.RangeMock.callArgs, mm_params)
+	mmRange.RangeMock.mutex.Unlock()
+
+	for _, e := range mmRange.RangeMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return
+		}
+	}
+
+	if mmRange.RangeMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmRange.RangeMock.defaultExpectation.Counter, 1)
+		mm_want := mmRange.RangeMock.defaultExpectation.params
+		mm_got := StorageMockRangeParams{f}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmRange.t.Errorf("StorageMock.Range got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		return
+
+	}
+	if mmRange.funcRange != nil {
+		mmRange.funcRange(f)
+		return
+	}
+	mmRange.t.Fatalf("Unexpected call to StorageMock.Range. %v", f)
+
+}
+
+// RangeAfterCounter returns a count of finished StorageMock.Range invocations
+func (mmRange *StorageMock) RangeAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRange.afterRangeCounter)
+}
+
+// RangeBeforeCounter returns a count of StorageMock.Range invocations
+func (mmRange *StorageMock) RangeBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRange.beforeRangeCounter)
+}
+
+// Calls returns a list of arguments used in each call to StorageMock.Range.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmRange *mStorageMockRange) Calls() []*StorageMockRangeParams {
+	mmRange.mutex.RLock()
+
+	argCopy := make([]*StorageMockRangeParams, len(mmRange.callArgs))
+	copy(argCopy, mmRange.callArgs)
+
+	mmRange.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockRangeDone returns true if the count of the Range invocations corresponds
+// the number of defined expectations
+func (m *StorageMock) MinimockRangeDone() bool {
+	for _, e := range m.RangeMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RangeMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRangeCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRange != nil && mm_atomic.LoadUint64(&m.afterRangeCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockRangeInspect logs each unmet expectation
+func (m *StorageMock) MinimockRangeInspect() {
+	for _, e := range m.RangeMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to StorageMock.Range with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RangeMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRangeCounter) < 1 {
+		if m.RangeMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to StorageMock.Range")
+		} else {
+			m.t.Errorf("Expected call to StorageMock.Range with params: %#v", *m.RangeMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRange != nil && mm_atomic.LoadUint64(&m.afterRangeCounter) < 1 {
+		m.t.Error("Expected call to StorageMock.Range")
+	}
+}
+
+type mStorageMockResourceVersion struct {
+	mock               *StorageMock
+	defaultExpectation *StorageMockResourceVersionExpectation
+	expectations       []*StorageMockResourceVersionExpectation
+}
+
+// StorageMockResourceVersionExpectation specifies expectation struct of the Storage.ResourceVersion
+type StorageMockResourceVersionExpectation struct {
+	mock *StorageMock
+
+	results *StorageMockResourceVersionResults
+	Counter uint64
+}
+
+// StorageMockResourceVersionResults contains results of the Storage.ResourceVersion
+type StorageMockResourceVersionResults struct {
+	u1 uint64
+}
+
+// Expect sets up expected params for Storage.ResourceVersion
+func (mmResourceVersion *mStorageMockResourceVersion) Expect() *mStorageMockResourceVersion {
+	if mmResourceVersion.mock.funcResourceVersion != nil {
+		mmResourceVersion.mock.t.Fatalf("StorageMock.ResourceVersion mock is already set by Set")
+	}
+
+	if mmResourceVersion.defaultExpectation == nil {
+		mmResourceVersion.defaultExpectation = &StorageMockResourceVersionExpectation{}
+	}
+
+	return mmResourceVersion
+}
+
+// Inspect accepts an inspector function that has same arguments as the Storage.ResourceVersion
+func (mmResourceVersion *mStorageMockResourceVersion) Inspect(f func()) *mStorageMockResourceVersion {
+	if mmResourceVersion.mock.inspectFuncResourceVersion != nil {
+		mmResourceVersion.mock.t.Fatalf("Inspect function is already set for StorageMock.ResourceVersion")
+	}
+
+	mmResourceVersion.mock.inspectFuncResourceVersion = f
+
+	return mmResourceVersion
+}
+
+// Return sets up results that will be returned by Storage.ResourceVersion
+func (mmResourceVersion *mStorageMockResourceVersion) Return(u1 uint64) *StorageMock {
+	if mmResourceVersion.mock.funcResourceVersion != nil {
+		mmResourceVersion.mock.t.Fatalf("StorageMock.ResourceVersion mock is already set by Set")
+	}
+
+	if mmResourceVersion.defaultExpectation == nil {
+		mmResourceVersion.defaultExpectation = &StorageMockResourceVersionExpectation{mock: mmResourceVersion.mock}
+	}
+	mmResourceVersion.defaultExpectation.results = &StorageMockResourceVersionResults{u1}
+	return mmResourceVersion.mock
+}
+
+// Set uses given function f to mock the Storage.ResourceVersion method
+func (mmResourceVersion *mStorageMockResourceVersion) Set(f func() (u1 uint64)) *StorageMock {
+	if mmResourceVersion.defaultExpectation != nil {
+		mmResourceVersion.mock.t.Fatalf("Default expectation is already set for the Storage.ResourceVersion method")
+	}
+
+	if len(mmResourceVersion.expectations) > 0 {
+		mmResourceVersion.mock.t.Fatalf("Some expectations are already set for the Storage.ResourceVersion method")
+	}
+
+	mmResourceVersion.mock.funcResourceVersion = f
+	return mmResourceVersion.mock
+}
+
+// ResourceVersion implements Storage
+func (mmResourceVersion *StorageMock) ResourceVersion() (u1 uint64) {
+	mm_atomic.AddUint64(&mmResourceVersion.beforeResourceVersionCounter, 1)
+	defer mm_atomic.AddUint64(&mmResourceVersion.afterResourceVersionCounter, 1)
+
+	if mmResourceVersion.inspectFuncResourceVersion != nil {
+		mmResourceVersion.inspectFuncResourceVersion()
+	}
+
+	if mmResourceVersion.ResourceVersionMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmResourceVersion.ResourceVersionMock.defaultExpectation.Counter, 1)
+
+		mm_results := mmResourceVersion.ResourceVersionMock.defaultExpectation.results
+		if mm_results == nil {
+			mmResourceVersion.t.Fatal("No results are set for the StorageMock.ResourceVersion")
+		}
+		return (*mm_results).u1
+	}
+	if mmResourceVersion.funcResourceVersion != nil {
+		return mmResourceVersion.funcResourceVersion()
+	}
+	mmResourceVersion.t.Fatalf("Unexpected call to StorageMock.ResourceVersion.")
+	return
+}
+
+// ResourceVersionAfterCounter returns a count of finished StorageMock.ResourceVersion invocations
+func (mmResourceVersion *StorageMock) ResourceVersionAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmResourceVersion.afterResourceVersionCounter)
+}
+
+// ResourceVersionBeforeCounter returns a count of StorageMock.ResourceVersion invocations
+func (mmResourceVersion *StorageMock) ResourceVersionBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmResourceVersion.beforeResourceVersionCounter)
+}
+
+// MinimockResourceVersionDone returns true if the count of the ResourceVersion invocations corresponds
+// the number of defined expectations
+func (m *StorageMock) MinimockResourceVersionDone() bool {
+	for _, e := range m.ResourceVersionMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ResourceVersionMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcResourceVersion != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockResourceVersionInspect logs each unmet expectation
+func (m *StorageMock) MinimockResourceVersionInspect() {
+	for _, e := range m.ResourceVersionMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Error("Expected call to StorageMock.ResourceVersion")
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.ResourceVersionMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		m.t.Error("Expected call to StorageMock.ResourceVersion")
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcResourceVersion != nil && mm_atomic.LoadUint64(&m.afterResourceVersionCounter) < 1 {
+		m.t.Error("Expected call to StorageMock.ResourceVersion")
+	}
+}
+
 // MinimockFinish checks that all mocked methods have been called the expected number of times
 func (m *StorageMock) MinimockFinish() {
 	if !m.minimockDone() {
 		m.MinimockAddInspect()
 
+		m.MinimockChangesInspect()
+
 		m.MinimockDelInspect()
 
 		m.MinimockGetInspect()
+
+		m.MinimockRangeInspect()
+
+		m.MinimockResourceVersionInspect()
 		m.t.FailNow()
 	}
 }
@@ -733,6 +1318,9 @@ func (m *StorageMock) minimockDone() bool {
 	done := true
 	return done &&
 		m.MinimockAddDone() &&
+		m.MinimockChangesDone() &&
 		m.MinimockDelDone() &&
-		m.MinimockGetDone()
+		m.MinimockGetDone() &&
+		m.MinimockRangeDone() &&
+		m.MinimockResourceVersionDone()
 }
@@ -0,0 +1,63 @@
+package shadow
+
+import "reflect"
+
+// Diff returns the fields of desired that differ from reported. Nested objects
+// are compared recursively; a nil desired value means the field must be absent.
+// Diff returns nil when there is nothing to apply.
+func Diff(desired, reported map[string]any) map[string]any {
+	var delta map[string]any
+	for k, want := range desired {
+		got, ok := reported[k]
+		if want == nil {
+			if !ok {
+				continue
+			}
+		} else if ok {
+			wantObj, wantIsObj := want.(map[string]any)
+			gotObj, gotIsObj := got.(map[string]any)
+			if wantIsObj && gotIsObj {
+				if sub := Diff(wantObj, gotObj); sub != nil {
+					delta = set(delta, k, sub)
+				}
+				continue
+			}
+			if reflect.DeepEqual(want, got) {
+				continue
+			}
+		}
+		delta = set(delta, k, want)
+	}
+	return delta
+}
+
+// Merge applies patch to doc and returns the result. Nil values in patch remove fields.
+// doc is not modified.
+func Merge(doc, patch map[string]any) map[string]any {
+	res := make(map[string]any, len(doc))
+	for k, v := range doc {
+		res[k] = v
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(res, k)
+			continue
+		}
+		if patchObj, ok := v.(map[string]any); ok {
+			docObj, _ := res[k].(map[string]any)
+			res[k] = Merge(docObj, patchObj)
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
+
+func set(m map[string]any, k string, v any) map[string]any {
+	if m == nil {
+		m = make(map[string]any)
+	}
+	m[k] = v
+	return m
+}
@@ -0,0 +1,190 @@
+package shadow
+
+import (
+	"errors"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const EventDeltaChanged = "shadow.delta"
+
+var ErrVersionConflict = errors.New("shadow version conflict")
+
+type Service interface {
+	GetShadow(num string) (model.Shadow, error)
+	// SetDesired replaces the desired document. A non-zero version must match the current one.
+	SetDesired(num string, doc map[string]any, version int) (model.Shadow, error)
+	// SetReported replaces the reported document. A non-zero version must match the current one.
+	SetReported(num string, doc map[string]any, version int) (model.Shadow, error)
+	GetDelta(num string) (model.Delta, error)
+	// AckDelta marks the delta computed from the given desired version as applied by the device.
+	AckDelta(num string, version int) (model.Shadow, error)
+	// LastReported returns when the device last set or acknowledged its reported document.
+	LastReported(num string) (time.Time, bool)
+	// TrackedSince returns when the service started. Shadows are kept in memory,
+	// so devices may have reported before then.
+	TrackedSince() time.Time
+}
+
+type Option func(*shadowService)
+
+// WithClock replaces time.Now for report timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(s *shadowService) {
+		s.now = now
+	}
+}
+
+func NewService(devices service.Service, bus *events.Bus, options ...Option) Service {
+	s := &shadowService{devices: devices, bus: bus, now: time.Now, shadows: make(map[string]entry)}
+	for _, option := range options {
+		option(s)
+	}
+	s.started = s.now().UTC()
+	return s
+}
+
+type shadowService struct {
+	devices service.Service
+	bus     *events.Bus
+	now     func() time.Time
+	started time.Time
+
+	mu      sync.Mutex
+	shadows map[string]entry
+}
+
+// entry is a stored shadow and the creation time of its device, so a device
+// deleted and created again under the same serial number starts with a new shadow.
+type entry struct {
+	model.Shadow
+	created time.Time
+}
+
+func (s *shadowService) GetShadow(num string) (model.Shadow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.load(num)
+	if err != nil {
+		return model.Shadow{}, err
+	}
+	sh := e.Shadow
+	sh.Delta = Diff(sh.Desired, sh.Reported)
+	return sh, nil
+}
+
+func (s *shadowService) SetDesired(num string, doc map[string]any, version int) (model.Shadow, error) {
+	return s.update(num, func(sh *model.Shadow) error {
+		if version != 0 && version != sh.DesiredVersion {
+			return ErrVersionConflict
+		}
+		sh.Desired = doc
+		sh.DesiredVersion++
+		return nil
+	})
+}
+
+func (s *shadowService) SetReported(num string, doc map[string]any, version int) (model.Shadow, error) {
+	return s.update(num, func(sh *model.Shadow) error {
+		if version != 0 && version != sh.ReportedVersion {
+			return ErrVersionConflict
+		}
+		sh.Reported = doc
+		sh.ReportedVersion++
+		sh.ReportedAt = s.reportedAt()
+		return nil
+	})
+}
+
+func (s *shadowService) GetDelta(num string) (model.Delta, error) {
+	sh, err := s.GetShadow(num)
+	if err != nil {
+		return model.Delta{}, err
+	}
+	return model.Delta{Version: sh.DesiredVersion, State: sh.Delta}, nil
+}
+
+func (s *shadowService) AckDelta(num string, version int) (model.Shadow, error) {
+	return s.update(num, func(sh *model.Shadow) error {
+		if version != sh.DesiredVersion {
+			return ErrVersionConflict
+		}
+		sh.Reported = Merge(sh.Reported, Diff(sh.Desired, sh.Reported))
+		sh.ReportedVersion++
+		sh.ReportedAt = s.reportedAt()
+		return nil
+	})
+}
+
+func (s *shadowService) LastReported(num string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.load(num)
+	if err != nil || e.ReportedAt == nil {
+		return time.Time{}, false
+	}
+	return *e.ReportedAt, true
+}
+
+func (s *shadowService) TrackedSince() time.Time {
+	return s.started
+}
+
+// update applies f to the shadow of num and publishes an event if the delta changed.
+func (s *shadowService) update(num string, f func(sh *model.Shadow) error) (model.Shadow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.load(num)
+	if err != nil {
+		return model.Shadow{}, err
+	}
+	before := Diff(e.Desired, e.Reported)
+
+	if err := f(&e.Shadow); err != nil {
+		return model.Shadow{}, err
+	}
+	s.shadows[num] = e
+
+	sh := e.Shadow
+	sh.Delta = Diff(sh.Desired, sh.Reported)
+	if !reflect.DeepEqual(before, sh.Delta) && s.bus != nil {
+		s.bus.Publish(events.Event{
+			Type:   EventDeltaChanged,
+			Serial: num,
+			Data:   model.Delta{Version: sh.DesiredVersion, State: sh.Delta},
+		})
+	}
+	return sh, nil
+}
+
+func (s *shadowService) reportedAt() *time.Time {
+	t := s.now().UTC()
+	return &t
+}
+
+// load returns the stored shadow of an existing device, or a new one if the device
+// was created after it. Shadows of deleted devices are dropped.
+func (s *shadowService) load(num string) (entry, error) {
+	d, err := s.devices.GetDevice(num)
+	if err != nil {
+		delete(s.shadows, num)
+		return entry{}, err
+	}
+
+	var created time.Time
+	if d.CreatedAt != nil {
+		created = *d.CreatedAt
+	}
+	e, ok := s.shadows[num]
+	if !ok || !e.created.Equal(created) {
+		e = entry{Shadow: model.Shadow{Desired: map[string]any{}, Reported: map[string]any{}}, created: created}
+	}
+	return e, nil
+}
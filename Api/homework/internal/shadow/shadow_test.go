@@ -0,0 +1,166 @@
+package shadow
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework/internal/events"
+	"homework/internal/model"
+	"homework/internal/service"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T) (Service, *events.Bus) {
+	devices := service.NewService(service.NewStorage())
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}))
+
+	bus := events.NewBus()
+	return NewService(devices, bus), bus
+}
+
+func TestDiff(t *testing.T) {
+	desired := map[string]any{
+		"ntp":  "pool.ntp.org",
+		"vlan": map[string]any{"id": 10.0, "name": "lab"},
+		"snmp": nil,
+	}
+	reported := map[string]any{
+		"ntp":    "pool.ntp.org",
+		"vlan":   map[string]any{"id": 20.0, "name": "lab"},
+		"snmp":   "public",
+		"uptime": 42.0,
+	}
+
+	want := map[string]any{
+		"vlan": map[string]any{"id": 10.0},
+		"snmp": nil,
+	}
+	assert.Equal(t, want, Diff(desired, reported))
+	assert.Nil(t, Diff(reported, reported))
+}
+
+func TestMerge(t *testing.T) {
+	doc := map[string]any{"a": 1.0, "b": map[string]any{"c": 2.0, "d": 3.0}}
+	patch := map[string]any{"a": nil, "b": map[string]any{"c": 4.0}, "e": map[string]any{"f": nil, "g": 5.0}}
+
+	want := map[string]any{"b": map[string]any{"c": 4.0, "d": 3.0}, "e": map[string]any{"g": 5.0}}
+	assert.Equal(t, want, Merge(doc, patch))
+	assert.Equal(t, 1.0, doc["a"])
+}
+
+func TestShadowDeltaAndAck(t *testing.T) {
+	s, bus := newTestService(t)
+	ch, cancel := bus.Subscribe()
+	defer cancel()
+
+	sh, err := s.SetDesired("1", map[string]any{"ntp": "pool.ntp.org", "mtu": 9000.0}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sh.DesiredVersion)
+
+	_, err = s.SetReported("1", map[string]any{"mtu": 9000.0, "uptime": 1.0}, 0)
+	assert.Nil(t, err)
+
+	delta, err := s.GetDelta("1")
+	assert.Nil(t, err)
+	assert.Equal(t, model.Delta{Version: 1, State: map[string]any{"ntp": "pool.ntp.org"}}, delta)
+
+	_, err = s.AckDelta("1", 2)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	sh, err = s.AckDelta("1", 1)
+	assert.Nil(t, err)
+	assert.Nil(t, sh.Delta)
+	assert.Equal(t, 2, sh.ReportedVersion)
+	assert.Equal(t, map[string]any{"ntp": "pool.ntp.org", "mtu": 9000.0, "uptime": 1.0}, sh.Reported)
+
+	var deltas []model.Delta
+	for len(deltas) < 3 {
+		select {
+		case e := <-ch:
+			assert.Equal(t, EventDeltaChanged, e.Type)
+			assert.Equal(t, "1", e.Serial)
+			deltas = append(deltas, e.Data.(model.Delta))
+		case <-time.After(time.Second):
+			t.Fatal("delta event wasn't published")
+		}
+	}
+	assert.Len(t, deltas[0].State, 2)
+	assert.Len(t, deltas[1].State, 1)
+	assert.Nil(t, deltas[2].State)
+}
+
+func TestShadowVersionConflict(t *testing.T) {
+	s, _ := newTestService(t)
+
+	_, err := s.SetDesired("1", map[string]any{"mtu": 1500.0}, 0)
+	assert.Nil(t, err)
+
+	_, err = s.SetDesired("1", map[string]any{"mtu": 9000.0}, 5)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	sh, err := s.SetDesired("1", map[string]any{"mtu": 9000.0}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sh.DesiredVersion)
+}
+
+func TestShadowUnknownDevice(t *testing.T) {
+	s, _ := newTestService(t)
+
+	_, err := s.GetShadow("000")
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+
+	_, err = s.SetDesired("000", map[string]any{}, 0)
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+}
+
+func TestLastReported(t *testing.T) {
+	devices := service.NewService(service.NewStorage())
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewService(devices, nil, WithClock(func() time.Time { return now }))
+	assert.Equal(t, now, s.TrackedSince())
+
+	_, ok := s.LastReported("1")
+	assert.False(t, ok)
+	_, err := s.SetDesired("1", map[string]any{"ntp": "pool.ntp.org"}, 0)
+	assert.Nil(t, err)
+	_, ok = s.LastReported("1")
+	assert.False(t, ok, "desired changes aren't heartbeats")
+
+	now = now.Add(time.Minute)
+	_, err = s.SetReported("1", map[string]any{}, 0)
+	assert.Nil(t, err)
+	last, ok := s.LastReported("1")
+	assert.True(t, ok)
+	assert.Equal(t, now, last)
+
+	now = now.Add(time.Minute)
+	_, err = s.AckDelta("1", 1)
+	assert.Nil(t, err)
+	last, _ = s.LastReported("1")
+	assert.Equal(t, now, last)
+}
+
+func TestShadowRecreatedDevice(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	devices := service.NewService(service.NewStorage(), service.WithClock(func() time.Time { return now }))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}))
+	s := NewService(devices, nil)
+
+	_, err := s.SetDesired("1", map[string]any{"ntp": "pool.ntp.org"}, 0)
+	assert.Nil(t, err)
+	_, err = s.SetReported("1", map[string]any{}, 0)
+	assert.Nil(t, err)
+
+	// deleted and created again without the shadow being read in between
+	assert.Nil(t, devices.DeleteDevice("1"))
+	now = now.Add(time.Second)
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "1", Model: "model1", IP: "1.1.1.1"}))
+
+	_, ok := s.LastReported("1")
+	assert.False(t, ok)
+	sh, err := s.GetShadow("1")
+	assert.Nil(t, err)
+	assert.Empty(t, sh.Desired)
+	assert.Zero(t, sh.DesiredVersion)
+	assert.Zero(t, sh.ReportedVersion)
+}
@@ -0,0 +1,80 @@
+package topology
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"io/fs"
+	"os"
+)
+
+// Snapshot persists the relationships as a JSON file, rewritten on every change,
+// so they survive restarts.
+type Snapshot struct {
+	path  string
+	state snapshotState
+}
+
+type snapshotState struct {
+	LastID       int                 `json:"last_id"`
+	Links        []storedLink        `json:"links"`
+	Containments []storedContainment `json:"containments"`
+}
+
+// storedLink and storedContainment carry the resource version the relationship
+// was made at, so deletions seen later only drop relationships made before them.
+type storedLink struct {
+	model.Link
+	ResourceVersion uint64 `json:"rv"`
+}
+
+type storedContainment struct {
+	model.Containment
+	ResourceVersion uint64 `json:"rv"`
+}
+
+// OpenSnapshot reads the relationships saved at path, if there are any.
+func OpenSnapshot(path string) (*Snapshot, error) {
+	s := &Snapshot{path: path}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &s.state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// save replaces the file atomically with state.
+func (s *Snapshot) save(state snapshotState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(raw)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
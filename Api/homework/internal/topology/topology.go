@@ -0,0 +1,651 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/model"
+	"homework/internal/service"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrLinkDoesNotExist   = errors.New("link doesn't exist")
+	ErrInvalidLink        = errors.New("invalid link")
+	ErrPortInUse          = errors.New("port is already linked")
+	ErrInvalidContainment = errors.New("invalid containment")
+	ErrContainmentCycle   = errors.New("containment would form a cycle")
+	ErrSlotInUse          = errors.New("slot is already occupied")
+	ErrNotContained       = errors.New("device has no parent")
+	ErrDeviceInUse        = errors.New("device has links or contains devices")
+	ErrNoPath             = errors.New("no path between devices")
+)
+
+// DeletePolicy decides what deleting a device with relationships does.
+type DeletePolicy string
+
+const (
+	// DeleteBlock refuses to delete a device that has links or contains devices.
+	DeleteBlock DeletePolicy = "block"
+	// DeleteCascade deletes the devices it contains too, and the links of all of them.
+	DeleteCascade DeletePolicy = "cascade"
+)
+
+var linkTypes = map[model.LinkType]bool{
+	model.LinkEthernet: true,
+	model.LinkFiber:    true,
+	model.LinkSerial:   true,
+	model.LinkPower:    true,
+}
+
+type Service interface {
+	CreateLink(l model.Link) (model.Link, error)
+	DeleteLink(id string) error
+	// ListLinks returns the links of a device, or every link if num is empty.
+	ListLinks(num string) ([]model.Link, error)
+	// SetParent places the child in the parent, moving it out of its current parent.
+	SetParent(c model.Containment) error
+	RemoveParent(child string) error
+	Neighbors(num string) ([]model.Neighbor, error)
+	// Path returns the serial numbers on a shortest path between two devices,
+	// over links and containment.
+	Path(from, to string) ([]string, error)
+	Subtree(num string) (model.TopologyNode, error)
+	// DOT renders devices and their relationships as a Graphviz graph.
+	DOT() (string, error)
+	// DeleteDevice deletes num with del according to the delete policy and drops
+	// the relationships of every deleted device. check, if not nil, is called with every
+	// device to delete before any is. Use Guard rather than calling it directly.
+	DeleteDevice(num string, del func(num string) error, check func(nums ...string) error) error
+	// Forget drops every relationship of a device deleted without DeleteDevice.
+	Forget(num string)
+	// Watch drops the relationships of devices deleted without DeleteDevice, such as
+	// by replication, restores or retention, until ctx is done.
+	Watch(ctx context.Context)
+}
+
+// DeleteChecker is implemented by services that may refuse to delete devices, such as
+// freeze guards. Guard checks every device of a cascade with it before deleting any.
+type DeleteChecker interface {
+	CheckDelete(nums ...string) error
+}
+
+type Option func(*topologyService)
+
+// WithSnapshot loads the relationships from snap and saves every change to it.
+func WithSnapshot(snap *Snapshot) Option {
+	return func(s *topologyService) {
+		s.snapshot = snap
+	}
+}
+
+// NewService returns the topology of devices. Loaded relationships of devices
+// that no longer exist are dropped.
+func NewService(devices service.Service, policy DeletePolicy, options ...Option) Service {
+	if policy == "" {
+		policy = DeleteBlock
+	}
+	s := &topologyService{
+		devices:  devices,
+		policy:   policy,
+		links:    make(map[string]model.Link),
+		ports:    make(map[model.Endpoint]string),
+		parents:  make(map[string]model.Containment),
+		children: make(map[string]map[string]bool),
+		since:    make(map[string]uint64),
+		placed:   make(map[string]uint64),
+		rv:       devices.ResourceVersion(),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	if s.snapshot != nil {
+		s.load(s.snapshot.state)
+		s.prune()
+	}
+	return s
+}
+
+type topologyService struct {
+	devices  service.Service
+	policy   DeletePolicy
+	snapshot *Snapshot
+
+	mu       sync.RWMutex
+	links    map[string]model.Link
+	ports    map[model.Endpoint]string
+	parents  map[string]model.Containment
+	children map[string]map[string]bool
+	lastID   int
+	// since and placed are the resource versions links and containments, by child, were made at.
+	since  map[string]uint64
+	placed map[string]uint64
+	// rv is the resource version Watch starts after.
+	rv uint64
+}
+
+func (s *topologyService) CreateLink(l model.Link) (model.Link, error) {
+	if !linkTypes[l.Type] || l.A.SerialNum == "" || l.B.SerialNum == "" || l.A.Port == "" || l.B.Port == "" || l.A == l.B {
+		return model.Link{}, ErrInvalidLink
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// taken before the check, so a concurrent delete is always newer than the link
+	rv := s.devices.ResourceVersion()
+	if err := s.exist(l.A.SerialNum, l.B.SerialNum); err != nil {
+		return model.Link{}, err
+	}
+	for _, e := range []model.Endpoint{l.A, l.B} {
+		if _, ok := s.ports[e]; ok {
+			return model.Link{}, fmt.Errorf("%w: %s %s", ErrPortInUse, e.SerialNum, e.Port)
+		}
+	}
+
+	s.lastID++
+	l.ID = strconv.Itoa(s.lastID)
+	s.links[l.ID] = l
+	s.ports[l.A] = l.ID
+	s.ports[l.B] = l.ID
+	s.since[l.ID] = rv
+	s.save()
+	return l, nil
+}
+
+func (s *topologyService) DeleteLink(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.links[id]
+	if !ok {
+		return ErrLinkDoesNotExist
+	}
+	s.unlink(l)
+	s.save()
+	return nil
+}
+
+func (s *topologyService) ListLinks(num string) ([]model.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if num != "" {
+		if err := s.exist(num); err != nil {
+			return nil, err
+		}
+	}
+	links := make([]model.Link, 0)
+	for _, l := range s.links {
+		if num == "" || l.A.SerialNum == num || l.B.SerialNum == num {
+			links = append(links, l)
+		}
+	}
+	sortLinks(links)
+	return links, nil
+}
+
+func (s *topologyService) SetParent(c model.Containment) error {
+	if c.Parent == "" || c.Child == "" || c.Parent == c.Child {
+		return ErrInvalidContainment
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rv := s.devices.ResourceVersion()
+	if err := s.exist(c.Parent, c.Child); err != nil {
+		return err
+	}
+	for p := c.Parent; p != ""; p = s.parents[p].Parent {
+		if p == c.Child {
+			return ErrContainmentCycle
+		}
+	}
+	if c.Slot != "" {
+		for sibling := range s.children[c.Parent] {
+			if sibling != c.Child && s.parents[sibling].Slot == c.Slot {
+				return fmt.Errorf("%w: %s", ErrSlotInUse, c.Slot)
+			}
+		}
+	}
+
+	s.detach(c.Child)
+	s.place(c, rv)
+	s.save()
+	return nil
+}
+
+func (s *topologyService) RemoveParent(child string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.parents[child]; !ok {
+		return ErrNotContained
+	}
+	s.detach(child)
+	s.save()
+	return nil
+}
+
+func (s *topologyService) Neighbors(num string) ([]model.Neighbor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.exist(num); err != nil {
+		return nil, err
+	}
+
+	neighbors := make([]model.Neighbor, 0)
+	if c, ok := s.parents[num]; ok {
+		neighbors = append(neighbors, model.Neighbor{SerialNum: c.Parent, Relation: model.RelationParent, Slot: c.Slot})
+	}
+	for _, child := range s.sortedChildren(num) {
+		neighbors = append(neighbors, model.Neighbor{SerialNum: child, Relation: model.RelationChild, Slot: s.parents[child].Slot})
+	}
+
+	var links []model.Link
+	for _, l := range s.links {
+		if l.A.SerialNum == num || l.B.SerialNum == num {
+			links = append(links, l)
+		}
+	}
+	sortLinks(links)
+	for i := range links {
+		other := links[i].B.SerialNum
+		if other == num {
+			other = links[i].A.SerialNum
+		}
+		neighbors = append(neighbors, model.Neighbor{SerialNum: other, Relation: model.RelationLink, Link: &links[i]})
+	}
+	return neighbors, nil
+}
+
+func (s *topologyService) Path(from, to string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.exist(from, to); err != nil {
+		return nil, err
+	}
+
+	adjacent := s.adjacency()
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == to {
+			var path []string
+			for n := to; n != ""; n = prev[n] {
+				path = append([]string{n}, path...)
+			}
+			return path, nil
+		}
+		for _, next := range adjacent[cur] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil, ErrNoPath
+}
+
+func (s *topologyService) Subtree(num string) (model.TopologyNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.exist(num); err != nil {
+		return model.TopologyNode{}, err
+	}
+	return s.subtree(num), nil
+}
+
+func (s *topologyService) DOT() (string, error) {
+	devices, err := s.devices.ListDevices(service.Filter{})
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	for _, d := range devices {
+		fmt.Fprintf(&b, "\t%s [label=%s];\n", quote(d.SerialNum), quote(d.SerialNum+"\n"+d.Model))
+	}
+
+	containments := make([]model.Containment, 0, len(s.parents))
+	for _, c := range s.parents {
+		containments = append(containments, c)
+	}
+	sort.Slice(containments, func(i, j int) bool { return containments[i].Child < containments[j].Child })
+	for _, c := range containments {
+		fmt.Fprintf(&b, "\t%s -- %s [dir=forward, style=dashed", quote(c.Parent), quote(c.Child))
+		if c.Slot != "" {
+			fmt.Fprintf(&b, ", label=%s", quote("slot "+c.Slot))
+		}
+		b.WriteString("];\n")
+	}
+
+	links := make([]model.Link, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l)
+	}
+	sortLinks(links)
+	for _, l := range links {
+		fmt.Fprintf(&b, "\t%s -- %s [label=%s, taillabel=%s, headlabel=%s];\n",
+			quote(l.A.SerialNum), quote(l.B.SerialNum), quote(string(l.Type)), quote(l.A.Port), quote(l.B.Port))
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+func (s *topologyService) DeleteDevice(num string, del func(num string) error, check func(nums ...string) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.exist(num); err != nil {
+		return err
+	}
+
+	targets := []string{num}
+	switch s.policy {
+	case DeleteCascade:
+		targets = s.postOrder(num)
+	default:
+		if len(s.children[num]) > 0 || s.linked(num) {
+			return ErrDeviceInUse
+		}
+	}
+	if check != nil {
+		if err := check(targets...); err != nil {
+			return err
+		}
+	}
+
+	// contained devices go first, so a failure never leaves them without their parent
+	defer s.save()
+	for _, t := range targets {
+		if err := del(t); err != nil && (t == num || !errors.Is(err, service.ErrDeviceDoesNotExist)) {
+			return err
+		}
+		s.forget(t, math.MaxUint64)
+	}
+	return nil
+}
+
+func (s *topologyService) Forget(num string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forget(num, math.MaxUint64)
+	s.save()
+}
+
+func (s *topologyService) Watch(ctx context.Context) {
+	s.mu.RLock()
+	rv := s.rv
+	s.mu.RUnlock()
+
+	for {
+		changes, next, err := s.devices.Changes(rv)
+		if err != nil {
+			// the deletions are gone from the history, so check which devices are
+			slog.Warn("topology missed device changes", slog.Any("error", err))
+			rv = s.devices.ResourceVersion()
+			s.mu.Lock()
+			s.prune()
+			s.mu.Unlock()
+			continue
+		}
+
+		s.mu.Lock()
+		n := len(s.links) + len(s.parents)
+		for _, c := range changes {
+			if c.Type == model.ChangeDeleted {
+				s.forget(c.Device.SerialNum, c.ResourceVersion)
+			}
+			rv = c.ResourceVersion
+		}
+		s.rv = rv
+		if len(s.links)+len(s.parents) != n {
+			s.save()
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-next:
+		}
+	}
+}
+
+// exist returns the error of the first serial number that isn't a device.
+func (s *topologyService) exist(nums ...string) error {
+	for _, num := range nums {
+		if _, err := s.devices.GetDevice(num); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *topologyService) linked(num string) bool {
+	for _, l := range s.links {
+		if l.A.SerialNum == num || l.B.SerialNum == num {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *topologyService) unlink(l model.Link) {
+	delete(s.links, l.ID)
+	delete(s.ports, l.A)
+	delete(s.ports, l.B)
+	delete(s.since, l.ID)
+}
+
+func (s *topologyService) place(c model.Containment, rv uint64) {
+	s.parents[c.Child] = c
+	if s.children[c.Parent] == nil {
+		s.children[c.Parent] = make(map[string]bool)
+	}
+	s.children[c.Parent][c.Child] = true
+	s.placed[c.Child] = rv
+}
+
+// detach removes child from its parent, if it has one.
+func (s *topologyService) detach(child string) {
+	c, ok := s.parents[child]
+	if !ok {
+		return
+	}
+	delete(s.parents, child)
+	delete(s.placed, child)
+	delete(s.children[c.Parent], child)
+	if len(s.children[c.Parent]) == 0 {
+		delete(s.children, c.Parent)
+	}
+}
+
+// forget drops the relationships of a device deleted at resource version rv
+// that were made before it.
+func (s *topologyService) forget(num string, rv uint64) {
+	for _, l := range s.links {
+		if (l.A.SerialNum == num || l.B.SerialNum == num) && s.since[l.ID] < rv {
+			s.unlink(l)
+		}
+	}
+	if _, ok := s.parents[num]; ok && s.placed[num] < rv {
+		s.detach(num)
+	}
+	for child := range s.children[num] {
+		if s.placed[child] < rv {
+			s.detach(child)
+		}
+	}
+}
+
+// prune drops every relationship of devices that no longer exist.
+func (s *topologyService) prune() {
+	gone := make(map[string]bool)
+	check := func(num string) {
+		if _, ok := gone[num]; !ok {
+			_, err := s.devices.GetDevice(num)
+			gone[num] = errors.Is(err, service.ErrDeviceDoesNotExist)
+		}
+	}
+	for _, l := range s.links {
+		check(l.A.SerialNum)
+		check(l.B.SerialNum)
+	}
+	for child, c := range s.parents {
+		check(child)
+		check(c.Parent)
+	}
+	for num := range gone {
+		if gone[num] {
+			s.forget(num, math.MaxUint64)
+		}
+	}
+	s.save()
+}
+
+func (s *topologyService) load(state snapshotState) {
+	s.lastID = state.LastID
+	for _, l := range state.Links {
+		s.links[l.ID] = l.Link
+		s.ports[l.A] = l.ID
+		s.ports[l.B] = l.ID
+		s.since[l.ID] = l.ResourceVersion
+	}
+	for _, c := range state.Containments {
+		s.place(c.Containment, c.ResourceVersion)
+	}
+}
+
+// save writes the relationships to the snapshot, if there is one. Failures are
+// logged, the relationships stay in memory.
+func (s *topologyService) save() {
+	if s.snapshot == nil {
+		return
+	}
+	state := snapshotState{LastID: s.lastID, Links: make([]storedLink, 0, len(s.links)), Containments: make([]storedContainment, 0, len(s.parents))}
+	for id, l := range s.links {
+		state.Links = append(state.Links, storedLink{Link: l, ResourceVersion: s.since[id]})
+	}
+	for child, c := range s.parents {
+		state.Containments = append(state.Containments, storedContainment{Containment: c, ResourceVersion: s.placed[child]})
+	}
+	if err := s.snapshot.save(state); err != nil {
+		slog.Error("topology snapshot write failed", slog.String("path", s.snapshot.path), slog.Any("error", err))
+	}
+}
+
+func (s *topologyService) sortedChildren(num string) []string {
+	children := make([]string, 0, len(s.children[num]))
+	for child := range s.children[num] {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	return children
+}
+
+func (s *topologyService) subtree(num string) model.TopologyNode {
+	node := model.TopologyNode{SerialNum: num, Slot: s.parents[num].Slot}
+	for _, child := range s.sortedChildren(num) {
+		node.Children = append(node.Children, s.subtree(child))
+	}
+	return node
+}
+
+// postOrder returns num and every device it contains, contained devices first.
+func (s *topologyService) postOrder(num string) []string {
+	var order []string
+	for _, child := range s.sortedChildren(num) {
+		order = append(order, s.postOrder(child)...)
+	}
+	return append(order, num)
+}
+
+// adjacency returns the sorted neighbors of every device over links and containment.
+func (s *topologyService) adjacency() map[string][]string {
+	sets := make(map[string]map[string]bool)
+	connect := func(a, b string) {
+		for _, p := range [][2]string{{a, b}, {b, a}} {
+			if sets[p[0]] == nil {
+				sets[p[0]] = make(map[string]bool)
+			}
+			sets[p[0]][p[1]] = true
+		}
+	}
+	for _, l := range s.links {
+		if l.A.SerialNum != l.B.SerialNum {
+			connect(l.A.SerialNum, l.B.SerialNum)
+		}
+	}
+	for child, c := range s.parents {
+		connect(c.Parent, child)
+	}
+
+	adjacent := make(map[string][]string, len(sets))
+	for n, set := range sets {
+		for m := range set {
+			adjacent[n] = append(adjacent[n], m)
+		}
+		sort.Strings(adjacent[n])
+	}
+	return adjacent
+}
+
+// sortLinks sorts links by their numeric IDs.
+func sortLinks(links []model.Link) {
+	sort.Slice(links, func(i, j int) bool {
+		a, _ := strconv.Atoi(links[i].ID)
+		b, _ := strconv.Atoi(links[j].ID)
+		return a < b
+	})
+}
+
+// quote makes a DOT string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// guardedService deletes devices through the topology, so relationships
+// never refer to deleted devices.
+type guardedService struct {
+	service.Service
+	topology Service
+}
+
+// Guard decorates devices so that DeleteDevice enforces the delete policy of t.
+// Every device of a cascade is deleted through devices, checked first if devices
+// is a DeleteChecker. t must be built on the undecorated service, or deletes would loop.
+func Guard(devices service.Service, t Service) service.Service {
+	return &guardedService{Service: devices, topology: t}
+}
+
+func (s *guardedService) DeleteDevice(num string) error {
+	var check func(nums ...string) error
+	if c, ok := s.Service.(DeleteChecker); ok {
+		check = c.CheckDelete
+	}
+	return s.topology.DeleteDevice(num, s.Service.DeleteDevice, check)
+}
+
+// WithContext binds the decorated service to ctx.
+func (s *guardedService) WithContext(ctx context.Context) service.Service {
+	bound := *s
+	bound.Service = service.Bind(ctx, s.Service)
+	return &bound
+}
@@ -0,0 +1,195 @@
+package topology
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/model"
+	"homework/internal/service"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestService builds chassis "c" with line cards "c1" and "c2", switches
+// "s1" and "s2", and links c1:eth0 - s1:eth0 and s1:eth1 - s2:eth0.
+func newTestService(t *testing.T, policy DeletePolicy) (Service, service.Service) {
+	devices := service.NewService(service.NewStorage())
+	for _, num := range []string{"c", "c1", "c2", "s1", "s2", "x"} {
+		assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: num, Model: "model", IP: "1.1.1.1"}))
+	}
+
+	s := NewService(devices, policy)
+	assert.Nil(t, s.SetParent(model.Containment{Parent: "c", Child: "c1", Slot: "1"}))
+	assert.Nil(t, s.SetParent(model.Containment{Parent: "c", Child: "c2", Slot: "2"}))
+	_, err := s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "c1", Port: "eth0"}, B: model.Endpoint{SerialNum: "s1", Port: "eth0"}})
+	assert.Nil(t, err)
+	_, err = s.CreateLink(model.Link{Type: model.LinkFiber, A: model.Endpoint{SerialNum: "s1", Port: "eth1"}, B: model.Endpoint{SerialNum: "s2", Port: "eth0"}})
+	assert.Nil(t, err)
+	return s, Guard(devices, s)
+}
+
+func TestValidation(t *testing.T) {
+	s, _ := newTestService(t, DeleteBlock)
+
+	_, err := s.CreateLink(model.Link{Type: "carrier pigeon", A: model.Endpoint{SerialNum: "s1", Port: "a"}, B: model.Endpoint{SerialNum: "s2", Port: "b"}})
+	assert.ErrorIs(t, err, ErrInvalidLink)
+	_, err = s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "s1", Port: "eth0"}, B: model.Endpoint{SerialNum: "s2", Port: "eth9"}})
+	assert.ErrorIs(t, err, ErrPortInUse)
+	_, err = s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "s1", Port: "eth9"}, B: model.Endpoint{SerialNum: "nope", Port: "eth0"}})
+	assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+
+	assert.ErrorIs(t, s.SetParent(model.Containment{Parent: "c1", Child: "c"}), ErrContainmentCycle)
+	assert.ErrorIs(t, s.SetParent(model.Containment{Parent: "c", Child: "x", Slot: "1"}), ErrSlotInUse)
+	assert.ErrorIs(t, s.SetParent(model.Containment{Parent: "x", Child: "x"}), ErrInvalidContainment)
+	assert.ErrorIs(t, s.RemoveParent("x"), ErrNotContained)
+	assert.ErrorIs(t, s.DeleteLink("42"), ErrLinkDoesNotExist)
+}
+
+func TestQueries(t *testing.T) {
+	s, _ := newTestService(t, DeleteBlock)
+
+	neighbors, err := s.Neighbors("s1")
+	assert.Nil(t, err)
+	assert.Len(t, neighbors, 2)
+	assert.Equal(t, "c1", neighbors[0].SerialNum)
+	assert.Equal(t, "eth0", neighbors[0].Link.A.Port)
+
+	neighbors, err = s.Neighbors("c1")
+	assert.Nil(t, err)
+	assert.Equal(t, model.Neighbor{SerialNum: "c", Relation: model.RelationParent, Slot: "1"}, neighbors[0])
+
+	path, err := s.Path("c2", "s2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c2", "c", "c1", "s1", "s2"}, path)
+	_, err = s.Path("s2", "x")
+	assert.ErrorIs(t, err, ErrNoPath)
+
+	tree, err := s.Subtree("c")
+	assert.Nil(t, err)
+	assert.Equal(t, model.TopologyNode{SerialNum: "c", Children: []model.TopologyNode{
+		{SerialNum: "c1", Slot: "1"},
+		{SerialNum: "c2", Slot: "2"},
+	}}, tree)
+
+	links, err := s.ListLinks("s1")
+	assert.Nil(t, err)
+	assert.Len(t, links, 2)
+
+	dot, err := s.DOT()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(dot, "graph topology {\n"))
+	assert.Contains(t, dot, "\t\"c\" -- \"c1\" [dir=forward, style=dashed, label=\"slot 1\"];\n")
+	assert.Contains(t, dot, "\t\"s1\" -- \"s2\" [label=\"fiber\", taillabel=\"eth1\", headlabel=\"eth0\"];\n")
+}
+
+func TestDeleteBlock(t *testing.T) {
+	s, devices := newTestService(t, DeleteBlock)
+
+	assert.ErrorIs(t, devices.DeleteDevice("c"), ErrDeviceInUse)
+	assert.ErrorIs(t, devices.DeleteDevice("s2"), ErrDeviceInUse)
+
+	// a contained device without links only leaves its parent
+	assert.Nil(t, devices.DeleteDevice("c2"))
+	tree, _ := s.Subtree("c")
+	assert.Len(t, tree.Children, 1)
+
+	links, _ := s.ListLinks("s2")
+	assert.Nil(t, s.DeleteLink(links[0].ID))
+	assert.Nil(t, devices.DeleteDevice("s2"))
+}
+
+func TestDeleteCascade(t *testing.T) {
+	s, devices := newTestService(t, DeleteCascade)
+
+	assert.Nil(t, devices.DeleteDevice("c"))
+	for _, num := range []string{"c", "c1", "c2"} {
+		_, err := devices.GetDevice(num)
+		assert.ErrorIs(t, err, service.ErrDeviceDoesNotExist)
+	}
+
+	links, err := s.ListLinks("")
+	assert.Nil(t, err)
+	assert.Len(t, links, 1)
+	assert.Equal(t, "s2", links[0].B.SerialNum)
+
+	// the port freed by the cascade can be linked again
+	_, err = s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "s1", Port: "eth0"}, B: model.Endpoint{SerialNum: "x", Port: "eth0"}})
+	assert.Nil(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	devices := service.NewService(service.NewStorage())
+	for _, num := range []string{"a", "b", "c"} {
+		assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: num, Model: "model", IP: "1.1.1.1"}))
+	}
+	s := NewService(devices, DeleteBlock)
+	_, err := s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "a", Port: "eth0"}, B: model.Endpoint{SerialNum: "b", Port: "eth0"}})
+	assert.Nil(t, err)
+	assert.Nil(t, s.SetParent(model.Containment{Parent: "c", Child: "b"}))
+
+	// deleted past the guard, e.g. by replication, and created again with a new link
+	assert.Nil(t, devices.DeleteDevice("b"))
+	assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: "b", Model: "model", IP: "1.1.1.1"}))
+	_, err = s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "a", Port: "eth1"}, B: model.Endpoint{SerialNum: "b", Port: "eth1"}})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx)
+
+	assert.Eventually(t, func() bool {
+		links, _ := s.ListLinks("")
+		return len(links) == 1
+	}, time.Second, 10*time.Millisecond)
+	links, _ := s.ListLinks("b")
+	assert.Equal(t, "eth1", links[0].B.Port)
+	_, err = s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "a", Port: "eth0"}, B: model.Endpoint{SerialNum: "c", Port: "eth0"}})
+	assert.Nil(t, err)
+	tree, _ := s.Subtree("c")
+	assert.Empty(t, tree.Children)
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.json")
+	devices := service.NewService(service.NewStorage())
+	for _, num := range []string{"c", "c1", "c2", "s1", "s2", "x"} {
+		assert.Nil(t, devices.CreateDevice(model.Device{SerialNum: num, Model: "model", IP: "1.1.1.1"}))
+	}
+	snap, err := OpenSnapshot(path)
+	assert.Nil(t, err)
+	s := NewService(devices, DeleteBlock, WithSnapshot(snap))
+	assert.Nil(t, s.SetParent(model.Containment{Parent: "c", Child: "c1", Slot: "1"}))
+	_, err = s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "c1", Port: "eth0"}, B: model.Endpoint{SerialNum: "s1", Port: "eth0"}})
+	assert.Nil(t, err)
+	_, err = s.CreateLink(model.Link{Type: model.LinkPower, A: model.Endpoint{SerialNum: "x", Port: "psu"}, B: model.Endpoint{SerialNum: "s2", Port: "psu"}})
+	assert.Nil(t, err)
+
+	snap, err = OpenSnapshot(path)
+	assert.Nil(t, err)
+	s = NewService(devices, DeleteBlock, WithSnapshot(snap))
+	links, _ := s.ListLinks("")
+	assert.Len(t, links, 2)
+	tree, _ := s.Subtree("c")
+	assert.Equal(t, []model.TopologyNode{{SerialNum: "c1", Slot: "1"}}, tree.Children)
+
+	// relationships of devices deleted while stopped are dropped on load
+	links, _ = s.ListLinks("x")
+	assert.Nil(t, s.DeleteLink(links[0].ID))
+	assert.Nil(t, devices.DeleteDevice("x"))
+	_, err = s.CreateLink(model.Link{Type: model.LinkPower, A: model.Endpoint{SerialNum: "s2", Port: "psu"}, B: model.Endpoint{SerialNum: "s1", Port: "psu"}})
+	assert.Nil(t, err)
+	assert.Nil(t, devices.DeleteDevice("s2"))
+
+	snap, err = OpenSnapshot(path)
+	assert.Nil(t, err)
+	s = NewService(devices, DeleteBlock, WithSnapshot(snap))
+	links, _ = s.ListLinks("")
+	assert.Len(t, links, 1)
+	assert.Equal(t, "c1", links[0].A.SerialNum)
+
+	// link IDs are not reused after a restart
+	l, err := s.CreateLink(model.Link{Type: model.LinkEthernet, A: model.Endpoint{SerialNum: "s1", Port: "eth9"}, B: model.Endpoint{SerialNum: "c2", Port: "eth9"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "4", l.ID)
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"homework/internal/model"
+	"homework/internal/service"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Service traces every call of next. Bind it to a request context with
+// service.Bind to make its spans children of the request span.
+func Service(next service.Service) service.Service {
+	return &tracedService{next: next, ctx: context.Background()}
+}
+
+type tracedService struct {
+	next service.Service
+	ctx  context.Context
+}
+
+func (s *tracedService) WithContext(ctx context.Context) service.Service {
+	return &tracedService{next: s.next, ctx: ctx}
+}
+
+// start starts a span and returns next bound to it.
+func (s *tracedService) start(op string, attrs ...attribute.KeyValue) (service.Service, trace.Span) {
+	ctx, span := Start(s.ctx, "service."+op, attrs...)
+	return service.Bind(ctx, s.next), span
+}
+
+func (s *tracedService) GetDevice(num string) (model.Device, error) {
+	next, span := s.start("GetDevice", SerialKey.String(num))
+	defer span.End()
+	d, err := next.GetDevice(num)
+	return d, Record(span, err)
+}
+
+func (s *tracedService) CreateDevice(d model.Device) error {
+	next, span := s.start("CreateDevice", SerialKey.String(d.SerialNum))
+	defer span.End()
+	return Record(span, next.CreateDevice(d))
+}
+
+func (s *tracedService) RestoreDevice(d model.Device) error {
+	next, span := s.start("RestoreDevice", SerialKey.String(d.SerialNum))
+	defer span.End()
+	return Record(span, next.RestoreDevice(d))
+}
+
+func (s *tracedService) DeleteDevice(num string) error {
+	next, span := s.start("DeleteDevice", SerialKey.String(num))
+	defer span.End()
+	return Record(span, next.DeleteDevice(num))
+}
+
+func (s *tracedService) UpdateDevice(d model.Device) error {
+	next, span := s.start("UpdateDevice", SerialKey.String(d.SerialNum))
+	defer span.End()
+	return Record(span, next.UpdateDevice(d))
+}
+
+func (s *tracedService) TransitionDevice(num string, to model.State, reason string) error {
+	next, span := s.start("TransitionDevice", SerialKey.String(num), attribute.String("device.state", string(to)))
+	defer span.End()
+	return Record(span, next.TransitionDevice(num, to, reason))
+}
+
+func (s *tracedService) SetFirmware(num, firmware string) error {
+	next, span := s.start("SetFirmware", SerialKey.String(num), attribute.String("device.firmware", firmware))
+	defer span.End()
+	return Record(span, next.SetFirmware(num, firmware))
+}
+
+func (s *tracedService) ListDevices(f service.Filter) ([]model.Device, error) {
+	next, span := s.start("ListDevices")
+	defer span.End()
+	devices, err := next.ListDevices(f)
+	span.SetAttributes(attribute.Int("devices.count", len(devices)))
+	return devices, Record(span, err)
+}
+
+func (s *tracedService) ResourceVersion() uint64 {
+	next, span := s.start("ResourceVersion")
+	defer span.End()
+	return next.ResourceVersion()
+}
+
+func (s *tracedService) Changes(rv uint64) ([]model.Change, <-chan struct{}, error) {
+	next, span := s.start("Changes", attribute.Int64("resource_version", int64(rv)))
+	defer span.End()
+	changes, ch, err := next.Changes(rv)
+	return changes, ch, Record(span, err)
+}
+
+// Storage traces every call of next, see Service.
+func Storage(next service.Storage) service.Storage {
+	return &tracedStorage{next: next, ctx: context.Background()}
+}
+
+type tracedStorage struct {
+	next service.Storage
+	ctx  context.Context
+}
+
+func (s *tracedStorage) WithContext(ctx context.Context) service.Storage {
+	return &tracedStorage{next: s.next, ctx: ctx}
+}
+
+func (s *tracedStorage) start(op string, attrs ...attribute.KeyValue) (service.Storage, trace.Span) {
+	ctx, span := Start(s.ctx, "storage."+op, attrs...)
+	return service.BindStorage(ctx, s.next), span
+}
+
+func (s *tracedStorage) Add(d model.Device) bool {
+	next, span := s.start("Add", SerialKey.String(d.SerialNum))
+	defer span.End()
+	return next.Add(d)
+}
+
+func (s *tracedStorage) Get(num string) (model.Device, bool) {
+	next, span := s.start("Get", SerialKey.String(num))
+	defer span.End()
+	return next.Get(num)
+}
+
+func (s *tracedStorage) Del(num string) bool {
+	next, span := s.start("Del", SerialKey.String(num))
+	defer span.End()
+	return next.Del(num)
+}
+
+func (s *tracedStorage) Range(f func(d model.Device) bool) {
+	next, span := s.start("Range")
+	defer span.End()
+	next.Range(f)
+}
+
+func (s *tracedStorage) ResourceVersion() uint64 {
+	next, span := s.start("ResourceVersion")
+	defer span.End()
+	return next.ResourceVersion()
+}
+
+func (s *tracedStorage) Changes(rv uint64) ([]model.Change, <-chan struct{}, bool) {
+	next, span := s.start("Changes", attribute.Int64("resource_version", int64(rv)))
+	defer span.End()
+	return next.Changes(rv)
+}
@@ -0,0 +1,94 @@
+package tracing_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/handler"
+	"homework/internal/middleware"
+	"homework/internal/model"
+	"homework/internal/router"
+	"homework/internal/service"
+	"homework/internal/tracing"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpansNest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	svc := tracing.Service(service.NewService(tracing.Storage(service.NewStorage())))
+	assert.Nil(t, svc.CreateDevice(model.Device{SerialNum: "1", Model: "switch", IP: "1.1.1.1"}))
+
+	mux := router.NewRouter(handler.NewHandler(svc))
+	srv := httptest.NewServer(middleware.Tracing(middleware.Route(mux))(mux))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/devices/1")
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	resp, err = http.Get(srv.URL + "/v1/devices/2")
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = append(spans[s.Name()], s)
+	}
+
+	get := spans["service.GetDevice"]
+	assert.Len(t, get, 2)
+	assert.Equal(t, spans["HTTP GET /v1/devices/{serial}"][0].SpanContext().SpanID(), get[0].Parent().SpanID())
+	assert.Equal(t, get[0].SpanContext().SpanID(), spans["storage.Get"][0].Parent().SpanID())
+	assert.Contains(t, get[0].Attributes(), tracing.SerialKey.String("1"))
+	assert.Equal(t, "Error", get[1].Status().Code.String())
+
+	// calls without a request context start their own trace
+	create := spans["service.CreateDevice"][0]
+	assert.False(t, create.Parent().IsValid())
+	assert.Equal(t, create.SpanContext().SpanID(), spans["storage.Add"][0].Parent().SpanID())
+}
+
+func TestOTLPFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := tracing.Setup(tracing.ExporterOTLPFile, path)
+	assert.Nil(t, err)
+
+	_, span := tracing.Start(context.Background(), "test")
+	span.End()
+	assert.Nil(t, shutdown(context.Background()))
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	assert.True(t, sc.Scan())
+	var line struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					Name string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	assert.Nil(t, json.Unmarshal(sc.Bytes(), &line))
+	assert.Equal(t, "test", line.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	assert.True(t, strings.Contains(sc.Text(), "device-registry"))
+}
+
+func TestUnknownExporter(t *testing.T) {
+	_, err := tracing.Setup("jaeger", "")
+	assert.NotNil(t, err)
+}